@@ -1,8 +1,12 @@
 package eris
 
 import (
+	"bytes"
+	"context"
+	"fmt"
 	"io"
 	"testing"
+	"time"
 )
 
 // BenchmarkEncodeSmall benchmarks how fast we are at encoding a "small" bit of
@@ -29,7 +33,32 @@ func BenchmarkEncode(b *testing.B) {
 	}
 }
 
-func benchmarkEncode(b *testing.B, size int64, blockSize int) {
+func BenchmarkEncodeConcurrent(b *testing.B) {
+	for _, n := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("Concurrency=%d", n), func(b *testing.B) {
+			benchmarkEncode(b, 10*1024*1024, 32*1024, WithConcurrency(n))
+		})
+	}
+}
+
+func BenchmarkEncodeReaderAt(b *testing.B) {
+	const size = 10 * 1024 * 1024
+	content := bytes.NewReader(randomContent(size))
+	put := func(context.Context, Reference, []byte) error { return nil }
+	for _, n := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("Workers=%d", n), func(b *testing.B) {
+			b.SetBytes(size)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := EncodeReaderAt(context.Background(), content, size, [ConvergenceSecretSize]byte{}, 32*1024, n, put); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func benchmarkEncode(b *testing.B, size int64, blockSize int, opts ...EncoderOption) {
 	// Create an io.Reader that reads zero bytes, to use as
 	// our content.
 	lr := &io.LimitedReader{R: onesReader{}, N: size}
@@ -45,7 +74,7 @@ func benchmarkEncode(b *testing.B, size int64, blockSize int) {
 
 	// Repeatedly encode the content; we do this N times so
 	// that the benchmark is statistically significant.
-	enc := NewEncoder(lr, secret, blockSize)
+	enc := NewEncoder(lr, secret, blockSize, opts...)
 	for i := 0; i < b.N; i++ {
 		lr.N = size // reset without alloc
 		enc.reset(lr)
@@ -57,3 +86,104 @@ func benchmarkEncode(b *testing.B, size int64, blockSize int) {
 		}
 	}
 }
+
+// decodeMethods are the different ways of decoding content that the decode
+// benchmarks compare.
+var decodeMethods = []struct {
+	name   string
+	decode func(context.Context, FetchFunc, ReadCapability) error
+}{
+	{"Recursive", func(ctx context.Context, fetch FetchFunc, rc ReadCapability) error {
+		_, err := DecodeRecursive(ctx, fetch, rc)
+		return err
+	}},
+	{"Iterative", func(ctx context.Context, fetch FetchFunc, rc ReadCapability) error {
+		dec := NewDecoder(fetch, rc)
+		for dec.Next(ctx) {
+			io.Discard.Write(dec.Block())
+		}
+		return dec.Err()
+	}},
+	{"Trusted", func(ctx context.Context, fetch FetchFunc, rc ReadCapability) error {
+		dec := NewDecoder(fetch, rc, WithTrustedFetch())
+		for dec.Next(ctx) {
+			io.Discard.Write(dec.Block())
+		}
+		return dec.Err()
+	}},
+	{"WriterAt", func(ctx context.Context, fetch FetchFunc, rc ReadCapability) error {
+		_, err := DecodeToWriterAt(ctx, fetch, rc, discardWriterAt{}, 8)
+		return err
+	}},
+}
+
+// discardWriterAt is an io.WriterAt on which all WriteAt calls succeed
+// without doing anything.
+type discardWriterAt struct{}
+
+func (discardWriterAt) WriteAt(p []byte, _ int64) (int, error) { return len(p), nil }
+
+func BenchmarkDecode(b *testing.B) {
+	trees := []struct {
+		name      string
+		size      int
+		blockSize int
+	}{
+		{"1MiB/BlockSize=1KiB", 1024 * 1024, 1024},
+		{"1MiB/BlockSize=32KiB", 1024 * 1024, 32 * 1024},
+		{"10MiB/BlockSize=32KiB", 10 * 1024 * 1024, 32 * 1024},
+
+		// 1KiB blocks have an arity of 16, so this is a tree of
+		// level 4 with many internal nodes.
+		{"Deep/BlockSize=1KiB", 16*16*16*1024 + 1, 1024},
+	}
+	for _, tree := range trees {
+		rc, blocks := encodeToMap(b, randomContent(tree.size), tree.blockSize)
+		for _, method := range decodeMethods {
+			b.Run(tree.name+"/"+method.name, func(b *testing.B) {
+				benchmarkDecode(b, int64(tree.size), rc, mapFetch(blocks, nil), method.decode)
+			})
+		}
+	}
+}
+
+// BenchmarkDecodeLatency benchmarks decoding from a store with a fixed
+// latency per fetch, like a remote store, where the number of round trips
+// dominates the time taken.
+func BenchmarkDecodeLatency(b *testing.B) {
+	const size = 256 * 1024
+	rc, blocks := encodeToMap(b, randomContent(size), 1024)
+	for _, latency := range []time.Duration{0, 50 * time.Microsecond, 500 * time.Microsecond} {
+		fetch := mapFetch(blocks, nil)
+		if latency > 0 {
+			fetch = latencyFetch(fetch, latency)
+		}
+		for _, method := range decodeMethods {
+			b.Run(fmt.Sprintf("Latency=%v/%s", latency, method.name), func(b *testing.B) {
+				benchmarkDecode(b, size, rc, fetch, method.decode)
+			})
+		}
+	}
+}
+
+// latencyFetch wraps a FetchFunc so that each call waits for the given
+// latency before fetching the block.
+func latencyFetch(fetch FetchFunc, latency time.Duration) FetchFunc {
+	return func(ctx context.Context, ref Reference, buf []byte) ([]byte, error) {
+		time.Sleep(latency)
+		return fetch(ctx, ref, buf)
+	}
+}
+
+func benchmarkDecode(b *testing.B, size int64, rc ReadCapability, fetch FetchFunc, decode func(context.Context, FetchFunc, ReadCapability) error) {
+	ctx := context.Background()
+	b.SetBytes(size)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if err := decode(ctx, fetch, rc); err != nil {
+			b.Fatalf("error decoding: %v", err)
+		}
+	}
+}
@@ -0,0 +1,358 @@
+// Package blockserver implements an HTTP server for ERIS blocks, backed by a
+// store.Store.
+//
+// The server implements the ERIS over HTTP protocol, which uses the
+// name-to-resource resolution path from RFC 2169. A block is identified by a
+// URN of the form "urn:blake2b:" followed by the unpadded base32 encoding of
+// its reference, which is passed as the query string:
+//
+//	GET  /uri-res/N2R?urn:blake2b:<reference>   fetch a block
+//	HEAD /uri-res/N2R?urn:blake2b:<reference>   check whether a block exists
+//	PUT  /uri-res/N2R?urn:blake2b:<reference>   store a block
+//
+// Blocks written with PUT are verified against their reference and must be one
+// of the block sizes defined by the specification.
+//
+// A server can also host multiple independent stores, each under a namespace
+// prefix (e.g. /alice/uri-res/N2R); see Options.Namespace. Access to each
+// block can be controlled with Options.Authorize. To limit how much each user
+// can upload, serve a store.QuotaStore and set the principal of each request's
+// context with store.WithPrincipal in a middleware; uploads over quota are
+// rejected with status 507 (Insufficient Storage).
+package blockserver
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+
+	"github.com/andrew-d/eris-go"
+	"github.com/andrew-d/eris-go/store"
+)
+
+// Path is the path at which blocks are served.
+const Path = "/uri-res/N2R"
+
+var base32Enc = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// BlockURN returns the URN that identifies the block with the given reference.
+func BlockURN(ref eris.Reference) string {
+	return "urn:blake2b:" + ref.Base32()
+}
+
+// ParseBlockURN parses a URN returned by BlockURN.
+func ParseBlockURN(urn string) (ref eris.Reference, err error) {
+	encoded, ok := strings.CutPrefix(urn, "urn:blake2b:")
+	if !ok {
+		return ref, fmt.Errorf("invalid block URN prefix: %q", urn[:min(len(urn), 12)])
+	}
+	if base32Enc.DecodedLen(len(encoded)) != len(ref) {
+		return ref, fmt.Errorf("invalid block URN length: %d", len(urn))
+	}
+	if _, err := base32Enc.Decode(ref[:], []byte(encoded)); err != nil {
+		return ref, fmt.Errorf("invalid block URN: %w", err)
+	}
+	return ref, nil
+}
+
+// ErrUnauthenticated can be returned (possibly wrapped) by an AuthorizeFunc to
+// indicate that the request has no valid credentials, as opposed to
+// credentials that don't permit the request. The handler responds with status
+// 401 (Unauthorized) rather than 403 (Forbidden).
+var ErrUnauthenticated = errors.New("unauthenticated")
+
+// ErrNoNamespace can be returned (possibly wrapped) by a NamespaceFunc to
+// indicate that a namespace does not exist. The handler responds with status
+// 404 (Not Found).
+var ErrNoNamespace = errors.New("namespace does not exist")
+
+// Request describes a request for a single block; it is passed to an
+// AuthorizeFunc.
+type Request struct {
+	// HTTP is the underlying HTTP request, which can be used to inspect
+	// headers or client certificates. Its body must not be read.
+	HTTP *http.Request
+	// Method is the HTTP method of the request: GET, HEAD or PUT.
+	Method string
+	// Namespace is the namespace that the request is for, or the empty
+	// string for the default store.
+	Namespace string
+	// Reference is the reference of the requested block.
+	Reference eris.Reference
+}
+
+// AuthorizeFunc decides whether a request is allowed. It returns nil to allow
+// the request, or an error describing why it is not; the error is returned to
+// the client.
+type AuthorizeFunc func(*Request) error
+
+// NamespaceFunc returns the store for the given namespace. It returns an
+// error wrapping ErrNoNamespace if the namespace does not exist.
+type NamespaceFunc func(ctx context.Context, name string) (store.Store, error)
+
+// Options contains options for a Handler.
+type Options struct {
+	// Token, if non-empty, is a bearer token that must be provided in
+	// the Authorization header of PUT requests. Blocks can always be
+	// read without a token.
+	Token string
+
+	// ReadOnly, if set, rejects all PUT requests.
+	ReadOnly bool
+
+	// Authorize, if non-nil, is called for every block request that
+	// passes the Token and ReadOnly checks, and can reject it; for
+	// example, to restrict which clients may upload blocks, or to enforce
+	// per-namespace access control.
+	Authorize AuthorizeFunc
+
+	// Namespace, if non-nil, enables namespaced requests, of the form
+	// /<namespace>/uri-res/N2R, which are served from the store that it
+	// returns. A namespace is a single, non-empty path segment. If
+	// Namespace is nil, only requests for Path are served.
+	Namespace NamespaceFunc
+
+	// MaxConcurrent limits the number of requests that are handled
+	// concurrently; requests beyond the limit are rejected with status
+	// 503 (Service Unavailable) rather than queued. If zero, the number
+	// of concurrent requests is unlimited.
+	MaxConcurrent int
+
+	// ErrorLog, if non-nil, is used to log errors returned by the store.
+	// If nil, errors are logged with the log package's standard logger.
+	ErrorLog *log.Logger
+}
+
+// Handler is an http.Handler that serves blocks from a store.
+//
+// A Handler only handles requests for Path (and, if namespaces are enabled,
+// namespaced paths); it responds to all other requests with status 404. It can
+// be mounted on an http.ServeMux alongside other handlers.
+type Handler struct {
+	st   store.Store
+	opts Options
+	sem  chan struct{} // nil if unlimited
+}
+
+// NewHandler creates a Handler that serves blocks from st. The store must be
+// safe for concurrent use. If st is nil, only namespaced requests are served.
+func NewHandler(st store.Store, opts Options) *Handler {
+	h := &Handler{st: st, opts: opts}
+	if opts.MaxConcurrent > 0 {
+		h.sem = make(chan struct{}, opts.MaxConcurrent)
+	}
+	return h
+}
+
+// ServeHTTP implements the http.Handler interface.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ns, ok := h.parsePath(r.URL.Path)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if h.sem != nil {
+		select {
+		case h.sem <- struct{}{}:
+			defer func() { <-h.sem }()
+		default:
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "too many requests", http.StatusServiceUnavailable)
+			return
+		}
+	}
+
+	ref, err := ParseBlockURN(r.URL.RawQuery)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+	case http.MethodPut:
+		if h.opts.ReadOnly {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "server is read-only", http.StatusMethodNotAllowed)
+			return
+		}
+		if !h.hasToken(r) {
+			unauthenticated(w, "unauthorized")
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, HEAD, PUT")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if h.opts.Authorize != nil {
+		err := h.opts.Authorize(&Request{
+			HTTP:      r,
+			Method:    r.Method,
+			Namespace: ns,
+			Reference: ref,
+		})
+		if errors.Is(err, ErrUnauthenticated) {
+			unauthenticated(w, err.Error())
+			return
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+	}
+
+	st := h.st
+	if ns != "" {
+		var err error
+		st, err = h.opts.Namespace(r.Context(), ns)
+		if errors.Is(err, ErrNoNamespace) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		} else if err != nil {
+			h.internalError(w, r, ref, err)
+			return
+		}
+	}
+
+	if r.Method == http.MethodPut {
+		h.servePut(w, r, st, ref)
+	} else {
+		h.serveGet(w, r, st, ref)
+	}
+}
+
+// parsePath returns the namespace of a request from its path, or false if the
+// handler doesn't serve the path.
+func (h *Handler) parsePath(path string) (ns string, ok bool) {
+	if path == Path {
+		return "", h.st != nil
+	}
+	if h.opts.Namespace == nil {
+		return "", false
+	}
+	ns, ok = strings.CutSuffix(path, Path)
+	if !ok || len(ns) < 2 || ns[0] != '/' || strings.Contains(ns[1:], "/") {
+		return "", false
+	}
+	return ns[1:], true
+}
+
+func (h *Handler) serveGet(w http.ResponseWriter, r *http.Request, st store.Store, ref eris.Reference) {
+	block, err := st.Get(r.Context(), ref, make([]byte, eris.BlockSizeLarge))
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "block not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		h.internalError(w, r, ref, err)
+		return
+	}
+
+	// Blocks are content-addressed, so they never change.
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.Itoa(len(block)))
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.WriteHeader(http.StatusOK)
+	if r.Method != http.MethodHead {
+		w.Write(block)
+	}
+}
+
+func (h *Handler) servePut(w http.ResponseWriter, r *http.Request, st store.Store, ref eris.Reference) {
+	// Read one byte more than the largest block, so that we can tell
+	// that a body is too large without reading all of it.
+	block, err := io.ReadAll(io.LimitReader(r.Body, eris.BlockSizeLarge+1))
+	if err != nil {
+		http.Error(w, "reading block: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(block) != eris.BlockSizeSmall && len(block) != eris.BlockSizeLarge {
+		http.Error(w, "invalid block size", http.StatusBadRequest)
+		return
+	}
+	if blake2b.Sum256(block) != ref {
+		http.Error(w, "block does not match reference", http.StatusBadRequest)
+		return
+	}
+
+	err = st.Put(r.Context(), ref, block)
+	if errors.Is(err, store.ErrQuotaExceeded) {
+		http.Error(w, err.Error(), http.StatusInsufficientStorage)
+		return
+	} else if err != nil {
+		h.internalError(w, r, ref, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HealthHandler returns an http.Handler that answers health checks, such as
+// the readiness probes of an orchestration platform, by checking the handler's
+// store with store.Ping. It responds with status 200 (OK) if the store is
+// working, and 503 (Service Unavailable) otherwise; the error is logged rather
+// than returned to the client. The stores of namespaces aren't checked.
+//
+// It is typically mounted at a path such as /healthz.
+func (h *Handler) HealthHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.st != nil {
+			if err := store.Ping(r.Context(), h.st); err != nil {
+				if !errors.Is(err, context.Canceled) {
+					h.logf("health check: %v", err)
+				}
+				http.Error(w, "store unavailable", http.StatusServiceUnavailable)
+				return
+			}
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		io.WriteString(w, "ok\n")
+	})
+}
+
+// hasToken reports whether r carries the bearer token required to write
+// blocks, if any.
+func (h *Handler) hasToken(r *http.Request) bool {
+	return HasBearerToken(r, h.opts.Token)
+}
+
+// HasBearerToken reports whether r carries token in its Authorization header,
+// as required by Options.Token, comparing it in constant time. It always
+// returns true if token is empty. It can be used to protect other handlers
+// served alongside a Handler with the same token.
+func HasBearerToken(r *http.Request, token string) bool {
+	if token == "" {
+		return true
+	}
+	got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+}
+
+func unauthenticated(w http.ResponseWriter, msg string) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="eris"`)
+	http.Error(w, msg, http.StatusUnauthorized)
+}
+
+func (h *Handler) internalError(w http.ResponseWriter, r *http.Request, ref eris.Reference, err error) {
+	// Don't log errors caused by the client going away.
+	if !errors.Is(err, context.Canceled) {
+		h.logf("%s %v: %v", r.Method, ref, err)
+	}
+	http.Error(w, "internal error", http.StatusInternalServerError)
+}
+
+func (h *Handler) logf(format string, args ...any) {
+	if h.opts.ErrorLog != nil {
+		h.opts.ErrorLog.Printf(format, args...)
+	} else {
+		log.Printf(format, args...)
+	}
+}
@@ -0,0 +1,381 @@
+package blockserver
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/andrew-d/eris-go"
+	"github.com/andrew-d/eris-go/store"
+	"github.com/andrew-d/eris-go/store/storetest"
+)
+
+func TestBlockURN(t *testing.T) {
+	ref, _ := storetest.MakeBlock(1, eris.BlockSizeSmall)
+	urn := BlockURN(ref)
+	got, err := ParseBlockURN(urn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != ref {
+		t.Errorf("ParseBlockURN(%q) = %v, want %v", urn, got, ref)
+	}
+
+	for _, urn := range []string{
+		"",
+		"urn:eris:" + urn[len("urn:blake2b:"):],
+		urn[:len(urn)-1],
+		urn + "A",
+		urn[:len(urn)-1] + "!",
+	} {
+		if _, err := ParseBlockURN(urn); err == nil {
+			t.Errorf("ParseBlockURN(%q): expected error", urn)
+		}
+	}
+}
+
+// do makes a request to h and returns the response.
+func do(t *testing.T, h http.Handler, method, target string, body []byte, hdr ...string) *http.Response {
+	t.Helper()
+	req := httptest.NewRequest(method, target, bytes.NewReader(body))
+	for i := 0; i < len(hdr); i += 2 {
+		req.Header.Set(hdr[i], hdr[i+1])
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec.Result()
+}
+
+func blockPath(ref eris.Reference) string {
+	return Path + "?" + BlockURN(ref)
+}
+
+func TestHandler(t *testing.T) {
+	st := store.NewMemory()
+	h := NewHandler(st, Options{})
+
+	ref, block := storetest.MakeBlock(1, eris.BlockSizeSmall)
+	if resp := do(t, h, "GET", blockPath(ref), nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("GET missing block: status %d, want 404", resp.StatusCode)
+	}
+	if resp := do(t, h, "PUT", blockPath(ref), block); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("PUT: status %d, want 204", resp.StatusCode)
+	}
+	if has, _ := st.Has(context.Background(), ref); !has {
+		t.Fatal("block was not stored")
+	}
+
+	resp := do(t, h, "GET", blockPath(ref), nil)
+	got, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || !bytes.Equal(got, block) {
+		t.Errorf("GET: status %d with %d bytes, want 200 with the block", resp.StatusCode, len(got))
+	}
+
+	resp = do(t, h, "HEAD", blockPath(ref), nil)
+	got, _ = io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || len(got) != 0 || resp.ContentLength != int64(len(block)) {
+		t.Errorf("HEAD: status %d with %d bytes, length %d", resp.StatusCode, len(got), resp.ContentLength)
+	}
+}
+
+func TestHandler_BadRequests(t *testing.T) {
+	st := store.NewMemory()
+	h := NewHandler(st, Options{})
+
+	ref, block := storetest.MakeBlock(1, eris.BlockSizeSmall)
+	otherRef, _ := storetest.MakeBlock(2, eris.BlockSizeSmall)
+	oddRef, oddBlock := storetest.MakeBlock(3, 100)
+	bigRef, bigBlock := storetest.MakeBlock(4, eris.BlockSizeLarge+1)
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   []byte
+		want   int
+	}{
+		{"WrongPath", "GET", "/foo?" + BlockURN(ref), nil, http.StatusNotFound},
+		{"InvalidURN", "GET", Path + "?urn:blake2b:foo", nil, http.StatusBadRequest},
+		{"ReadCapabilityURN", "GET", Path + "?urn:eris:AAAA", nil, http.StatusBadRequest},
+		{"Method", "DELETE", blockPath(ref), nil, http.StatusMethodNotAllowed},
+		{"WrongReference", "PUT", blockPath(otherRef), block, http.StatusBadRequest},
+		{"InvalidSize", "PUT", blockPath(oddRef), oddBlock, http.StatusBadRequest},
+		{"TooLarge", "PUT", blockPath(bigRef), bigBlock, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if resp := do(t, h, tt.method, tt.target, tt.body); resp.StatusCode != tt.want {
+				t.Errorf("status %d, want %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+	if st.Len() != 0 {
+		t.Errorf("store has %d blocks after invalid requests", st.Len())
+	}
+}
+
+func TestHandler_Token(t *testing.T) {
+	st := store.NewMemory()
+	h := NewHandler(st, Options{Token: "secret"})
+	ref, block := storetest.MakeBlock(1, eris.BlockSizeSmall)
+
+	if resp := do(t, h, "PUT", blockPath(ref), block); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("PUT without token: status %d, want 401", resp.StatusCode)
+	}
+	if resp := do(t, h, "PUT", blockPath(ref), block, "Authorization", "Bearer wrong"); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("PUT with wrong token: status %d, want 401", resp.StatusCode)
+	}
+	if resp := do(t, h, "PUT", blockPath(ref), block, "Authorization", "Bearer secret"); resp.StatusCode != http.StatusNoContent {
+		t.Errorf("PUT with token: status %d, want 204", resp.StatusCode)
+	}
+
+	// Reading doesn't require the token.
+	if resp := do(t, h, "GET", blockPath(ref), nil); resp.StatusCode != http.StatusOK {
+		t.Errorf("GET: status %d, want 200", resp.StatusCode)
+	}
+}
+
+func TestHandler_ReadOnly(t *testing.T) {
+	h := NewHandler(store.NewMemory(), Options{ReadOnly: true})
+	ref, block := storetest.MakeBlock(1, eris.BlockSizeSmall)
+	if resp := do(t, h, "PUT", blockPath(ref), block); resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("PUT: status %d, want 405", resp.StatusCode)
+	}
+}
+
+// blockingStore is a store whose Get blocks until release is closed.
+type blockingStore struct {
+	store.Store
+	started chan struct{}
+	release chan struct{}
+}
+
+func (s *blockingStore) Get(ctx context.Context, ref eris.Reference, buf []byte) ([]byte, error) {
+	s.started <- struct{}{}
+	<-s.release
+	return s.Store.Get(ctx, ref, buf)
+}
+
+func TestHandler_MaxConcurrent(t *testing.T) {
+	st := &blockingStore{
+		Store:   store.NewMemory(),
+		started: make(chan struct{}),
+		release: make(chan struct{}),
+	}
+	h := NewHandler(st, Options{MaxConcurrent: 1, ErrorLog: log.New(io.Discard, "", 0)})
+	ref, _ := storetest.MakeBlock(1, eris.BlockSizeSmall)
+
+	done := make(chan *http.Response)
+	go func() { done <- do(t, h, "GET", blockPath(ref), nil) }()
+	<-st.started
+
+	if resp := do(t, h, "GET", blockPath(ref), nil); resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("concurrent GET: status %d, want 503", resp.StatusCode)
+	}
+	close(st.release)
+	if resp := <-done; resp.StatusCode != http.StatusNotFound {
+		t.Errorf("first GET: status %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestHandler_Health(t *testing.T) {
+	dir := t.TempDir()
+	st, err := store.NewDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewHandler(st, Options{ErrorLog: log.New(io.Discard, "", 0)}).HealthHandler()
+
+	if resp := do(t, h, "GET", "/healthz", nil); resp.StatusCode != http.StatusOK {
+		t.Errorf("healthy store: status %d, want 200", resp.StatusCode)
+	}
+
+	// The store fails its health check once its directory is gone.
+	if err := os.Remove(dir); err != nil {
+		t.Fatal(err)
+	}
+	if resp := do(t, h, "GET", "/healthz", nil); resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("broken store: status %d, want 503", resp.StatusCode)
+	}
+}
+
+// TestHandler_Encode checks that content can be encoded into and decoded from
+// a store over HTTP.
+func TestHandler_Encode(t *testing.T) {
+	st := store.NewMemory()
+	srv := httptest.NewServer(NewHandler(st, Options{}))
+	defer srv.Close()
+
+	content := make([]byte, 100*1024)
+	for i := range content {
+		content[i] = byte(i % 251)
+	}
+	var secret [eris.ConvergenceSecretSize]byte
+	enc := eris.NewEncoder(bytes.NewReader(content), secret, eris.BlockSizeSmall)
+	for enc.Next() {
+		req, _ := http.NewRequest("PUT", srv.URL+blockPath(enc.Reference()), bytes.NewReader(enc.Block()))
+		resp, err := srv.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNoContent {
+			t.Fatalf("PUT: status %d", resp.StatusCode)
+		}
+	}
+	if err := enc.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	fetch := func(ctx context.Context, ref eris.Reference, buf []byte) ([]byte, error) {
+		resp, err := srv.Client().Get(srv.URL + blockPath(ref))
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		n, err := io.ReadFull(resp.Body, buf[:cap(buf)])
+		if err == io.ErrUnexpectedEOF {
+			err = nil
+		}
+		return buf[:n], err
+	}
+	got, err := eris.DecodeRecursive(context.Background(), fetch, enc.Capability())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Error("decoded content does not match")
+	}
+}
+
+func TestHandler_Authorize(t *testing.T) {
+	st := store.NewMemory()
+	allowed, block := storetest.MakeBlock(1, eris.BlockSizeSmall)
+	denied, deniedBlock := storetest.MakeBlock(2, eris.BlockSizeSmall)
+	st.Put(context.Background(), denied, deniedBlock)
+
+	var calls []Request
+	h := NewHandler(st, Options{
+		Authorize: func(r *Request) error {
+			calls = append(calls, *r)
+			switch {
+			case r.HTTP.Header.Get("X-User") == "":
+				return ErrUnauthenticated
+			case r.Reference == denied:
+				return errors.New("access to block denied")
+			case r.Method == "PUT" && r.HTTP.Header.Get("X-User") != "admin":
+				return errors.New("only admins may upload")
+			}
+			return nil
+		},
+	})
+
+	tests := []struct {
+		method string
+		ref    eris.Reference
+		body   []byte
+		user   string
+		want   int
+	}{
+		{"GET", denied, nil, "", http.StatusUnauthorized},
+		{"GET", denied, nil, "bob", http.StatusForbidden},
+		{"PUT", allowed, block, "bob", http.StatusForbidden},
+		{"PUT", allowed, block, "admin", http.StatusNoContent},
+		{"GET", allowed, nil, "bob", http.StatusOK},
+	}
+	for _, tt := range tests {
+		resp := do(t, h, tt.method, blockPath(tt.ref), tt.body, "X-User", tt.user)
+		if resp.StatusCode != tt.want {
+			t.Errorf("%s %v as %q: status %d, want %d", tt.method, tt.ref, tt.user, resp.StatusCode, tt.want)
+		}
+	}
+
+	if len(calls) != len(tests) {
+		t.Fatalf("Authorize called %d times, want %d", len(calls), len(tests))
+	}
+	if got := calls[2]; got.Method != "PUT" || got.Reference != allowed || got.Namespace != "" {
+		t.Errorf("Authorize called with %+v", got)
+	}
+}
+
+func TestHandler_Namespace(t *testing.T) {
+	stores := map[string]*store.Memory{
+		"alice": store.NewMemory(),
+		"bob":   store.NewMemory(),
+	}
+	var authorized []string
+	h := NewHandler(nil, Options{
+		Authorize: func(r *Request) error {
+			authorized = append(authorized, r.Namespace)
+			return nil
+		},
+		Namespace: func(_ context.Context, name string) (store.Store, error) {
+			st, ok := stores[name]
+			if !ok {
+				return nil, ErrNoNamespace
+			}
+			return st, nil
+		},
+	})
+	ref, block := storetest.MakeBlock(1, eris.BlockSizeSmall)
+
+	if resp := do(t, h, "PUT", "/alice"+blockPath(ref), block); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("PUT: status %d, want 204", resp.StatusCode)
+	}
+	if stores["alice"].Len() != 1 || stores["bob"].Len() != 0 {
+		t.Errorf("block stored in wrong namespace")
+	}
+	if resp := do(t, h, "GET", "/alice"+blockPath(ref), nil); resp.StatusCode != http.StatusOK {
+		t.Errorf("GET in same namespace: status %d, want 200", resp.StatusCode)
+	}
+	if resp := do(t, h, "GET", "/bob"+blockPath(ref), nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("GET in other namespace: status %d, want 404", resp.StatusCode)
+	}
+	if !slices.Equal(authorized, []string{"alice", "alice", "bob"}) {
+		t.Errorf("authorized namespaces = %q", authorized)
+	}
+
+	for _, path := range []string{
+		"/carol" + blockPath(ref),
+		blockPath(ref), // no default store
+		"//" + blockPath(ref)[1:],
+		"/a/b" + blockPath(ref),
+	} {
+		if resp := do(t, h, "GET", path, nil); resp.StatusCode != http.StatusNotFound {
+			t.Errorf("GET %s: status %d, want 404", path, resp.StatusCode)
+		}
+	}
+}
+
+func TestHandler_Quota(t *testing.T) {
+	q := store.NewQuota(store.NewMemory(), func(principal string) int64 {
+		if principal == "alice" {
+			return eris.BlockSizeSmall
+		}
+		return 0
+	})
+	inner := NewHandler(q, Options{})
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := store.WithPrincipal(r.Context(), r.Header.Get("X-User"))
+		inner.ServeHTTP(w, r.WithContext(ctx))
+	})
+
+	ref1, block1 := storetest.MakeBlock(1, eris.BlockSizeSmall)
+	ref2, block2 := storetest.MakeBlock(2, eris.BlockSizeSmall)
+	if resp := do(t, h, "PUT", blockPath(ref1), block1, "X-User", "alice"); resp.StatusCode != http.StatusNoContent {
+		t.Errorf("PUT within quota: status %d, want 204", resp.StatusCode)
+	}
+	if resp := do(t, h, "PUT", blockPath(ref2), block2, "X-User", "alice"); resp.StatusCode != http.StatusInsufficientStorage {
+		t.Errorf("PUT over quota: status %d, want 507", resp.StatusCode)
+	}
+	if got := q.Usage("alice"); got != eris.BlockSizeSmall {
+		t.Errorf("usage = %d, want %d", got, eris.BlockSizeSmall)
+	}
+}
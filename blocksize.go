@@ -0,0 +1,65 @@
+package eris
+
+import (
+	"bytes"
+	"errors"
+	"io"
+)
+
+const (
+	// BlockSizeSmall is the small block size defined by the ERIS
+	// specification (1KiB).
+	BlockSizeSmall = 1024
+
+	// BlockSizeLarge is the large block size defined by the ERIS
+	// specification (32KiB).
+	BlockSizeLarge = 32 * 1024
+
+	// smallContentThreshold is the content size below which the small
+	// block size is recommended.
+	smallContentThreshold = 16 * 1024
+)
+
+// RecommendedBlockSize returns the recommended block size for content of the
+// given size, in bytes. The specification recommends using 1KiB blocks for
+// content smaller than 16KiB, and 32KiB blocks for anything larger.
+//
+// A negative size indicates that the size of the content is unknown, in which
+// case the large block size is returned.
+func RecommendedBlockSize(size int64) int {
+	if size >= 0 && size < smallContentThreshold {
+		return BlockSizeSmall
+	}
+	return BlockSizeLarge
+}
+
+// EncodeAuto creates a new Encoder for the given content, choosing the block
+// size with RecommendedBlockSize.
+//
+// Since the size of an io.Reader isn't known up front, EncodeAuto reads and
+// buffers up to 16KiB of content to determine which block size to use; the
+// buffered content is then encoded before the remainder of the reader. Any
+// error that occurs while reading the buffered content is returned.
+//
+// The block size is chosen based on the size of the content before any
+// options (such as WithSizePadding) are applied.
+func EncodeAuto(content io.Reader, secret [ConvergenceSecretSize]byte, opts ...EncoderOption) (*Encoder, error) {
+	// If we can fill the buffer, then the content is at least as large as
+	// the threshold and we don't need to read any further.
+	buf := make([]byte, smallContentThreshold)
+	n, err := io.ReadFull(content, buf)
+	switch {
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		// We've read all the content; it's small.
+		rdr := bytes.NewReader(buf[:n])
+		return NewEncoder(rdr, secret, RecommendedBlockSize(int64(n)), opts...), nil
+	case err != nil:
+		return nil, err
+	}
+
+	// We filled the buffer, so the content is at least as large as the
+	// threshold; use the large block size, and encode the buffered data
+	// followed by the rest of the reader.
+	rdr := io.MultiReader(bytes.NewReader(buf), content)
+	return NewEncoder(rdr, secret, BlockSizeLarge, opts...), nil
+}
@@ -0,0 +1,61 @@
+package eris
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestRecommendedBlockSize(t *testing.T) {
+	testCases := []struct {
+		size int64
+		want int
+	}{
+		{-1, BlockSizeLarge},
+		{0, BlockSizeSmall},
+		{1, BlockSizeSmall},
+		{16*1024 - 1, BlockSizeSmall},
+		{16 * 1024, BlockSizeLarge},
+		{1024 * 1024, BlockSizeLarge},
+	}
+	for _, tc := range testCases {
+		if got := RecommendedBlockSize(tc.size); got != tc.want {
+			t.Errorf("RecommendedBlockSize(%d) = %d, want %d", tc.size, got, tc.want)
+		}
+	}
+}
+
+func TestEncodeAuto(t *testing.T) {
+	var secret [ConvergenceSecretSize]byte
+	sizes := []int64{0, 100, 16*1024 - 1, 16 * 1024, 100 * 1024}
+	for _, size := range sizes {
+		content, err := io.ReadAll(&io.LimitedReader{R: onesReader{}, N: size})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		enc, err := EncodeAuto(bytes.NewReader(content), secret)
+		if err != nil {
+			t.Fatalf("size %d: EncodeAuto: %v", size, err)
+		}
+		if got, want := enc.BlockSize(), RecommendedBlockSize(size); got != want {
+			t.Errorf("size %d: block size = %d, want %d", size, got, want)
+		}
+
+		// Verify that the auto encoder produces the same capability
+		// as an encoder constructed with the same block size; i.e.
+		// that we didn't lose any buffered content.
+		for enc.Next() {
+		}
+		if err := enc.Err(); err != nil {
+			t.Fatalf("size %d: error encoding: %v", size, err)
+		}
+
+		want := NewEncoder(bytes.NewReader(content), secret, enc.BlockSize())
+		for want.Next() {
+		}
+		if !enc.Capability().Equal(want.Capability()) {
+			t.Errorf("size %d: capability mismatch", size)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package eris
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+)
+
+// bundleVersion is the version byte at the start of a marshaled Bundle.
+const bundleVersion = 1
+
+// bundleFlagInline is set in the flags byte of a marshaled Bundle if it
+// contains inline content.
+const bundleFlagInline = 1
+
+// Bundle is a read capability packaged, when the content is small enough to
+// fit in a single block, with the content itself. This lets messages carry
+// small content in one self-contained blob, without the recipient needing
+// access to a store, while larger content is still referred to by its
+// capability alone.
+//
+// The inline content is checked against the capability when the bundle is
+// created or unmarshaled, so a Bundle is as trustworthy as its capability.
+type Bundle struct {
+	// Capability is the read capability for the content.
+	Capability ReadCapability
+
+	// Inline reports whether the bundle contains the content; if not,
+	// it must be fetched using the capability.
+	Inline bool
+
+	// Content is the content, if Inline is set.
+	Content []byte
+}
+
+// NewBundle returns a Bundle for the content with read capability rc. If the
+// tree consists of a single block (that is, if rc.Level is 0), content is
+// inlined; it returns an error if the content doesn't match rc. Otherwise, or
+// if content is nil, the bundle contains only the capability. (Empty content
+// is inlined if it is given as a non-nil slice.)
+func NewBundle(rc ReadCapability, content []byte) (Bundle, error) {
+	b := Bundle{Capability: rc}
+	if content == nil || rc.Level != 0 {
+		return b, nil
+	}
+	if len(content) >= rc.BlockSize {
+		return Bundle{}, fmt.Errorf("content is too large to be a single %d byte block", rc.BlockSize)
+	}
+
+	// The leaf is the padded content; encrypting it with the key from
+	// the capability must give the block that the capability refers to.
+	leaf := make([]byte, rc.BlockSize)
+	padBlock(leaf, copy(leaf, content), rc.BlockSize)
+	if _, ref := encryptLeafWithKey(leaf, rc.Root.Key); ref != rc.Root.Reference {
+		return Bundle{}, errors.New("content does not match read capability")
+	}
+
+	b.Inline = true
+	b.Content = bytes.Clone(content)
+	return b, nil
+}
+
+// Decode returns the content of the bundle. Inline content is returned
+// directly; otherwise, the content is decoded with DecodeRecursive using
+// fetch, which may be nil if the bundle is inline.
+func (b Bundle) Decode(ctx context.Context, fetch FetchFunc) ([]byte, error) {
+	if b.Inline {
+		return bytes.Clone(b.Content), nil
+	}
+	if fetch == nil {
+		return nil, errors.New("bundle has no inline content, and no fetch function was given")
+	}
+	return DecodeRecursive(ctx, fetch, b.Capability)
+}
+
+// Fetch returns a FetchFunc that returns the encrypted block for inline
+// content, and calls fetch for any other block, so that a bundle can be read
+// with a Decoder (or any other function that takes a FetchFunc) whether or
+// not it is inline. If fetch is nil, requests for other blocks fail.
+func (b Bundle) Fetch(fetch FetchFunc) FetchFunc {
+	var (
+		inlineRef   Reference
+		inlineBlock []byte
+	)
+	if b.Inline {
+		leaf := make([]byte, b.Capability.BlockSize)
+		padBlock(leaf, copy(leaf, b.Content), b.Capability.BlockSize)
+		inlineBlock, inlineRef = encryptLeafWithKey(leaf, b.Capability.Root.Key)
+	}
+	return func(ctx context.Context, ref Reference, buf []byte) ([]byte, error) {
+		if b.Inline && ref == inlineRef {
+			return append(buf[:0], inlineBlock...), nil
+		}
+		if fetch == nil {
+			return nil, fmt.Errorf("block %v is not in the bundle", ref)
+		}
+		return fetch(ctx, ref, buf)
+	}
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface.
+//
+// The binary representation of a Bundle is a version byte, a flags byte, the
+// binary representation of the read capability, and then the inline content,
+// if any.
+func (b Bundle) MarshalBinary() ([]byte, error) {
+	var flags byte
+	if b.Inline {
+		flags |= bundleFlagInline
+	}
+	data, err := b.Capability.AppendBinary([]byte{bundleVersion, flags})
+	if err != nil {
+		return nil, err
+	}
+	if b.Inline {
+		data = append(data, b.Content...)
+	}
+	return data, nil
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface. Inline
+// content is checked against the read capability, as by NewBundle.
+func (b *Bundle) UnmarshalBinary(data []byte) error {
+	const headerLen = 2 + readCapabilityLen
+	if len(data) < headerLen {
+		return fmt.Errorf("bundle too short: %d", len(data))
+	}
+	if data[0] != bundleVersion {
+		return fmt.Errorf("unsupported bundle version: %d", data[0])
+	}
+	flags := data[1]
+	if flags&^bundleFlagInline != 0 {
+		return fmt.Errorf("unknown bundle flags: 0x%02x", flags)
+	}
+
+	var rc ReadCapability
+	if err := rc.UnmarshalBinary(data[2:headerLen]); err != nil {
+		return err
+	}
+	content := data[headerLen:]
+	if flags&bundleFlagInline == 0 {
+		if len(content) != 0 {
+			return fmt.Errorf("bundle has %d bytes of content but isn't inline", len(content))
+		}
+		*b = Bundle{Capability: rc}
+		return nil
+	}
+	if rc.Level != 0 {
+		return fmt.Errorf("inline bundle has a tree of level %d", rc.Level)
+	}
+
+	nb, err := NewBundle(rc, content)
+	if err != nil {
+		return err
+	}
+	*b = nb
+	return nil
+}
@@ -0,0 +1,100 @@
+package eris
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func TestBundle(t *testing.T) {
+	ctx := context.Background()
+	for _, tc := range []struct {
+		name      string
+		content   []byte
+		blockSize int
+		inline    bool
+	}{
+		{"empty", []byte{}, BlockSizeSmall, true},
+		{"small", randomContent(100), BlockSizeSmall, true},
+		{"almost a block", randomContent(1023), BlockSizeSmall, true},
+		{"one block", randomContent(1024), BlockSizeSmall, false},
+		{"large block", randomContent(20000), BlockSizeLarge, true},
+		{"tree", randomContent(100 * 1024), BlockSizeSmall, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rc, blocks := encodeToMap(t, tc.content, tc.blockSize)
+			b, err := NewBundle(rc, tc.content)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if b.Inline != tc.inline {
+				t.Errorf("Inline = %v, want %v", b.Inline, tc.inline)
+			}
+
+			data, err := b.MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var b2 Bundle
+			if err := b2.UnmarshalBinary(data); err != nil {
+				t.Fatalf("UnmarshalBinary: %v", err)
+			}
+			if !b2.Capability.Equal(rc) || b2.Inline != b.Inline || !bytes.Equal(b2.Content, b.Content) {
+				t.Errorf("bundle changed after round trip")
+			}
+
+			// Inline bundles don't need a fetch function.
+			var fetch FetchFunc
+			if !tc.inline {
+				fetch = mapFetch(blocks, nil)
+			}
+			got, err := b2.Decode(ctx, fetch)
+			if err != nil || !bytes.Equal(got, tc.content) {
+				t.Errorf("Decode: %v", err)
+			}
+
+			// Nor does a Decoder using the bundle's fetch function.
+			dec := NewDecoder(b2.Fetch(fetch), rc)
+			var out []byte
+			for dec.Next(ctx) {
+				out = append(out, dec.Block()...)
+			}
+			if err := dec.Err(); err != nil || !bytes.Equal(out, tc.content) {
+				t.Errorf("decoding with Fetch: %v", err)
+			}
+		})
+	}
+}
+
+func TestBundle_Invalid(t *testing.T) {
+	content := randomContent(100)
+	rc, _ := encodeToMap(t, content, BlockSizeSmall)
+
+	other := bytes.Clone(content)
+	other[0] ^= 1
+	if _, err := NewBundle(rc, other); err == nil {
+		t.Error("NewBundle accepted content that doesn't match the capability")
+	}
+
+	b, _ := NewBundle(rc, content)
+	data, _ := b.MarshalBinary()
+	for name, corrupt := range map[string]func([]byte) []byte{
+		"version":   func(d []byte) []byte { d[0] = 2; return d },
+		"flags":     func(d []byte) []byte { d[1] |= 2; return d },
+		"content":   func(d []byte) []byte { d[len(d)-1] ^= 1; return d },
+		"truncated": func(d []byte) []byte { return d[:10] },
+		"not inline": func(d []byte) []byte {
+			d[1] = 0
+			return d
+		},
+	} {
+		var b2 Bundle
+		if err := b2.UnmarshalBinary(corrupt(bytes.Clone(data))); err == nil {
+			t.Errorf("%s: UnmarshalBinary succeeded", name)
+		}
+	}
+
+	if _, err := (Bundle{Capability: rc}).Decode(context.Background(), nil); err == nil {
+		t.Error("Decode succeeded without inline content or a fetch function")
+	}
+}
@@ -0,0 +1,114 @@
+package eris
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// MinChallengeNonceSize is the minimum size of the nonce used to create a
+// Challenge.
+const MinChallengeNonceSize = 16
+
+// Challenge is a proof-of-retrievability challenge, which allows a verifier to
+// check that a remote store (the prover) still holds all the blocks of some
+// content without downloading all of it.
+//
+// The verifier creates a challenge from a fresh random nonce with
+// NewChallenge, which selects a pseudo-random subset of the blocks in the
+// tree, and sends it to the prover. The prover computes a response over the
+// selected blocks with Respond and returns it. The verifier then checks the
+// response with Check, using its own copy of the blocks; for example, a local
+// store, or blocks produced by re-encoding the original content.
+//
+// Since the response is a keyed hash over the full contents of the selected
+// blocks, and the nonce is not known in advance, the prover cannot compute a
+// valid response without having the blocks. Each challenge only covers the
+// selected blocks; the chance of detecting a loss of a fraction f of the
+// blocks with n selected blocks is 1-(1-f)^n.
+type Challenge struct {
+	// Nonce is the random nonce provided by the verifier.
+	Nonce []byte
+	// References are the references of the selected blocks.
+	References []Reference
+}
+
+// NewChallenge creates a challenge for the content identified by rc, selecting
+// n blocks from the tree pseudo-randomly based on the given nonce. Blocks are
+// selected with replacement from all the nodes of the tree, so the same block
+// may be selected more than once.
+//
+// Only the internal nodes of the tree are fetched. The nonce must be at least
+// MinChallengeNonceSize bytes, and should be generated by a cryptographically
+// secure random number generator for every challenge.
+func NewChallenge(ctx context.Context, fetch FetchFunc, rc ReadCapability, nonce []byte, n int) (Challenge, error) {
+	if len(nonce) < MinChallengeNonceSize {
+		return Challenge{}, fmt.Errorf("nonce too short: %d < %d", len(nonce), MinChallengeNonceSize)
+	}
+	if n < 1 {
+		return Challenge{}, errors.New("must select at least one block")
+	}
+
+	var refs []Reference
+	err := walkReferences(ctx, fetch, rc, func(ref ReferenceKeyPair, _ int) error {
+		refs = append(refs, ref.Reference)
+		return nil
+	})
+	if err != nil {
+		return Challenge{}, err
+	}
+
+	// Derive each index by hashing the nonce and a counter.
+	c := Challenge{
+		Nonce:      append([]byte(nil), nonce...),
+		References: make([]Reference, n),
+	}
+	var seed []byte
+	for i := range c.References {
+		seed = binary.BigEndian.AppendUint64(append(seed[:0], nonce...), uint64(i))
+		sum := blake2b.Sum256(seed)
+		idx := binary.BigEndian.Uint64(sum[:8]) % uint64(len(refs))
+		c.References[i] = refs[idx]
+	}
+	return c, nil
+}
+
+// Respond computes the response to the challenge, fetching each of the
+// selected blocks with fetch. This is run by the prover.
+func (c Challenge) Respond(ctx context.Context, fetch FetchFunc) ([]byte, error) {
+	// The blake2b key is limited in length, so derive it from the nonce
+	// rather than using the nonce directly.
+	key := blake2b.Sum256(c.Nonce)
+	h, err := blake2b.New256(key[:])
+	if err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, BlockSizeLarge)
+	for _, ref := range c.References {
+		block, err := fetch(ctx, ref, buf)
+		if err != nil {
+			return nil, fmt.Errorf("fetching block %v: %w", ref, err)
+		}
+		h.Write(block)
+	}
+	return h.Sum(nil), nil
+}
+
+// Check verifies a response to the challenge returned by the prover,
+// computing the expected response using the verifier's own copy of the
+// blocks, which are fetched with fetch. It returns true if the response is
+// valid.
+//
+// The comparison is done in constant time.
+func (c Challenge) Check(ctx context.Context, fetch FetchFunc, response []byte) (bool, error) {
+	want, err := c.Respond(ctx, fetch)
+	if err != nil {
+		return false, err
+	}
+	return subtle.ConstantTimeCompare(want, response) == 1, nil
+}
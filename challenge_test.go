@@ -0,0 +1,60 @@
+package eris
+
+import (
+	"context"
+	"testing"
+)
+
+func TestChallenge(t *testing.T) {
+	ctx := context.Background()
+	content := randomContent(100 * 1024)
+	rc, blocks := encodeToMap(t, content, 1024)
+	nonce := []byte("0123456789abcdef")
+
+	c, err := NewChallenge(ctx, mapFetch(blocks, nil), rc, nonce, 10)
+	if err != nil {
+		t.Fatalf("NewChallenge: %v", err)
+	}
+	if len(c.References) != 10 {
+		t.Fatalf("got %d references, want 10", len(c.References))
+	}
+
+	// The same nonce must select the same blocks.
+	c2, err := NewChallenge(ctx, mapFetch(blocks, nil), rc, nonce, 10)
+	if err != nil {
+		t.Fatalf("NewChallenge: %v", err)
+	}
+	for i := range c.References {
+		if c.References[i] != c2.References[i] {
+			t.Fatalf("challenge is not deterministic")
+		}
+	}
+
+	// An honest prover passes the challenge.
+	resp, err := c.Respond(ctx, mapFetch(blocks, nil))
+	if err != nil {
+		t.Fatalf("Respond: %v", err)
+	}
+	if ok, err := c.Check(ctx, mapFetch(blocks, nil), resp); err != nil || !ok {
+		t.Errorf("Check = %v, %v; want true, nil", ok, err)
+	}
+
+	// A prover that lost a selected block and substitutes other data
+	// fails the challenge.
+	lossy := make(map[Reference][]byte, len(blocks))
+	for ref, block := range blocks {
+		lossy[ref] = block
+	}
+	lossy[c.References[3]] = make([]byte, 1024)
+	resp, err = c.Respond(ctx, mapFetch(lossy, nil))
+	if err != nil {
+		t.Fatalf("Respond: %v", err)
+	}
+	if ok, err := c.Check(ctx, mapFetch(blocks, nil), resp); err != nil || ok {
+		t.Errorf("Check = %v, %v; want false, nil", ok, err)
+	}
+
+	if _, err := NewChallenge(ctx, mapFetch(blocks, nil), rc, nonce[:8], 10); err == nil {
+		t.Errorf("expected error for short nonce")
+	}
+}
@@ -0,0 +1,215 @@
+package eris
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrCheckpointUnavailable is returned by Encoder.Checkpoint when the encoder
+// is not in a state that can be checkpointed.
+var ErrCheckpointUnavailable = errors.New("encoder cannot be checkpointed in its current state")
+
+// checkpointVersion is the version byte at the start of a marshaled
+// EncoderCheckpoint. Version 1 checkpoints, which have no flags byte, are
+// still accepted.
+const checkpointVersion = 2
+
+// checkpointFlagSizePadding is set in the flags byte of a marshaled
+// EncoderCheckpoint if the content was padded with WithSizePadding.
+const checkpointFlagSizePadding = 1
+
+// EncoderCheckpoint is a snapshot of the progress of an Encoder, which can be
+// serialized and later used to resume encoding with ResumeEncoder.
+//
+// A checkpoint records the reference-key pairs of every leaf block that has
+// been read from the content so far. Since every leaf (other than the final,
+// padded one) is exactly BlockSize bytes, this implies that the encoder has
+// consumed exactly Offset bytes of content; resuming requires that the content
+// be identical and seekable to that offset.
+//
+// A checkpoint does not contain the convergence secret; the same secret must
+// be provided when resuming.
+type EncoderCheckpoint struct {
+	// BlockSize is the block size of the encoder.
+	BlockSize int
+	// SizePadding reports whether the encoder was created with
+	// WithSizePadding; the same padding policy must be given to
+	// ResumeEncoder.
+	SizePadding bool
+	// Leaves is the list of reference-key pairs for all leaf blocks that
+	// have been generated so far, in order.
+	Leaves []ReferenceKeyPair
+}
+
+// Offset returns the offset in the content at which encoding should resume.
+func (c *EncoderCheckpoint) Offset() int64 {
+	return int64(len(c.Leaves)) * int64(c.BlockSize)
+}
+
+// AppendBinary appends the binary representation of the checkpoint to the
+// given byte slice and returns it.
+func (c *EncoderCheckpoint) AppendBinary(data []byte) ([]byte, error) {
+	var flags byte
+	if c.SizePadding {
+		flags |= checkpointFlagSizePadding
+	}
+	data = append(data, checkpointVersion, flags)
+	data = binary.AppendUvarint(data, uint64(c.BlockSize))
+	data = binary.AppendUvarint(data, uint64(len(c.Leaves)))
+	for _, rk := range c.Leaves {
+		data = append(data, rk.Reference[:]...)
+		data = append(data, rk.Key[:]...)
+	}
+	return data, nil
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface.
+func (c *EncoderCheckpoint) MarshalBinary() ([]byte, error) {
+	return c.AppendBinary(nil)
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
+func (c *EncoderCheckpoint) UnmarshalBinary(data []byte) error {
+	if len(data) < 1 {
+		return errors.New("checkpoint data too short")
+	}
+	var flags byte
+	switch data[0] {
+	case 1:
+		data = data[1:]
+	case checkpointVersion:
+		if len(data) < 2 {
+			return errors.New("checkpoint data too short")
+		}
+		flags = data[1]
+		if flags&^checkpointFlagSizePadding != 0 {
+			return fmt.Errorf("unknown checkpoint flags: 0x%02x", flags)
+		}
+		data = data[2:]
+	default:
+		return fmt.Errorf("unsupported checkpoint version: %d", data[0])
+	}
+
+	blockSize, n := binary.Uvarint(data)
+	if n <= 0 || blockSize > 1<<30 {
+		return errors.New("invalid checkpoint block size")
+	}
+	data = data[n:]
+
+	count, n := binary.Uvarint(data)
+	if n <= 0 {
+		return errors.New("invalid checkpoint leaf count")
+	}
+	data = data[n:]
+
+	// Check the length before allocating, so that a corrupt count
+	// can't cause us to allocate a huge slice.
+	if count > uint64(len(data)/referenceKeyLen) || uint64(len(data)) != count*referenceKeyLen {
+		return fmt.Errorf("checkpoint has %d bytes of leaf data for %d leaves", len(data), count)
+	}
+
+	c.BlockSize = int(blockSize)
+	c.SizePadding = flags&checkpointFlagSizePadding != 0
+	c.Leaves = make([]ReferenceKeyPair, count)
+	for i := range c.Leaves {
+		copy(c.Leaves[i].Reference[:], data[:ReferenceSize])
+		copy(c.Leaves[i].Key[:], data[ReferenceSize:referenceKeyLen])
+		data = data[referenceKeyLen:]
+	}
+	return nil
+}
+
+// Checkpoint returns a snapshot of the encoder's progress that can be used
+// to resume encoding with ResumeEncoder.
+//
+// An encoder can only be checkpointed while it is reading content; i.e.
+// before the final block of content has been read, and, with WithSizePadding,
+// before any padding has been added. Encoders created with WithContentHash
+// can't be checkpointed at all, since the hash of the content read so far
+// can't be saved. Otherwise, this method returns ErrCheckpointUnavailable. The
+// encoder is not modified, and can continue to be used after this method
+// returns.
+func (e *Encoder) Checkpoint() (*EncoderCheckpoint, error) {
+	if e.err != nil || e.state != 0 || e.contentHash != nil {
+		return nil, ErrCheckpointUnavailable
+	}
+	if e.sizePadding != nil && e.content.(*sizePaddingReader).pad >= 0 {
+		return nil, ErrCheckpointUnavailable
+	}
+
+	// If the splitter has read the final (padded) block, then the
+	// content offset is no longer a multiple of the block size and we
+	// can't resume from here.
+	if e.splitter != nil && e.splitter.done {
+		return nil, ErrCheckpointUnavailable
+	}
+
+	leaves := make([]ReferenceKeyPair, len(e.referenceKeyPairs))
+	copy(leaves, e.referenceKeyPairs)
+	return &EncoderCheckpoint{
+		BlockSize:   e.blockSize,
+		SizePadding: e.sizePadding != nil,
+		Leaves:      leaves,
+	}, nil
+}
+
+// ResumeEncoder creates a new Encoder that continues encoding from the given
+// checkpoint. It seeks content to the checkpoint's Offset before returning;
+// the content and secret must be the same as those used by the encoder that
+// created the checkpoint, or the resulting capability will be incorrect.
+//
+// The options are applied as by NewEncoder, and must include the same
+// WithSizePadding policy, if any, as the original encoder; an error is
+// returned if the checkpoint says otherwise, but a different policy can't be
+// detected. The index from WithIndex, the log from WithLeafLog and the counts
+// from Stats cover the leaves from before the checkpoint too, but a
+// BlockObserver is only called for blocks constructed after it, and
+// WithContentHash can't be used.
+//
+// Blocks emitted before the checkpoint was taken are not emitted again.
+func ResumeEncoder(content io.ReadSeeker, secret [ConvergenceSecretSize]byte, cp *EncoderCheckpoint, opts ...EncoderOption) (*Encoder, error) {
+	if cp.BlockSize <= 0 || cp.BlockSize%referenceKeyLen != 0 {
+		return nil, fmt.Errorf("invalid checkpoint block size: %d", cp.BlockSize)
+	}
+
+	e := NewEncoder(content, secret, cp.BlockSize, opts...)
+	if e.contentHash != nil {
+		return nil, errors.New("WithContentHash can't be used when resuming an encoder")
+	}
+	if cp.SizePadding != (e.sizePadding != nil) {
+		return nil, fmt.Errorf("checkpoint has size padding %t, but encoder has size padding %t", cp.SizePadding, e.sizePadding != nil)
+	}
+	if _, err := content.Seek(cp.Offset(), io.SeekStart); err != nil {
+		return nil, fmt.Errorf("seeking to checkpoint offset: %w", err)
+	}
+	if e.sizePadding != nil {
+		// The padding depends on the total size of the content.
+		e.content.(*sizePaddingReader).n = cp.Offset()
+	}
+
+	// Offsets in read errors count from the start of the content.
+	e.splitter = newSplitter(e.content, e.blockSize)
+	e.splitter.off = cp.Offset()
+
+	e.referenceKeyPairs = make([]ReferenceKeyPair, len(cp.Leaves))
+	copy(e.referenceKeyPairs, cp.Leaves)
+
+	// Every leaf in the checkpoint was emitted by the original encoder
+	// the first time it was seen, so mark them all as seen to avoid
+	// emitting duplicates.
+	for _, rk := range cp.Leaves {
+		e.blocks[rk.Reference] = true
+		if e.leafLog != nil {
+			e.leafLog.add(rk.Reference, cp.BlockSize)
+		}
+	}
+	e.stats.Leaves = int64(len(cp.Leaves))
+	e.stats.DuplicateLeaves = int64(len(cp.Leaves) - len(e.blocks))
+	if e.index != nil {
+		e.index.Leaves = append(e.index.Leaves, cp.Leaves...)
+		e.index.Size = cp.Offset()
+	}
+	return e, nil
+}
@@ -0,0 +1,294 @@
+package eris
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"slices"
+	"testing"
+)
+
+// encodeResumed encodes content with the given options, stopping after n
+// blocks to take a checkpoint, and then resumes encoding from the checkpoint
+// (after a round trip through its binary form) with the same options. It
+// returns the finished encoder.
+func encodeResumed(t *testing.T, content []byte, blockSize, n int, opts ...EncoderOption) *Encoder {
+	t.Helper()
+	var secret [ConvergenceSecretSize]byte
+	enc := NewEncoder(bytes.NewReader(content), secret, blockSize, opts...)
+	for i := 0; i < n && enc.Next(); i++ {
+	}
+	cp, err := enc.Checkpoint()
+	if err != nil {
+		t.Fatalf("Checkpoint: %v", err)
+	}
+	data, _ := cp.MarshalBinary()
+	var cp2 EncoderCheckpoint
+	if err := cp2.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary: %v", err)
+	}
+
+	enc2, err := ResumeEncoder(bytes.NewReader(content), secret, &cp2, opts...)
+	if err != nil {
+		t.Fatalf("ResumeEncoder: %v", err)
+	}
+	for enc2.Next() {
+	}
+	if err := enc2.Err(); err != nil {
+		t.Fatalf("error encoding: %v", err)
+	}
+	return enc2
+}
+
+func TestEncoderCheckpoint(t *testing.T) {
+	const blockSize = 1024
+	var secret [ConvergenceSecretSize]byte
+	content := randomContent(100*blockSize + 17)
+	wantRC, wantBlocks := encodeToMap(t, content, blockSize)
+
+	// Encode part of the content, then take a checkpoint.
+	blocks := make(map[Reference][]byte)
+	enc := NewEncoder(bytes.NewReader(content), secret, blockSize)
+	for i := 0; i < 40 && enc.Next(); i++ {
+		blocks[enc.Reference()] = enc.Block()
+	}
+	cp, err := enc.Checkpoint()
+	if err != nil {
+		t.Fatalf("Checkpoint: %v", err)
+	}
+	if cp.Offset() != 40*blockSize {
+		t.Errorf("Offset() = %d, want %d", cp.Offset(), 40*blockSize)
+	}
+
+	// Round-trip the checkpoint through its binary form.
+	data, err := cp.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary: %v", err)
+	}
+	var cp2 EncoderCheckpoint
+	if err := cp2.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary: %v", err)
+	}
+
+	// Resume encoding from the checkpoint and verify that we end up with
+	// the same blocks and capability as a single encode.
+	enc2, err := ResumeEncoder(bytes.NewReader(content), secret, &cp2)
+	if err != nil {
+		t.Fatalf("ResumeEncoder: %v", err)
+	}
+	for enc2.Next() {
+		if _, ok := blocks[enc2.Reference()]; ok {
+			t.Errorf("block %v emitted twice", enc2.Reference())
+		}
+		blocks[enc2.Reference()] = enc2.Block()
+	}
+	if err := enc2.Err(); err != nil {
+		t.Fatalf("error encoding: %v", err)
+	}
+	if !enc2.Capability().Equal(wantRC) {
+		t.Errorf("capability mismatch after resume")
+	}
+	if len(blocks) != len(wantBlocks) {
+		t.Errorf("got %d blocks, want %d", len(blocks), len(wantBlocks))
+	}
+
+	// Once the encoder has finished, it can't be checkpointed.
+	if _, err := enc2.Checkpoint(); err != ErrCheckpointUnavailable {
+		t.Errorf("Checkpoint after finish: got %v, want ErrCheckpointUnavailable", err)
+	}
+}
+
+func TestEncoderCheckpoint_UnmarshalInvalid(t *testing.T) {
+	cp := &EncoderCheckpoint{BlockSize: 1024, Leaves: make([]ReferenceKeyPair, 2)}
+	data, _ := cp.MarshalBinary()
+
+	for _, bad := range [][]byte{
+		nil,
+		{0xff},
+		data[:len(data)-1],
+		append(data, 0),
+		{checkpointVersion, 0x80, 0x08, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f},
+	} {
+		var got EncoderCheckpoint
+		if err := got.UnmarshalBinary(bad); err == nil {
+			t.Errorf("UnmarshalBinary(%x): expected error", bad)
+		}
+	}
+}
+
+func TestEncoderCheckpoint_SizePadding(t *testing.T) {
+	const blockSize = 1024
+	var secret [ConvergenceSecretSize]byte
+	content := randomContent(2 * blockSize)
+	pad := WithSizePadding(PadToPowerOfTwo)
+	wantRC, err := ComputeCapability(bytes.NewReader(content), secret, blockSize, pad)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	enc := encodeResumed(t, content, blockSize, 2, pad)
+	if !enc.Capability().Equal(wantRC) {
+		t.Errorf("capability mismatch after resume with size padding")
+	}
+
+	// The padding policy must be given when resuming.
+	enc = NewEncoder(bytes.NewReader(content), secret, blockSize, pad)
+	enc.Next()
+	cp, err := enc.Checkpoint()
+	if err != nil {
+		t.Fatalf("Checkpoint: %v", err)
+	}
+	if !cp.SizePadding {
+		t.Errorf("checkpoint doesn't record size padding")
+	}
+	if _, err := ResumeEncoder(bytes.NewReader(content), secret, cp); err == nil {
+		t.Errorf("ResumeEncoder without size padding: expected error")
+	}
+
+	// Once padding has started, the encoder can't be checkpointed.
+	enc.Next()
+	enc.Next()
+	if _, err := enc.Checkpoint(); err != ErrCheckpointUnavailable {
+		t.Errorf("Checkpoint during padding: got %v, want ErrCheckpointUnavailable", err)
+	}
+}
+
+func TestEncoderCheckpoint_Index(t *testing.T) {
+	const blockSize = 1024
+	var secret [ConvergenceSecretSize]byte
+	content := randomContent(20*blockSize + 100)
+
+	enc := NewEncoder(bytes.NewReader(content), secret, blockSize, WithIndex())
+	for enc.Next() {
+	}
+	want, err := enc.Index()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := encodeResumed(t, content, blockSize, 8, WithIndex()).Index()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Size != want.Size || len(got.Leaves) != len(want.Leaves) {
+		t.Fatalf("index after resume has size %d and %d leaves, want %d and %d", got.Size, len(got.Leaves), want.Size, len(want.Leaves))
+	}
+	for i := range got.Leaves {
+		if got.Leaves[i] != want.Leaves[i] {
+			t.Errorf("leaf %d differs after resume", i)
+		}
+	}
+}
+
+func TestEncoderCheckpoint_ContentHash(t *testing.T) {
+	var secret [ConvergenceSecretSize]byte
+	content := randomContent(10 * 1024)
+
+	enc := NewEncoder(bytes.NewReader(content), secret, 1024, WithContentHash(sha256.New()))
+	enc.Next()
+	if _, err := enc.Checkpoint(); err != ErrCheckpointUnavailable {
+		t.Errorf("Checkpoint with content hash: got %v, want ErrCheckpointUnavailable", err)
+	}
+
+	cp := &EncoderCheckpoint{BlockSize: 1024}
+	if _, err := ResumeEncoder(bytes.NewReader(content), secret, cp, WithContentHash(sha256.New())); err == nil {
+		t.Errorf("ResumeEncoder with content hash: expected error")
+	}
+}
+
+// repetitiveContent returns content of n blocks of the given size, in which
+// every third block is a duplicate of the first.
+func repetitiveContent(n, blockSize int) []byte {
+	content := randomContent(n*blockSize + 10)
+	for i := 3; i < n; i += 3 {
+		copy(content[i*blockSize:(i+1)*blockSize], content[:blockSize])
+	}
+	return content
+}
+
+func TestEncoderCheckpoint_LeafLog(t *testing.T) {
+	const blockSize = 1024
+	var secret [ConvergenceSecretSize]byte
+	content := repetitiveContent(20, blockSize)
+
+	enc := NewEncoder(bytes.NewReader(content), secret, blockSize, WithLeafLog())
+	for enc.Next() {
+	}
+	want, err := enc.LeafLog()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := encodeResumed(t, content, blockSize, 5, WithLeafLog()).LeafLog()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("leaf log after resume = %v, want %v", got, want)
+	}
+}
+
+func TestEncoderCheckpoint_Stats(t *testing.T) {
+	const blockSize = 1024
+	var secret [ConvergenceSecretSize]byte
+	content := repetitiveContent(20, blockSize)
+
+	enc := NewEncoder(bytes.NewReader(content), secret, blockSize)
+	for enc.Next() {
+	}
+	want := enc.Stats()
+
+	if got := encodeResumed(t, content, blockSize, 5).Stats(); got != want {
+		t.Errorf("stats after resume = %+v, want %+v", got, want)
+	}
+}
+
+// brokenReadSeeker is an io.ReadSeeker that fails when reading at or past
+// limit.
+type brokenReadSeeker struct {
+	*bytes.Reader
+	limit int64
+	err   error
+}
+
+func (r *brokenReadSeeker) Read(p []byte) (int, error) {
+	pos, _ := r.Seek(0, io.SeekCurrent)
+	if pos >= r.limit {
+		return 0, r.err
+	}
+	return r.Reader.Read(p[:min(int64(len(p)), r.limit-pos)])
+}
+
+func TestEncoderCheckpoint_ReadError(t *testing.T) {
+	const blockSize = 1024
+	var secret [ConvergenceSecretSize]byte
+	content := randomContent(10 * blockSize)
+
+	enc := NewEncoder(bytes.NewReader(content), secret, blockSize)
+	for i := 0; i < 4 && enc.Next(); i++ {
+	}
+	cp, err := enc.Checkpoint()
+	if err != nil {
+		t.Fatalf("Checkpoint: %v", err)
+	}
+
+	// The offset of a read error counts from the start of the content,
+	// not from the checkpoint.
+	const limit = 7*blockSize + 100
+	errBroken := errors.New("broken")
+	r := &brokenReadSeeker{Reader: bytes.NewReader(content), limit: limit, err: errBroken}
+	enc2, err := ResumeEncoder(r, secret, cp)
+	if err != nil {
+		t.Fatalf("ResumeEncoder: %v", err)
+	}
+	for enc2.Next() {
+	}
+	var re *ReadError
+	if err := enc2.Err(); !errors.As(err, &re) || !errors.Is(err, errBroken) {
+		t.Fatalf("Err = %v, want a *ReadError wrapping %v", err, errBroken)
+	}
+	if re.Offset != limit {
+		t.Errorf("Offset = %d, want %d (checkpoint at %d)", re.Offset, limit, cp.Offset())
+	}
+}
@@ -0,0 +1,60 @@
+package eris
+
+import (
+	"context"
+	"fmt"
+)
+
+// Closure returns the distinct references of every block in the ERIS trees
+// rooted at the given read capabilities: the set of blocks that must be
+// copied to export all of them, or kept to retain all of them.
+//
+// The references are returned in the order in which they are first found by
+// a depth-first walk of each tree in turn. A subtree that was already visited,
+// whether in the same tree or an earlier one, is not walked again, so content
+// shared between the trees is only fetched once. Only internal nodes are
+// fetched; leaves are never fetched.
+func Closure(ctx context.Context, fetch FetchFunc, rcs ...ReadCapability) ([]Reference, error) {
+	seen := make(map[Reference]bool)
+	var refs []Reference
+	for _, rc := range rcs {
+		err := walkReferences(ctx, fetch, rc, func(ref ReferenceKeyPair, _ int) error {
+			if seen[ref.Reference] {
+				return SkipSubtree
+			}
+			seen[ref.Reference] = true
+			refs = append(refs, ref.Reference)
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("walking tree %v: %w", rc.Root.Reference, err)
+		}
+	}
+	return refs, nil
+}
+
+// ClosureCounts is like Closure, but also counts how many of the trees rooted
+// at the given read capabilities contain each block. A block with a count of
+// 1 is used by only one of the trees, and can be deleted once that tree is no
+// longer needed; one with a higher count is shared.
+//
+// Unlike Closure, ClosureCounts has to walk the subtrees that are shared
+// between trees once for each tree that contains them.
+func ClosureCounts(ctx context.Context, fetch FetchFunc, rcs ...ReadCapability) (map[Reference]int, error) {
+	counts := make(map[Reference]int)
+	for _, rc := range rcs {
+		seen := make(map[Reference]bool)
+		err := walkReferences(ctx, fetch, rc, func(ref ReferenceKeyPair, _ int) error {
+			if seen[ref.Reference] {
+				return SkipSubtree
+			}
+			seen[ref.Reference] = true
+			counts[ref.Reference]++
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("walking tree %v: %w", rc.Root.Reference, err)
+		}
+	}
+	return counts, nil
+}
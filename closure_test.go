@@ -0,0 +1,99 @@
+package eris
+
+import (
+	"context"
+	"testing"
+)
+
+func TestClosure(t *testing.T) {
+	ctx := context.Background()
+
+	// The two contents share their first 150 leaves, and so the internal
+	// nodes above them.
+	a := randomContent(300 * 1024)
+	b := append(a[:150*1024:150*1024], randomContent(100*1024)...)
+	rcA, blocksA := encodeToMap(t, a, BlockSizeSmall)
+	rcB, blocksB := encodeToMap(t, b, BlockSizeSmall)
+
+	all := make(map[Reference][]byte)
+	var shared int
+	for ref, block := range blocksA {
+		all[ref] = block
+	}
+	for ref, block := range blocksB {
+		if _, ok := all[ref]; ok {
+			shared++
+		}
+		all[ref] = block
+	}
+	if shared < 150 {
+		t.Fatalf("trees only share %d blocks", shared)
+	}
+
+	var calls int
+	refs, err := Closure(ctx, mapFetch(all, &calls), rcA, rcB, rcA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(refs) != len(all) {
+		t.Errorf("Closure returned %d references, want %d", len(refs), len(all))
+	}
+	seen := make(map[Reference]bool)
+	for _, ref := range refs {
+		if _, ok := all[ref]; !ok || seen[ref] {
+			t.Errorf("Closure returned unexpected or duplicate reference %v", ref)
+		}
+		seen[ref] = true
+	}
+	if refs[0] != rcA.Root.Reference {
+		t.Errorf("Closure didn't start with the first root")
+	}
+
+	// Shared subtrees are only fetched once, and repeating a tree
+	// fetches nothing more.
+	var callsA, callsB, callsAB int
+	Closure(ctx, mapFetch(all, &callsA), rcA)
+	Closure(ctx, mapFetch(all, &callsB), rcB)
+	Closure(ctx, mapFetch(all, &callsAB), rcA, rcB)
+	if calls != callsAB || callsAB >= callsA+callsB {
+		t.Errorf("fetched %d blocks for A, B and A, %d for A and B, and %d and %d separately", calls, callsAB, callsA, callsB)
+	}
+
+	counts, err := ClosureCounts(ctx, mapFetch(all, nil), rcA, rcB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(counts) != len(all) {
+		t.Errorf("ClosureCounts returned %d blocks, want %d", len(counts), len(all))
+	}
+	var twice int
+	for ref, n := range counts {
+		_, inA := blocksA[ref]
+		_, inB := blocksB[ref]
+		want := 0
+		if inA {
+			want++
+		}
+		if inB {
+			want++
+		}
+		if n != want {
+			t.Errorf("count of %v = %d, want %d", ref, n, want)
+		}
+		if n == 2 {
+			twice++
+		}
+	}
+	if twice != shared {
+		t.Errorf("%d blocks counted twice, want %d", twice, shared)
+	}
+
+	// A missing block is reported along with the tree it belongs to.
+	delete(all, rcB.Root.Reference)
+	if _, err := Closure(ctx, mapFetch(all, nil), rcA, rcB); err == nil {
+		t.Error("Closure succeeded with a missing root")
+	}
+	if _, err := ClosureCounts(ctx, mapFetch(all, nil), rcA, rcB); err == nil {
+		t.Error("ClosureCounts succeeded with a missing root")
+	}
+}
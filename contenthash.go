@@ -0,0 +1,37 @@
+package eris
+
+import (
+	"errors"
+	"hash"
+)
+
+// WithContentHash returns an EncoderOption that writes the content to h as it
+// is encoded, so that a conventional checksum of the content (such as a
+// SHA-256 hash) can be computed without reading it twice. Once encoding has
+// finished, the checksum is available from Encoder.ContentHash.
+//
+// The hash covers the original content, before any padding added by
+// WithSizePadding. Since the state of the hash can't be saved, an encoder with
+// this option can't be checkpointed or resumed; see Encoder.Checkpoint.
+func WithContentHash(h hash.Hash) EncoderOption {
+	return func(e *Encoder) {
+		e.contentHash = h
+	}
+}
+
+// ContentHash returns the checksum of the encoded content, if the encoder was
+// created with the WithContentHash option. It is only valid to call this
+// method after a call to the Next method has returned false, and if there was
+// no error.
+func (e *Encoder) ContentHash() ([]byte, error) {
+	if e.contentHash == nil {
+		return nil, errors.New("encoder was not created with WithContentHash")
+	}
+	if e.err != nil {
+		return nil, e.err
+	}
+	if e.state != 2 {
+		return nil, errors.New("encoder has not finished")
+	}
+	return e.contentHash.Sum(nil), nil
+}
@@ -0,0 +1,53 @@
+package eris
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"testing"
+)
+
+func TestWithContentHash(t *testing.T) {
+	content := randomContent(100*1024 + 7)
+	want := sha256.Sum256(content)
+
+	for _, tc := range []struct {
+		name string
+		opts []EncoderOption
+	}{
+		{"default", nil},
+		{"concurrent", []EncoderOption{WithConcurrency(4)}},
+		{"size padding", []EncoderOption{WithSizePadding(PadToPowerOfTwo)}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			enc := NewEncoder(bytes.NewReader(content), [32]byte{}, BlockSizeLarge, append(tc.opts, WithContentHash(sha256.New()))...)
+			if _, err := enc.ContentHash(); err == nil {
+				t.Error("ContentHash succeeded before encoding")
+			}
+			for enc.Next() {
+			}
+			if err := enc.Err(); err != nil {
+				t.Fatal(err)
+			}
+			got, err := enc.ContentHash()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want[:]) {
+				t.Errorf("ContentHash = %x, want %x", got, want)
+			}
+
+			// The capability is unchanged by the option.
+			rc, _ := ComputeCapability(bytes.NewReader(content), [32]byte{}, BlockSizeLarge, tc.opts...)
+			if !enc.Capability().Equal(rc) {
+				t.Error("capability changed by WithContentHash")
+			}
+		})
+	}
+
+	enc := NewEncoder(bytes.NewReader(content), [32]byte{}, BlockSizeLarge)
+	for enc.Next() {
+	}
+	if _, err := enc.ContentHash(); err == nil {
+		t.Error("ContentHash succeeded without WithContentHash")
+	}
+}
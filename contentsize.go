@@ -0,0 +1,57 @@
+package eris
+
+import (
+	"context"
+	"fmt"
+)
+
+// ContentSize returns the exact size of the content with read capability rc,
+// fetching only the blocks on the right-most path of the tree: one block per
+// level, plus the final leaf.
+//
+// Every node in a tree produced by a conforming encoder is full except for
+// those on the right-most path, so the size follows from the number of
+// children of each node on that path and the length of the final leaf. For a
+// tree that doesn't have that shape (which a Decoder created with
+// WithStrictValidation would reject), the result is not the length of the
+// content that a Decoder would return.
+func ContentSize(ctx context.Context, fetch FetchFunc, rc ReadCapability) (int64, error) {
+	if err := rc.Validate(); err != nil {
+		return 0, err
+	}
+	blockSize := rc.BlockSize
+	arity := int64(arity(blockSize))
+	buf := make([]byte, blockSize)
+
+	// Count the leaves to the left of the right-most path.
+	var leaves int64
+	node := rc.Root
+	for level := rc.Level; level > 0; level-- {
+		block, err := dereferenceNode(ctx, fetch, buf, node, level, blockSize)
+		if err != nil {
+			return 0, err
+		}
+		if level == rc.Level && !verifyNodeKey(block, rc.Root.Key) {
+			return 0, ErrInvalidKey
+		}
+		refs, err := decodeInternalNode(block, blockSize)
+		if err != nil {
+			return 0, err
+		}
+		if len(refs) == 0 {
+			return 0, fmt.Errorf("%w: node %v has no children", ErrMalformedTree, node.Reference)
+		}
+		leaves += int64(len(refs)-1) * leavesPerNode(arity, level-1)
+		node = refs[len(refs)-1]
+	}
+
+	leaf, err := dereferenceNode(ctx, fetch, buf, node, 0, blockSize)
+	if err != nil {
+		return 0, err
+	}
+	last, err := removePadding(leaf, blockSize)
+	if err != nil {
+		return 0, err
+	}
+	return leaves*int64(blockSize) + int64(len(last)), nil
+}
@@ -0,0 +1,57 @@
+package eris
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestContentSize(t *testing.T) {
+	ctx := context.Background()
+	for _, tc := range []struct {
+		size      int
+		blockSize int
+	}{
+		{0, BlockSizeSmall},
+		{1, BlockSizeSmall},
+		{1023, BlockSizeSmall},
+		{1024, BlockSizeSmall},
+		{16 * 1024, BlockSizeSmall},
+		{16*1024 + 1, BlockSizeSmall},
+		{300 * 1024, BlockSizeSmall},
+		{100 * 1024, BlockSizeLarge},
+	} {
+		rc, blocks := encodeToMap(t, randomContent(tc.size), tc.blockSize)
+		var calls int
+		got, err := ContentSize(ctx, mapFetch(blocks, &calls), rc)
+		if err != nil {
+			t.Errorf("size %d: %v", tc.size, err)
+			continue
+		}
+		if got != int64(tc.size) {
+			t.Errorf("ContentSize = %d, want %d", got, tc.size)
+		}
+		if calls != rc.Level+1 {
+			t.Errorf("size %d: fetched %d blocks, want %d", tc.size, calls, rc.Level+1)
+		}
+	}
+}
+
+func TestContentSize_Errors(t *testing.T) {
+	ctx := context.Background()
+	rc, blocks := encodeToMap(t, randomContent(50*1024), BlockSizeSmall)
+
+	if _, err := ContentSize(ctx, mapFetch(blocks, nil), ReadCapability{BlockSize: 1000}); !errors.Is(err, ErrInvalidCapability) {
+		t.Errorf("invalid capability: got %v, want %v", err, ErrInvalidCapability)
+	}
+
+	bad := rc
+	bad.Root.Key[0] ^= 1
+	if _, err := ContentSize(ctx, mapFetch(blocks, nil), bad); err == nil {
+		t.Error("wrong key: expected error")
+	}
+
+	if _, err := ContentSize(ctx, mapFetch(map[Reference][]byte{}, nil), rc); err == nil {
+		t.Error("missing blocks: expected error")
+	}
+}
@@ -2,8 +2,10 @@ package eris
 
 import (
 	"context"
+	"crypto/subtle"
 	"errors"
 	"fmt"
+	"slices"
 
 	"golang.org/x/crypto/blake2b"
 	"golang.org/x/crypto/chacha20"
@@ -14,6 +16,18 @@ var (
 	ErrInvalidBlock     = errors.New("invalid block")
 	ErrInvalidPadding   = errors.New("invalid padding")
 	ErrInvalidKey       = errors.New("key in read capability is invalid")
+	ErrMalformedTree    = errors.New("malformed tree")
+
+	// ErrInvalidCapability is wrapped by the errors returned by
+	// ReadCapability.Validate.
+	ErrInvalidCapability = errors.New("invalid read capability")
+
+	// ErrInvalidNodePadding is returned when an internal node contains a
+	// non-zero reference-key pair after a zero reference. The
+	// specification pads internal nodes with zeroes after the last
+	// reference-key pair, so a conforming encoder never produces such a
+	// node; see WithLenientNodePadding. It wraps ErrInvalidPadding.
+	ErrInvalidNodePadding = fmt.Errorf("%w: non-zero data after the end of an internal node", ErrInvalidPadding)
 )
 
 // FetchFunc is the function signature for a function that fetches an encrypted
@@ -43,6 +57,19 @@ func dereferenceNode(
 	buf []byte,
 	ref ReferenceKeyPair,
 	level, blockSize int,
+) ([]byte, error) {
+	return fetchNode(ctx, fetch, buf, ref, level, blockSize, true)
+}
+
+// fetchNode is like dereferenceNode, but only checks that the fetched block
+// matches its reference if verify is set; see WithTrustedFetch.
+func fetchNode(
+	ctx context.Context,
+	fetch FetchFunc,
+	buf []byte,
+	ref ReferenceKeyPair,
+	level, blockSize int,
+	verify bool,
 ) ([]byte, error) {
 	if extraChecks && len(buf) < blockSize {
 		panic(fmt.Sprintf(
@@ -63,8 +90,7 @@ func dereferenceNode(
 	}
 	// Ensure that the block is valid for the reference; the hash of the
 	// contents returned should be the reference.
-	returnedRef := blake2b.Sum256(block)
-	if returnedRef != ref.Reference {
+	if verify && blake2b.Sum256(block) != ref.Reference {
 		return nil, ErrInvalidBlock
 	}
 
@@ -78,9 +104,32 @@ func dereferenceNode(
 	return block, nil
 }
 
+// verifyNodeKey reports whether key is the hash of the given decrypted internal
+// node, as it is for every internal node produced by the encoder. This is the
+// Verify-Key function from the spec. The comparison takes constant time.
+func verifyNodeKey(node []byte, key Key) bool {
+	hash := blake2b.Sum256(node)
+	return subtle.ConstantTimeCompare(hash[:], key[:]) == 1
+}
+
 // decodeInternalNode decodes an internal node from a decrypted block of data.
 // The length of the given slice must equal blockSize.
 func decodeInternalNode(data []byte, blockSize int) (refs []ReferenceKeyPair, err error) {
+	return appendInternalNode(nil, data, blockSize)
+}
+
+// appendInternalNode is like decodeInternalNode, but appends the decoded
+// reference-key pairs to refs and returns the extended slice. Callers that
+// decode many nodes can pass a scratch slice truncated to zero length to
+// avoid allocating a new slice for every node.
+func appendInternalNode(refs []ReferenceKeyPair, data []byte, blockSize int) ([]ReferenceKeyPair, error) {
+	return appendInternalNodeLenient(refs, data, blockSize, false)
+}
+
+// appendInternalNodeLenient is like appendInternalNode, but if lenient is set,
+// reference-key pairs with a zero reference are skipped wherever they appear,
+// rather than ending the node.
+func appendInternalNodeLenient(refs []ReferenceKeyPair, data []byte, blockSize int, lenient bool) ([]ReferenceKeyPair, error) {
 	if len(data) != blockSize {
 		return nil, ErrInvalidBlockSize
 	}
@@ -94,9 +143,12 @@ func decodeInternalNode(data []byte, blockSize int) (refs []ReferenceKeyPair, er
 		// data and the rest of the data is padding. Double-check that
 		// the rest of the buffer is zero, just to be safe.
 		if ref.isZero() {
+			if lenient {
+				continue
+			}
 			for j := i + ReferenceSize; j < len(data); j++ {
 				if data[j] != 0 {
-					return nil, ErrInvalidPadding
+					return nil, ErrInvalidNodePadding
 				}
 			}
 			break
@@ -114,6 +166,11 @@ func decodeInternalNode(data []byte, blockSize int) (refs []ReferenceKeyPair, er
 	return refs, nil
 }
 
+// decodeRecursiveMaxHint is the largest amount of memory that DecodeRecursive
+// will preallocate for its output, so that a malicious read capability can't
+// cause a huge allocation before any content has been fetched.
+const decodeRecursiveMaxHint = 64 << 20
+
 // DecodeRecursive recursively decodes the content of an ERIS tree rooted at rc
 // and returns the content, or an error if the content could not be decoded.
 //
@@ -121,63 +178,88 @@ func decodeInternalNode(data []byte, blockSize int) (refs []ReferenceKeyPair, er
 // store; see the documentation for FetchFunc for the exact semantics.
 //
 // The provided context is passed to the fetch function.
+//
+// Since all of the content is held in memory, DecodeRecursive is only
+// suitable for small content; use a Decoder to stream larger content.
 func DecodeRecursive(ctx context.Context, fetch FetchFunc, rc ReadCapability) ([]byte, error) {
 	blockSize := rc.BlockSize
-	buf := make([]byte, blockSize)
-
-	// Verify integrity of read capability key if level is larger than 0
-	if rc.Level > 0 {
-		// This is the Verify-Key function from the spec, inlined
-
-		// Dereference the node
-		node, err := dereferenceNode(ctx, fetch, buf, rc.Root, rc.Level, blockSize)
+	if rc.Level == 0 {
+		leaf, err := dereferenceNode(ctx, fetch, make([]byte, blockSize), rc.Root, 0, blockSize)
 		if err != nil {
 			return nil, err
 		}
+		return removePadding(leaf, blockSize)
+	}
 
-		// Verify integrity of key
-		gotHash := blake2b.Sum256(node)
-		if gotHash != rc.Root.Key {
-			return nil, ErrInvalidKey
-		}
+	// Each level of internal nodes has its own fetch buffer and scratch
+	// slice for the node's children, since the children of a node must
+	// remain valid while the subtrees beneath it are decoded.
+	type levelState struct {
+		buf  []byte
+		refs []ReferenceKeyPair
+	}
+	levels := make([]levelState, rc.Level+1)
+	for i := 1; i <= rc.Level; i++ {
+		levels[i].buf = make([]byte, blockSize)
 	}
 
-	var decodeRecursive func(level int, refKey ReferenceKeyPair) ([]byte, error)
-	decodeRecursive = func(level int, refKey ReferenceKeyPair) ([]byte, error) {
-		// Dereference the node
-		node, err := dereferenceNode(ctx, fetch, buf, refKey, level, blockSize)
-		if err != nil {
-			return nil, err
-		}
+	// Verify integrity of the read capability key; this is the
+	// Verify-Key function from the spec, inlined.
+	root, err := dereferenceNode(ctx, fetch, levels[rc.Level].buf, rc.Root, rc.Level, blockSize)
+	if err != nil {
+		return nil, err
+	}
+	if !verifyNodeKey(root, rc.Root.Key) {
+		return nil, ErrInvalidKey
+	}
 
-		// If the level is 0, then thi sis a leaf node and we can return
-		// the contents as-is.
-		if level == 0 {
-			return node, nil
+	// Every child of the root except the last is full, so we know a
+	// lower bound on the size of the content; use it to size the output
+	// and avoid repeatedly copying it as it grows.
+	var output []byte
+	if children := int64(internalNodeLen(root) / referenceKeyLen); children > 1 {
+		leaves := leavesPerNode(int64(arity(blockSize)), rc.Level-1)
+		hint := int64(decodeRecursiveMaxHint)
+		if leaves <= hint/int64(blockSize)/(children-1) {
+			hint = (children - 1) * leaves * int64(blockSize)
 		}
+		output = make([]byte, 0, hint+int64(blockSize))
+	}
 
-		// Otherwise, this is an internal node and we need to decode it.
-		refs, err := decodeInternalNode(node, blockSize)
+	var decodeRecursive func(level int, node []byte) error
+	decodeRecursive = func(level int, node []byte) error {
+		refs, err := appendInternalNode(levels[level].refs[:0], node, blockSize)
 		if err != nil {
-			return nil, err
+			return err
 		}
+		levels[level].refs = refs
 
-		// Recursively decode each child node
-		var output []byte
 		for _, ref := range refs {
-			child, err := decodeRecursive(level-1, ref)
+			// Leaves are decrypted directly into the output,
+			// avoiding an extra copy.
+			if level == 1 {
+				output = slices.Grow(output, blockSize)
+				leaf, err := dereferenceNode(ctx, fetch, output[len(output):len(output)+blockSize], ref, 0, blockSize)
+				if err != nil {
+					return err
+				}
+				output = append(output, leaf...)
+				continue
+			}
+
+			child, err := dereferenceNode(ctx, fetch, levels[level-1].buf, ref, level-1, blockSize)
 			if err != nil {
-				return nil, err
+				return err
+			}
+			if err := decodeRecursive(level-1, child); err != nil {
+				return err
 			}
-			output = append(output, child...)
 		}
-		return output, nil
+		return nil
 	}
 
-	// Call through to the recursive function
-	padded, err := decodeRecursive(rc.Level, rc.Root)
-	if err != nil {
+	if err := decodeRecursive(rc.Level, root); err != nil {
 		return nil, err
 	}
-	return removePadding(padded, blockSize)
+	return removePadding(output, blockSize)
 }
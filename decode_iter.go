@@ -2,8 +2,8 @@ package eris
 
 import (
 	"context"
-
-	"golang.org/x/crypto/blake2b"
+	"fmt"
+	"math"
 )
 
 // decodeNode is a wrapper type that represents a node in an ERIS-encoded tree
@@ -20,6 +20,11 @@ type decodeNode struct {
 //
 // It is agnostic to how encrypted blocks of data are fetched or how output is
 // written, and it is up to the caller to perform these operations.
+//
+// After the first call to Next, decoding does not allocate, as long as the
+// fetch function returns blocks in the buffer it is given rather than
+// allocating new ones. This makes a Decoder suitable for decoding large
+// content on hot paths.
 type Decoder struct {
 	// fetch is the function that will be used to fetch encrypted blocks of data
 	fetch FetchFunc
@@ -49,74 +54,224 @@ type Decoder struct {
 	// stack is the current stack of nodes that we're processing.
 	stack []decodeNode
 
+	// refs is scratch storage for the children of an internal node, which
+	// is reused to avoid allocating for every node.
+	refs []ReferenceKeyPair
+
 	// didInit is whether we initialized the decoder; we do this on the
 	// first call to Next so that constructing a decoder doesn't require a
 	// call to fetch.
 	didInit bool
+
+	// offset is the offset in the original content immediately after the
+	// current block; i.e. the total number of content bytes returned so
+	// far.
+	offset int64
+
+	// blocksFetched is the number of blocks (both leaf and internal
+	// nodes) that have been successfully fetched and decrypted.
+	blocksFetched int64
+
+	// level is the level in the tree of the most recently fetched node.
+	level int
+
+	// leafIndex is the index of the next leaf node that will be popped
+	// from the stack.
+	leafIndex int64
+
+	// skip is the number of bytes at the start of the next leaf that
+	// should be skipped, as set by SkipTo.
+	skip int
+
+	// finalLeaf is the final leaf, if SkipTo fetched it, so that Next
+	// doesn't need to fetch it again.
+	finalLeaf []byte
+
+	// maxLevel, maxBytes and maxBlocks are the limits set by the
+	// WithMaxLevel, WithMaxBytes and WithMaxBlocks options; zero means
+	// that there is no limit.
+	maxLevel  int
+	maxBytes  int64
+	maxBlocks int64
+
+	// strict is set by the WithStrictValidation option.
+	strict bool
+
+	// trustedFetch is set by the WithTrustedFetch option.
+	trustedFetch bool
+
+	// lenientPadding is set by the WithLenientNodePadding option.
+	lenientPadding bool
+
+	// logConsumed is set by the WithConsumedLog option, and consumed is
+	// the log of fetched blocks.
+	logConsumed bool
+	consumed    []ConsumedBlock
+
+	// finished is set once Next has returned all of the content.
+	finished bool
+
+	// trace is the span reported to the tracer given with
+	// WithDecoderTracer, if any.
+	trace tracedOp
+}
+
+// DecoderOption is an option that can be passed to NewDecoder to change how
+// content is decoded.
+type DecoderOption func(*Decoder)
+
+// WithMaxLevel returns a DecoderOption that limits the level of the tree that
+// can be decoded; decoding a read capability with a larger level fails with a
+// *LimitError before any blocks are fetched. A level of n allows content of
+// up to arity^n blocks, where the arity is 16 for 1KiB blocks and 512 for
+// 32KiB blocks.
+func WithMaxLevel(n int) DecoderOption {
+	return func(d *Decoder) {
+		d.maxLevel = n
+	}
+}
+
+// WithMaxBytes returns a DecoderOption that limits the total number of bytes
+// of content that can be decoded; once the limit would be exceeded, decoding
+// fails with a *LimitError.
+func WithMaxBytes(n int64) DecoderOption {
+	return func(d *Decoder) {
+		d.maxBytes = n
+	}
+}
+
+// WithMaxBlocks returns a DecoderOption that limits the total number of
+// blocks, including both leaf and internal nodes, that can be fetched; once
+// the limit is reached, decoding fails with a *LimitError.
+func WithMaxBlocks(n int64) DecoderOption {
+	return func(d *Decoder) {
+		d.maxBlocks = n
+	}
+}
+
+// WithStrictValidation returns a DecoderOption that enables additional checks
+// on the structure of the tree, beyond those needed to decode it correctly,
+// for deployments that want to detect tampering or buggy encoders as
+// aggressively as possible. In strict mode, the decoder returns an error
+// wrapping ErrMalformedTree if:
+//
+//   - an internal node's key is not the hash of its contents, as it is for
+//     every internal node produced by a conforming encoder;
+//   - an internal node other than the right-most one at its level is not
+//     completely full, or any internal node has no children; or
+//   - the root node has only a single child, which means that the tree's
+//     level is larger than necessary.
+//
+// These checks don't require any additional blocks to be fetched.
+func WithStrictValidation() DecoderOption {
+	return func(d *Decoder) {
+		d.strict = true
+	}
+}
+
+// WithTrustedFetch returns a DecoderOption that tells the decoder that the
+// fetch function only returns blocks whose hash it has already checked against
+// the requested reference; for example, a local store that verified every
+// block as it was written. The decoder then doesn't hash each block again,
+// which saves CPU time (and battery) on low-power devices.
+//
+// If the fetch function can return a corrupted or forged block, the decoder
+// will silently return the wrong content, so this must not be used with
+// remote or otherwise untrusted stores. By default, every block is verified.
+func WithTrustedFetch() DecoderOption {
+	return func(d *Decoder) {
+		d.trustedFetch = true
+	}
+}
+
+// WithLenientNodePadding returns a DecoderOption that skips reference-key
+// pairs with a zero reference wherever they appear in an internal node, and
+// decodes the pairs that follow them.
+//
+// The specification requires the reference-key pairs in an internal node to be
+// followed only by zeroes, and by default, a non-zero pair after a zero
+// reference is an error wrapping ErrInvalidNodePadding. This option is meant
+// for testing interoperability with other implementations that might produce
+// such nodes; it shouldn't be needed to decode content from a conforming
+// encoder.
+func WithLenientNodePadding() DecoderOption {
+	return func(d *Decoder) {
+		d.lenientPadding = true
+	}
 }
 
 // NewDecoder creates a new Decoder instance which will use the provided fetch
 // function to fetch encrypted blocks of data, starting at the root of the tree
 // as described by rc.
-func NewDecoder(fetch FetchFunc, rc ReadCapability) *Decoder {
-	return &Decoder{
+//
+// By default, the decoder will decode a tree of any size. Since a small
+// number of blocks can describe an enormous tree (for example, an internal
+// node whose children are all the same block), decoders for untrusted read
+// capabilities should set limits with WithMaxLevel, WithMaxBytes or
+// WithMaxBlocks.
+func NewDecoder(fetch FetchFunc, rc ReadCapability, opts ...DecoderOption) *Decoder {
+	d := &Decoder{
 		fetch: fetch,
 		rc:    rc,
 		buf:   make([]byte, rc.BlockSize),
 	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
 }
 
 // Next will fetch blocks of the ERIS-encoded tree and decode them until it
 // retrieves a block of the original content or until an error occurs.
 //
 // If an error occurs or decoding is finished, the function will return false.
-// The caller should call the Err method to check if an error occurred.
+// The caller should call the Err method to check if an error occurred; if
+// not, Finished will return true. Calling Next again after it has returned
+// false does nothing and returns false.
 //
 // If no error occurs and decoding is not finished, the function will return
 // true and the Block function can be called to retrieve the next block of the
-// original content.
+// original content, which is never empty. This means that for empty content,
+// the first call to Next returns false, and Finished returns true while
+// Offset returns 0.
 //
 // The provided Context will be passed to the fetch function.
 func (d *Decoder) Next(ctx context.Context) bool {
+	if d.trace.tracer == nil {
+		return d.next(ctx)
+	}
+
+	d.startSpan(ctx)
+	if d.next(ctx) {
+		return true
+	}
+	d.trace.end(d.err,
+		Attribute{"eris.blocks_fetched", d.blocksFetched},
+		Attribute{"eris.bytes", d.offset},
+	)
+	return false
+}
+
+// startSpan starts the decoder's span, if it is traced and the span hasn't
+// been started.
+func (d *Decoder) startSpan(ctx context.Context) {
+	d.trace.start(ctx, SpanDecode,
+		Attribute{"eris.block_size", int64(d.rc.BlockSize)},
+		Attribute{"eris.level", int64(d.rc.Level)},
+	)
+}
+
+// next implements Next.
+func (d *Decoder) next(ctx context.Context) bool {
 	if d.err != nil {
 		return false
 	}
 
 	if !d.didInit {
-		// Verify integrity of read capability key if level is larger
-		// than 0, and as a side effect, fill in the stack with the
-		// children of the root node.
-		//
-		// This is the Verify-Key function from the spec, inlined.
-		if d.rc.Level > 0 {
-			node, err := d.dereferenceNode(ctx, d.rc.Root, d.rc.Level)
-			if err != nil {
-				d.err = err
-				return false
-			}
-
-			// Verify integrity of key
-			gotHash := blake2b.Sum256(node)
-			if gotHash != d.rc.Root.Key {
-				d.err = ErrInvalidKey
-				return false
-			}
-
-			// Fill in the stack with the children of the root node.
-			if err := d.decodeInternalNode(node, d.rc.Level-1); err != nil {
-				d.err = err
-				return false
-			}
-		} else {
-			// Otherwise, the root node is also the (only) leaf
-			// node, and we can just set it directly in the stack.
-			d.stack = append(d.stack, decodeNode{
-				ref:   d.rc.Root,
-				level: 0,
-			})
+		if err := d.init(ctx); err != nil {
+			d.err = err
+			return false
 		}
-
-		d.didInit = true
 	}
 
 	// Continue searching until we find a leaf node or exhaust the stack.
@@ -131,17 +286,23 @@ func (d *Decoder) Next(ctx context.Context) bool {
 			panic("invalid level")
 		}
 
-		// Fetch the node and decrypt it.
-		buf, err := d.dereferenceNode(ctx, curr.ref, curr.level)
-		if err != nil {
-			d.err = err
-			return false
+		// Fetch the node and decrypt it, unless SkipTo already did.
+		buf := d.finalLeaf
+		d.finalLeaf = nil
+		if buf == nil {
+			var err error
+			buf, err = d.dereferenceNode(ctx, curr.ref, curr.level)
+			if err != nil {
+				d.err = err
+				return false
+			}
 		}
 
 		// If this node is a leaf node (with level 0), then we have
 		// some content that we can output.
 		if curr.level == 0 {
 			d.block = buf
+			d.leafIndex++
 
 			// If this is the last block, then we need to unpad it.
 			if isFinal {
@@ -151,24 +312,36 @@ func (d *Decoder) Next(ctx context.Context) bool {
 					d.err = err
 					return false
 				}
+			}
 
-				// If we unpadded the block to zero length, then we're
-				// done and have nothing left to do.
-				//
-				// Technically we could return true here and
-				// let the caller observe a zero-length
-				// Block(), but it's easier to just return
-				// false given that we know we're done.
-				if len(d.block) == 0 {
-					return false
-				}
+			// If SkipTo landed in the middle of this block, then
+			// drop the content preceding the requested offset.
+			if d.skip > 0 {
+				d.block = d.block[min(d.skip, len(d.block)):]
+				d.skip = 0
+			}
+
+			// If we unpadded the block to zero length, then we're
+			// done and have nothing left to do.
+			//
+			// Technically we could return true here and let the
+			// caller observe a zero-length Block(), but it's easier
+			// to just return false given that we know we're done.
+			if len(d.block) == 0 {
+				d.finished = true
+				return false
+			}
+			if d.maxBytes > 0 && d.offset+int64(len(d.block)) > d.maxBytes {
+				d.err = &LimitError{Limit: LimitBytes, Max: d.maxBytes}
+				return false
 			}
+			d.offset += int64(len(d.block))
 			return true
 		}
 
 		// Otherwise, this is an intermediate node, so we need to
 		// process all children of this node.
-		if err := d.decodeInternalNode(buf, curr.level-1); err != nil {
+		if err := d.decodeInternalNode(buf, curr.ref, curr.level-1, isFinal); err != nil {
 			d.err = err
 			return false
 		}
@@ -186,20 +359,196 @@ func (d *Decoder) Next(ctx context.Context) bool {
 
 	// If we reach this point, then we've exhausted the stack and there
 	// are no more nodes to process.
+	d.finished = true
 	return false
 }
 
+// init verifies the integrity of the read capability key if the level is
+// larger than 0, and as a side effect, fills in the stack with the children of
+// the root node.
+//
+// This is the Verify-Key function from the spec, inlined.
+func (d *Decoder) init(ctx context.Context) error {
+	if d.maxLevel > 0 && d.rc.Level > d.maxLevel {
+		return &LimitError{Limit: LimitLevel, Max: int64(d.maxLevel)}
+	}
+
+	if d.rc.Level > 0 {
+		node, err := d.dereferenceNode(ctx, d.rc.Root, d.rc.Level)
+		if err != nil {
+			return err
+		}
+
+		// Verify integrity of key
+		if !verifyNodeKey(node, d.rc.Root.Key) {
+			return ErrInvalidKey
+		}
+
+		// Fill in the stack with the children of the root node.
+		if err := d.decodeInternalNode(node, d.rc.Root, d.rc.Level-1, true); err != nil {
+			return err
+		}
+		if d.strict && len(d.stack) < 2 {
+			return fmt.Errorf("%w: root node has %d children", ErrMalformedTree, len(d.stack))
+		}
+	} else {
+		// Otherwise, the root node is also the (only) leaf node, and
+		// we can just set it directly in the stack.
+		d.stack = append(d.stack, decodeNode{
+			ref:   d.rc.Root,
+			level: 0,
+		})
+	}
+
+	d.didInit = true
+	return nil
+}
+
+// SkipTo advances the decoder so that the next call to Next returns content
+// starting at the given offset in the original content. Only the internal
+// nodes on the path to the leaf containing offset are fetched; the leaves
+// preceding it are skipped entirely, which allows resuming an interrupted
+// download without re-fetching content that was already retrieved.
+//
+// The offset must not be less than the current Offset. If the offset is at or
+// past the end of the content, Offset is set to the end of the content and
+// the next call to Next will return false; to find where the content ends,
+// the final leaf is fetched when the offset falls in or after it.
+//
+// The provided Context will be passed to the fetch function.
+func (d *Decoder) SkipTo(ctx context.Context, offset int64) error {
+	if d.err != nil {
+		return d.err
+	}
+	if offset < d.offset {
+		return fmt.Errorf("cannot skip backwards from offset %d to %d", d.offset, offset)
+	}
+	d.startSpan(ctx)
+	if !d.didInit {
+		if err := d.init(ctx); err != nil {
+			d.err = err
+			return err
+		}
+	}
+
+	blockSize := int64(d.rc.BlockSize)
+	arity := int64(arity(d.rc.BlockSize))
+	target := offset / blockSize
+
+	for len(d.stack) > 0 {
+		lastIdx := len(d.stack) - 1
+		curr := d.stack[lastIdx]
+
+		// If the target leaf isn't underneath this node, then we can
+		// skip the whole subtree. Every node that isn't on the
+		// right-most edge of the tree is full, so we know exactly how
+		// many leaves it covers. The right-most node is never skipped,
+		// so that we end up at the final leaf if the target is past
+		// the end of the content.
+		span := leavesPerNode(arity, curr.level)
+		if target-d.leafIndex >= span && lastIdx > 0 {
+			d.stack = d.stack[:lastIdx]
+			d.leafIndex += span
+			continue
+		}
+
+		// If this is a leaf, then it's the one we're looking for and
+		// the next call to Next will return it.
+		if curr.level == 0 {
+			break
+		}
+
+		// Otherwise, the target is somewhere underneath this internal
+		// node; fetch it and descend.
+		d.stack = d.stack[:lastIdx]
+		node, err := d.dereferenceNode(ctx, curr.ref, curr.level)
+		if err != nil {
+			d.err = err
+			return err
+		}
+		if err := d.decodeInternalNode(node, curr.ref, curr.level-1, len(d.stack) == 0); err != nil {
+			d.err = err
+			return err
+		}
+	}
+
+	// If the stack was already exhausted, then Next has returned all of
+	// the content and the current offset is the end of it.
+	if len(d.stack) == 0 {
+		return nil
+	}
+
+	// If the target is in or past the final leaf, then fetch it to find
+	// out where the content ends, since it may end before the target.
+	skip := offset - d.leafIndex*blockSize
+	if len(d.stack) == 1 && skip > 0 {
+		leaf, err := d.dereferenceNode(ctx, d.stack[0].ref, 0)
+		if err != nil {
+			d.err = err
+			return err
+		}
+		content, err := removePadding(leaf, d.rc.BlockSize)
+		if err != nil {
+			d.err = err
+			return err
+		}
+		if end := d.leafIndex*blockSize + int64(len(content)); offset >= end {
+			d.stack = d.stack[:0]
+			d.leafIndex++
+			d.skip = 0
+			d.offset = end
+			return nil
+		}
+		d.finalLeaf = leaf
+	}
+
+	// Record how much of the target leaf needs to be skipped when it's
+	// returned by Next.
+	d.skip = int(skip)
+	d.offset = offset
+	return nil
+}
+
+// leavesPerNode returns the number of leaves underneath a full node at the
+// given level of a tree with the given arity, saturating at math.MaxInt64.
+func leavesPerNode(arity int64, level int) int64 {
+	n := int64(1)
+	for i := 0; i < level; i++ {
+		if n > math.MaxInt64/arity {
+			return math.MaxInt64
+		}
+		n *= arity
+	}
+	return n
+}
+
 // decodeInternalNode will decode an internal node and push all children onto
-// the stack.
-func (d *Decoder) decodeInternalNode(node []byte, atLevel int) error {
+// the stack. The ref is the reference-key pair of the node, and rightmost is
+// whether it is the right-most node at its level in the tree.
+func (d *Decoder) decodeInternalNode(node []byte, ref ReferenceKeyPair, atLevel int, rightmost bool) error {
 	if extraChecks && atLevel < 0 {
 		panic("invalid level")
 	}
 
-	refs, err := decodeInternalNode(node, d.rc.BlockSize)
+	// In strict mode, check the key before decoding, since decoding
+	// doesn't modify the node.
+	if d.strict && !verifyNodeKey(node, ref.Key) {
+		return fmt.Errorf("%w: key of node %v is not the hash of its contents", ErrMalformedTree, ref.Reference)
+	}
+
+	refs, err := appendInternalNodeLenient(d.refs[:0], node, d.rc.BlockSize, d.lenientPadding)
 	if err != nil {
 		return err
 	}
+	d.refs = refs
+	if d.strict {
+		if len(refs) == 0 {
+			return fmt.Errorf("%w: node %v has no children", ErrMalformedTree, ref.Reference)
+		}
+		if !rightmost && len(refs) != arity(d.rc.BlockSize) {
+			return fmt.Errorf("%w: non-final node %v has %d children", ErrMalformedTree, ref.Reference, len(refs))
+		}
+	}
 
 	// Push all children onto the stack in reverse order. This ensures
 	// we process them in left-to-right order when popping.
@@ -213,14 +562,39 @@ func (d *Decoder) decodeInternalNode(node []byte, atLevel int) error {
 }
 
 func (d *Decoder) dereferenceNode(ctx context.Context, ref ReferenceKeyPair, level int) ([]byte, error) {
-	return dereferenceNode(
+	if d.maxBlocks > 0 && d.blocksFetched >= d.maxBlocks {
+		return nil, &LimitError{Limit: LimitBlocks, Max: d.maxBlocks}
+	}
+
+	var span Span
+	if d.trace.span != nil {
+		span = d.trace.tracer.StartSpan(ctx, SpanFetch, d.trace.span,
+			Attribute{"eris.level", int64(level)},
+			Attribute{"eris.reference", ref.Reference.String()},
+		)
+	}
+	node, err := fetchNode(
 		ctx,
 		d.fetch,
 		d.buf,
 		ref,
 		level,
 		d.rc.BlockSize,
+		!d.trustedFetch,
 	)
+	if span != nil {
+		span.End(err)
+	}
+	if d.logConsumed {
+		d.recordConsumed(ref.Reference, level, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	d.blocksFetched++
+	d.level = level
+	return node, nil
 }
 
 // Block returns the next block of the original content.
@@ -241,3 +615,34 @@ func (d *Decoder) Block() []byte {
 func (d *Decoder) Err() error {
 	return d.err
 }
+
+// Finished reports whether the decoder has successfully returned all of the
+// content; i.e. whether Next has returned false without an error. It returns
+// false before the first call to Next, while there is content remaining, and
+// after an error.
+func (d *Decoder) Finished() bool {
+	return d.finished && d.err == nil
+}
+
+// Offset returns the offset in the original content immediately following
+// the current Block; equivalently, it is the total number of bytes of content
+// that have been returned by the decoder so far.
+//
+// This can be used to report progress, or to record how far a download got
+// before it was interrupted.
+func (d *Decoder) Offset() int64 {
+	return d.offset
+}
+
+// BlocksFetched returns the number of blocks, including both leaf and internal
+// nodes, that the decoder has fetched and decrypted so far.
+func (d *Decoder) BlocksFetched() int64 {
+	return d.blocksFetched
+}
+
+// Level returns the level in the ERIS tree of the node that the decoder most
+// recently fetched; leaf nodes are at level 0. Before the first call to Next,
+// it returns 0.
+func (d *Decoder) Level() int {
+	return d.level
+}
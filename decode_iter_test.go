@@ -0,0 +1,580 @@
+package eris
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/chacha20"
+)
+
+// encodeToMap encodes content with the given block size and a zero
+// convergence secret, and returns the read capability along with a map of all
+// blocks keyed by their reference.
+func encodeToMap(t testing.TB, content []byte, blockSize int) (ReadCapability, map[Reference][]byte) {
+	t.Helper()
+
+	var secret [ConvergenceSecretSize]byte
+	blocks := make(map[Reference][]byte)
+	enc := NewEncoder(bytes.NewReader(content), secret, blockSize)
+	for enc.Next() {
+		blocks[enc.Reference()] = enc.Block()
+	}
+	if err := enc.Err(); err != nil {
+		t.Fatalf("error encoding: %v", err)
+	}
+	return enc.Capability(), blocks
+}
+
+// mapFetch returns a FetchFunc that fetches blocks from the given map, and
+// increments *calls (if non-nil) on every call.
+func mapFetch(blocks map[Reference][]byte, calls *int) FetchFunc {
+	return func(_ context.Context, ref Reference, buf []byte) ([]byte, error) {
+		if calls != nil {
+			*calls++
+		}
+		block, ok := blocks[ref]
+		if !ok {
+			return nil, fmt.Errorf("block %v not found", ref)
+		}
+		return append(buf[:0], block...), nil
+	}
+}
+
+// randomContent returns size bytes of deterministic pseudo-random content.
+func randomContent(size int) []byte {
+	content := make([]byte, size)
+	rand.New(rand.NewSource(int64(size))).Read(content)
+	return content
+}
+
+func TestDecoder_SkipTo(t *testing.T) {
+	// 300 1KiB leaves gives us a tree of level 3 (arity 16), which
+	// exercises skipping entire subtrees at multiple levels.
+	const blockSize = 1024
+	content := randomContent(300*blockSize + 123)
+	rc, blocks := encodeToMap(t, content, blockSize)
+	if rc.Level != 3 {
+		t.Fatalf("unexpected level: %d", rc.Level)
+	}
+
+	offsets := []int64{
+		0,
+		1,
+		blockSize - 1,
+		blockSize,
+		17 * blockSize,
+		17*blockSize + 500,
+		256*blockSize + 1,
+		int64(len(content)) - 1,
+		int64(len(content)),
+		int64(len(content)) + 1000,
+	}
+	for _, offset := range offsets {
+		t.Run(fmt.Sprint(offset), func(t *testing.T) {
+			ctx := context.Background()
+			var calls int
+			dec := NewDecoder(mapFetch(blocks, &calls), rc)
+			if err := dec.SkipTo(ctx, offset); err != nil {
+				t.Fatalf("SkipTo: %v", err)
+			}
+
+			// We should fetch at most the internal nodes on the
+			// path to the target leaf, and the final leaf if the
+			// target is in or past it.
+			maxCalls := rc.Level
+			if offset > 300*blockSize {
+				maxCalls++
+			}
+			if calls > maxCalls {
+				t.Errorf("SkipTo made %d fetch calls, want at most %d", calls, maxCalls)
+			}
+			if want := min(offset, int64(len(content))); dec.Offset() != want {
+				t.Errorf("Offset() = %d, want %d", dec.Offset(), want)
+			}
+
+			var got []byte
+			for dec.Next(ctx) {
+				got = append(got, dec.Block()...)
+			}
+			if err := dec.Err(); err != nil {
+				t.Fatalf("error decoding: %v", err)
+			}
+
+			want := content[min(offset, int64(len(content))):]
+			if !bytes.Equal(got, want) {
+				t.Errorf("decoded %d bytes, want %d", len(got), len(want))
+			}
+		})
+	}
+
+	t.Run("AfterNext", func(t *testing.T) {
+		ctx := context.Background()
+		dec := NewDecoder(mapFetch(blocks, nil), rc)
+		if !dec.Next(ctx) {
+			t.Fatalf("Next: %v", dec.Err())
+		}
+		if err := dec.SkipTo(ctx, 0); err == nil {
+			t.Errorf("expected error skipping backwards")
+		}
+
+		const offset = 100*blockSize + 7
+		if err := dec.SkipTo(ctx, offset); err != nil {
+			t.Fatalf("SkipTo: %v", err)
+		}
+		if !dec.Next(ctx) {
+			t.Fatalf("Next: %v", dec.Err())
+		}
+		if want := content[offset : 101*blockSize]; !bytes.Equal(dec.Block(), want) {
+			t.Errorf("block mismatch after SkipTo")
+		}
+	})
+
+	t.Run("PastEndAfterNext", func(t *testing.T) {
+		ctx := context.Background()
+		dec := NewDecoder(mapFetch(blocks, nil), rc)
+		for dec.Next(ctx) {
+		}
+		if err := dec.SkipTo(ctx, int64(len(content))+1000); err != nil {
+			t.Fatalf("SkipTo: %v", err)
+		}
+		if dec.Offset() != int64(len(content)) {
+			t.Errorf("Offset() = %d, want %d", dec.Offset(), len(content))
+		}
+		if dec.Next(ctx) {
+			t.Errorf("Next returned true past the end of the content")
+		}
+	})
+}
+
+// selfSimilarTree builds a tree of the given level in which every internal
+// node refers to the same child for all of its slots, so that a handful of
+// blocks describe an enormous amount of content.
+func selfSimilarTree(t *testing.T, level int) (ReadCapability, map[Reference][]byte) {
+	t.Helper()
+	var secret [ConvergenceSecretSize]byte
+	blocks := make(map[Reference][]byte)
+
+	leaf := make([]byte, 1024)
+	padBlock(leaf, 1000, 1024)
+	block, refKey := encryptLeafNode(leaf, secret)
+	blocks[refKey.Reference] = block
+
+	for l := 1; l <= level; l++ {
+		var node []byte
+		for i := 0; i < arity(1024); i++ {
+			node = append(node, refKey.Reference[:]...)
+			node = append(node, refKey.Key[:]...)
+		}
+		block, refKey = encryptInternalNode(node, l, secret)
+		blocks[refKey.Reference] = block
+	}
+	return ReadCapability{BlockSize: 1024, Level: level, Root: refKey}, blocks
+}
+
+func TestDecoder_Limits(t *testing.T) {
+	rc, blocks := selfSimilarTree(t, 20)
+
+	testCases := []struct {
+		name      string
+		opt       DecoderOption
+		wantLimit Limit
+		maxCalls  int
+	}{
+		{"level", WithMaxLevel(10), LimitLevel, 0},
+		{"bytes", WithMaxBytes(100 * 1024), LimitBytes, 200},
+		{"blocks", WithMaxBlocks(500), LimitBlocks, 500},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var calls int
+			dec := NewDecoder(mapFetch(blocks, &calls), rc, tc.opt)
+			for dec.Next(context.Background()) {
+			}
+
+			err := dec.Err()
+			if !errors.Is(err, ErrLimitExceeded) {
+				t.Fatalf("got error %v, want ErrLimitExceeded", err)
+			}
+			var le *LimitError
+			if !errors.As(err, &le) || le.Limit != tc.wantLimit {
+				t.Errorf("got error %v, want a %v limit error", err, tc.wantLimit)
+			}
+			if calls > tc.maxCalls {
+				t.Errorf("fetched %d blocks, want at most %d", calls, tc.maxCalls)
+			}
+		})
+	}
+}
+
+func TestDecoder_LimitsNotExceeded(t *testing.T) {
+	content := randomContent(10000)
+	rc, blocks := encodeToMap(t, content, 1024)
+
+	dec := NewDecoder(mapFetch(blocks, nil), rc,
+		WithMaxLevel(rc.Level),
+		WithMaxBytes(int64(len(content))),
+		WithMaxBlocks(int64(len(blocks))),
+	)
+	var got []byte
+	for dec.Next(context.Background()) {
+		got = append(got, dec.Block()...)
+	}
+	if err := dec.Err(); err != nil {
+		t.Fatalf("decoding: %v", err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Errorf("content mismatch")
+	}
+}
+
+// treeBuilder builds hand-crafted trees of 1KiB blocks for testing how the
+// decoder handles unusual or malformed structures.
+type treeBuilder struct {
+	blocks map[Reference][]byte
+	n      int
+}
+
+func newTreeBuilder() *treeBuilder {
+	return &treeBuilder{blocks: make(map[Reference][]byte)}
+}
+
+// leaf adds a leaf with unique content; if final is set, the leaf is padded.
+func (b *treeBuilder) leaf(final bool) ReferenceKeyPair {
+	b.n++
+	content := bytes.Repeat([]byte{byte(b.n)}, 1024)
+	if final {
+		padBlock(content, 100, 1024)
+	}
+	block, refKey := encryptLeafNode(content, [ConvergenceSecretSize]byte{})
+	b.blocks[refKey.Reference] = block
+	return refKey
+}
+
+// node adds an internal node at the given level with the given children. If
+// key is non-nil, the node is encrypted with that key instead of the hash of
+// its contents.
+func (b *treeBuilder) node(level int, key *Key, children ...ReferenceKeyPair) ReferenceKeyPair {
+	var node []byte
+	for _, child := range children {
+		node = append(node, child.Reference[:]...)
+		node = append(node, child.Key[:]...)
+	}
+	node = appendPadWithZeroes(node, 1024)
+
+	if key == nil {
+		block, refKey := encryptInternalNode(node, level, [ConvergenceSecretSize]byte{})
+		b.blocks[refKey.Reference] = block
+		return refKey
+	}
+
+	var nonce [chacha20.NonceSize]byte
+	nonce[0] = byte(level)
+	cipher, _ := chacha20.NewUnauthenticatedCipher(key[:], nonce[:])
+	block := make([]byte, len(node))
+	cipher.XORKeyStream(block, node)
+	refKey := ReferenceKeyPair{Reference: blake2b.Sum256(block), Key: *key}
+	b.blocks[refKey.Reference] = block
+	return refKey
+}
+
+// leaves adds n leaves, the last of which is final if final is set.
+func (b *treeBuilder) leaves(n int, final bool) []ReferenceKeyPair {
+	var refs []ReferenceKeyPair
+	for i := 0; i < n; i++ {
+		refs = append(refs, b.leaf(final && i == n-1))
+	}
+	return refs
+}
+
+func TestDecoder_StrictValidation(t *testing.T) {
+	testCases := []struct {
+		name  string
+		build func(b *treeBuilder) ReadCapability
+	}{
+		{
+			name: "internal node key is not its hash",
+			build: func(b *treeBuilder) ReadCapability {
+				badKey := Key{1, 2, 3}
+				first := b.node(1, &badKey, b.leaves(16, false)...)
+				second := b.node(1, nil, b.leaves(2, true)...)
+				return ReadCapability{BlockSize: 1024, Level: 2, Root: b.node(2, nil, first, second)}
+			},
+		},
+		{
+			name: "non-final node is not full",
+			build: func(b *treeBuilder) ReadCapability {
+				first := b.node(1, nil, b.leaves(15, false)...)
+				second := b.node(1, nil, b.leaves(2, true)...)
+				return ReadCapability{BlockSize: 1024, Level: 2, Root: b.node(2, nil, first, second)}
+			},
+		},
+		{
+			name: "root has a single child",
+			build: func(b *treeBuilder) ReadCapability {
+				child := b.node(1, nil, b.leaves(3, true)...)
+				return ReadCapability{BlockSize: 1024, Level: 2, Root: b.node(2, nil, child)}
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			b := newTreeBuilder()
+			rc := tc.build(b)
+
+			// These trees can be decoded normally...
+			dec := NewDecoder(mapFetch(b.blocks, nil), rc)
+			for dec.Next(context.Background()) {
+			}
+			if err := dec.Err(); err != nil {
+				t.Fatalf("non-strict decode: %v", err)
+			}
+
+			// ... but not in strict mode.
+			dec = NewDecoder(mapFetch(b.blocks, nil), rc, WithStrictValidation())
+			for dec.Next(context.Background()) {
+			}
+			if err := dec.Err(); !errors.Is(err, ErrMalformedTree) {
+				t.Errorf("strict decode: got error %v, want ErrMalformedTree", err)
+			}
+		})
+	}
+}
+
+func TestDecoder_StrictValidationValidTrees(t *testing.T) {
+	for _, size := range []int{0, 1, 1024, 16 * 1024, 17 * 1024, 300 * 1024} {
+		content := randomContent(size)
+		rc, blocks := encodeToMap(t, content, 1024)
+
+		dec := NewDecoder(mapFetch(blocks, nil), rc, WithStrictValidation())
+		var got []byte
+		for dec.Next(context.Background()) {
+			got = append(got, dec.Block()...)
+		}
+		if err := dec.Err(); err != nil {
+			t.Fatalf("size %d: %v", size, err)
+		}
+		if !bytes.Equal(got, content) {
+			t.Errorf("size %d: content mismatch", size)
+		}
+
+		// Skipping around the tree also checks the nodes it visits.
+		dec = NewDecoder(mapFetch(blocks, nil), rc, WithStrictValidation())
+		if err := dec.SkipTo(context.Background(), int64(size/2)); err != nil {
+			t.Errorf("size %d: SkipTo: %v", size, err)
+		}
+	}
+}
+
+func TestDecoder_Finished(t *testing.T) {
+	for _, size := range []int{0, 1, 1023, 1024, 5000} {
+		rc, blocks := encodeToMap(t, randomContent(size), 1024)
+
+		var calls int
+		dec := NewDecoder(mapFetch(blocks, &calls), rc)
+		if dec.Finished() {
+			t.Errorf("size %d: Finished before first call to Next", size)
+		}
+
+		var n int
+		for dec.Next(context.Background()) {
+			if len(dec.Block()) == 0 {
+				t.Errorf("size %d: Next returned an empty block", size)
+			}
+			if dec.Finished() {
+				t.Errorf("size %d: Finished while content remains", size)
+			}
+			n += len(dec.Block())
+		}
+		if err := dec.Err(); err != nil {
+			t.Fatalf("size %d: %v", size, err)
+		}
+		if !dec.Finished() || n != size || dec.Offset() != int64(size) {
+			t.Errorf("size %d: Finished = %v after %d bytes (offset %d)", size, dec.Finished(), n, dec.Offset())
+		}
+
+		// Calling Next again after finishing is a no-op.
+		fetched := calls
+		if dec.Next(context.Background()) || !dec.Finished() || calls != fetched {
+			t.Errorf("size %d: Next after finishing changed the decoder's state", size)
+		}
+	}
+}
+
+func TestDecoder_FinishedAfterError(t *testing.T) {
+	rc, _ := encodeToMap(t, randomContent(5000), 1024)
+	dec := NewDecoder(mapFetch(map[Reference][]byte{}, nil), rc)
+	if dec.Next(context.Background()) {
+		t.Fatal("Next succeeded with no blocks")
+	}
+	if dec.Err() == nil || dec.Finished() {
+		t.Errorf("Err = %v, Finished = %v; want an error and not finished", dec.Err(), dec.Finished())
+	}
+}
+
+func TestDecoder_NoAllocs(t *testing.T) {
+	// 2000 1KiB leaves gives a tree of level 3, so that Next regularly
+	// fetches and decodes internal nodes between leaves.
+	const blockSize = 1024
+	rc, blocks := encodeToMap(t, randomContent(2000*blockSize), blockSize)
+	ctx := context.Background()
+
+	for _, tc := range []struct {
+		name string
+		opts []DecoderOption
+	}{
+		{"default", nil},
+		{"strict", []DecoderOption{WithStrictValidation(), WithMaxBytes(1 << 30)}},
+		{"trusted", []DecoderOption{WithTrustedFetch()}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			// mapFetch copies into the provided buffer, so it
+			// doesn't allocate.
+			dec := NewDecoder(mapFetch(blocks, nil), rc, tc.opts...)
+
+			// The first call initializes the decoder and grows the
+			// stack to its maximum depth; after that, decoding
+			// should not allocate at all.
+			if !dec.Next(ctx) {
+				t.Fatalf("unexpected end of content: %v", dec.Err())
+			}
+			//
+			// AllocsPerRun rounds down, so decode a full internal
+			// node's worth of leaves in each run to ensure that an
+			// allocation per internal node is caught.
+			allocs := testing.AllocsPerRun(100, func() {
+				for i := 0; i < arity(blockSize); i++ {
+					if !dec.Next(ctx) {
+						t.Fatalf("unexpected end of content: %v", dec.Err())
+					}
+				}
+			})
+			if allocs > 0 {
+				t.Errorf("unexpected allocations per internal node: %f", allocs)
+			}
+		})
+	}
+}
+
+// TestDecoder_GiantContent checks that offsets are computed correctly for
+// content far larger than 4GiB, which would overflow 32-bit sizes.
+func TestDecoder_GiantContent(t *testing.T) {
+	// A level 9 tree with 1KiB blocks has 16^9 leaves, or 64TiB of
+	// content; every leaf is the same, and all but the last are full.
+	rc, blocks := selfSimilarTree(t, 9)
+	const leaves = int64(1) << 36
+	const size = (leaves-1)*1024 + 1000
+
+	for _, offset := range []int64{1<<32 + 5, 1<<40 + 1023, size - 10} {
+		var calls int
+		dec := NewDecoder(mapFetch(blocks, &calls), rc)
+		if err := dec.SkipTo(context.Background(), offset); err != nil {
+			t.Fatalf("SkipTo(%d): %v", offset, err)
+		}
+		if !dec.Next(context.Background()) {
+			t.Fatalf("Next after SkipTo(%d): %v", offset, dec.Err())
+		}
+
+		// Only the path to the leaf is fetched.
+		if calls != rc.Level+1 {
+			t.Errorf("SkipTo(%d): fetched %d blocks, want %d", offset, calls, rc.Level+1)
+		}
+
+		leafEnd := min((offset/1024+1)*1024, size)
+		if got, want := int64(len(dec.Block())), leafEnd-offset; got != want {
+			t.Errorf("SkipTo(%d): got %d bytes, want %d", offset, got, want)
+		}
+		if dec.Offset() != leafEnd {
+			t.Errorf("SkipTo(%d): Offset() = %d, want %d", offset, dec.Offset(), leafEnd)
+		}
+	}
+}
+
+func TestDecoder_TrustedFetch(t *testing.T) {
+	ctx := context.Background()
+	content := randomContent(50 * 1024)
+	rc, blocks := encodeToMap(t, content, 1024)
+
+	decode := func(opts ...DecoderOption) ([]byte, error) {
+		var out []byte
+		dec := NewDecoder(mapFetch(blocks, nil), rc, opts...)
+		for dec.Next(ctx) {
+			out = append(out, dec.Block()...)
+		}
+		return out, dec.Err()
+	}
+
+	got, err := decode(WithTrustedFetch())
+	if err != nil || !bytes.Equal(got, content) {
+		t.Fatalf("trusted decode failed: %v", err)
+	}
+
+	// Corrupt the first leaf. The default decoder detects it, but a decoder that
+	// trusts its fetch function doesn't hash the block, so it returns
+	// the wrong content.
+	var leaf Reference
+	Walk(ctx, mapFetch(blocks, nil), rc, func(ref ReferenceKeyPair, level int) error {
+		if level == 0 && leaf.isZero() {
+			leaf = ref.Reference
+		}
+		return nil
+	})
+	blocks[leaf][0] ^= 1
+
+	if _, err := decode(); !errors.Is(err, ErrInvalidBlock) {
+		t.Errorf("default decode: got %v, want ErrInvalidBlock", err)
+	}
+	if got, err := decode(WithTrustedFetch()); err != nil || bytes.Equal(got, content) {
+		t.Errorf("trusted decode: got %v; expected corrupted content", err)
+	}
+}
+
+func TestDecoder_LenientNodePadding(t *testing.T) {
+	ctx := context.Background()
+	content := randomContent(3000)
+	var secret [ConvergenceSecretSize]byte
+
+	// Build a tree by hand whose root has a zero reference-key pair
+	// between its first and second children.
+	blocks := make(map[Reference][]byte)
+	node := make([]byte, BlockSizeSmall)
+	pos := 0
+	for i := 0; i < len(content); i += BlockSizeSmall {
+		leaf := make([]byte, BlockSizeSmall)
+		padBlock(leaf, copy(leaf, content[i:]), BlockSizeSmall)
+		block, refKey := encryptLeafNode(leaf, secret)
+		blocks[refKey.Reference] = block
+
+		copy(node[pos:], refKey.Reference[:])
+		copy(node[pos+ReferenceSize:], refKey.Key[:])
+		pos += referenceKeyLen
+		if i == 0 {
+			pos += referenceKeyLen
+		}
+	}
+	block, root := encryptInternalNode(node, 1, secret)
+	blocks[root.Reference] = block
+	rc := ReadCapability{BlockSize: BlockSizeSmall, Level: 1, Root: root}
+
+	decode := func(opts ...DecoderOption) ([]byte, error) {
+		var out []byte
+		dec := NewDecoder(mapFetch(blocks, nil), rc, opts...)
+		for dec.Next(ctx) {
+			out = append(out, dec.Block()...)
+		}
+		return out, dec.Err()
+	}
+
+	_, err := decode()
+	if !errors.Is(err, ErrInvalidNodePadding) || !errors.Is(err, ErrInvalidPadding) {
+		t.Errorf("default decode: got %v, want %v", err, ErrInvalidNodePadding)
+	}
+	got, err := decode(WithLenientNodePadding())
+	if err != nil || !bytes.Equal(got, content) {
+		t.Errorf("lenient decode: %v", err)
+	}
+}
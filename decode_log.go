@@ -0,0 +1,90 @@
+package eris
+
+import (
+	"errors"
+	"fmt"
+)
+
+// BlockStatus describes what happened when a Decoder fetched a block; see
+// WithConsumedLog.
+type BlockStatus int
+
+const (
+	// BlockVerified means that the block was fetched and its hash matched
+	// its reference.
+	BlockVerified BlockStatus = iota + 1
+	// BlockUnverified means that the block was fetched, but its hash
+	// wasn't checked because the decoder was created with
+	// WithTrustedFetch.
+	BlockUnverified
+	// BlockInvalid means that the block was fetched, but had the wrong
+	// size or its hash didn't match its reference.
+	BlockInvalid
+	// BlockUnavailable means that the fetch function returned an error.
+	BlockUnavailable
+)
+
+// String implements the fmt.Stringer interface.
+func (s BlockStatus) String() string {
+	switch s {
+	case BlockVerified:
+		return "verified"
+	case BlockUnverified:
+		return "unverified"
+	case BlockInvalid:
+		return "invalid"
+	case BlockUnavailable:
+		return "unavailable"
+	default:
+		return fmt.Sprintf("BlockStatus(%d)", int(s))
+	}
+}
+
+// ConsumedBlock records a block that was fetched by a Decoder; see
+// WithConsumedLog.
+type ConsumedBlock struct {
+	// Reference is the reference of the block.
+	Reference Reference
+	// Level is the level of the block in the tree; 0 for leaf nodes.
+	Level int
+	// Status is the outcome of fetching the block.
+	Status BlockStatus
+}
+
+// WithConsumedLog returns a DecoderOption that records every block the
+// decoder fetches, and whether it was verified, for retrieval with
+// Decoder.Consumed once decoding has finished. Together with the decoded
+// content, this certifies exactly which blocks the content was assembled from;
+// for example, for an audit log of downloads.
+//
+// A block is recorded each time it is fetched, so blocks that appear more than
+// once in the tree are recorded more than once. Blocks skipped over by SkipTo
+// are never fetched, and so aren't recorded. The log grows by one entry per
+// block, so it uses memory in proportion to the size of the content.
+func WithConsumedLog() DecoderOption {
+	return func(d *Decoder) {
+		d.logConsumed = true
+	}
+}
+
+// Consumed returns the blocks fetched by the decoder so far, in the order that
+// they were fetched, if it was created with WithConsumedLog. If decoding
+// failed because of a block, that block is the last one in the log.
+func (d *Decoder) Consumed() []ConsumedBlock {
+	return d.consumed
+}
+
+// recordConsumed adds a block to the log, given the error (if any) returned
+// when fetching it.
+func (d *Decoder) recordConsumed(ref Reference, level int, err error) {
+	status := BlockVerified
+	switch {
+	case errors.Is(err, ErrInvalidBlock), errors.Is(err, ErrInvalidBlockSize):
+		status = BlockInvalid
+	case err != nil:
+		status = BlockUnavailable
+	case d.trustedFetch:
+		status = BlockUnverified
+	}
+	d.consumed = append(d.consumed, ConsumedBlock{Reference: ref, Level: level, Status: status})
+}
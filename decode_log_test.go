@@ -0,0 +1,76 @@
+package eris
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func TestDecoder_ConsumedLog(t *testing.T) {
+	ctx := context.Background()
+	content := randomContent(50 * 1024)
+	rc, blocks := encodeToMap(t, content, 1024)
+
+	// The log should list every block in the tree, in the order that
+	// Walk visits them.
+	var want []ConsumedBlock
+	Walk(ctx, mapFetch(blocks, nil), rc, func(ref ReferenceKeyPair, level int) error {
+		want = append(want, ConsumedBlock{Reference: ref.Reference, Level: level, Status: BlockVerified})
+		return nil
+	})
+
+	decode := func(opts ...DecoderOption) *Decoder {
+		dec := NewDecoder(mapFetch(blocks, nil), rc, append(opts, WithConsumedLog())...)
+		var out []byte
+		for dec.Next(ctx) {
+			out = append(out, dec.Block()...)
+		}
+		if dec.Err() == nil && !bytes.Equal(out, content) {
+			t.Fatal("decoded content does not match")
+		}
+		return dec
+	}
+
+	dec := decode()
+	if err := dec.Err(); err != nil {
+		t.Fatal(err)
+	}
+	got := dec.Consumed()
+	if len(got) != len(want) {
+		t.Fatalf("logged %d blocks, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("log[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	for _, c := range decode(WithTrustedFetch()).Consumed() {
+		if c.Status != BlockUnverified {
+			t.Fatalf("trusted decode logged %v, want %v", c.Status, BlockUnverified)
+		}
+	}
+
+	// A corrupt leaf ends the log, and the decode.
+	leaf := want[len(want)-1].Reference
+	blocks[leaf][0] ^= 1
+	dec = decode()
+	got = dec.Consumed()
+	if dec.Err() == nil || len(got) != len(want) {
+		t.Fatalf("decoding corrupt content: err %v, %d blocks logged", dec.Err(), len(got))
+	}
+	if last := got[len(got)-1]; last.Reference != leaf || last.Status != BlockInvalid {
+		t.Errorf("last logged block = %+v, want %v invalid", last, leaf)
+	}
+
+	// As does a missing one.
+	delete(blocks, leaf)
+	got = decode().Consumed()
+	if last := got[len(got)-1]; last.Reference != leaf || last.Status != BlockUnavailable {
+		t.Errorf("last logged block = %+v, want %v unavailable", last, leaf)
+	}
+
+	if got := NewDecoder(mapFetch(blocks, nil), rc).Consumed(); got != nil {
+		t.Errorf("Consumed without WithConsumedLog = %v, want nil", got)
+	}
+}
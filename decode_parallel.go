@@ -0,0 +1,133 @@
+package eris
+
+import (
+	"context"
+	"io"
+	"sync"
+)
+
+// leafJob is a single leaf node that needs to be fetched and written by a
+// DecodeToWriterAt worker.
+type leafJob struct {
+	ref   ReferenceKeyPair
+	index int64
+	final bool
+}
+
+// DecodeToWriterAt decodes the content of an ERIS tree rooted at rc and
+// writes it to w, fetching and decrypting up to concurrency leaf blocks in
+// parallel. Since leaves are written at their offset in the content as they
+// complete, they may be written out of order.
+//
+// The internal nodes of the tree are fetched sequentially to discover the
+// leaves; since there are far fewer internal nodes than leaves, this is
+// rarely a bottleneck.
+//
+// The fetch function is called concurrently from multiple goroutines and must
+// be safe for concurrent use. If concurrency is less than 1, a single worker is
+// used.
+//
+// On success, DecodeToWriterAt returns the size of the decoded content. If an
+// error occurs, some content may already have been written to w.
+func DecodeToWriterAt(ctx context.Context, fetch FetchFunc, rc ReadCapability, w io.WriterAt, concurrency int) (int64, error) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		firstErr error
+		size     int64
+	)
+	setErr := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if firstErr == nil {
+			firstErr = err
+			cancel()
+		}
+	}
+
+	jobs := make(chan leafJob, concurrency)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			buf := make([]byte, rc.BlockSize)
+			for job := range jobs {
+				n, err := decodeLeafTo(ctx, fetch, buf, job, rc.BlockSize, w)
+				if err != nil {
+					setErr(err)
+					continue
+				}
+				if job.final {
+					mu.Lock()
+					size = job.index*int64(rc.BlockSize) + int64(n)
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+
+	// Walk the internal nodes of the tree, sending leaves to the workers
+	// in order.
+	err := walkLeaves(ctx, fetch, rc, func(job leafJob) error {
+		select {
+		case jobs <- job:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	close(jobs)
+	wg.Wait()
+
+	// Prefer the error from a worker, since a failing worker will cancel
+	// the context and cause the walk to fail with a less useful error.
+	if firstErr != nil {
+		return 0, firstErr
+	}
+	if err != nil {
+		return 0, err
+	}
+	return size, nil
+}
+
+// decodeLeafTo fetches and decrypts a single leaf, and writes it to w at the
+// correct offset. It returns the number of content bytes in the leaf.
+func decodeLeafTo(ctx context.Context, fetch FetchFunc, buf []byte, job leafJob, blockSize int, w io.WriterAt) (int, error) {
+	block, err := dereferenceNode(ctx, fetch, buf, job.ref, 0, blockSize)
+	if err != nil {
+		return 0, err
+	}
+	if job.final {
+		block, err = removePadding(block, blockSize)
+		if err != nil {
+			return 0, err
+		}
+	}
+	if _, err := w.WriteAt(block, job.index*int64(blockSize)); err != nil {
+		return 0, err
+	}
+	return len(block), nil
+}
+
+// walkLeaves traverses the ERIS tree rooted at rc, fetching only internal
+// nodes, and calls fn for each leaf in order. The final leaf is marked as
+// such.
+func walkLeaves(ctx context.Context, fetch FetchFunc, rc ReadCapability, fn func(leafJob) error) error {
+	buf := make([]byte, rc.BlockSize)
+	var index int64
+	return walkTree(rc, func(ref ReferenceKeyPair, level int, final bool) ([]byte, error) {
+		if level > 0 {
+			return dereferenceNode(ctx, fetch, buf, ref, level, rc.BlockSize)
+		}
+		job := leafJob{ref: ref, index: index, final: final}
+		index++
+		return nil, fn(job)
+	})
+}
@@ -0,0 +1,74 @@
+package eris
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+// writerAtBuffer is a simple in-memory io.WriterAt.
+type writerAtBuffer struct {
+	mu  sync.Mutex
+	buf []byte
+}
+
+func (w *writerAtBuffer) WriteAt(p []byte, off int64) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if end := int(off) + len(p); end > len(w.buf) {
+		w.buf = append(w.buf, make([]byte, end-len(w.buf))...)
+	}
+	return copy(w.buf[off:], p), nil
+}
+
+func TestDecodeToWriterAt(t *testing.T) {
+	for _, size := range []int{0, 1, 1024, 300*1024 + 123} {
+		content := randomContent(size)
+		rc, blocks := encodeToMap(t, content, 1024)
+
+		var mu sync.Mutex
+		fetch := func(ctx context.Context, ref Reference, buf []byte) ([]byte, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			return mapFetch(blocks, nil)(ctx, ref, buf)
+		}
+
+		for _, concurrency := range []int{0, 1, 8} {
+			w := &writerAtBuffer{}
+			n, err := DecodeToWriterAt(context.Background(), fetch, rc, w, concurrency)
+			if err != nil {
+				t.Fatalf("size=%d concurrency=%d: %v", size, concurrency, err)
+			}
+			if n != int64(size) {
+				t.Errorf("size=%d concurrency=%d: returned size %d", size, concurrency, n)
+			}
+			if !bytes.Equal(w.buf, content) {
+				t.Errorf("size=%d concurrency=%d: content mismatch", size, concurrency)
+			}
+		}
+	}
+}
+
+func TestDecodeToWriterAt_FetchError(t *testing.T) {
+	content := randomContent(100 * 1024)
+	rc, blocks := encodeToMap(t, content, 1024)
+
+	// Fail a single fetch, so that the decode fails part of the way
+	// through.
+	var calls int
+	wantErr := errors.New("missing")
+	fetch := func(ctx context.Context, ref Reference, buf []byte) ([]byte, error) {
+		calls++
+		if calls == 10 {
+			return nil, wantErr
+		}
+		return mapFetch(blocks, nil)(ctx, ref, buf)
+	}
+
+	_, err := DecodeToWriterAt(context.Background(), fetch, rc, &writerAtBuffer{}, 1)
+	if !errors.Is(err, wantErr) {
+		t.Errorf("got error %v, want %v", err, wantErr)
+	}
+}
@@ -0,0 +1,146 @@
+package eris
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Hole is a range of content that DecodePartial could not recover, because a
+// block was missing or corrupt.
+type Hole struct {
+	// Offset is the offset of the hole in the content.
+	Offset int64
+
+	// Size is the size of the hole. If the hole extends to the end of
+	// the content, its size can't be known; it is then -1, and nothing is
+	// written for it.
+	Size int64
+
+	// Reference and Level identify the block that couldn't be used. If
+	// it is an internal node, the hole covers every leaf underneath it.
+	Reference Reference
+	Level     int
+
+	// Err is the error from fetching or checking the block.
+	Err error
+}
+
+// PartialOptions contains options for DecodePartial.
+type PartialOptions struct {
+	// Fill is a pattern that is written in place of the content of each
+	// hole, repeated from the start of every block; for example, a
+	// marker that is easy to spot in a hex dump. If empty, holes are
+	// filled with zeroes.
+	Fill []byte
+}
+
+// PartialReport contains the results of DecodePartial.
+type PartialReport struct {
+	// Size is the number of bytes written, including the fill for holes.
+	Size int64
+
+	// Holes contains the ranges of content that couldn't be recovered,
+	// in order of their offset.
+	Holes []Hole
+
+	// Truncated is set if the end of the content couldn't be recovered,
+	// so that its size is unknown; the last hole then has a size of -1.
+	Truncated bool
+}
+
+// OK reports whether all of the content was recovered.
+func (r *PartialReport) OK() bool {
+	return len(r.Holes) == 0
+}
+
+// DecodePartial decodes the content of the ERIS tree rooted at rc and writes
+// it to w, like a Decoder, but doesn't stop at a block that is missing or
+// corrupt. Instead, the content that the block covers is written as a fill
+// pattern (see PartialOptions.Fill) and recorded as a hole in the returned
+// report, and decoding continues with the rest of the tree. This is meant for
+// recovering as much as possible of content that has been partly lost; the
+// content that is recovered is verified as usual, so it can be trusted.
+//
+// Every hole is filled with exactly the amount of content that it replaces,
+// so the recovered content is at the same offsets as in the original, except
+// that a hole at the end of the content, whose size isn't known, isn't filled
+// at all. A missing internal node can cover a great deal of content: up to
+// arity^level blocks.
+//
+// As with Verify, errors from ctx, and errors that mean that the tree itself is
+// malformed, such as an invalid root key, stop decoding and are returned along
+// with the report so far. Errors from w are also returned.
+func DecodePartial(ctx context.Context, fetch FetchFunc, rc ReadCapability, w io.Writer, opts PartialOptions) (PartialReport, error) {
+	var report PartialReport
+	bs := int64(rc.BlockSize)
+	buf := make([]byte, rc.BlockSize)
+	fill := make([]byte, rc.BlockSize)
+	if len(opts.Fill) > 0 {
+		for i := range fill {
+			fill[i] = opts.Fill[i%len(opts.Fill)]
+		}
+	}
+
+	write := func(p []byte) error {
+		n, err := w.Write(p)
+		report.Size += int64(n)
+		return err
+	}
+
+	// fetchNode fetches and decrypts a node, returning a nil node if the
+	// block is missing or corrupt, in which case it has been recorded as
+	// a hole and filled. The final node is the last one in the tree at
+	// its level, which covers the end of the content.
+	fetchNode := func(ref ReferenceKeyPair, level int, final bool) ([]byte, error) {
+		node, err := dereferenceNode(ctx, fetch, buf, ref, level, rc.BlockSize)
+		if err == nil {
+			return node, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		hole := Hole{Offset: report.Size, Reference: ref.Reference, Level: level, Err: err}
+		if final {
+			hole.Size = -1
+			report.Truncated = true
+			report.Holes = append(report.Holes, hole)
+			return nil, nil
+		}
+		leaves := leavesPerNode(int64(arity(rc.BlockSize)), level)
+		if leaves > math.MaxInt64/bs {
+			return nil, fmt.Errorf("hole at offset %d for block %v is too large", hole.Offset, ref.Reference)
+		}
+		hole.Size = leaves * bs
+		report.Holes = append(report.Holes, hole)
+		for i := int64(0); i < leaves; i++ {
+			if err := write(fill); err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	}
+
+	// writeLeaf writes the content of a leaf, which is unpadded if it is
+	// the final one.
+	writeLeaf := func(leaf []byte, final bool) error {
+		if final {
+			var err error
+			if leaf, err = removePadding(leaf, rc.BlockSize); err != nil {
+				return err
+			}
+		}
+		return write(leaf)
+	}
+
+	err := walkTree(rc, func(ref ReferenceKeyPair, level int, final bool) ([]byte, error) {
+		node, err := fetchNode(ref, level, final)
+		if err != nil || node == nil || level > 0 {
+			return node, err
+		}
+		return nil, writeLeaf(node, final)
+	})
+	return report, err
+}
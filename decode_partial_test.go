@@ -0,0 +1,81 @@
+package eris
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func TestDecodePartial(t *testing.T) {
+	ctx := context.Background()
+	content := randomContent(300*1024 + 1)
+	rc, blocks := encodeToMap(t, content, 1024)
+
+	var buf bytes.Buffer
+	report, err := DecodePartial(ctx, mapFetch(blocks, nil), rc, &buf, PartialOptions{})
+	if err != nil {
+		t.Fatalf("DecodePartial: %v", err)
+	}
+	if !report.OK() || report.Size != int64(len(content)) || !bytes.Equal(buf.Bytes(), content) {
+		t.Fatalf("report = %+v, want OK with %d bytes", report, len(content))
+	}
+
+	var leaves, level1 []Reference
+	Walk(ctx, mapFetch(blocks, nil), rc, func(ref ReferenceKeyPair, level int) error {
+		switch level {
+		case 0:
+			leaves = append(leaves, ref.Reference)
+		case 1:
+			level1 = append(level1, ref.Reference)
+		}
+		return nil
+	})
+
+	// Remove a leaf, the internal node above leaves 32 to 47, and the
+	// final leaf.
+	delete(blocks, leaves[10])
+	delete(blocks, level1[2])
+	delete(blocks, leaves[len(leaves)-1])
+
+	buf.Reset()
+	report, err = DecodePartial(ctx, mapFetch(blocks, nil), rc, &buf, PartialOptions{Fill: []byte("HOLE")})
+	if err != nil {
+		t.Fatalf("DecodePartial: %v", err)
+	}
+	if !report.Truncated || len(report.Holes) != 3 {
+		t.Fatalf("report = %+v, want 3 holes and truncated", report)
+	}
+	wantHoles := []Hole{
+		{Offset: 10 * 1024, Size: 1024, Reference: leaves[10], Level: 0},
+		{Offset: 32 * 1024, Size: 16 * 1024, Reference: level1[2], Level: 1},
+		{Offset: 300 * 1024, Size: -1, Reference: leaves[len(leaves)-1], Level: 0},
+	}
+	for i, h := range report.Holes {
+		want := wantHoles[i]
+		if h.Offset != want.Offset || h.Size != want.Size || h.Reference != want.Reference || h.Level != want.Level || h.Err == nil {
+			t.Errorf("hole %d = %+v, want %+v", i, h, want)
+		}
+	}
+
+	// The recovered content matches the original everywhere but the
+	// holes, which are filled with the pattern.
+	want := bytes.Clone(content[:300*1024])
+	copy(want[10*1024:11*1024], bytes.Repeat([]byte("HOLE"), 256))
+	copy(want[32*1024:48*1024], bytes.Repeat([]byte("HOLE"), 16*256))
+	if report.Size != int64(len(want)) || !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("recovered content doesn't match")
+	}
+}
+
+func TestDecodePartial_MissingRoot(t *testing.T) {
+	rc, _ := encodeToMap(t, randomContent(5000), 1024)
+
+	var buf bytes.Buffer
+	report, err := DecodePartial(context.Background(), mapFetch(nil, nil), rc, &buf, PartialOptions{})
+	if err != nil {
+		t.Fatalf("DecodePartial: %v", err)
+	}
+	if !report.Truncated || len(report.Holes) != 1 || report.Size != 0 || buf.Len() != 0 {
+		t.Errorf("report = %+v, want a single hole covering everything", report)
+	}
+}
@@ -0,0 +1,71 @@
+package eris
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func TestDecodeRecursive(t *testing.T) {
+	ctx := context.Background()
+	for _, tc := range []struct {
+		size, blockSize int
+	}{
+		{0, 1024},
+		{1023, 1024},
+		{1024, 1024},
+		{16 * 1024, 1024},
+		{300*1024 + 7, 1024},
+		{5000 * 1024, 1024},
+		{100 * 1024, 32 * 1024},
+	} {
+		content := randomContent(tc.size)
+		rc, blocks := encodeToMap(t, content, tc.blockSize)
+
+		var calls int
+		got, err := DecodeRecursive(ctx, mapFetch(blocks, &calls), rc)
+		if err != nil {
+			t.Fatalf("size %d: DecodeRecursive: %v", tc.size, err)
+		}
+		if !bytes.Equal(got, content) {
+			t.Errorf("size %d: decoded content mismatch", tc.size)
+		}
+
+		// Every block in the tree is fetched exactly once, including
+		// the root.
+		var treeBlocks int
+		err = walkReferences(ctx, mapFetch(blocks, nil), rc, func(ReferenceKeyPair, int) error {
+			treeBlocks++
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if calls != treeBlocks {
+			t.Errorf("size %d: fetched %d blocks, want %d", tc.size, calls, treeBlocks)
+		}
+	}
+}
+
+func TestDecodeRecursive_Errors(t *testing.T) {
+	ctx := context.Background()
+	rc, blocks := encodeToMap(t, randomContent(300*1024), 1024)
+
+	// A capability with the wrong key for the root is rejected.
+	bad := rc
+	bad.Root.Key[0] ^= 1
+	if _, err := DecodeRecursive(ctx, mapFetch(blocks, nil), bad); err == nil {
+		t.Error("expected error for invalid root key")
+	}
+
+	// A missing block fails decoding.
+	for ref := range blocks {
+		if ref != rc.Root.Reference {
+			delete(blocks, ref)
+			break
+		}
+	}
+	if _, err := DecodeRecursive(ctx, mapFetch(blocks, nil), rc); err == nil {
+		t.Error("expected error for missing block")
+	}
+}
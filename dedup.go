@@ -0,0 +1,116 @@
+package eris
+
+import "context"
+
+// DedupStats contains block statistics for a single tree analyzed by
+// AnalyzeDedup.
+type DedupStats struct {
+	// Blocks is the number of blocks referenced by the tree, counting
+	// each reference separately even if it refers to the same block.
+	Blocks int64
+	// UniqueBlocks is the number of distinct blocks in the tree.
+	UniqueBlocks int64
+	// Bytes is the total size of all blocks referenced by the tree.
+	Bytes int64
+	// UniqueBytes is the total size of the distinct blocks in the tree,
+	// which is the storage required to store just this tree.
+	UniqueBytes int64
+}
+
+// DedupReport contains the results of AnalyzeDedup.
+type DedupReport struct {
+	// Trees contains statistics for each analyzed tree, in the same
+	// order as the read capabilities passed to AnalyzeDedup.
+	Trees []DedupStats
+
+	// Total contains statistics for all of the trees together; i.e.
+	// Total.UniqueBytes is the storage required to store every tree.
+	Total DedupStats
+
+	// Shared is the overlap matrix between trees: Shared[i][j] is the
+	// number of distinct blocks that appear in both tree i and tree j.
+	// The diagonal Shared[i][i] is equal to Trees[i].UniqueBlocks.
+	Shared [][]int64
+}
+
+// SavedBytes returns the number of bytes saved by deduplicating blocks, both
+// within and across trees, compared to storing every block reference
+// separately.
+func (r *DedupReport) SavedBytes() int64 {
+	return r.Total.Bytes - r.Total.UniqueBytes
+}
+
+// SharedBytes returns the number of bytes saved by deduplicating blocks across
+// trees; that is, the difference between storing each tree separately and
+// storing them all in a single store.
+func (r *DedupReport) SharedBytes() int64 {
+	var separate int64
+	for _, t := range r.Trees {
+		separate += t.UniqueBytes
+	}
+	return separate - r.Total.UniqueBytes
+}
+
+// AnalyzeDedup walks the ERIS trees rooted at each of the given read
+// capabilities and computes statistics about how many blocks they share. This
+// can be used to quantify the benefit of convergent encryption with a shared
+// convergence secret: identical content encoded with the same secret and
+// block size produces identical blocks, which only need to be stored once.
+//
+// Only internal nodes are fetched; leaves are identified by their reference.
+// Memory usage is proportional to the number of distinct blocks.
+func AnalyzeDedup(ctx context.Context, fetch FetchFunc, rcs []ReadCapability) (DedupReport, error) {
+	report := DedupReport{
+		Trees:  make([]DedupStats, len(rcs)),
+		Shared: make([][]int64, len(rcs)),
+	}
+	for i := range report.Shared {
+		report.Shared[i] = make([]int64, len(rcs))
+	}
+
+	// For every distinct block, record the trees that contain it, in
+	// order and without duplicates.
+	type blockInfo struct {
+		size  int64
+		trees []int
+	}
+	blocks := make(map[Reference]*blockInfo)
+
+	for i, rc := range rcs {
+		stats := &report.Trees[i]
+		size := int64(rc.BlockSize)
+		err := walkReferences(ctx, fetch, rc, func(ref ReferenceKeyPair, _ int) error {
+			stats.Blocks++
+			stats.Bytes += size
+
+			info, ok := blocks[ref.Reference]
+			if !ok {
+				info = &blockInfo{size: size}
+				blocks[ref.Reference] = info
+			}
+			if n := len(info.trees); n == 0 || info.trees[n-1] != i {
+				info.trees = append(info.trees, i)
+				stats.UniqueBlocks++
+				stats.UniqueBytes += size
+			}
+			return nil
+		})
+		if err != nil {
+			return DedupReport{}, err
+		}
+
+		report.Total.Blocks += stats.Blocks
+		report.Total.Bytes += stats.Bytes
+	}
+
+	for _, info := range blocks {
+		report.Total.UniqueBlocks++
+		report.Total.UniqueBytes += info.size
+		for _, i := range info.trees {
+			for _, j := range info.trees {
+				report.Shared[i][j]++
+			}
+		}
+	}
+	return report, nil
+}
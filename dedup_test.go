@@ -0,0 +1,83 @@
+package eris
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func TestAnalyzeDedup(t *testing.T) {
+	// Two pieces of content that share their first 4 blocks, and a third
+	// that is unrelated.
+	shared := randomContent(4096)
+	rc1, blocks := encodeToMap(t, append(bytes.Clone(shared), 'a'), 1024)
+	rc2, blocks2 := encodeToMap(t, append(bytes.Clone(shared), 'b'), 1024)
+	rc3, blocks3 := encodeToMap(t, randomContent(2048), 1024)
+	for _, m := range []map[Reference][]byte{blocks2, blocks3} {
+		for ref, block := range m {
+			blocks[ref] = block
+		}
+	}
+
+	report, err := AnalyzeDedup(context.Background(), mapFetch(blocks, nil), []ReadCapability{rc1, rc2, rc3})
+	if err != nil {
+		t.Fatalf("AnalyzeDedup: %v", err)
+	}
+
+	// The first two trees have a root and 5 leaves each; the third has a
+	// root and 3 leaves.
+	wantTrees := []DedupStats{
+		{Blocks: 6, UniqueBlocks: 6, Bytes: 6 * 1024, UniqueBytes: 6 * 1024},
+		{Blocks: 6, UniqueBlocks: 6, Bytes: 6 * 1024, UniqueBytes: 6 * 1024},
+		{Blocks: 4, UniqueBlocks: 4, Bytes: 4 * 1024, UniqueBytes: 4 * 1024},
+	}
+	for i, want := range wantTrees {
+		if report.Trees[i] != want {
+			t.Errorf("Trees[%d] = %+v, want %+v", i, report.Trees[i], want)
+		}
+	}
+	if report.Total.UniqueBlocks != int64(len(blocks)) {
+		t.Errorf("Total.UniqueBlocks = %d, want %d", report.Total.UniqueBlocks, len(blocks))
+	}
+	if got := report.SavedBytes(); got != 4*1024 {
+		t.Errorf("SavedBytes = %d, want %d", got, 4*1024)
+	}
+	if got := report.SharedBytes(); got != 4*1024 {
+		t.Errorf("SharedBytes = %d, want %d", got, 4*1024)
+	}
+
+	wantShared := [][]int64{
+		{6, 4, 0},
+		{4, 6, 0},
+		{0, 0, 4},
+	}
+	for i := range wantShared {
+		for j := range wantShared[i] {
+			if report.Shared[i][j] != wantShared[i][j] {
+				t.Errorf("Shared[%d][%d] = %d, want %d", i, j, report.Shared[i][j], wantShared[i][j])
+			}
+		}
+	}
+}
+
+func TestAnalyzeDedup_WithinTree(t *testing.T) {
+	content := bytes.Repeat(randomContent(1024), 40)
+	rc, blocks := encodeToMap(t, content, 1024)
+
+	report, err := AnalyzeDedup(context.Background(), mapFetch(blocks, nil), []ReadCapability{rc})
+	if err != nil {
+		t.Fatalf("AnalyzeDedup: %v", err)
+	}
+	stats := report.Trees[0]
+	if stats.UniqueBlocks != int64(len(blocks)) {
+		t.Errorf("UniqueBlocks = %d, want %d", stats.UniqueBlocks, len(blocks))
+	}
+
+	// 1 root + 3 level-1 nodes + 41 leaves.
+	if stats.Blocks != 45 {
+		t.Errorf("Blocks = %d, want 45", stats.Blocks)
+	}
+	if report.SharedBytes() != 0 {
+		t.Errorf("SharedBytes = %d, want 0 for a single tree", report.SharedBytes())
+	}
+}
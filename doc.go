@@ -12,8 +12,32 @@
 // This package does not implement any storage layer, but only concerns itself
 // with the encoding and decoding of content. Users of this package are
 // expected to implement their own storage layer, which can be as simple as
-// files stored on-disk. Example(s) of how to use this package are provided in
-// the 'examples' directory.
+// files stored on-disk. The 'store' subpackage defines a common interface for
+// block stores along with some simple implementations. Example(s) of how to
+// use this package are provided in the 'examples' directory.
+//
+// # Timing safety
+//
+// The convergence secret and the keys in read capabilities and internal nodes
+// are secret; references and block contents are not, since anyone who can
+// see the stored blocks already knows them. Comparisons involving secrets take
+// constant time: the Equal methods on Key, Reference, ReferenceKeyPair and
+// ReadCapability, the verification of internal node keys while decoding, and
+// Challenge.Check. Encryption and hashing use the constant-time
+// implementations in x/crypto.
+//
+// Other operations are not timing-safe, and may take time that depends on
+// secret data; in particular, the time taken to encode content depends on how
+// many of its blocks are duplicates, and the time taken to decode content
+// depends on its size. Lookups of blocks by reference (for example, in the
+// store package) are also not constant-time, since references are public.
+//
+// # WebAssembly
+//
+// This package and the store subpackage can be compiled to WebAssembly with
+// GOOS=js or GOOS=wasip1, and with TinyGo. Under TinyGo, SniffContentType is
+// not available, since it depends on net/http. The 'examples/wasm' directory
+// contains an example of decoding ERIS URNs in a web browser.
 //
 // This package intentionally does not have any dependencies other than Go's
 // x/crypto library for cryptographic primitives.
@@ -0,0 +1,189 @@
+package eris
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// DumpFormat is the output format used by DumpTree.
+type DumpFormat int
+
+const (
+	// DumpText prints one node per line, indented by depth.
+	DumpText DumpFormat = iota
+	// DumpDOT prints a Graphviz DOT graph of the tree.
+	DumpDOT
+)
+
+// DumpOptions contains options for DumpTree and DumpTrees.
+type DumpOptions struct {
+	// Format is the output format; the default is DumpText.
+	Format DumpFormat
+
+	// ShowKeys causes the key of every node to be printed along with its
+	// reference. By default keys are redacted, so that the output can be
+	// shared without granting access to the content.
+	ShowKeys bool
+
+	// OmitLeaves causes leaf nodes to be left out of the output, which is
+	// useful for large trees.
+	OmitLeaves bool
+}
+
+// DumpTree prints the structure of the ERIS tree rooted at rc to w, for
+// debugging. For every node, it prints the node's level and reference, and
+// for internal nodes, how many of the node's slots are filled. Only internal
+// nodes are fetched.
+//
+// A subtree that has already been printed (for example, because the content
+// contains repeated data) is only printed once; later occurrences are marked
+// as duplicates.
+func DumpTree(ctx context.Context, fetch FetchFunc, rc ReadCapability, w io.Writer, opts DumpOptions) error {
+	return DumpTrees(ctx, fetch, []ReadCapability{rc}, w, opts)
+}
+
+// DumpTrees is like DumpTree, but prints the trees of several read
+// capabilities. Subtrees that are shared between the trees are only printed
+// once, so this can be used to visualize how content is deduplicated.
+func DumpTrees(ctx context.Context, fetch FetchFunc, rcs []ReadCapability, w io.Writer, opts DumpOptions) error {
+	bw := bufio.NewWriter(w)
+	d := &treeDumper{
+		fetch: fetch,
+		opts:  opts,
+		w:     bw,
+		seen:  make(map[Reference]bool),
+	}
+
+	if opts.Format == DumpDOT {
+		fmt.Fprintln(bw, "digraph eris {")
+		fmt.Fprintln(bw, "\tnode [shape=box, fontname=\"monospace\"];")
+	}
+	for i, rc := range rcs {
+		if err := d.dumpTree(ctx, i, rc); err != nil {
+			return err
+		}
+	}
+	if opts.Format == DumpDOT {
+		fmt.Fprintln(bw, "}")
+	}
+	return bw.Flush()
+}
+
+// treeDumper holds the state for DumpTrees.
+type treeDumper struct {
+	fetch FetchFunc
+	opts  DumpOptions
+	w     *bufio.Writer
+	seen  map[Reference]bool
+	buf   []byte
+}
+
+// dumpTree prints the tree of a single read capability, which is the i-th
+// one passed to DumpTrees.
+func (d *treeDumper) dumpTree(ctx context.Context, i int, rc ReadCapability) error {
+	if rc.BlockSize <= 0 || rc.BlockSize%referenceKeyLen != 0 {
+		return ErrInvalidBlockSize
+	}
+	if len(d.buf) < rc.BlockSize {
+		d.buf = make([]byte, rc.BlockSize)
+	}
+
+	switch d.opts.Format {
+	case DumpDOT:
+		label := fmt.Sprintf("content %d\\nblock size %d", i, rc.BlockSize)
+		fmt.Fprintf(d.w, "\t\"cap%d\" [label=\"%s\", shape=note];\n", i, label)
+		fmt.Fprintf(d.w, "\t\"cap%d\" -> \"%v\";\n", i, rc.Root.Reference)
+	default:
+		if i > 0 {
+			fmt.Fprintln(d.w)
+		}
+		fmt.Fprintf(d.w, "content %d: block size %d, level %d\n", i, rc.BlockSize, rc.Level)
+	}
+	return d.dumpNode(ctx, rc, rc.Root, rc.Level, 1, true)
+}
+
+// dumpNode prints a single node and, if it hasn't already been printed, all
+// of its children.
+func (d *treeDumper) dumpNode(ctx context.Context, rc ReadCapability, ref ReferenceKeyPair, level, depth int, isRoot bool) error {
+	if level == 0 && d.opts.OmitLeaves {
+		return nil
+	}
+
+	duplicate := d.seen[ref.Reference]
+	d.seen[ref.Reference] = true
+
+	var children []ReferenceKeyPair
+	if level > 0 && !duplicate {
+		node, err := dereferenceNode(ctx, d.fetch, d.buf, ref, level, rc.BlockSize)
+		if err != nil {
+			return fmt.Errorf("fetching node %v at level %d: %w", ref.Reference, level, err)
+		}
+		if isRoot && !verifyNodeKey(node, rc.Root.Key) {
+			return ErrInvalidKey
+		}
+		children, err = decodeInternalNode(node, rc.BlockSize)
+		if err != nil {
+			return fmt.Errorf("decoding node %v at level %d: %w", ref.Reference, level, err)
+		}
+	}
+
+	switch d.opts.Format {
+	case DumpDOT:
+		d.dotNode(ref, level, len(children), rc.BlockSize, duplicate)
+	default:
+		d.textNode(ref, level, len(children), rc.BlockSize, depth, duplicate)
+	}
+
+	for _, child := range children {
+		if d.opts.Format == DumpDOT && !(level == 1 && d.opts.OmitLeaves) {
+			fmt.Fprintf(d.w, "\t\"%v\" -> \"%v\";\n", ref.Reference, child.Reference)
+		}
+		if err := d.dumpNode(ctx, rc, child, level-1, depth+1, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// textNode prints a node in the DumpText format.
+func (d *treeDumper) textNode(ref ReferenceKeyPair, level, numChildren, blockSize, depth int, duplicate bool) {
+	var sb strings.Builder
+	sb.WriteString(strings.Repeat("  ", depth))
+	fmt.Fprintf(&sb, "level %d ref %v", level, ref.Reference)
+	if d.opts.ShowKeys {
+		fmt.Fprintf(&sb, " key %v", ref.Key)
+	}
+	switch {
+	case duplicate:
+		sb.WriteString(" (duplicate)")
+	case level > 0:
+		fmt.Fprintf(&sb, " fill %d/%d", numChildren, arity(blockSize))
+	}
+	fmt.Fprintln(d.w, sb.String())
+}
+
+// dotNode prints a node in the DumpDOT format. Since every node is
+// identified by its reference, duplicate nodes are only declared once.
+func (d *treeDumper) dotNode(ref ReferenceKeyPair, level, numChildren, blockSize int, duplicate bool) {
+	if duplicate {
+		return
+	}
+
+	// Abbreviate the reference to keep the graph readable; the full
+	// reference is used as the node ID.
+	label := fmt.Sprintf("L%d %.16v", level, ref.Reference)
+	if d.opts.ShowKeys {
+		label += fmt.Sprintf("\\nkey %.16v", ref.Key)
+	}
+	if level > 0 {
+		label += fmt.Sprintf("\\n%d/%d", numChildren, arity(blockSize))
+	}
+	shape := "box"
+	if level == 0 {
+		shape = "ellipse"
+	}
+	fmt.Fprintf(d.w, "\t\"%v\" [label=\"%s\", shape=%s];\n", ref.Reference, label, shape)
+}
@@ -0,0 +1,83 @@
+package eris
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestDumpTree(t *testing.T) {
+	// Repeat a single block of content, so that most leaves (and some
+	// internal nodes) are duplicates.
+	content := bytes.Repeat(randomContent(1024), 40)
+	rc, blocks := encodeToMap(t, content, 1024)
+
+	var buf bytes.Buffer
+	if err := DumpTree(context.Background(), mapFetch(blocks, nil), rc, &buf, DumpOptions{}); err != nil {
+		t.Fatalf("DumpTree: %v", err)
+	}
+	out := buf.String()
+	t.Logf("output:\n%s", out)
+
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if !strings.HasPrefix(lines[0], "content 0: block size 1024, level 2") {
+		t.Errorf("unexpected header: %q", lines[0])
+	}
+	if !strings.Contains(lines[1], "level 2 ref "+rc.Root.Reference.String()+" fill 3/16") {
+		t.Errorf("unexpected root line: %q", lines[1])
+	}
+
+	// The first two level-1 nodes are identical, so the second one's
+	// leaves aren't printed: 1 header + 1 root + 3 level-1 nodes + 16
+	// leaves under the first + 9 under the last.
+	if len(lines) != 30 {
+		t.Errorf("got %d lines, want 30", len(lines))
+	}
+	if !strings.Contains(out, "(duplicate)") {
+		t.Errorf("expected duplicate leaves to be marked")
+	}
+	if strings.Contains(out, "key") {
+		t.Errorf("keys should be redacted by default")
+	}
+
+	buf.Reset()
+	err := DumpTree(context.Background(), mapFetch(blocks, nil), rc, &buf, DumpOptions{ShowKeys: true, OmitLeaves: true})
+	if err != nil {
+		t.Fatalf("DumpTree: %v", err)
+	}
+	if !strings.Contains(buf.String(), "key "+rc.Root.Key.String()) {
+		t.Errorf("expected root key in output:\n%s", buf.String())
+	}
+	if strings.Contains(buf.String(), "level 0") {
+		t.Errorf("expected leaves to be omitted:\n%s", buf.String())
+	}
+}
+
+func TestDumpTrees_DOT(t *testing.T) {
+	shared := randomContent(4096)
+	rc1, blocks := encodeToMap(t, append(append([]byte{}, shared...), 'a'), 1024)
+	rc2, blocks2 := encodeToMap(t, append(append([]byte{}, shared...), 'b'), 1024)
+	for ref, block := range blocks2 {
+		blocks[ref] = block
+	}
+
+	var buf bytes.Buffer
+	err := DumpTrees(context.Background(), mapFetch(blocks, nil), []ReadCapability{rc1, rc2}, &buf, DumpOptions{Format: DumpDOT})
+	if err != nil {
+		t.Fatalf("DumpTrees: %v", err)
+	}
+	out := buf.String()
+	t.Logf("output:\n%s", out)
+
+	if !strings.HasPrefix(out, "digraph eris {\n") || !strings.HasSuffix(out, "}\n") {
+		t.Errorf("output is not a DOT graph")
+	}
+
+	// Every block is declared exactly once, even though the first four
+	// leaves are shared between both trees.
+	decls := strings.Count(out, "[label=\"L")
+	if decls != len(blocks) {
+		t.Errorf("got %d node declarations, want %d", decls, len(blocks))
+	}
+}
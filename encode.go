@@ -1,7 +1,9 @@
 package eris
 
 import (
+	"context"
 	"fmt"
+	"hash"
 	"io"
 
 	"golang.org/x/crypto/blake2b"
@@ -42,6 +44,10 @@ type Encoder struct {
 	// currRef is the current reference of the block of data that was encoded.
 	currRef Reference
 
+	// currLevel is the level in the tree of the current block of data
+	// that was encoded; 0 for leaf nodes.
+	currLevel int
+
 	// level is the current level of the ERIS tree.
 	level int
 
@@ -69,10 +75,107 @@ type Encoder struct {
 
 	// internalNodePos is the current position in internalNodes that we're constructing.
 	internalNodePos int
+
+	// discardBlocks is set when the caller only wants the read capability
+	// for the content; no blocks are emitted, and the set of seen blocks
+	// isn't tracked.
+	discardBlocks bool
+
+	// sizePadding, if non-nil, is the policy used to pad the content
+	// before encoding it; see WithSizePadding.
+	sizePadding PaddingPolicy
+
+	// observer, if non-nil, is called for every block; see
+	// WithBlockObserver.
+	observer BlockObserver
+
+	// index, if non-nil, records the leaves of the tree; see WithIndex.
+	index *Index
+
+	// leafLog, if non-nil, records the leaves of the tree; see
+	// WithLeafLog.
+	leafLog *leafLogger
+
+	// ctx, if non-nil, stops the encoder when it is done; see
+	// WithContext.
+	ctx context.Context
+
+	// contentHash, if non-nil, is written the content as it is read;
+	// see WithContentHash.
+	contentHash hash.Hash
+
+	// concurrency is the number of blocks to encrypt in parallel; see
+	// WithConcurrency.
+	concurrency int
+
+	// batch is the current batch of blocks that were encrypted in
+	// parallel, and batchPos is the position of the next block in it
+	// to process. They are only used if concurrency is greater than 1.
+	batch    []batchBlock
+	batchPos int
+
+	// stats counts the blocks that have been constructed; see Stats.
+	stats EncoderStats
+
+	// trace is the span reported to the tracer given with
+	// WithEncoderTracer, if any.
+	trace tracedOp
 }
 
-func NewEncoder(content io.Reader, secret [ConvergenceSecretSize]byte, blockSize int) *Encoder {
-	return &Encoder{
+// EncoderStats contains counts of the blocks constructed by an Encoder; see
+// Encoder.Stats.
+type EncoderStats struct {
+	// Leaves and InternalNodes are the number of leaf and internal nodes
+	// in the tree, including duplicates.
+	Leaves        int64
+	InternalNodes int64
+
+	// DuplicateLeaves and DuplicateInternalNodes are the number of those
+	// nodes that were identical to an earlier node, and so weren't
+	// returned by Next.
+	DuplicateLeaves        int64
+	DuplicateInternalNodes int64
+}
+
+// Emitted returns the number of blocks that were returned by Next: the
+// distinct blocks of the tree.
+func (s EncoderStats) Emitted() int64 {
+	return s.Leaves + s.InternalNodes - s.DuplicateLeaves - s.DuplicateInternalNodes
+}
+
+// EncoderOption is an option that can be passed to NewEncoder to change how
+// content is encoded.
+type EncoderOption func(*Encoder)
+
+// BlockObserver is a function that is called by an Encoder for every block in
+// the ERIS tree; see WithBlockObserver.
+type BlockObserver func(ref Reference, level int, size int)
+
+// WithBlockObserver returns an EncoderOption that calls fn for every block in
+// the ERIS tree as it is encoded, including blocks that are duplicates of
+// earlier blocks and are therefore not returned by Next. This can be used to
+// build an index of which blocks make up which content without walking the
+// tree afterwards.
+//
+// The level is 0 for leaf nodes and 1 or more for internal nodes. The size is
+// the number of bytes in the block, not including padding: for a leaf, this
+// is the number of bytes of the original content that it contains, and for an
+// internal node, it is the number of bytes of reference-key pairs.
+//
+// The observer is called in the order that blocks are constructed: first
+// every leaf in the order of the content, and then each level of internal
+// nodes from left to right. It is called before the block is returned by
+// Next, and from the same goroutine.
+func WithBlockObserver(fn BlockObserver) EncoderOption {
+	return func(e *Encoder) {
+		e.observer = fn
+	}
+}
+
+// NewEncoder creates a new Encoder that encodes the given content with the
+// given convergence secret and block size.
+func NewEncoder(content io.Reader, secret [ConvergenceSecretSize]byte, blockSize int, opts ...EncoderOption) *Encoder {
+	e := &Encoder{
 		state:     0, // initial state
 		content:   content,
 		secret:    secret,
@@ -80,6 +183,46 @@ func NewEncoder(content io.Reader, secret [ConvergenceSecretSize]byte, blockSize
 		blocks:    make(map[Reference]bool),
 		level:     0, // level starts at 0
 	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	e.content = e.wrapContent(content)
+	return e
+}
+
+// wrapContent wraps the content to be encoded with the readers needed by the
+// encoder's options.
+func (e *Encoder) wrapContent(r io.Reader) io.Reader {
+	if e.ctx != nil {
+		r = newContextReader(e.ctx, r)
+	}
+	if e.contentHash != nil {
+		r = io.TeeReader(r, e.contentHash)
+	}
+	if e.sizePadding != nil {
+		r = newSizePaddingReader(r, e.sizePadding)
+	}
+	return r
+}
+
+// ComputeCapability computes the read capability for the given content
+// without emitting any blocks; it is equivalent to encoding the content with
+// an Encoder and discarding every block, but avoids tracking which blocks have
+// already been seen.
+//
+// This is useful when only the URN of some content is needed; for example, to
+// check whether the content has already been stored.
+func ComputeCapability(content io.Reader, secret [ConvergenceSecretSize]byte, blockSize int, opts ...EncoderOption) (ReadCapability, error) {
+	e := NewEncoder(content, secret, blockSize, opts...)
+	e.discardBlocks = true
+	for e.Next() {
+		// Next never returns true when discarding blocks, but loop
+		// anyway to be safe.
+	}
+	if err := e.Err(); err != nil {
+		return ReadCapability{}, err
+	}
+	return e.Capability(), nil
 }
 
 // reset will reset the encoder to its initial state, using the given reader
@@ -89,6 +232,11 @@ func NewEncoder(content io.Reader, secret [ConvergenceSecretSize]byte, blockSize
 // to consumers; we use it internally to reset the encoder when we're
 // doing benchmarks.
 func (e *Encoder) reset(r io.Reader) {
+	if e.contentHash != nil {
+		e.contentHash.Reset()
+	}
+	r = e.wrapContent(r)
+
 	e.state = 0
 	e.err = nil
 	e.content = r
@@ -102,10 +250,25 @@ func (e *Encoder) reset(r io.Reader) {
 	// Clear some other internal state that we may or may not have set.
 	e.currBlock = nil
 	e.currRef = Reference{}
+	e.currLevel = 0
 	e.referenceKeyPairs = e.referenceKeyPairs[:0]
 	e.rootRefKey = ReferenceKeyPair{}
 	e.internalNodes = e.internalNodes[:0]
 	e.internalNodePos = 0
+	clear(e.batch)
+	e.batch = e.batch[:0]
+	e.batchPos = 0
+	e.stats = EncoderStats{}
+	e.trace = tracedOp{tracer: e.trace.tracer}
+
+	if e.index != nil {
+		e.index.Size = 0
+		e.index.Leaves = e.index.Leaves[:0]
+	}
+	if e.leafLog != nil {
+		e.leafLog.log = e.leafLog.log[:0]
+		clear(e.leafLog.seen)
+	}
 
 	// Reset our splitter; we could also nil this out, but this avoids an
 	// allocation.
@@ -143,11 +306,65 @@ func (e *Encoder) Reference() Reference {
 	return e.currRef
 }
 
+// BlockLevel returns the level in the ERIS tree of the current block of data
+// that was encoded: 0 for a leaf node containing content, and 1 or more for an
+// internal node. Storage layers can use this to treat internal nodes
+// differently; for example, to replicate them more widely, since losing an
+// internal node makes all of the content underneath it unreadable.
+//
+// It is only valid to call this method after a call to the Next method has
+// returned true.
+func (e *Encoder) BlockLevel() int {
+	return e.currLevel
+}
+
 // Err returns the error that caused the encoder to stop, if any.
+//
+// An error returned by the content is wrapped in a *ReadError. The only other
+// errors are from the context given with WithContext, which are returned as
+// they are; violations of the encoder's internal invariants cause a panic
+// rather than an error.
 func (e *Encoder) Err() error {
 	return e.err
 }
 
+// ReadError is returned by an Encoder when reading its content fails. Since
+// an Encoder can't go back and reread content, such errors can only be
+// recovered from by encoding the content again, which may succeed if the error
+// was transient: either from the start, or from the last checkpoint taken with
+// Encoder.Checkpoint, using ResumeEncoder.
+type ReadError struct {
+	// Offset is the number of bytes of content that were read
+	// successfully before the error, including any before the
+	// checkpoint that the encoder was resumed from.
+	Offset int64
+	// Err is the error returned by the content's Read method.
+	Err error
+}
+
+// Error implements the error interface.
+func (e *ReadError) Error() string {
+	return fmt.Sprintf("reading content at offset %d: %v", e.Offset, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *ReadError) Unwrap() error {
+	return e.Err
+}
+
+// Stats returns counts of the blocks that the encoder has constructed so far,
+// including how many were duplicates of earlier blocks and so were skipped by
+// Next. It can be called at any time; once Next has returned false without an
+// error, it covers the whole tree.
+func (e *Encoder) Stats() EncoderStats {
+	return e.stats
+}
+
+// BlockSize returns the block size that the encoder is using.
+func (e *Encoder) BlockSize() int {
+	return e.blockSize
+}
+
 // Capability returns the read capability that can be used to read the encoded
 // data.
 //
@@ -183,9 +400,39 @@ const (
 // the Capability() method to get the read capability that can be used to read
 // the encoded data.
 func (e *Encoder) Next() bool {
+	if e.trace.tracer == nil {
+		return e.next()
+	}
+
+	ctx := e.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	e.trace.start(ctx, SpanEncode, Attribute{"eris.block_size", int64(e.blockSize)})
+	if e.next() {
+		return true
+	}
+	e.trace.end(e.err,
+		Attribute{"eris.level", int64(e.level)},
+		Attribute{"eris.leaves", e.stats.Leaves},
+		Attribute{"eris.internal_nodes", e.stats.InternalNodes},
+		Attribute{"eris.duplicate_leaves", e.stats.DuplicateLeaves},
+		Attribute{"eris.duplicate_internal_nodes", e.stats.DuplicateInternalNodes},
+	)
+	return false
+}
+
+// next implements Next.
+func (e *Encoder) next() bool {
 	if e.err != nil {
 		return false
 	}
+	if e.ctx != nil {
+		if err := e.ctx.Err(); err != nil {
+			e.err = err
+			return false
+		}
+	}
 
 	for {
 		var res stateRes
@@ -217,14 +464,28 @@ func (e *Encoder) Next() bool {
 // If the block has already been seen, this method will return false. If the
 // block hasn't been seen, it will be added to the set of seen blocks and
 // stored in e.currBlock, and the method will return true.
-func (e *Encoder) maybeEmitBlock(block []byte, ref Reference) bool {
+func (e *Encoder) maybeEmitBlock(block []byte, ref Reference, level int) bool {
+	if level == 0 {
+		e.stats.Leaves++
+	} else {
+		e.stats.InternalNodes++
+	}
+	if e.discardBlocks {
+		return false
+	}
 	if _, ok := e.blocks[ref]; ok {
+		if level == 0 {
+			e.stats.DuplicateLeaves++
+		} else {
+			e.stats.DuplicateInternalNodes++
+		}
 		return false
 	}
 
 	e.blocks[ref] = true
 	e.currBlock = block
 	e.currRef = ref
+	e.currLevel = level
 	return true
 }
 
@@ -235,11 +496,28 @@ func (e *Encoder) nextContent() stateRes {
 
 	// Repeatedly read blocks of data from our input until we get a block
 	// that we haven't seen yet.
-	for e.splitter.Next() {
-		data := e.splitter.Block()
+	for {
+		var (
+			block   []byte
+			refKey  ReferenceKeyPair
+			dataLen int
+		)
+		if e.concurrency > 1 {
+			if e.batchPos == len(e.batch) && !e.fillLeafBatch() {
+				break
+			}
+			b := &e.batch[e.batchPos]
+			e.batchPos++
+			block, refKey, dataLen = b.block, b.refKey, b.size
+		} else {
+			if !e.splitter.Next() {
+				break
+			}
 
-		// Encrypt the block
-		block, refKey := encryptLeafNode(data, e.secret)
+			// Encrypt the block
+			block, refKey = encryptLeafNode(e.splitter.Block(), e.secret)
+			dataLen = e.splitter.ContentLen()
+		}
 
 		// Add the reference-key pair to the list of reference-key pairs. We
 		// need to do this even if we've already seen this block, since the
@@ -247,8 +525,19 @@ func (e *Encoder) nextContent() stateRes {
 		// tree.
 		e.referenceKeyPairs = append(e.referenceKeyPairs, refKey)
 
+		if e.observer != nil {
+			e.observer(refKey.Reference, 0, dataLen)
+		}
+		if e.index != nil {
+			e.index.Leaves = append(e.index.Leaves, refKey)
+			e.index.Size += int64(dataLen)
+		}
+		if e.leafLog != nil {
+			e.leafLog.add(refKey.Reference, dataLen)
+		}
+
 		// If we have already seen this block, skip it.
-		if !e.maybeEmitBlock(block, refKey.Reference) {
+		if !e.maybeEmitBlock(block, refKey.Reference, 0) {
 			continue
 		}
 
@@ -258,7 +547,12 @@ func (e *Encoder) nextContent() stateRes {
 
 	// If we get here, we need to see if the splitter encountered an error.
 	if err := e.splitter.Err(); err != nil {
-		e.err = err
+		if e.ctx != nil && err == e.ctx.Err() {
+			// Stopped by WithContext, rather than by the content.
+			e.err = err
+		} else {
+			e.err = &ReadError{Offset: e.splitter.Offset(), Err: err}
+		}
 		return stateReturnFalse
 	}
 
@@ -313,16 +607,37 @@ func (e *Encoder) nextInternalNodes() stateRes {
 	// a block that we haven't seen before.
 	for i := e.internalNodePos; i < len(e.internalNodes); i++ {
 		node := e.internalNodes[i]
-		block, refKey := encryptInternalNode(node, e.level, e.secret)
+
+		var (
+			block  []byte
+			refKey ReferenceKeyPair
+		)
+		if e.concurrency > 1 {
+			// Batches never span levels, since every batch
+			// ends at or before the last node in the level.
+			if e.batchPos == len(e.batch) {
+				e.internalNodePos = i
+				e.fillInternalBatch()
+			}
+			b := &e.batch[e.batchPos]
+			e.batchPos++
+			block, refKey = b.block, b.refKey
+		} else {
+			block, refKey = encryptInternalNode(node, e.level, e.secret)
+		}
 
 		// TODO: can we zero out 'node' here to eagerly free memory?
 
 		// Add reference-key pair to list of reference-key pairs
 		e.referenceKeyPairs = append(e.referenceKeyPairs, refKey)
 
+		if e.observer != nil {
+			e.observer(refKey.Reference, e.level, internalNodeLen(node))
+		}
+
 		// If we have already seen this block, don't emit it and
 		// continue to generate the next block.
-		if !e.maybeEmitBlock(block, refKey.Reference) {
+		if !e.maybeEmitBlock(block, refKey.Reference, e.level) {
 			continue
 		}
 
@@ -332,12 +647,25 @@ func (e *Encoder) nextInternalNodes() stateRes {
 	}
 
 	// If we get here, we've finished generating all the blocks for the
-	// current level. Tell the caller to continue the state loop, which
+	// current level; the remaining nodes were all duplicates, so mark them
+	// as processed. Tell the caller to continue the state loop, which
 	// will call ourselves again to either move to the next level or
 	// finish.
+	e.internalNodePos = len(e.internalNodes)
 	return stateContinue
 }
 
+// internalNodeLen returns the number of bytes of reference-key pairs in an
+// unencrypted internal node; i.e. the length of the node without padding.
+func internalNodeLen(node []byte) int {
+	for i := 0; i < len(node); i += referenceKeyLen {
+		if Reference(node[i : i+ReferenceSize]).isZero() {
+			return i
+		}
+	}
+	return len(node)
+}
+
 // appendPadWithZeroes appends enough zero bytes to the given byte slice to
 // make it have a given length.
 func appendPadWithZeroes(buf []byte, length int) []byte {
@@ -383,23 +711,29 @@ func encryptLeafNode(node []byte, convergenceSecret [ConvergenceSecretSize]byte)
 		panic("keyed hash has wrong length")
 	}
 
+	block, refKey.Reference = encryptLeafWithKey(node, refKey.Key)
+
+	// All done!
+	return block, refKey
+}
+
+// encryptLeafWithKey encrypts the given leaf node with a key that has already
+// been derived from it, and returns the encrypted block and its reference.
+func encryptLeafWithKey(node []byte, key Key) (block []byte, ref Reference) {
 	// The nonce is 12 bytes of 0
 	var nonce [chacha20.NonceSize]byte
 
 	// Encrypt node to block.
 	//
 	// Per the ERIS spec, the 32 bit initial counter is set to null.
-	cipher, _ := chacha20.NewUnauthenticatedCipher(refKey.Key[:], nonce[:])
+	cipher, _ := chacha20.NewUnauthenticatedCipher(key[:], nonce[:])
 
 	// TODO: can we reuse the node buffer?
 	block = make([]byte, len(node))
 	cipher.XORKeyStream(block, node)
 
 	// Compute the reference to the encrypted block using unkeyed Blake2b
-	refKey.Reference = blake2b.Sum256(block)
-
-	// All done!
-	return block, refKey
+	return block, blake2b.Sum256(block)
 }
 
 // encryptInternalNode is used to encrypt internal nodes (level 1 and above).
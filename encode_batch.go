@@ -0,0 +1,111 @@
+package eris
+
+import (
+	"bytes"
+	"sync"
+)
+
+// batchBlocksPerWorker is the number of blocks that each worker encrypts per
+// batch when the encoder is using multiple workers. Larger batches amortize
+// the cost of starting workers, at the cost of buffering more content.
+const batchBlocksPerWorker = 4
+
+// WithConcurrency returns an EncoderOption that hashes and encrypts up to n
+// blocks in parallel.
+//
+// Every block at a given level of the ERIS tree can be encrypted independently
+// of the others, so the encoder reads a batch of blocks ahead of the ones
+// returned by Next, and encrypts the batch across n goroutines. Blocks are
+// still returned in the same order as a sequential encoder would return them,
+// and the resulting read capability is identical.
+//
+// The encoder buffers up to 4*n blocks of content at a time. If n is less than
+// 2, blocks are encrypted sequentially; this is the default.
+func WithConcurrency(n int) EncoderOption {
+	return func(e *Encoder) {
+		e.concurrency = n
+	}
+}
+
+// batchBlock is a single block in a batch of blocks that are encrypted in
+// parallel.
+type batchBlock struct {
+	// node is the unencrypted node.
+	node []byte
+	// size is the number of bytes of content in a leaf node, not
+	// including padding; it is unused for internal nodes.
+	size int
+
+	// block and refKey are the result of encrypting node.
+	block  []byte
+	refKey ReferenceKeyPair
+}
+
+// batchSize returns the maximum number of blocks in a batch.
+func (e *Encoder) batchSize() int {
+	return e.concurrency * batchBlocksPerWorker
+}
+
+// fillLeafBatch reads the next batch of leaf nodes from the splitter and
+// encrypts them. It returns false if no more content could be read.
+func (e *Encoder) fillLeafBatch() bool {
+	clear(e.batch)
+	e.batch = e.batch[:0]
+	e.batchPos = 0
+
+	for len(e.batch) < e.batchSize() && e.splitter.Next() {
+		// The splitter reuses its buffer, so we need to copy each
+		// block before reading the next one.
+		e.batch = append(e.batch, batchBlock{
+			node: bytes.Clone(e.splitter.Block()),
+			size: e.splitter.ContentLen(),
+		})
+	}
+	if len(e.batch) == 0 {
+		return false
+	}
+
+	encryptBatch(e.batch, 0, e.secret, e.concurrency)
+	return true
+}
+
+// fillInternalBatch encrypts the next batch of internal nodes, starting at
+// e.internalNodePos.
+func (e *Encoder) fillInternalBatch() {
+	clear(e.batch)
+	e.batch = e.batch[:0]
+	e.batchPos = 0
+
+	end := min(e.internalNodePos+e.batchSize(), len(e.internalNodes))
+	for _, node := range e.internalNodes[e.internalNodePos:end] {
+		e.batch = append(e.batch, batchBlock{node: node})
+	}
+	encryptBatch(e.batch, e.level, e.secret, e.concurrency)
+}
+
+// encryptBatch encrypts every node in batch using up to concurrency
+// goroutines. Leaf nodes are encrypted if level is 0, and internal nodes
+// otherwise.
+func encryptBatch(batch []batchBlock, level int, secret [ConvergenceSecretSize]byte, concurrency int) {
+	workers := min(concurrency, len(batch))
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			// Interleave blocks between workers; every block is
+			// the same size, so this is as good as anything else.
+			for i := w; i < len(batch); i += workers {
+				b := &batch[i]
+				if level == 0 {
+					b.block, b.refKey = encryptLeafNode(b.node, secret)
+				} else {
+					b.block, b.refKey = encryptInternalNode(b.node, level, secret)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
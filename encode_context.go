@@ -0,0 +1,67 @@
+package eris
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// WithContext returns an EncoderOption that stops the encoder when ctx is done:
+// the next call to Next returns false, and Err returns ctx.Err().
+//
+// The Encoder reads its content synchronously, so by default a read that never
+// returns (for example, from a network connection whose peer has gone away)
+// blocks Next forever, whatever happens to ctx. If the content has a
+// SetReadDeadline method, as net.Conn and os.File (for pipes and other pollable
+// files) do, the deadline is set to the past when ctx is done, which aborts a
+// read that is in progress. Otherwise, ctx is only checked between reads, and
+// a hung read must be interrupted some other way, such as by closing the
+// content. In either case, the read deadline is left as it is once the encoder
+// has stopped.
+func WithContext(ctx context.Context) EncoderOption {
+	return func(e *Encoder) {
+		e.ctx = ctx
+	}
+}
+
+// readDeadliner is implemented by readers whose reads can be interrupted with a
+// deadline.
+type readDeadliner interface {
+	SetReadDeadline(t time.Time) error
+}
+
+// contextReader is an io.Reader that stops reading when a context is done; see
+// WithContext.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+	dl  readDeadliner // nil if r doesn't support deadlines
+}
+
+func newContextReader(ctx context.Context, r io.Reader) *contextReader {
+	cr := &contextReader{ctx: ctx, r: r}
+	cr.dl, _ = r.(readDeadliner)
+	return cr
+}
+
+// Read implements the io.Reader interface.
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	if r.dl == nil {
+		return r.r.Read(p)
+	}
+
+	stop := context.AfterFunc(r.ctx, func() {
+		// Any time in the past will do.
+		r.dl.SetReadDeadline(time.Unix(1, 0))
+	})
+	n, err := r.r.Read(p)
+	if !stop() {
+		// The context was done during the read, which may have
+		// failed because of the deadline; report the real reason.
+		return n, r.ctx.Err()
+	}
+	return n, err
+}
@@ -0,0 +1,66 @@
+package eris
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestEncoder_WithContext(t *testing.T) {
+	content := randomContent(10000)
+	want, _ := encodeToMap(t, content, BlockSizeSmall)
+
+	enc := NewEncoder(bytes.NewReader(content), [ConvergenceSecretSize]byte{}, BlockSizeSmall, WithContext(context.Background()))
+	for enc.Next() {
+	}
+	if err := enc.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if got := enc.Capability(); !got.Equal(want) {
+		t.Errorf("capability = %v, want %v", got, want)
+	}
+}
+
+func TestEncoder_WithContextCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	content := randomContent(10000)
+	enc := NewEncoder(bytes.NewReader(content), [ConvergenceSecretSize]byte{}, BlockSizeSmall, WithContext(ctx))
+	if !enc.Next() {
+		t.Fatalf("first Next failed: %v", enc.Err())
+	}
+	cancel()
+	for enc.Next() {
+	}
+	if err := enc.Err(); !errors.Is(err, context.Canceled) {
+		t.Errorf("Err = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestEncoder_WithContextHungRead(t *testing.T) {
+	// Nothing is ever written to the pipe, so without the context the
+	// encoder would block forever.
+	r, w := net.Pipe()
+	defer r.Close()
+	defer w.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	enc := NewEncoder(r, [ConvergenceSecretSize]byte{}, BlockSizeSmall, WithContext(ctx))
+
+	done := make(chan bool)
+	go func() { done <- enc.Next() }()
+	select {
+	case ok := <-done:
+		if ok {
+			t.Fatal("Next returned true")
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Next did not return after the context was done")
+	}
+	if err := enc.Err(); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Err = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
@@ -0,0 +1,70 @@
+package eris
+
+import "io"
+
+// InputRange describes where one of the inputs to an Encoder created with
+// NewMultiEncoder lies in the encoded content.
+type InputRange struct {
+	// Input is the index of the input in the slice passed to
+	// NewMultiEncoder.
+	Input int
+	// Offset is the offset of the start of the input in the content.
+	Offset int64
+	// Size is the size of the input.
+	Size int64
+}
+
+// NewMultiEncoder creates an Encoder that encodes the concatenation of the
+// given inputs, as if they were combined with io.MultiReader, and calls
+// onInput with the range of the content that each input occupies once it has
+// been read to the end. This lets a single encode produce one read capability
+// for a whole set of files, such as an archive, while keeping track of which
+// bytes came from which file.
+//
+// Inputs are read in order, and onInput is called once for each of them, in
+// order, including for empty inputs. It is called from the goroutine calling
+// Next, when the encoder reads past the end of the input; since content is
+// read a block at a time, that may be before the blocks containing the end of
+// the input have been returned. The leaves that contain an input are those
+// from Offset/blockSize to (Offset+Size-1)/blockSize. If onInput is nil, the
+// inputs are simply concatenated.
+//
+// If an input returns an error other than io.EOF, encoding stops with a
+// *ReadError, and onInput isn't called for that input or any later ones.
+func NewMultiEncoder(inputs []io.Reader, secret [ConvergenceSecretSize]byte, blockSize int, onInput func(InputRange), opts ...EncoderOption) *Encoder {
+	r := &boundaryReader{inputs: inputs, onInput: onInput}
+	return NewEncoder(r, secret, blockSize, opts...)
+}
+
+// boundaryReader concatenates readers like io.MultiReader, and reports the
+// range that each one occupies in the concatenation.
+type boundaryReader struct {
+	inputs  []io.Reader
+	onInput func(InputRange)
+
+	// i is the index of the current input, start is the offset at which
+	// it began, and off is the total number of bytes read.
+	i          int
+	start, off int64
+}
+
+// Read implements the io.Reader interface.
+func (r *boundaryReader) Read(p []byte) (int, error) {
+	for r.i < len(r.inputs) {
+		n, err := r.inputs[r.i].Read(p)
+		r.off += int64(n)
+		if err == io.EOF {
+			if r.onInput != nil {
+				r.onInput(InputRange{Input: r.i, Offset: r.start, Size: r.off - r.start})
+			}
+			r.i++
+			r.start = r.off
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		return n, err
+	}
+	return 0, io.EOF
+}
@@ -0,0 +1,74 @@
+package eris
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"testing/iotest"
+)
+
+func TestNewMultiEncoder(t *testing.T) {
+	parts := [][]byte{
+		randomContent(1500),
+		nil,
+		randomContent(10),
+		randomContent(3000),
+	}
+	var inputs []io.Reader
+	for _, p := range parts {
+		// Return data and io.EOF together, to check that the data
+		// isn't lost.
+		inputs = append(inputs, iotest.DataErrReader(bytes.NewReader(p)))
+	}
+
+	var ranges []InputRange
+	enc := NewMultiEncoder(inputs, [ConvergenceSecretSize]byte{}, BlockSizeSmall, func(r InputRange) {
+		ranges = append(ranges, r)
+	})
+	for enc.Next() {
+	}
+	if err := enc.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The capability is the same as for the concatenated content.
+	want, _ := encodeToMap(t, bytes.Join(parts, nil), BlockSizeSmall)
+	if got := enc.Capability(); !got.Equal(want) {
+		t.Errorf("capability = %v, want %v", got, want)
+	}
+
+	wantRanges := []InputRange{
+		{Input: 0, Offset: 0, Size: 1500},
+		{Input: 1, Offset: 1500, Size: 0},
+		{Input: 2, Offset: 1500, Size: 10},
+		{Input: 3, Offset: 1510, Size: 3000},
+	}
+	if !reflect.DeepEqual(ranges, wantRanges) {
+		t.Errorf("ranges = %v, want %v", ranges, wantRanges)
+	}
+}
+
+func TestNewMultiEncoder_Error(t *testing.T) {
+	errBroken := errors.New("broken")
+	inputs := []io.Reader{
+		bytes.NewReader(randomContent(100)),
+		iotest.ErrReader(errBroken),
+		bytes.NewReader(randomContent(100)),
+	}
+	var ranges []InputRange
+	enc := NewMultiEncoder(inputs, [ConvergenceSecretSize]byte{}, BlockSizeSmall, func(r InputRange) {
+		ranges = append(ranges, r)
+	})
+	for enc.Next() {
+	}
+
+	var re *ReadError
+	if err := enc.Err(); !errors.As(err, &re) || !errors.Is(err, errBroken) {
+		t.Errorf("Err = %v, want a *ReadError wrapping %v", err, errBroken)
+	}
+	if len(ranges) != 1 {
+		t.Errorf("onInput called for %d inputs, want 1", len(ranges))
+	}
+}
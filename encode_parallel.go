@@ -0,0 +1,161 @@
+package eris
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// PutFunc is the type of the function called by EncodeReaderAt to store each
+// block. Its signature matches the Put method of the store package's Store
+// interface.
+type PutFunc func(ctx context.Context, ref Reference, block []byte) error
+
+// EncodeReaderAt encodes size bytes of content read from r, calling put for
+// every distinct block in the resulting ERIS tree, and returns the read
+// capability for the content. The capability and blocks are identical to
+// those produced by an Encoder with the same secret and block size.
+//
+// Since the position of every leaf in the content is known in advance, the
+// content is split into one region per worker, and up to workers regions are
+// read, encrypted and stored in parallel; each level of internal nodes is then
+// encrypted and stored in parallel once the level below it is complete. This
+// scales with the number of cores for large local files, unlike an Encoder,
+// which must read its content sequentially. If workers is less than 1, a single
+// worker is used.
+//
+// Both r and put are called concurrently from multiple goroutines, and must
+// be safe for concurrent use; put may retain the block it is passed. Blocks
+// are not passed to put in any particular order, but every block is stored
+// before any internal node that refers to it.
+//
+// EncoderOptions such as WithSizePadding or WithIndex aren't supported; use
+// an Encoder for those.
+func EncodeReaderAt(ctx context.Context, r io.ReaderAt, size int64, secret [ConvergenceSecretSize]byte, blockSize int, workers int, put PutFunc) (ReadCapability, error) {
+	if blockSize != BlockSizeSmall && blockSize != BlockSizeLarge {
+		return ReadCapability{}, fmt.Errorf("unsupported block size: %d", blockSize)
+	}
+	if size < 0 {
+		return ReadCapability{}, fmt.Errorf("negative content size: %d", size)
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	p := &parallelPutter{put: put, cancel: cancel, seen: make(map[Reference]bool)}
+
+	// Padding always adds at least one byte, so there is one more leaf
+	// than there are full blocks of content.
+	bs := int64(blockSize)
+	numLeaves := size/bs + 1
+	refKeys := make([]ReferenceKeyPair, numLeaves)
+
+	regions := min(int64(workers), numLeaves)
+	perRegion := (numLeaves + regions - 1) / regions
+	p.run(int(regions), func(region int) error {
+		buf := make([]byte, blockSize)
+		end := min(int64(region+1)*perRegion, numLeaves)
+		for i := int64(region) * perRegion; i < end; i++ {
+			off := i * bs
+			n := int(min(bs, size-off))
+			if m, err := r.ReadAt(buf[:n], off); m < n {
+				if err == io.EOF {
+					err = io.ErrUnexpectedEOF
+				}
+				return fmt.Errorf("reading content at offset %d: %w", off, err)
+			}
+			padBlock(buf, n, blockSize)
+
+			block, refKey := encryptLeafNode(buf, secret)
+			refKeys[i] = refKey
+			if err := p.putOnce(ctx, refKey.Reference, block); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if p.err != nil {
+		return ReadCapability{}, p.err
+	}
+
+	level := 0
+	for len(refKeys) > 1 {
+		level++
+		nodes := constructInternalNodes(refKeys, blockSize)
+		refKeys = make([]ReferenceKeyPair, len(nodes))
+
+		n := min(workers, len(nodes))
+		p.run(n, func(w int) error {
+			// Interleave nodes between workers; every node is the
+			// same size, so this is as good as anything else.
+			for i := w; i < len(nodes); i += n {
+				block, refKey := encryptInternalNode(nodes[i], level, secret)
+				refKeys[i] = refKey
+				if err := p.putOnce(ctx, refKey.Reference, block); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if p.err != nil {
+			return ReadCapability{}, p.err
+		}
+	}
+
+	return ReadCapability{
+		BlockSize: blockSize,
+		Level:     level,
+		Root:      refKeys[0],
+	}, nil
+}
+
+// parallelPutter stores blocks for EncodeReaderAt from multiple goroutines,
+// skipping blocks that were already stored and recording the first error.
+type parallelPutter struct {
+	put    PutFunc
+	cancel context.CancelFunc
+
+	// mu protects the fields below.
+	mu   sync.Mutex
+	seen map[Reference]bool
+	err  error
+}
+
+// run calls fn(0) to fn(n-1) in separate goroutines and waits for them to
+// finish. The first error is recorded in p.err, and cancels the context.
+func (p *parallelPutter) run(n int, fn func(int) error) {
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := fn(i); err != nil {
+				p.mu.Lock()
+				defer p.mu.Unlock()
+				if p.err == nil {
+					p.err = err
+					p.cancel()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// putOnce stores a block, unless it has already been stored.
+func (p *parallelPutter) putOnce(ctx context.Context, ref Reference, block []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	p.mu.Lock()
+	seen := p.seen[ref]
+	p.seen[ref] = true
+	p.mu.Unlock()
+	if seen {
+		return nil
+	}
+	return p.put(ctx, ref, block)
+}
@@ -0,0 +1,99 @@
+package eris
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+)
+
+// mapPutter is a PutFunc that stores blocks in a map, and records blocks that
+// are stored more than once.
+type mapPutter struct {
+	mu     sync.Mutex
+	blocks map[Reference][]byte
+	dups   int
+}
+
+func (p *mapPutter) put(_ context.Context, ref Reference, block []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.blocks[ref]; ok {
+		p.dups++
+	}
+	p.blocks[ref] = block
+	return nil
+}
+
+func TestEncodeReaderAt(t *testing.T) {
+	repetitive := bytes.Repeat([]byte("abcdefgh"), 40*1024)
+	for _, tc := range []struct {
+		name      string
+		content   []byte
+		blockSize int
+	}{
+		{"empty", nil, BlockSizeSmall},
+		{"one byte", []byte{1}, BlockSizeSmall},
+		{"one block", randomContent(1024), BlockSizeSmall},
+		{"small", randomContent(300*1024 + 123), BlockSizeSmall},
+		{"repetitive", repetitive, BlockSizeSmall},
+		{"large", randomContent(600 * 1024), BlockSizeLarge},
+	} {
+		want, wantBlocks := encodeToMap(t, tc.content, tc.blockSize)
+		for _, workers := range []int{0, 1, 3, 8} {
+			p := &mapPutter{blocks: make(map[Reference][]byte)}
+			rc, err := EncodeReaderAt(context.Background(), bytes.NewReader(tc.content), int64(len(tc.content)), [32]byte{}, tc.blockSize, workers, p.put)
+			if err != nil {
+				t.Fatalf("%s, %d workers: %v", tc.name, workers, err)
+			}
+			if !rc.Equal(want) {
+				t.Errorf("%s, %d workers: capability %+v, want %+v", tc.name, workers, rc, want)
+			}
+			if p.dups != 0 {
+				t.Errorf("%s, %d workers: %d blocks stored more than once", tc.name, workers, p.dups)
+			}
+			if len(p.blocks) != len(wantBlocks) {
+				t.Errorf("%s, %d workers: stored %d blocks, want %d", tc.name, workers, len(p.blocks), len(wantBlocks))
+			}
+			for ref, block := range wantBlocks {
+				if !bytes.Equal(p.blocks[ref], block) {
+					t.Errorf("%s, %d workers: block %v differs", tc.name, workers, ref)
+				}
+			}
+		}
+	}
+}
+
+func TestEncodeReaderAt_Errors(t *testing.T) {
+	ctx := context.Background()
+	content := randomContent(100 * 1024)
+	p := &mapPutter{blocks: make(map[Reference][]byte)}
+
+	// The content is shorter than the given size.
+	_, err := EncodeReaderAt(ctx, bytes.NewReader(content), int64(len(content))+10, [32]byte{}, BlockSizeSmall, 4, p.put)
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("short content: got %v, want io.ErrUnexpectedEOF", err)
+	}
+
+	// An error from put is returned.
+	errPut := errors.New("put failed")
+	var mu sync.Mutex
+	var calls int
+	failing := func(ctx context.Context, ref Reference, block []byte) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if calls++; calls == 50 {
+			return errPut
+		}
+		return nil
+	}
+	if _, err := EncodeReaderAt(ctx, bytes.NewReader(content), int64(len(content)), [32]byte{}, BlockSizeSmall, 4, failing); !errors.Is(err, errPut) {
+		t.Errorf("failing put: got %v, want %v", err, errPut)
+	}
+
+	if _, err := EncodeReaderAt(ctx, bytes.NewReader(content), int64(len(content)), [32]byte{}, 4096, 4, p.put); err == nil {
+		t.Error("expected error for unsupported block size")
+	}
+}
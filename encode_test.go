@@ -1,11 +1,15 @@
 package eris
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"io"
 	"maps"
 	"reflect"
 	"runtime"
 	"testing"
+	"testing/iotest"
 )
 
 // TestReset verifies that the reset method on the Encoder will actually reset
@@ -165,3 +169,242 @@ func TestAppendPadWithZeros(t *testing.T) {
 		}
 	})
 }
+
+func TestComputeCapability(t *testing.T) {
+	var secret [ConvergenceSecretSize]byte
+	for _, size := range []int{0, 1000, 100 * 1024} {
+		content := randomContent(size)
+		want, _ := encodeToMap(t, content, 1024)
+
+		got, err := ComputeCapability(bytes.NewReader(content), secret, 1024)
+		if err != nil {
+			t.Fatalf("size %d: ComputeCapability: %v", size, err)
+		}
+		if !got.Equal(want) {
+			t.Errorf("size %d: capability mismatch", size)
+		}
+	}
+}
+
+// TestEncoder_TrailingDuplicateInternalNodes verifies that the encoder
+// terminates when the final internal node at a level is a duplicate of one
+// that was already emitted.
+func TestEncoder_TrailingDuplicateInternalNodes(t *testing.T) {
+	// Construct a block that looks like a padded block, and then content
+	// that consists of 31 copies of that block followed by the unpadded
+	// version of it. After padding, we have 32 identical leaves, which
+	// results in two identical internal nodes at level 1 (arity 16).
+	block := append(bytes.Repeat([]byte{'a'}, 1023), 0x80)
+	content := append(bytes.Repeat(block, 31), block[:1023]...)
+	rc, blocks := encodeToMap(t, content, 1024)
+	if rc.Level != 2 {
+		t.Fatalf("unexpected level: %d", rc.Level)
+	}
+
+	got, err := DecodeRecursive(context.Background(), mapFetch(blocks, nil), rc)
+	if err != nil {
+		t.Fatalf("DecodeRecursive: %v", err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Errorf("decoded content mismatch")
+	}
+}
+
+func TestEncoder_BlockLevel(t *testing.T) {
+	var secret [ConvergenceSecretSize]byte
+	enc := NewEncoder(bytes.NewReader(randomContent(300*1024)), secret, 1024)
+
+	levels := make(map[Reference]int)
+	blocks := make(map[Reference][]byte)
+	for enc.Next() {
+		levels[enc.Reference()] = enc.BlockLevel()
+		blocks[enc.Reference()] = bytes.Clone(enc.Block())
+	}
+	if err := enc.Err(); err != nil {
+		t.Fatal(err)
+	}
+	rc := enc.Capability()
+
+	// Walk the tree, and check that every block was emitted with the
+	// level that it appears at.
+	var seen int
+	err := walkReferences(context.Background(), mapFetch(blocks, nil), rc, func(ref ReferenceKeyPair, level int) error {
+		seen++
+		if got := levels[ref.Reference]; got != level {
+			t.Errorf("block %v emitted at level %d, but is at level %d in the tree", ref.Reference, got, level)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seen != len(levels) {
+		t.Errorf("walked %d blocks, but %d were emitted", seen, len(levels))
+	}
+	if levels[rc.Root.Reference] != rc.Level {
+		t.Errorf("root emitted at level %d, want %d", levels[rc.Root.Reference], rc.Level)
+	}
+}
+
+func TestEncoder_BlockObserver(t *testing.T) {
+	var secret [ConvergenceSecretSize]byte
+
+	// Use repetitive content so that many blocks are duplicates.
+	content := append(bytes.Repeat(randomContent(1024), 40), randomContent(500)...)
+
+	type observed struct {
+		ref         Reference
+		level, size int
+	}
+	var seen []observed
+	observer := func(ref Reference, level, size int) {
+		seen = append(seen, observed{ref, level, size})
+	}
+
+	enc := NewEncoder(bytes.NewReader(content), secret, 1024, WithBlockObserver(observer))
+	blocks := make(map[Reference][]byte)
+	for enc.Next() {
+		blocks[enc.Reference()] = bytes.Clone(enc.Block())
+	}
+	if err := enc.Err(); err != nil {
+		t.Fatal(err)
+	}
+	rc := enc.Capability()
+
+	// Every block in the tree is observed, including the duplicates.
+	var treeBlocks int
+	err := walkReferences(context.Background(), mapFetch(blocks, nil), rc, func(ReferenceKeyPair, int) error {
+		treeBlocks++
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(seen) != treeBlocks {
+		t.Errorf("observed %d blocks, want %d", len(seen), treeBlocks)
+	}
+	if len(blocks) >= treeBlocks {
+		t.Errorf("expected some duplicate blocks; got %d unique of %d", len(blocks), treeBlocks)
+	}
+
+	// Leaves come first, in order, and their sizes add up to the content.
+	var contentLen, leaves, level1Refs int
+	for i, o := range seen {
+		switch o.level {
+		case 0:
+			if i != leaves {
+				t.Errorf("leaf observed at position %d after internal nodes", i)
+			}
+			leaves++
+			contentLen += o.size
+		case 1:
+			level1Refs += o.size / referenceKeyLen
+		}
+	}
+	if contentLen != len(content) {
+		t.Errorf("leaf sizes add up to %d, want %d", contentLen, len(content))
+	}
+	if level1Refs != leaves {
+		t.Errorf("level 1 nodes refer to %d leaves, want %d", level1Refs, leaves)
+	}
+	if last := seen[len(seen)-1]; last.ref != rc.Root.Reference || last.level != rc.Level {
+		t.Errorf("last observed block is %v at level %d, want the root", last.ref, last.level)
+	}
+}
+
+func TestEncoder_Concurrency(t *testing.T) {
+	var secret [ConvergenceSecretSize]byte
+
+	type emitted struct {
+		ref   Reference
+		level int
+		block []byte
+	}
+	encodeAll := func(content []byte, opts ...EncoderOption) ([]emitted, ReadCapability) {
+		enc := NewEncoder(bytes.NewReader(content), secret, 1024, opts...)
+		var out []emitted
+		for enc.Next() {
+			out = append(out, emitted{enc.Reference(), enc.BlockLevel(), bytes.Clone(enc.Block())})
+		}
+		if err := enc.Err(); err != nil {
+			t.Fatal(err)
+		}
+		return out, enc.Capability()
+	}
+
+	contents := map[string][]byte{
+		"empty":      nil,
+		"one block":  randomContent(1000),
+		"multilevel": randomContent(300 * 1024),
+		// Repetitive content, so that some blocks in a batch are
+		// duplicates of each other.
+		"repetitive": append(bytes.Repeat(randomContent(1024), 70), randomContent(10)...),
+	}
+	for name, content := range contents {
+		want, wantRC := encodeAll(content)
+		for _, n := range []int{2, 3, 8} {
+			got, gotRC := encodeAll(content, WithConcurrency(n))
+			if !gotRC.Equal(wantRC) {
+				t.Errorf("%s: concurrency %d: capability mismatch", name, n)
+			}
+			if len(got) != len(want) {
+				t.Errorf("%s: concurrency %d: got %d blocks, want %d", name, n, len(got), len(want))
+				continue
+			}
+			for i := range got {
+				if got[i].ref != want[i].ref || got[i].level != want[i].level || !bytes.Equal(got[i].block, want[i].block) {
+					t.Errorf("%s: concurrency %d: block %d differs", name, n, i)
+					break
+				}
+			}
+		}
+	}
+}
+
+func TestEncoder_Stats(t *testing.T) {
+	var secret [ConvergenceSecretSize]byte
+
+	// 70 identical leaves and a final distinct one, under four identical
+	// full internal nodes, a fifth for the rest, and the root.
+	content := append(bytes.Repeat(randomContent(1024), 70), randomContent(10)...)
+	want := EncoderStats{
+		Leaves:                 71,
+		InternalNodes:          6,
+		DuplicateLeaves:        69,
+		DuplicateInternalNodes: 3,
+	}
+	for _, n := range []int{1, 3} {
+		enc := NewEncoder(bytes.NewReader(content), secret, 1024, WithConcurrency(n))
+		var emitted int64
+		for enc.Next() {
+			emitted++
+		}
+		if err := enc.Err(); err != nil {
+			t.Fatal(err)
+		}
+		if got := enc.Stats(); got != want {
+			t.Errorf("concurrency %d: Stats = %+v, want %+v", n, got, want)
+		}
+		if got := enc.Stats().Emitted(); got != emitted {
+			t.Errorf("concurrency %d: Emitted = %d, want %d", n, got, emitted)
+		}
+	}
+}
+
+func TestEncoder_ReadError(t *testing.T) {
+	errBroken := errors.New("broken")
+	for _, concurrency := range []int{1, 4} {
+		content := io.MultiReader(bytes.NewReader(randomContent(2500)), iotest.ErrReader(errBroken))
+		enc := NewEncoder(content, [ConvergenceSecretSize]byte{}, BlockSizeSmall, WithConcurrency(concurrency))
+		for enc.Next() {
+		}
+
+		var re *ReadError
+		if err := enc.Err(); !errors.As(err, &re) || !errors.Is(err, errBroken) {
+			t.Fatalf("concurrency %d: Err = %v, want a *ReadError wrapping %v", concurrency, err, errBroken)
+		}
+		if re.Offset != 2500 {
+			t.Errorf("concurrency %d: Offset = %d, want 2500", concurrency, re.Offset)
+		}
+	}
+}
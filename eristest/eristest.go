@@ -0,0 +1,215 @@
+// Package eristest implements helpers for testing code that encodes, decodes
+// or stores ERIS content: deterministic content, in-memory trees, and fetch
+// functions and stores that misbehave in the ways that real storage does.
+package eristest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/andrew-d/eris-go"
+	"github.com/andrew-d/eris-go/store"
+)
+
+// Content returns size bytes of pseudo-random content derived from seed. The
+// same seed and size always return the same content.
+func Content(seed int64, size int) []byte {
+	b := make([]byte, size)
+	rand.New(rand.NewSource(seed)).Read(b)
+	return b
+}
+
+// PatternReader is an io.Reader and io.ReaderAt whose content is a pattern
+// repeated up to a fixed size. It can stand in for very large inputs without
+// holding them in memory; since the pattern repeats, content made from a
+// pattern whose length divides the block size encodes to very few distinct
+// leaves.
+type PatternReader struct {
+	pattern []byte
+	size    int64
+	off     int64
+}
+
+// NewPatternReader returns a PatternReader that repeats pattern, which must
+// not be empty, for size bytes.
+func NewPatternReader(pattern []byte, size int64) *PatternReader {
+	if len(pattern) == 0 {
+		panic("eristest: empty pattern")
+	}
+	return &PatternReader{pattern: bytes.Clone(pattern), size: size}
+}
+
+// Size returns the total size of the content.
+func (r *PatternReader) Size() int64 {
+	return r.size
+}
+
+// Read implements the io.Reader interface.
+func (r *PatternReader) Read(p []byte) (int, error) {
+	n, err := r.ReadAt(p, r.off)
+	r.off += int64(n)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+// ReadAt implements the io.ReaderAt interface.
+func (r *PatternReader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("eristest: negative offset")
+	}
+	if off >= r.size {
+		return 0, io.EOF
+	}
+	n := int(min(int64(len(p)), r.size-off))
+	start := int(off % int64(len(r.pattern)))
+	for i := 0; i < n; {
+		c := copy(p[i:n], r.pattern[start:])
+		i += c
+		start = 0
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// Encode encodes content with the given block size and the zero convergence
+// secret, and returns its read capability and every block in the tree. It
+// fails the test if encoding fails.
+func Encode(tb testing.TB, content []byte, blockSize int) (eris.ReadCapability, map[eris.Reference][]byte) {
+	tb.Helper()
+	blocks := make(map[eris.Reference][]byte)
+	enc := eris.NewEncoder(bytes.NewReader(content), [eris.ConvergenceSecretSize]byte{}, blockSize)
+	for enc.Next() {
+		blocks[enc.Reference()] = bytes.Clone(enc.Block())
+	}
+	if err := enc.Err(); err != nil {
+		tb.Fatalf("encoding content: %v", err)
+	}
+	return enc.Capability(), blocks
+}
+
+// MapFetch returns a FetchFunc that returns blocks from a map, such as the one
+// returned by Encode. Missing blocks are reported with an error wrapping
+// store.ErrNotFound. It is safe for concurrent use as long as the map isn't
+// modified.
+func MapFetch(blocks map[eris.Reference][]byte) eris.FetchFunc {
+	return func(_ context.Context, ref eris.Reference, buf []byte) ([]byte, error) {
+		block, ok := blocks[ref]
+		if !ok {
+			return nil, fmt.Errorf("%w: %v", store.ErrNotFound, ref)
+		}
+		return append(buf[:0], block...), nil
+	}
+}
+
+// SlowFetch returns a FetchFunc that waits for d before calling fetch, to
+// simulate a high-latency store. If ctx is done first, it returns ctx.Err().
+func SlowFetch(fetch eris.FetchFunc, d time.Duration) eris.FetchFunc {
+	return func(ctx context.Context, ref eris.Reference, buf []byte) ([]byte, error) {
+		t := time.NewTimer(d)
+		defer t.Stop()
+		select {
+		case <-t.C:
+			return fetch(ctx, ref, buf)
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// FailAfter returns a FetchFunc that calls fetch for the first n calls, and
+// returns err for every call after that. It is safe for concurrent use if
+// fetch is.
+func FailAfter(fetch eris.FetchFunc, n int, err error) eris.FetchFunc {
+	var calls atomic.Int64
+	return func(ctx context.Context, ref eris.Reference, buf []byte) ([]byte, error) {
+		if calls.Add(1) > int64(n) {
+			return nil, err
+		}
+		return fetch(ctx, ref, buf)
+	}
+}
+
+// CountFetch returns a FetchFunc that calls fetch and counts the calls in
+// calls.
+func CountFetch(fetch eris.FetchFunc, calls *atomic.Int64) eris.FetchFunc {
+	return func(ctx context.Context, ref eris.Reference, buf []byte) ([]byte, error) {
+		calls.Add(1)
+		return fetch(ctx, ref, buf)
+	}
+}
+
+// FlakyStore is a store.Store whose operations fail a fixed number of times
+// for each block before they succeed, like a store with transient network
+// errors. It is useful for testing that callers retry.
+type FlakyStore struct {
+	store.Store
+
+	failures int
+	err      error
+
+	mu       sync.Mutex
+	attempts map[eris.Reference]int
+}
+
+// NewFlakyStore returns a FlakyStore in which the first failures operations on
+// each block, whether Get, Put or Has, return err instead of calling s.
+func NewFlakyStore(s store.Store, failures int, err error) *FlakyStore {
+	return &FlakyStore{
+		Store:    s,
+		failures: failures,
+		err:      err,
+		attempts: make(map[eris.Reference]int),
+	}
+}
+
+// fail records an attempt to access ref, and reports whether it should fail.
+func (f *FlakyStore) fail(ref eris.Reference) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.attempts[ref]++
+	return f.attempts[ref] <= f.failures
+}
+
+// Get implements the store.Store interface.
+func (f *FlakyStore) Get(ctx context.Context, ref eris.Reference, buf []byte) ([]byte, error) {
+	if f.fail(ref) {
+		return nil, f.err
+	}
+	return f.Store.Get(ctx, ref, buf)
+}
+
+// Put implements the store.Store interface.
+func (f *FlakyStore) Put(ctx context.Context, ref eris.Reference, block []byte) error {
+	if f.fail(ref) {
+		return f.err
+	}
+	return f.Store.Put(ctx, ref, block)
+}
+
+// Has implements the store.Store interface.
+func (f *FlakyStore) Has(ctx context.Context, ref eris.Reference) (bool, error) {
+	if f.fail(ref) {
+		return false, f.err
+	}
+	return f.Store.Has(ctx, ref)
+}
+
+// Attempts returns the number of operations that have been attempted on the
+// block with the given reference, including those that failed.
+func (f *FlakyStore) Attempts(ref eris.Reference) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.attempts[ref]
+}
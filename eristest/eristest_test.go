@@ -0,0 +1,93 @@
+package eristest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sync/atomic"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/andrew-d/eris-go"
+	"github.com/andrew-d/eris-go/store"
+)
+
+func TestContent(t *testing.T) {
+	if !bytes.Equal(Content(1, 100), Content(1, 100)) {
+		t.Error("Content isn't deterministic")
+	}
+	if bytes.Equal(Content(1, 100), Content(2, 100)) {
+		t.Error("Content doesn't depend on the seed")
+	}
+}
+
+func TestPatternReader(t *testing.T) {
+	want := bytes.Repeat([]byte("abc"), 1000)[:2999]
+	if err := iotest.TestReader(NewPatternReader([]byte("abc"), 2999), want); err != nil {
+		t.Error(err)
+	}
+
+	r := NewPatternReader([]byte("abc"), 10)
+	buf := make([]byte, 4)
+	if n, err := r.ReadAt(buf, 8); n != 2 || err != io.EOF || string(buf[:n]) != "ca" {
+		t.Errorf("ReadAt at end = %d, %v, %q", n, err, buf[:n])
+	}
+}
+
+func TestEncodeAndFetch(t *testing.T) {
+	ctx := context.Background()
+	content := Content(1, 50*1024)
+	rc, blocks := Encode(t, content, eris.BlockSizeSmall)
+
+	var calls atomic.Int64
+	fetch := CountFetch(SlowFetch(MapFetch(blocks), time.Millisecond), &calls)
+	got, err := eris.DecodeRecursive(ctx, fetch, rc)
+	if err != nil || !bytes.Equal(got, content) {
+		t.Fatalf("decoding: %v", err)
+	}
+	if calls.Load() == 0 {
+		t.Error("fetches weren't counted")
+	}
+
+	if _, err := MapFetch(nil)(ctx, rc.Root.Reference, nil); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("MapFetch of missing block: got %v", err)
+	}
+
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := SlowFetch(MapFetch(blocks), time.Hour)(cctx, rc.Root.Reference, nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("SlowFetch with canceled context: got %v", err)
+	}
+
+	errFail := errors.New("fail")
+	_, err = eris.DecodeRecursive(ctx, FailAfter(MapFetch(blocks), 3, errFail), rc)
+	if !errors.Is(err, errFail) {
+		t.Errorf("FailAfter: got %v", err)
+	}
+}
+
+func TestFlakyStore(t *testing.T) {
+	ctx := context.Background()
+	errFlaky := errors.New("flaky")
+	s := NewFlakyStore(store.NewMemory(), 2, errFlaky)
+
+	_, blocks := Encode(t, Content(1, 1000), eris.BlockSizeSmall)
+	for ref, block := range blocks {
+		for i := 0; i < 2; i++ {
+			if err := s.Put(ctx, ref, block); !errors.Is(err, errFlaky) {
+				t.Fatalf("Put attempt %d: got %v", i, err)
+			}
+		}
+		if err := s.Put(ctx, ref, block); err != nil {
+			t.Fatalf("Put after failures: %v", err)
+		}
+		if got, err := s.Get(ctx, ref, nil); err != nil || !bytes.Equal(got, block) {
+			t.Errorf("Get: %v", err)
+		}
+		if n := s.Attempts(ref); n != 4 {
+			t.Errorf("Attempts = %d, want 4", n)
+		}
+	}
+}
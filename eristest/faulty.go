@@ -0,0 +1,176 @@
+package eristest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/andrew-d/eris-go"
+	"github.com/andrew-d/eris-go/store"
+)
+
+// FaultOptions configures the faults injected by a FaultyStore. Each rate is
+// the probability, between 0 and 1, that an operation suffers that fault.
+type FaultOptions struct {
+	// Seed seeds the random number generator that decides which
+	// operations fail, so that a test sees the same faults every time
+	// it performs the same sequence of operations.
+	Seed int64
+
+	// CorruptRate is the rate at which Get returns a block with a single
+	// bit flipped.
+	CorruptRate float64
+	// TruncateRate is the rate at which Get returns only part of a block.
+	TruncateRate float64
+	// NotFoundRate is the rate at which Get and Has report that a block
+	// that is present is missing.
+	NotFoundRate float64
+
+	// DelayRate is the rate at which an operation is delayed by Delay
+	// before it is performed.
+	DelayRate float64
+	Delay     time.Duration
+}
+
+// FaultStats counts the faults injected by a FaultyStore.
+type FaultStats struct {
+	Corrupted int64
+	Truncated int64
+	NotFound  int64
+	Delayed   int64
+}
+
+// FaultyStore is a store.Store that injects faults into the operations of an
+// underlying store, to exercise the retry, repair and verification paths of
+// the code that uses it. The underlying store is never modified by a fault.
+//
+// Faults are chosen with a random number generator seeded from
+// FaultOptions.Seed, so a sequence of operations always sees the same faults.
+// Concurrent operations see the same faults only if they are performed in the
+// same order.
+type FaultyStore struct {
+	store.Store
+	opts FaultOptions
+
+	// mu protects the fields below.
+	mu    sync.Mutex
+	rng   *rand.Rand
+	stats FaultStats
+}
+
+// NewFaultyStore returns a FaultyStore that injects faults into the operations
+// of s according to opts.
+func NewFaultyStore(s store.Store, opts FaultOptions) *FaultyStore {
+	return &FaultyStore{
+		Store: s,
+		opts:  opts,
+		rng:   rand.New(rand.NewSource(opts.Seed)),
+	}
+}
+
+// faults are the faults chosen for a single operation.
+type faults struct {
+	corrupt, truncate, notFound, delay bool
+
+	// pos is a random value used to choose the corrupted bit or the
+	// truncated length.
+	pos int
+}
+
+// choose chooses the faults for an operation. The same number of random
+// values is drawn for every operation, so that the faults chosen for later
+// operations don't depend on which kinds of operation came before.
+func (f *FaultyStore) choose() faults {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return faults{
+		corrupt:  f.rng.Float64() < f.opts.CorruptRate,
+		truncate: f.rng.Float64() < f.opts.TruncateRate,
+		notFound: f.rng.Float64() < f.opts.NotFoundRate,
+		delay:    f.rng.Float64() < f.opts.DelayRate,
+		pos:      f.rng.Int(),
+	}
+}
+
+// count updates the statistics.
+func (f *FaultyStore) count(fn func(*FaultStats)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	fn(&f.stats)
+}
+
+// wait applies a delay fault, returning early if ctx is done.
+func (f *FaultyStore) wait(ctx context.Context, fs faults) error {
+	if !fs.delay || f.opts.Delay <= 0 {
+		return nil
+	}
+	f.count(func(s *FaultStats) { s.Delayed++ })
+	t := time.NewTimer(f.opts.Delay)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Get implements the store.Store interface.
+func (f *FaultyStore) Get(ctx context.Context, ref eris.Reference, buf []byte) ([]byte, error) {
+	fs := f.choose()
+	if err := f.wait(ctx, fs); err != nil {
+		return nil, err
+	}
+	if fs.notFound {
+		f.count(func(s *FaultStats) { s.NotFound++ })
+		return nil, fmt.Errorf("%w: %v (injected)", store.ErrNotFound, ref)
+	}
+
+	block, err := f.Store.Get(ctx, ref, buf)
+	if err != nil || len(block) == 0 {
+		return block, err
+	}
+	if fs.corrupt {
+		f.count(func(s *FaultStats) { s.Corrupted++ })
+		// The store may have returned its own copy of the block.
+		block = bytes.Clone(block)
+		bit := fs.pos % (len(block) * 8)
+		block[bit/8] ^= 1 << (bit % 8)
+	}
+	if fs.truncate {
+		f.count(func(s *FaultStats) { s.Truncated++ })
+		block = block[:fs.pos%len(block)]
+	}
+	return block, nil
+}
+
+// Put implements the store.Store interface.
+func (f *FaultyStore) Put(ctx context.Context, ref eris.Reference, block []byte) error {
+	if err := f.wait(ctx, f.choose()); err != nil {
+		return err
+	}
+	return f.Store.Put(ctx, ref, block)
+}
+
+// Has implements the store.Store interface.
+func (f *FaultyStore) Has(ctx context.Context, ref eris.Reference) (bool, error) {
+	fs := f.choose()
+	if err := f.wait(ctx, fs); err != nil {
+		return false, err
+	}
+	if fs.notFound {
+		f.count(func(s *FaultStats) { s.NotFound++ })
+		return false, nil
+	}
+	return f.Store.Has(ctx, ref)
+}
+
+// Stats returns the number of faults of each kind that have been injected.
+func (f *FaultyStore) Stats() FaultStats {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.stats
+}
@@ -0,0 +1,102 @@
+package eristest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/andrew-d/eris-go"
+	"github.com/andrew-d/eris-go/store"
+)
+
+func TestFaultyStore(t *testing.T) {
+	ctx := context.Background()
+	mem := store.NewMemory()
+	_, blocks := Encode(t, Content(1, 100*1024), eris.BlockSizeSmall)
+	for ref, block := range blocks {
+		mem.Put(ctx, ref, block)
+	}
+
+	// Every fault is detected by the caller: corrupted and truncated
+	// blocks don't match their reference.
+	run := func(seed int64) (FaultStats, []string) {
+		s := NewFaultyStore(mem, FaultOptions{
+			Seed:         seed,
+			CorruptRate:  0.2,
+			TruncateRate: 0.2,
+			NotFoundRate: 0.2,
+			DelayRate:    0.1,
+			Delay:        time.Microsecond,
+		})
+		var results []string
+		for ref, block := range blocks {
+			got, err := s.Get(ctx, ref, nil)
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				results = append(results, "not found")
+			case err != nil:
+				t.Fatalf("Get: %v", err)
+			case len(got) != len(block):
+				results = append(results, "truncated")
+			case !bytes.Equal(got, block):
+				results = append(results, "corrupted")
+			default:
+				results = append(results, "ok")
+			}
+		}
+		return s.Stats(), results
+	}
+
+	stats, results := run(1)
+	if stats.Corrupted == 0 || stats.Truncated == 0 || stats.NotFound == 0 || stats.Delayed == 0 {
+		t.Errorf("not every kind of fault was injected: %+v", stats)
+	}
+	var ok int
+	for _, r := range results {
+		if r == "ok" {
+			ok++
+		}
+	}
+	if ok == 0 || ok == len(results) {
+		t.Errorf("%d of %d Gets succeeded", ok, len(results))
+	}
+
+	// The underlying store isn't damaged.
+	for ref, block := range blocks {
+		if got, err := mem.Get(ctx, ref, nil); err != nil || !bytes.Equal(got, block) {
+			t.Fatalf("underlying store was modified")
+		}
+	}
+
+	// The same seed gives the same faults, in the same order. (Ranging
+	// over the map gives a different order each time, so compare the
+	// totals.)
+	if stats2, _ := run(1); stats2 != stats {
+		t.Errorf("same seed gave different faults: %+v, then %+v", stats, stats2)
+	}
+}
+
+func TestFaultyStore_Has(t *testing.T) {
+	ctx := context.Background()
+	mem := store.NewMemory()
+	_, blocks := Encode(t, Content(2, 10*1024), eris.BlockSizeSmall)
+	for ref, block := range blocks {
+		mem.Put(ctx, ref, block)
+	}
+
+	s := NewFaultyStore(mem, FaultOptions{NotFoundRate: 1})
+	for ref := range blocks {
+		if has, err := s.Has(ctx, ref); has || err != nil {
+			t.Errorf("Has = %v, %v; want false", has, err)
+		}
+	}
+
+	s = NewFaultyStore(mem, FaultOptions{})
+	for ref := range blocks {
+		if has, err := s.Has(ctx, ref); !has || err != nil {
+			t.Errorf("Has with no faults = %v, %v", has, err)
+		}
+	}
+}
@@ -1,37 +1,108 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"cmp"
 	"context"
-	"encoding/base32"
+	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"io/fs"
 	"log"
+	"net/http"
+	"net/url"
 	"os"
-	"path/filepath"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/andrew-d/eris-go"
+	"github.com/andrew-d/eris-go/blockserver"
+	"github.com/andrew-d/eris-go/remote"
+	"github.com/andrew-d/eris-go/store"
 )
 
 var (
 	verbose bool
 
-	putFlagSet    = flag.NewFlagSet("put", flag.ExitOnError)
-	putSecretFlag = putFlagSet.String("secret", "", "convergence secret in hex; empty is the zero secret")
+	putFlagSet        = flag.NewFlagSet("put", flag.ExitOnError)
+	putSecretFlag     = putFlagSet.String("secret", "", "convergence secret in hex or base32; empty is the zero secret")
+	putSecretFileFlag = putFlagSet.String("secret-file", "", "file to read the convergence secret from, as created by new-secret")
+	putLedgerFlag     = putFlagSet.String("ledger", "", "file to record uploaded blocks in, for resuming an interrupted upload")
+	putEncodingFlag   = putFlagSet.String("encoding", "base32", "encoding of the printed URN: base32, zbase32 or hex")
+	putLeafLogFlag    = putFlagSet.String("leaf-log", "", "file to write the offset of every leaf, and whether it was a duplicate, to; CSV unless it ends in .json")
 
-	getFlagSet = flag.NewFlagSet("get", flag.ExitOnError)
-	getOutFlag = getFlagSet.String("o", "", "output file; empty is stdout")
+	getFlagSet     = flag.NewFlagSet("get", flag.ExitOnError)
+	getOutFlag     = getFlagSet.String("o", "", "output file; empty is stdout")
+	getAuditFlag   = getFlagSet.String("audit", "", "file to write the blocks read, and whether each was verified, to")
+	getPartialFlag = getFlagSet.Bool("partial", false, "recover as much as possible, filling missing or corrupt blocks with zeroes")
 
-	secret [eris.ConvergenceSecretSize]byte
+	catFlagSet    = flag.NewFlagSet("cat", flag.ExitOnError)
+	catOffsetFlag = catFlagSet.Int64("offset", 0, "offset in the file to start reading from")
+	catLengthFlag = catFlagSet.Int64("length", -1, "number of bytes to read; negative reads to the end of the file")
+	catOutFlag    = catFlagSet.String("o", "-", "output file; - is stdout")
+
+	verifyFlagSet    = flag.NewFlagSet("verify", flag.ExitOnError)
+	verifyJSONFlag   = verifyFlagSet.Bool("json", false, "print the report as JSON")
+	verifySampleFlag = verifyFlagSet.Float64("sample", 0, "fraction of the leaves to check, between 0 and 1; 0 checks every block")
+	verifySeedFlag   = verifyFlagSet.Int64("seed", 0, "seed for choosing the leaves to sample; 0 picks one at random")
+	verifyBudgetFlag = verifyFlagSet.Duration("budget", 0, "stop sampling after this long; 0 is unlimited")
+
+	statFlagSet  = flag.NewFlagSet("stat", flag.ExitOnError)
+	statJSONFlag = statFlagSet.Bool("json", false, "print the statistics as JSON")
+
+	treeFlagSet        = flag.NewFlagSet("tree", flag.ExitOnError)
+	treeDOTFlag        = treeFlagSet.Bool("dot", false, "print a Graphviz DOT graph")
+	treeKeysFlag       = treeFlagSet.Bool("keys", false, "print keys along with references")
+	treeOmitLeavesFlag = treeFlagSet.Bool("no-leaves", false, "omit leaf nodes")
+
+	migrateFlagSet      = flag.NewFlagSet("migrate", flag.ExitOnError)
+	migrateBookmarkFlag = migrateFlagSet.String("bookmark", "", "file to record progress in, for resuming an interrupted migration")
+	migrateParallelFlag = migrateFlagSet.Int("parallel", 4, "number of blocks to copy and verify concurrently")
+
+	syncFlagSet      = flag.NewFlagSet("sync", flag.ExitOnError)
+	syncParallelFlag = syncFlagSet.Int("parallel", 4, "number of blocks to copy concurrently")
+	syncDryRunFlag   = syncFlagSet.Bool("dry-run", false, "only print how many blocks are missing from the destination")
+	syncLedgerFlag   = syncFlagSet.String("ledger", "", "file to record copied blocks in, for resuming an interrupted sync")
+
+	gcFlagSet    = flag.NewFlagSet("gc", flag.ExitOnError)
+	gcPinsFlag   = gcFlagSet.String("pins", "", "file listing the URNs of the files to keep, one per line")
+	gcGraceFlag  = gcFlagSet.Duration("grace", time.Hour, "keep unreferenced blocks written more recently than this")
+	gcDryRunFlag = gcFlagSet.Bool("dry-run", false, "only print how much would be deleted")
+
+	checkFlagSet    = flag.NewFlagSet("check", flag.ExitOnError)
+	checkRepairFlag = checkFlagSet.Bool("repair", false, "remove torn blocks and rename misnamed ones")
+
+	serveFlagSet           = flag.NewFlagSet("serve", flag.ExitOnError)
+	serveAddrFlag          = serveFlagSet.String("addr", "localhost:8080", "address to listen on")
+	serveTokenFileFlag     = serveFlagSet.String("token-file", "", "file containing a bearer token required to upload blocks")
+	serveReadOnlyFlag      = serveFlagSet.Bool("read-only", false, "reject all uploads")
+	serveMaxConcurrentFlag = serveFlagSet.Int("max-concurrent", 256, "maximum number of requests to handle at once; 0 is unlimited")
+	serveMirrorFlag        = serveFlagSet.String("mirror", "", "store to mirror blocks to, and to repair missing blocks from")
+
+	remoteServeFlagSet      = flag.NewFlagSet("remote-serve", flag.ExitOnError)
+	remoteServeReadOnlyFlag = remoteServeFlagSet.Bool("read-only", false, "reject all uploads")
+
+	secret eris.ConvergenceSecret
 )
 
 func main() {
-	// Share the same verbose flag between the two commands.
+	// Share the same verbose flag between all commands.
 	putFlagSet.BoolVar(&verbose, "v", true, "verbose output")
 	getFlagSet.BoolVar(&verbose, "v", true, "verbose output")
+	catFlagSet.BoolVar(&verbose, "v", true, "verbose output")
+	migrateFlagSet.BoolVar(&verbose, "v", true, "verbose output")
+	syncFlagSet.BoolVar(&verbose, "v", true, "verbose output")
+	gcFlagSet.BoolVar(&verbose, "v", true, "verbose output")
+	checkFlagSet.BoolVar(&verbose, "v", true, "verbose output")
+	serveFlagSet.BoolVar(&verbose, "v", true, "verbose output")
 
 	if len(os.Args) < 2 {
 		printUsage()
@@ -43,16 +114,20 @@ func main() {
 	switch cmd {
 	case "put":
 		putFlagSet.Parse(os.Args[2:])
-		if *putSecretFlag != "" {
-			// Decode as hex.
-			dec, err := hex.DecodeString(*putSecretFlag)
-			if err != nil {
+		if s := *putSecretFlag; s != "" {
+			var err error
+			if secret, err = eris.ParseConvergenceSecret(s); err != nil {
 				log.Fatalf("invalid secret: %v", err)
 			}
-			if len(dec) != eris.ConvergenceSecretSize {
-				log.Fatalf("invalid secret: expected %d bytes, got %d", eris.ConvergenceSecretSize, len(dec))
+		}
+		if path := *putSecretFileFlag; path != "" {
+			if *putSecretFlag != "" {
+				log.Fatalf("-secret and -secret-file can't be used together")
+			}
+			var err error
+			if secret, err = eris.LoadConvergenceSecret(path); err != nil {
+				log.Fatalf("loading secret: %v", err)
 			}
-			copy(secret[:], dec)
 		}
 
 		if putFlagSet.NArg() != 2 {
@@ -61,9 +136,14 @@ func main() {
 			os.Exit(1)
 		}
 
+		enc, err := eris.ParseEncoding(*putEncodingFlag)
+		if err != nil {
+			log.Fatalf("invalid -encoding: %v", err)
+		}
+
 		dir := putFlagSet.Arg(0)
 		input := putFlagSet.Arg(1)
-		if err := putFile(dir, input); err != nil {
+		if err := putFile(dir, input, *putLedgerFlag, *putLeafLogFlag, enc); err != nil {
 			log.Fatalf("error: %v", err)
 			os.Exit(1)
 		}
@@ -90,11 +170,195 @@ func main() {
 
 		dir := getFlagSet.Arg(0)
 		urn := getFlagSet.Arg(1)
-		if err := getFile(dir, urn, out); err != nil {
+		if *getPartialFlag {
+			if *getAuditFlag != "" {
+				log.Fatalf("-audit can't be used with -partial")
+			}
+			ok, err := getPartial(dir, urn, out)
+			if err != nil {
+				log.Fatalf("error: %v", err)
+			}
+			if !ok {
+				os.Exit(1)
+			}
+			return
+		}
+		if err := getFile(dir, urn, out, *getAuditFlag); err != nil {
+			log.Fatalf("error: %v", err)
+			os.Exit(1)
+		}
+
+	case "cat":
+		catFlagSet.Parse(os.Args[2:])
+		if catFlagSet.NArg() != 2 {
+			log.Printf("expected 2 arguments, got %d", catFlagSet.NArg())
+			printUsage()
+			os.Exit(1)
+		}
+		if *catOffsetFlag < 0 {
+			log.Fatalf("invalid offset: %d", *catOffsetFlag)
+		}
+
+		var out io.Writer = os.Stdout
+		if *catOutFlag != "-" && *catOutFlag != "" {
+			f, err := os.OpenFile(*catOutFlag, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+			if err != nil {
+				log.Fatalf("error creating output file: %v", err)
+			}
+			defer f.Close()
+			out = f
+		}
+
+		if err := catRange(catFlagSet.Arg(0), catFlagSet.Arg(1), *catOffsetFlag, *catLengthFlag, out); err != nil {
+			log.Fatalf("error: %v", err)
+		}
+
+	case "verify":
+		verifyFlagSet.Parse(os.Args[2:])
+		if verifyFlagSet.NArg() != 2 {
+			log.Printf("expected 2 arguments, got %d", verifyFlagSet.NArg())
+			printUsage()
+			os.Exit(1)
+		}
+
+		ok, err := verifyFile(verifyFlagSet.Arg(0), verifyFlagSet.Arg(1), *verifyJSONFlag, *verifySampleFlag, *verifySeedFlag, *verifyBudgetFlag)
+		if err != nil {
 			log.Fatalf("error: %v", err)
+		}
+		if !ok {
 			os.Exit(1)
 		}
 
+	case "stat":
+		statFlagSet.Parse(os.Args[2:])
+		if statFlagSet.NArg() != 2 {
+			log.Printf("expected 2 arguments, got %d", statFlagSet.NArg())
+			printUsage()
+			os.Exit(1)
+		}
+
+		if err := statFile(statFlagSet.Arg(0), statFlagSet.Arg(1), *statJSONFlag); err != nil {
+			log.Fatalf("error: %v", err)
+		}
+
+	case "tree":
+		treeFlagSet.Parse(os.Args[2:])
+		if treeFlagSet.NArg() < 2 {
+			log.Printf("expected at least 2 arguments, got %d", treeFlagSet.NArg())
+			printUsage()
+			os.Exit(1)
+		}
+
+		opts := eris.DumpOptions{
+			ShowKeys:   *treeKeysFlag,
+			OmitLeaves: *treeOmitLeavesFlag,
+		}
+		if *treeDOTFlag {
+			opts.Format = eris.DumpDOT
+		}
+		if err := dumpTree(treeFlagSet.Arg(0), treeFlagSet.Args()[1:], opts); err != nil {
+			log.Fatalf("error: %v", err)
+		}
+
+	case "dedup":
+		if len(os.Args) < 4 {
+			log.Printf("expected at least 2 arguments, got %d", len(os.Args)-2)
+			printUsage()
+			os.Exit(1)
+		}
+		if err := analyzeDedup(os.Args[2], os.Args[3:]); err != nil {
+			log.Fatalf("error: %v", err)
+		}
+
+	case "migrate":
+		migrateFlagSet.Parse(os.Args[2:])
+		if migrateFlagSet.NArg() != 2 {
+			log.Printf("expected 2 arguments, got %d", migrateFlagSet.NArg())
+			printUsage()
+			os.Exit(1)
+		}
+
+		if err := migrateDir(migrateFlagSet.Arg(0), migrateFlagSet.Arg(1), *migrateBookmarkFlag, *migrateParallelFlag); err != nil {
+			log.Fatalf("error: %v", err)
+		}
+
+	case "sync":
+		syncFlagSet.Parse(os.Args[2:])
+		if syncFlagSet.NArg() < 3 {
+			log.Printf("expected at least 3 arguments, got %d", syncFlagSet.NArg())
+			printUsage()
+			os.Exit(1)
+		}
+
+		args := syncFlagSet.Args()
+		if err := syncDirs(args[0], args[1], args[2:], *syncParallelFlag, *syncDryRunFlag, *syncLedgerFlag); err != nil {
+			log.Fatalf("error: %v", err)
+		}
+
+	case "gc":
+		gcFlagSet.Parse(os.Args[2:])
+		if gcFlagSet.NArg() != 1 {
+			log.Printf("expected 1 argument, got %d", gcFlagSet.NArg())
+			printUsage()
+			os.Exit(1)
+		}
+		if *gcPinsFlag == "" {
+			log.Printf("the -pins flag is required")
+			printUsage()
+			os.Exit(1)
+		}
+
+		if err := gcDir(gcFlagSet.Arg(0), *gcPinsFlag, *gcGraceFlag, *gcDryRunFlag); err != nil {
+			log.Fatalf("error: %v", err)
+		}
+
+	case "check":
+		checkFlagSet.Parse(os.Args[2:])
+		if checkFlagSet.NArg() != 1 {
+			log.Printf("expected 1 argument, got %d", checkFlagSet.NArg())
+			printUsage()
+			os.Exit(1)
+		}
+
+		if err := checkDir(checkFlagSet.Arg(0), *checkRepairFlag); err != nil {
+			log.Fatalf("error: %v", err)
+		}
+
+	case "serve":
+		serveFlagSet.Parse(os.Args[2:])
+		if serveFlagSet.NArg() != 1 {
+			log.Printf("expected 1 argument, got %d", serveFlagSet.NArg())
+			printUsage()
+			os.Exit(1)
+		}
+
+		if err := serveDir(serveFlagSet.Arg(0), *serveAddrFlag, *serveTokenFileFlag, *serveReadOnlyFlag, *serveMaxConcurrentFlag, *serveMirrorFlag); err != nil {
+			log.Fatalf("error: %v", err)
+		}
+
+	case "remote-serve":
+		remoteServeFlagSet.Parse(os.Args[2:])
+		if remoteServeFlagSet.NArg() != 1 {
+			log.Printf("expected 1 argument, got %d", remoteServeFlagSet.NArg())
+			printUsage()
+			os.Exit(1)
+		}
+
+		if err := remoteServe(remoteServeFlagSet.Arg(0), *remoteServeReadOnlyFlag); err != nil {
+			log.Fatalf("error: %v", err)
+		}
+
+	case "new-secret":
+		if len(os.Args) != 3 {
+			log.Printf("expected 1 argument, got %d", len(os.Args)-2)
+			printUsage()
+			os.Exit(1)
+		}
+
+		if err := newSecret(os.Args[2]); err != nil {
+			log.Fatalf("error: %v", err)
+		}
+
 	case "-h", "-help", "--help", "help":
 		printUsage()
 
@@ -104,22 +368,104 @@ func main() {
 	}
 }
 
+// openStore opens the store at the given location, which is either a local
+// directory or a URL of the form ssh://[user@]host[:port]/path naming a
+// directory on another machine. A remote store is reached by running
+// "erisdir remote-serve" on the other machine over ssh; the ERISDIR_REMOTE
+// environment variable overrides the command that is run. The returned
+// function closes the store.
+func openStore(loc string) (store.Store, func() error, error) {
+	if !strings.HasPrefix(loc, "ssh://") {
+		st, err := store.NewDir(loc)
+		return st, func() error { return nil }, err
+	}
+
+	u, err := url.Parse(loc)
+	if err != nil {
+		return nil, nil, err
+	}
+	if u.Path == "" {
+		return nil, nil, fmt.Errorf("no directory in %q", loc)
+	}
+	var args []string
+	if u.Port() != "" {
+		args = append(args, "-p", u.Port())
+	}
+	host := u.Hostname()
+	if u.User != nil {
+		host = u.User.Username() + "@" + host
+	}
+	remoteCmd := cmp.Or(os.Getenv("ERISDIR_REMOTE"), "erisdir")
+	args = append(args, "--", host, remoteCmd+" remote-serve -- "+shellQuote(u.Path))
+
+	verbosef("connecting to %s", u.Host)
+	cmd := exec.Command("ssh", args...)
+	cmd.Stderr = os.Stderr
+	c, err := remote.Command(cmd)
+	if err != nil {
+		return nil, nil, err
+	}
+	return c, c.Close, nil
+}
+
+// openLedger wraps st with the ledger in the given file, if any, so that the
+// blocks confirmed to be in st by an interrupted upload aren't checked again.
+// The returned function closes the ledger.
+func openLedger(st store.Store, path string) (store.Store, func() error, error) {
+	if path == "" {
+		return st, func() error { return nil }, nil
+	}
+	l, err := store.OpenLedger(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("opening ledger: %w", err)
+	}
+	if n := l.Len(); n > 0 {
+		verbosef("resuming: %d blocks already confirmed", n)
+	}
+	return store.WithLedger(st, l), l.Close, nil
+}
+
+// shellQuote quotes s for use as a single argument in a POSIX shell command.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// remoteServe serves the store in dir over stdin and stdout, for use by
+// openStore on another machine.
+func remoteServe(dir string, readOnly bool) error {
+	st, err := store.NewDir(dir)
+	if err != nil {
+		return fmt.Errorf("opening store: %w", err)
+	}
+
+	// Stdout carries the protocol, so nothing else may be written to it;
+	// log messages go to stderr.
+	rw := struct {
+		io.Reader
+		io.Writer
+	}{os.Stdin, os.Stdout}
+	return remote.Serve(context.Background(), st, rw, remote.ServeOptions{ReadOnly: readOnly})
+}
+
 func verbosef(format string, args ...any) {
 	if verbose {
 		log.Printf(format, args...)
 	}
 }
 
-func putFile(dir, file string) error {
-	// If the dir is not a directory, return an error
-	if fi, err := os.Stat(dir); err != nil || !fi.IsDir() {
-		return fmt.Errorf("directory %s does not exist", dir)
+func putFile(dir, file, ledger, leafLog string, urnEnc eris.Encoding) error {
+	st, closeStore, err := openStore(dir)
+	if err != nil {
+		return fmt.Errorf("opening store: %w", err)
 	}
+	defer closeStore()
+	st, closeLedger, err := openLedger(st, ledger)
+	if err != nil {
+		return err
+	}
+	defer closeLedger()
 
-	var (
-		rdr       io.Reader
-		blockSize int = 32 * 1024
-	)
+	var rdr io.Reader
 	if file == "-" {
 		// As a special case, if the file is "-", read from stdin.
 		rdr = os.Stdin
@@ -131,50 +477,35 @@ func putFile(dir, file string) error {
 		defer f.Close()
 
 		rdr = f
-
-		// If the file is less than 16KiB in size, then use 1KiB blocks
-		// to save on space.
-		fi, err := f.Stat()
-		if err == nil && fi.Size() < 16*1024 {
-			verbosef("file is smaller than 16KiB, using 1KiB blocks")
-			blockSize = 1024
-		}
 	}
 
-	// Create a wrapper that tells us how much we actually read.
+	// Create a wrapper that tells us how much we actually read, and let
+	// the encoder pick the block size based on the size of the content.
 	stats := &statsReader{Reader: rdr}
-	enc := eris.NewEncoder(stats, secret, blockSize)
+	contentHash := sha256.New()
+	opts := []eris.EncoderOption{eris.WithContentHash(contentHash)}
+	if leafLog != "" {
+		opts = append(opts, eris.WithLeafLog())
+	}
+	// Start the timer first, since EncodeAuto reads the start of the
+	// content to pick the block size.
 	t0 := time.Now()
-
-	var written, skipped int
-	for enc.Next() {
-		block := enc.Block()
-		ref := enc.Reference()
-
-		// Write the block to disk, keyed by the encoded reference.
-		path := filepath.Join(dir, filenameForRef(ref))
-
-		// Create the file, but if it already exists, skip it since we
-		// know that the content is already there.
-		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
-		if err != nil {
-			if os.IsExist(err) {
-				skipped++
-				continue
-			}
-			return err
-		}
-
-		// Write the block to the file.
-		_, err = f.Write(block)
-		err2 := f.Close()
-		if err := errors.Join(err, err2); err != nil {
-			return err
-		}
-
-		written++
+	enc, err := eris.EncodeAuto(stats, secret, opts...)
+	if err != nil {
+		return fmt.Errorf("reading input: %w", err)
 	}
-	if err := enc.Err(); err != nil {
+	if enc.BlockSize() == eris.BlockSizeSmall {
+		verbosef("file is smaller than 16KiB, using 1KiB blocks")
+	}
+
+	// Write all blocks to the store; blocks that already exist are
+	// skipped, since we know that the content is already there. Keep
+	// encoding while blocks are being written, which matters most for
+	// remote stores.
+	rc, encStats, err := store.EncodeToStore(context.Background(), st, enc, store.EncodeOptions{
+		MaxInFlightBytes: 16 << 20,
+	})
+	if err != nil {
 		return fmt.Errorf("encoding error: %w", err)
 	}
 
@@ -182,22 +513,58 @@ func putFile(dir, file string) error {
 	elapsed := time.Since(t0)
 	verbosef("successfully encoded file")
 	verbosef("stats:")
-	verbosef("  blocks written: %d", written)
-	verbosef("  blocks skipped: %d", skipped)
+	blockStats := enc.Stats()
+	verbosef("  blocks written: %d", encStats.Uploaded)
+	verbosef("  blocks skipped: %d", encStats.Skipped)
+	verbosef("  duplicates:     %d leaves, %d internal nodes", blockStats.DuplicateLeaves, blockStats.DuplicateInternalNodes)
 	verbosef("  bytes read:     %d", stats.numBytes)
 	verbosef("  read calls:     %d", stats.numCalls)
 	verbosef("  elapsed time:   %v", elapsed)
 	verbosef("  encoding speed: %.2f MiB/s", float64(stats.numBytes)/elapsed.Seconds()/1024/1024)
+	verbosef("  sha256:         %x", contentHash.Sum(nil))
 
-	fmt.Println(enc.Capability().MustURN())
+	if leafLog != "" {
+		if err := writeLeafLog(leafLog, enc); err != nil {
+			return err
+		}
+	}
+
+	urn, err := rc.URNWithEncoding(urnEnc)
+	if err != nil {
+		return err
+	}
+	fmt.Println(urn)
+	return nil
+}
+
+// writeLeafLog writes the leaf log recorded by enc to path, as JSON if the
+// path ends in .json and as CSV otherwise.
+func writeLeafLog(path string, enc *eris.Encoder) error {
+	leaves, err := enc.LeafLog()
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if strings.HasSuffix(path, ".json") {
+		err = leaves.WriteJSON(&buf)
+	} else {
+		err = leaves.WriteCSV(&buf)
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("writing leaf log: %w", err)
+	}
 	return nil
 }
 
-func getFile(dir, urn string, w io.Writer) error {
-	// If the dir is not a directory, return an error
-	if fi, err := os.Stat(dir); err != nil || !fi.IsDir() {
-		return fmt.Errorf("directory %s does not exist", dir)
+func getFile(dir, urn string, w io.Writer, audit string) error {
+	st, closeStore, err := openStore(dir)
+	if err != nil {
+		return fmt.Errorf("opening store: %w", err)
 	}
+	defer closeStore()
 
 	// Parse the given URN.
 	rc, err := eris.ParseReadCapabilityURN(urn)
@@ -205,30 +572,25 @@ func getFile(dir, urn string, w io.Writer) error {
 		return fmt.Errorf("invalid URN %q: %w", urn, err)
 	}
 
-	// Our fetch function will look up a file in the given directory by the
-	// encoded value of the reference.
-	var blocksRead int
-	fetch := func(_ context.Context, ref eris.Reference, buf []byte) ([]byte, error) {
-		path := filepath.Join(dir, filenameForRef(ref))
-		f, err := os.Open(path)
+	// Our fetch function will look up the block in the store, and keep
+	// track of how many blocks we've read.
+	var blocksRead int64
+	fetch := func(ctx context.Context, ref eris.Reference, buf []byte) ([]byte, error) {
+		block, err := st.Get(ctx, ref, buf)
 		if err != nil {
 			return nil, err
 		}
-		defer f.Close()
-
-		// Use the provided buffer as scratch space for reading the
-		// block; the buffer is guaranteed to be exactly blockSize.
-		if _, err := io.ReadFull(f, buf); err != nil {
-			return nil, err
-		}
-
 		blocksRead++
-		return buf, nil
+		return block, nil
 	}
 
 	// Iteratively decode the file, writing the blocks to the output writer.
 	ctx := context.Background()
-	dec := eris.NewDecoder(fetch, rc)
+	var opts []eris.DecoderOption
+	if audit != "" {
+		opts = append(opts, eris.WithConsumedLog())
+	}
+	dec := eris.NewDecoder(fetch, rc, opts...)
 	t0 := time.Now()
 	var bytesRead int64
 	for dec.Next(ctx) {
@@ -238,6 +600,14 @@ func getFile(dir, urn string, w io.Writer) error {
 		}
 		bytesRead += int64(len(block))
 	}
+
+	// Write the audit log even if decoding failed, since it records the
+	// block that it failed on.
+	if audit != "" {
+		if err := writeAuditLog(audit, rc, dec.Consumed()); err != nil {
+			return err
+		}
+	}
 	if err := dec.Err(); err != nil {
 		return fmt.Errorf("decoding error: %w", err)
 	}
@@ -252,12 +622,658 @@ func getFile(dir, urn string, w io.Writer) error {
 	return nil
 }
 
-var base32Enc = base32.StdEncoding.WithPadding(base32.NoPadding)
+// writeAuditLog writes a log of the blocks read while decoding the file with
+// the given read capability to path, one block per line. The log only records
+// references, not the key, so it can't be used to decrypt the file.
+func writeAuditLog(path string, rc eris.ReadCapability, blocks []eris.ConsumedBlock) error {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# root %s level %d\n", rc.Root.Reference.Base32(), rc.Level)
+	for _, b := range blocks {
+		fmt.Fprintf(&buf, "%s %d %v\n", b.Reference.Base32(), b.Level, b.Status)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("writing audit log: %w", err)
+	}
+	return nil
+}
+
+// catRange writes length bytes of the file with the given URN, starting at
+// offset, to w. Only the blocks containing the requested range (and the
+// internal nodes above them) are read from the store.
+func catRange(dir, urn string, offset, length int64, w io.Writer) error {
+	st, closeStore, err := openStore(dir)
+	if err != nil {
+		return fmt.Errorf("opening store: %w", err)
+	}
+	defer closeStore()
+	rc, err := eris.ParseReadCapabilityURN(urn)
+	if err != nil {
+		return fmt.Errorf("invalid URN %q: %w", urn, err)
+	}
+
+	var blocksRead int64
+	fetch := func(ctx context.Context, ref eris.Reference, buf []byte) ([]byte, error) {
+		blocksRead++
+		return st.Get(ctx, ref, buf)
+	}
+
+	ctx := context.Background()
+	dec := eris.NewDecoder(fetch, rc)
+	if err := dec.SkipTo(ctx, offset); err != nil {
+		return fmt.Errorf("seeking to offset %d: %w", offset, err)
+	}
+
+	var written int64
+	for (length < 0 || written < length) && dec.Next(ctx) {
+		block := dec.Block()
+		if length >= 0 && int64(len(block)) > length-written {
+			block = block[:length-written]
+		}
+		if _, err := w.Write(block); err != nil {
+			return fmt.Errorf("writing block: %w", err)
+		}
+		written += int64(len(block))
+	}
+	if err := dec.Err(); err != nil {
+		return fmt.Errorf("decoding error: %w", err)
+	}
+
+	verbosef("wrote %d bytes, read %d blocks", written, blocksRead)
+	return nil
+}
+
+// getPartial writes as much of the file with the given URN as can be
+// recovered to w, and prints the ranges that couldn't be. It reports whether
+// the whole file was recovered.
+func getPartial(dir, urn string, w io.Writer) (bool, error) {
+	st, closeStore, err := openStore(dir)
+	if err != nil {
+		return false, fmt.Errorf("opening store: %w", err)
+	}
+	defer closeStore()
+	rc, err := eris.ParseReadCapabilityURN(urn)
+	if err != nil {
+		return false, fmt.Errorf("invalid URN %q: %w", urn, err)
+	}
+
+	report, err := eris.DecodePartial(context.Background(), st.Get, rc, w, eris.PartialOptions{})
+	for _, h := range report.Holes {
+		if h.Size < 0 {
+			log.Printf("hole from offset %d to the end: level %d block %v: %v", h.Offset, h.Level, h.Reference, h.Err)
+		} else {
+			log.Printf("hole from offset %d to %d: level %d block %v: %v", h.Offset, h.Offset+h.Size, h.Level, h.Reference, h.Err)
+		}
+	}
+	if err != nil {
+		return false, fmt.Errorf("decoding error: %w", err)
+	}
+	if !report.OK() {
+		log.Printf("wrote %d bytes with %d holes", report.Size, len(report.Holes))
+	} else {
+		verbosef("wrote %d bytes", report.Size)
+	}
+	return report.OK(), nil
+}
+
+// verifyReport is the JSON representation of an eris.VerifyReport.
+type verifyReport struct {
+	URN        string   `json:"urn"`
+	OK         bool     `json:"ok"`
+	Blocks     int64    `json:"blocks"`
+	Missing    []string `json:"missing"`
+	Corrupt    []string `json:"corrupt"`
+	Incomplete bool     `json:"incomplete"`
+
+	// Sample is set if only a sample of the leaves was checked.
+	Sample *sampleReport `json:"sample,omitempty"`
+}
+
+// sampleReport is the JSON representation of the sampling fields of an
+// eris.SampleReport.
+type sampleReport struct {
+	Fraction  float64 `json:"fraction"`
+	Seed      int64   `json:"seed"`
+	Leaves    int64   `json:"leaves"`
+	Sampled   int64   `json:"sampled"`
+	Truncated bool    `json:"truncated"`
+}
+
+func verifyFile(dir, urn string, asJSON bool, fraction float64, seed int64, budget time.Duration) (bool, error) {
+	st, closeStore, err := openStore(dir)
+	if err != nil {
+		return false, fmt.Errorf("opening store: %w", err)
+	}
+	defer closeStore()
+	rc, err := eris.ParseReadCapabilityURN(urn)
+	if err != nil {
+		return false, fmt.Errorf("invalid URN %q: %w", urn, err)
+	}
+
+	var (
+		report eris.VerifyReport
+		ok     bool
+		sample *sampleReport
+	)
+	if fraction == 0 {
+		report, err = eris.Verify(context.Background(), st.Get, rc)
+		ok = report.OK()
+	} else {
+		// Pick a seed if none was given, and print it, so that the same
+		// sample can be checked again.
+		if seed == 0 {
+			seed = time.Now().UnixNano()
+		}
+		ctx := context.Background()
+		if budget > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, budget)
+			defer cancel()
+		}
+		var sr eris.SampleReport
+		sr, err = eris.VerifySample(ctx, st.Get, rc, fraction, seed)
+		report, ok = sr.VerifyReport, sr.OK()
+		sample = &sampleReport{
+			Fraction:  fraction,
+			Seed:      seed,
+			Leaves:    sr.Leaves,
+			Sampled:   sr.Sampled,
+			Truncated: sr.Truncated,
+		}
+	}
+	if err != nil {
+		return false, fmt.Errorf("verifying: %w", err)
+	}
+
+	// Always use non-nil slices, so that the JSON output contains empty
+	// arrays rather than nulls.
+	out := verifyReport{
+		URN:        urn,
+		OK:         ok,
+		Blocks:     report.Blocks,
+		Missing:    []string{},
+		Corrupt:    []string{},
+		Incomplete: report.Incomplete,
+		Sample:     sample,
+	}
+	for _, ref := range report.Missing {
+		out.Missing = append(out.Missing, ref.String())
+	}
+	for _, ref := range report.Corrupt {
+		out.Corrupt = append(out.Corrupt, ref.String())
+	}
+
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(out); err != nil {
+			return false, err
+		}
+		return out.OK, nil
+	}
+
+	for _, ref := range out.Missing {
+		fmt.Printf("missing: %s\n", ref)
+	}
+	for _, ref := range out.Corrupt {
+		fmt.Printf("corrupt: %s\n", ref)
+	}
+	if out.Incomplete {
+		fmt.Println("some blocks could not be checked because an internal node was missing or corrupt")
+	}
+	if sample != nil {
+		fmt.Printf("sampled %d of %d leaves with seed %d\n", sample.Sampled, sample.Leaves, sample.Seed)
+		if sample.Truncated {
+			fmt.Println("ran out of time before every sampled block was checked")
+		}
+	}
+	fmt.Printf("%d valid, %d missing, %d corrupt blocks\n", out.Blocks, len(out.Missing), len(out.Corrupt))
+	return out.OK, nil
+}
+
+func dumpTree(dir string, urns []string, opts eris.DumpOptions) error {
+	st, closeStore, err := openStore(dir)
+	if err != nil {
+		return fmt.Errorf("opening store: %w", err)
+	}
+	defer closeStore()
+
+	var rcs []eris.ReadCapability
+	for _, urn := range urns {
+		rc, err := eris.ParseReadCapabilityURN(urn)
+		if err != nil {
+			return fmt.Errorf("invalid URN %q: %w", urn, err)
+		}
+		rcs = append(rcs, rc)
+	}
+	return eris.DumpTrees(context.Background(), st.Get, rcs, os.Stdout, opts)
+}
+
+type statReport struct {
+	URN          string  `json:"urn"`
+	Level        int     `json:"level"`
+	BlockSize    int     `json:"block_size"`
+	Size         int64   `json:"size"`
+	Blocks       int64   `json:"blocks"`
+	UniqueBlocks int64   `json:"unique_blocks"`
+	StoredBytes  int64   `json:"stored_bytes"`
+	DedupRatio   float64 `json:"dedup_ratio"`
+}
+
+func statFile(dir, urn string, asJSON bool) error {
+	st, closeStore, err := openStore(dir)
+	if err != nil {
+		return fmt.Errorf("opening store: %w", err)
+	}
+	defer closeStore()
+	rc, err := eris.ParseReadCapabilityURN(urn)
+	if err != nil {
+		return fmt.Errorf("invalid URN %q: %w", urn, err)
+	}
+
+	ctx := context.Background()
+	size, err := eris.ContentSize(ctx, st.Get, rc)
+	if err != nil {
+		return fmt.Errorf("reading content size: %w", err)
+	}
+	report, err := eris.AnalyzeDedup(ctx, st.Get, []eris.ReadCapability{rc})
+	if err != nil {
+		return fmt.Errorf("walking tree: %w", err)
+	}
+
+	// The dedup ratio compares storing every block reference separately
+	// with storing each distinct block once.
+	stats := report.Total
+	out := statReport{
+		URN:          urn,
+		Level:        rc.Level,
+		BlockSize:    rc.BlockSize,
+		Size:         size,
+		Blocks:       stats.Blocks,
+		UniqueBlocks: stats.UniqueBlocks,
+		StoredBytes:  stats.UniqueBytes,
+		DedupRatio:   float64(stats.Bytes) / float64(stats.UniqueBytes),
+	}
+
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(out)
+	}
+	fmt.Printf("level:        %d\n", out.Level)
+	fmt.Printf("block size:   %d\n", out.BlockSize)
+	fmt.Printf("size:         %d bytes\n", out.Size)
+	fmt.Printf("blocks:       %d (%d unique)\n", out.Blocks, out.UniqueBlocks)
+	fmt.Printf("stored bytes: %d\n", out.StoredBytes)
+	fmt.Printf("dedup ratio:  %.2f\n", out.DedupRatio)
+	return nil
+}
+
+func analyzeDedup(dir string, urns []string) error {
+	st, closeStore, err := openStore(dir)
+	if err != nil {
+		return fmt.Errorf("opening store: %w", err)
+	}
+	defer closeStore()
+
+	var rcs []eris.ReadCapability
+	for _, urn := range urns {
+		rc, err := eris.ParseReadCapabilityURN(urn)
+		if err != nil {
+			return fmt.Errorf("invalid URN %q: %w", urn, err)
+		}
+		rcs = append(rcs, rc)
+	}
+
+	report, err := eris.AnalyzeDedup(context.Background(), st.Get, rcs)
+	if err != nil {
+		return err
+	}
+
+	for i, stats := range report.Trees {
+		fmt.Printf("%d: %d blocks (%d unique), %d bytes (%d unique)\n",
+			i, stats.Blocks, stats.UniqueBlocks, stats.Bytes, stats.UniqueBytes)
+	}
+	fmt.Printf("total: %d blocks (%d unique), %d bytes (%d unique)\n",
+		report.Total.Blocks, report.Total.UniqueBlocks, report.Total.Bytes, report.Total.UniqueBytes)
+	fmt.Printf("saved by deduplication: %d bytes (%d across files)\n",
+		report.SavedBytes(), report.SharedBytes())
 
-func filenameForRef(ref eris.Reference) string {
-	// The filename is the base32-encoded hash of the reference; this
-	// mimics the upstream ERIS specification for cloud storage.
-	return base32Enc.EncodeToString(ref[:])
+	fmt.Println("")
+	fmt.Println("shared blocks:")
+	for i, row := range report.Shared {
+		fmt.Printf("%4d:", i)
+		for _, n := range row {
+			fmt.Printf(" %8d", n)
+		}
+		fmt.Println()
+	}
+	return nil
+}
+
+func migrateDir(srcDir, dstDir, bookmark string, parallel int) error {
+	src, err := store.NewDir(srcDir)
+	if err != nil {
+		return fmt.Errorf("opening source store: %w", err)
+	}
+	dst, err := store.NewDir(dstDir)
+	if err != nil {
+		return fmt.Errorf("opening destination store: %w", err)
+	}
+
+	// If we have a bookmark from a previous run, resume from there.
+	opts := store.MigrateOptions{Workers: parallel}
+	if bookmark != "" {
+		data, err := os.ReadFile(bookmark)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+		case err != nil:
+			return fmt.Errorf("reading bookmark: %w", err)
+		default:
+			dec, err := hex.DecodeString(strings.TrimSpace(string(data)))
+			if err != nil || len(dec) != eris.ReferenceSize {
+				return fmt.Errorf("invalid bookmark in %s", bookmark)
+			}
+			copy(opts.StartAfter[:], dec)
+			verbosef("resuming after block %v", opts.StartAfter)
+		}
+	}
+	saveBookmark := func(ref eris.Reference) {
+		if bookmark == "" {
+			return
+		}
+		if err := os.WriteFile(bookmark, []byte(ref.String()+"\n"), 0644); err != nil {
+			log.Printf("error saving bookmark: %v", err)
+		}
+	}
+
+	// Periodically save our progress and report it.
+	var processed int64
+	opts.Progress = func(res store.MigrateResult) {
+		processed++
+		if processed%1000 == 0 {
+			saveBookmark(res.Last)
+			verbosef("processed %d blocks", processed)
+		}
+	}
+	opts.OnCorrupt = func(ref eris.Reference, err error) {
+		log.Printf("skipping corrupt block %v: %v", ref, err)
+	}
+
+	t0 := time.Now()
+	res, err := store.Migrate(context.Background(), src, dst, opts)
+	if res.Last != opts.StartAfter {
+		saveBookmark(res.Last)
+	}
+	if err != nil {
+		return fmt.Errorf("migrating: %w", err)
+	}
+
+	verbosef("successfully migrated store")
+	verbosef("stats:")
+	verbosef("  blocks copied:  %d", res.Copied)
+	verbosef("  bytes copied:   %d", res.CopiedBytes)
+	verbosef("  blocks skipped: %d", res.Skipped)
+	verbosef("  corrupt blocks: %d", len(res.Corrupt))
+	verbosef("  elapsed time:   %v", time.Since(t0))
+	return nil
+}
+
+func syncDirs(srcDir, dstDir string, urns []string, parallel int, dryRun bool, ledger string) error {
+	src, closeSrc, err := openStore(srcDir)
+	if err != nil {
+		return fmt.Errorf("opening source store: %w", err)
+	}
+	defer closeSrc()
+	dst, closeDst, err := openStore(dstDir)
+	if err != nil {
+		return fmt.Errorf("opening destination store: %w", err)
+	}
+	defer closeDst()
+	dst, closeLedger, err := openLedger(dst, ledger)
+	if err != nil {
+		return err
+	}
+	defer closeLedger()
+
+	rcs := make([]eris.ReadCapability, len(urns))
+	for i, urn := range urns {
+		rcs[i], err = eris.ParseReadCapabilityURN(urn)
+		if err != nil {
+			return fmt.Errorf("invalid URN %q: %w", urn, err)
+		}
+	}
+
+	// Blocks are written bottom-up, so an interrupted sync can be resumed
+	// just by running it again; we only need to report progress.
+	opts := store.ReplicateOptions{
+		Parallel: parallel,
+		DryRun:   dryRun,
+		Progress: func(res store.ReplicateResult) {
+			if res.Copied%1000 == 0 {
+				verbosef("copied %d blocks", res.Copied)
+			}
+		},
+	}
+
+	t0 := time.Now()
+	res, err := store.Replicate(context.Background(), src, dst, rcs, opts)
+	if err != nil {
+		return fmt.Errorf("syncing (copied %d blocks before failing): %w", res.Copied, err)
+	}
+
+	if dryRun {
+		fmt.Printf("%d blocks (%d bytes) missing from destination\n", res.Copied, res.CopiedBytes)
+		return nil
+	}
+	verbosef("successfully synced %d files", len(rcs))
+	verbosef("stats:")
+	verbosef("  blocks copied:  %d", res.Copied)
+	verbosef("  bytes copied:   %d", res.CopiedBytes)
+	verbosef("  blocks present: %d", res.Present)
+	verbosef("  elapsed time:   %v", time.Since(t0))
+	return nil
+}
+
+func gcDir(dir, pinsPath string, grace time.Duration, dryRun bool) error {
+	st, err := store.NewDir(dir)
+	if err != nil {
+		return fmt.Errorf("opening store: %w", err)
+	}
+	roots, err := readPins(pinsPath)
+	if err != nil {
+		return err
+	}
+	verbosef("keeping %d files", len(roots))
+
+	t0 := time.Now()
+	res, err := store.GC(context.Background(), st, roots, store.GCOptions{
+		Grace:  grace,
+		DryRun: dryRun,
+	})
+	if err != nil {
+		return fmt.Errorf("collecting garbage (deleted %d blocks before failing): %w", res.Deleted, err)
+	}
+
+	if dryRun {
+		fmt.Printf("%d blocks (%d bytes) would be reclaimed\n", res.Deleted, res.DeletedBytes)
+	} else {
+		fmt.Printf("reclaimed %d blocks (%d bytes)\n", res.Deleted, res.DeletedBytes)
+	}
+
+	// Also clean up after any writes that were interrupted, which GC
+	// can't see since they never became blocks.
+	var compacted store.CompactResult
+	if !dryRun {
+		compacted, err = st.Compact(context.Background(), store.CompactOptions{})
+		if err != nil {
+			return fmt.Errorf("removing temporary files: %w", err)
+		}
+	}
+	verbosef("stats:")
+	verbosef("  blocks kept:   %d", res.Marked)
+	verbosef("  recent blocks: %d", res.Recent)
+	verbosef("  temp files:    %d removed (%d bytes)", compacted.Removed, compacted.RemovedBytes)
+	verbosef("  elapsed time:  %v", time.Since(t0))
+	return nil
+}
+
+func checkDir(dir string, repair bool) error {
+	st, err := store.NewDir(dir)
+	if err != nil {
+		return fmt.Errorf("opening store: %w", err)
+	}
+
+	t0 := time.Now()
+	res, err := st.Check(context.Background(), store.CheckOptions{Repair: repair})
+	if err != nil {
+		return fmt.Errorf("checking store: %w", err)
+	}
+	for _, ref := range res.Torn {
+		fmt.Printf("torn block: %v\n", ref)
+	}
+	for _, ref := range res.Corrupt {
+		fmt.Printf("corrupt block: %v\n", ref)
+	}
+	for _, name := range res.Misnamed {
+		fmt.Printf("misnamed block: %s\n", name)
+	}
+	for _, name := range res.Unknown {
+		fmt.Printf("unknown file: %s\n", name)
+	}
+
+	verbosef("stats:")
+	verbosef("  blocks checked: %d", res.Checked)
+	if repair {
+		verbosef("  torn removed:   %d", res.Removed)
+		verbosef("  renamed:        %d", res.Renamed)
+	}
+	verbosef("  elapsed time:   %v", time.Since(t0))
+
+	if len(res.Corrupt) > 0 || (!repair && len(res.Torn)+len(res.Misnamed) > 0) {
+		return errors.New("store has problems")
+	}
+	return nil
+}
+
+// readPins reads a pin file, which contains one ERIS URN per line. Blank
+// lines and lines starting with '#' are ignored.
+func readPins(path string) ([]eris.ReadCapability, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening pin file: %w", err)
+	}
+	defer f.Close()
+
+	var rcs []eris.ReadCapability
+	scanner := bufio.NewScanner(f)
+	for lineno := 1; scanner.Scan(); lineno++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rc, err := eris.ParseReadCapabilityURN(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid URN: %w", path, lineno, err)
+		}
+		rcs = append(rcs, rc)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading pin file: %w", err)
+	}
+	return rcs, nil
+}
+
+func serveDir(dir, addr, tokenFile string, readOnly bool, maxConcurrent int, mirror string) error {
+	var st store.Store
+	st, err := store.NewDir(dir)
+	if err != nil {
+		return fmt.Errorf("opening store: %w", err)
+	}
+	if mirror != "" {
+		secondary, closeMirror, err := openStore(mirror)
+		if err != nil {
+			return fmt.Errorf("opening mirror: %w", err)
+		}
+		defer closeMirror()
+
+		rr := store.NewReadRepair(st, secondary)
+		defer func() {
+			rr.Wait()
+			stats := rr.Stats()
+			verbosef("read repair: %d blocks read from mirror, %d repaired, %d failed",
+				stats.Fallbacks, stats.Repaired, stats.Failed)
+		}()
+		st = rr
+	}
+
+	opts := blockserver.Options{
+		ReadOnly:      readOnly,
+		MaxConcurrent: maxConcurrent,
+	}
+	if tokenFile != "" {
+		token, err := os.ReadFile(tokenFile)
+		if err != nil {
+			return fmt.Errorf("reading token: %w", err)
+		}
+		opts.Token = strings.TrimSpace(string(token))
+		if opts.Token == "" {
+			return fmt.Errorf("token file %s is empty", tokenFile)
+		}
+	}
+
+	// The remote protocol shares the token: without it, connections are
+	// read-only.
+	mux := http.NewServeMux()
+	h := blockserver.NewHandler(st, opts)
+	mux.Handle(blockserver.Path, h)
+	mux.Handle("/healthz", h.HealthHandler())
+	mux.HandleFunc("/remote", func(w http.ResponseWriter, r *http.Request) {
+		ro := readOnly || !blockserver.HasBearerToken(r, opts.Token)
+		remote.Handler(st, remote.ServeOptions{ReadOnly: ro}).ServeHTTP(w, r)
+	})
+
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       time.Minute,
+		WriteTimeout:      time.Minute,
+		IdleTimeout:       2 * time.Minute,
+		MaxHeaderBytes:    16 << 10,
+	}
+
+	// Shut down gracefully on SIGINT or SIGTERM, letting in-flight
+	// requests complete.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	shutdownErr := make(chan error, 1)
+	go func() {
+		<-ctx.Done()
+		verbosef("shutting down")
+		sctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		shutdownErr <- srv.Shutdown(sctx)
+	}()
+
+	verbosef("serving %s on %s", dir, addr)
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return <-shutdownErr
+}
+
+// newSecret generates a random convergence secret and saves it to path.
+func newSecret(path string) error {
+	secret, err := eris.RandomConvergenceSecret()
+	if err != nil {
+		return fmt.Errorf("generating secret: %w", err)
+	}
+	defer secret.Wipe()
+	if err := eris.SaveConvergenceSecret(path, secret); err != nil {
+		return fmt.Errorf("saving secret: %w", err)
+	}
+	verbosef("saved new convergence secret to %s", path)
+	return nil
 }
 
 func printUsage() {
@@ -269,13 +1285,32 @@ func printUsage() {
 	fmt.Println("  single ERIS block. each block is stored in a file with the name being")
 	fmt.Println("  the base32-encoded hash of that block's contents")
 	fmt.Println("")
+	fmt.Println("  the put, get, cat, verify, tree, dedup and sync commands also accept")
+	fmt.Println("  a store on another machine, as ssh://[user@]host[:port]/path; erisdir")
+	fmt.Println("  must be installed there, and is run over ssh with remote-serve")
+	fmt.Println("")
 	fmt.Println("commands:")
 	fmt.Println("  put [flags] <store-dir> <file>")
 	fmt.Println("    write the given file to the store directory and print its ERIS URN")
 	fmt.Println("")
 	fmt.Println("    flags:")
 	fmt.Println("      -secret <secret>")
-	fmt.Println("        the convergence secret to use when writing the file")
+	fmt.Println("        the convergence secret to use when writing the file, in hex or")
+	fmt.Println("        base32")
+	fmt.Println("      -secret-file <path>")
+	fmt.Println("        read the convergence secret from the given file, which must")
+	fmt.Println("        only be accessible by its owner")
+	fmt.Println("      -ledger <path>")
+	fmt.Println("        record the blocks that have been written in the given file; if")
+	fmt.Println("        the upload is interrupted, running it again with the same")
+	fmt.Println("        ledger skips them without checking the store")
+	fmt.Println("      -encoding <base32|zbase32|hex>")
+	fmt.Println("        the encoding of the printed URN; only base32 is defined by the")
+	fmt.Println("        ERIS specification, but erisdir accepts all three")
+	fmt.Println("      -leaf-log <path>")
+	fmt.Println("        write the offset and size of every leaf of the file, and whether")
+	fmt.Println("        it duplicates an earlier leaf, to the given file; as JSON if")
+	fmt.Println("        the path ends in .json, and as CSV otherwise")
 	fmt.Println("      -v")
 	fmt.Println("        verbose output")
 	fmt.Println("")
@@ -285,8 +1320,163 @@ func printUsage() {
 	fmt.Println("    flags:")
 	fmt.Println("      -o <path>")
 	fmt.Println("        write the output to the given file instead of stdout")
+	fmt.Println("      -audit <path>")
+	fmt.Println("        write the reference of every block read to the given file,")
+	fmt.Println("        along with whether it was verified")
+	fmt.Println("      -partial")
+	fmt.Println("        don't stop at missing or corrupt blocks, but write zeroes in")
+	fmt.Println("        place of the content they hold, print the ranges that couldn't")
+	fmt.Println("        be recovered, and exit with status 1")
 	fmt.Println("      -v")
 	fmt.Println("        verbose output")
+	fmt.Println("")
+	fmt.Println("  cat [flags] <store-dir> <urn>")
+	fmt.Println("    write part of the file with the given ERIS URN to stdout, reading")
+	fmt.Println("    only the blocks that contain it")
+	fmt.Println("")
+	fmt.Println("    flags:")
+	fmt.Println("      -offset <n>")
+	fmt.Println("        start reading at the given byte offset")
+	fmt.Println("      -length <n>")
+	fmt.Println("        read at most the given number of bytes; by default, read to")
+	fmt.Println("        the end of the file")
+	fmt.Println("      -o <path>")
+	fmt.Println("        write the output to the given file instead of stdout")
+	fmt.Println("      -v")
+	fmt.Println("        verbose output")
+	fmt.Println("")
+	fmt.Println("  verify [flags] <store-dir> <urn>")
+	fmt.Println("    check that every block of the file with the given ERIS URN is")
+	fmt.Println("    present in the store directory and valid; exits with status 1 if")
+	fmt.Println("    any block is missing or corrupt")
+	fmt.Println("")
+	fmt.Println("    flags:")
+	fmt.Println("      -json")
+	fmt.Println("        print the report as JSON")
+	fmt.Println("      -sample <fraction>")
+	fmt.Println("        check every internal node, but only a random sample of the")
+	fmt.Println("        given fraction of the leaves")
+	fmt.Println("      -seed <n>")
+	fmt.Println("        seed for choosing the sample; the seed used is printed, so that")
+	fmt.Println("        the same sample can be checked again")
+	fmt.Println("      -budget <duration>")
+	fmt.Println("        stop checking the sample after the given time")
+	fmt.Println("")
+	fmt.Println("  stat [flags] <store-dir> <urn>")
+	fmt.Println("    print the level, block size and exact size of the file with the")
+	fmt.Println("    given ERIS URN, the number of blocks in its tree, the bytes needed")
+	fmt.Println("    to store them, and how much deduplicating its blocks saves")
+	fmt.Println("")
+	fmt.Println("    flags:")
+	fmt.Println("      -json")
+	fmt.Println("        print the statistics as JSON")
+	fmt.Println("")
+	fmt.Println("  tree [flags] <store-dir> <urn>...")
+	fmt.Println("    print the structure of the ERIS tree for each of the given URNs;")
+	fmt.Println("    subtrees shared between files are only printed once")
+	fmt.Println("")
+	fmt.Println("    flags:")
+	fmt.Println("      -dot")
+	fmt.Println("        print a Graphviz DOT graph instead of text")
+	fmt.Println("      -keys")
+	fmt.Println("        print each node's key; keys are redacted by default")
+	fmt.Println("      -no-leaves")
+	fmt.Println("        omit leaf nodes from the output")
+	fmt.Println("")
+	fmt.Println("  dedup <store-dir> <urn>...")
+	fmt.Println("    print statistics about the blocks shared between the files with the")
+	fmt.Println("    given URNs, and the storage saved by deduplicating them")
+	fmt.Println("")
+	fmt.Println("  migrate [flags] <src-dir> <dst-dir>")
+	fmt.Println("    copy every block from one store directory to another, verifying")
+	fmt.Println("    each block on the way; corrupt blocks are reported and skipped")
+	fmt.Println("")
+	fmt.Println("    flags:")
+	fmt.Println("      -bookmark <path>")
+	fmt.Println("        record progress in the given file, and resume from it if it")
+	fmt.Println("        already exists")
+	fmt.Println("      -parallel <n>")
+	fmt.Println("        copy and verify up to n blocks concurrently (default 4)")
+	fmt.Println("      -v")
+	fmt.Println("        verbose output")
+	fmt.Println("")
+	fmt.Println("  sync [flags] <src-dir> <dst-dir> <urn>...")
+	fmt.Println("    copy every block of the files with the given URNs from one store")
+	fmt.Println("    directory to another; an interrupted sync resumes where it stopped")
+	fmt.Println("    when run again")
+	fmt.Println("")
+	fmt.Println("    flags:")
+	fmt.Println("      -parallel <n>")
+	fmt.Println("        copy up to n blocks concurrently (default 4)")
+	fmt.Println("      -dry-run")
+	fmt.Println("        only print the number of blocks missing from the destination")
+	fmt.Println("      -ledger <path>")
+	fmt.Println("        record the blocks that have been copied in the given file, as")
+	fmt.Println("        for put")
+	fmt.Println("      -v")
+	fmt.Println("        verbose output")
+	fmt.Println("")
+	fmt.Println("  gc [flags] -pins <file> <store-dir>")
+	fmt.Println("    delete every block in the store directory that isn't part of one of")
+	fmt.Println("    the files listed in the pin file, and print the space reclaimed; the")
+	fmt.Println("    pin file contains one URN per line")
+	fmt.Println("")
+	fmt.Println("    flags:")
+	fmt.Println("      -pins <path>")
+	fmt.Println("        the pin file; required")
+	fmt.Println("      -grace <duration>")
+	fmt.Println("        keep blocks written within the given duration, so that files")
+	fmt.Println("        being written concurrently aren't damaged (default 1h)")
+	fmt.Println("      -dry-run")
+	fmt.Println("        only print the space that would be reclaimed")
+	fmt.Println("      -v")
+	fmt.Println("        verbose output")
+	fmt.Println("")
+	fmt.Println("  check [flags] <store-dir>")
+	fmt.Println("    check every file in the store directory, reporting blocks that were")
+	fmt.Println("    torn by a crash, corrupted by bit rot, or stored under the wrong name")
+	fmt.Println("")
+	fmt.Println("    flags:")
+	fmt.Println("      -repair")
+	fmt.Println("        remove torn blocks and rename misnamed ones; corrupt blocks")
+	fmt.Println("        are left in place")
+	fmt.Println("      -v")
+	fmt.Println("        verbose output")
+	fmt.Println("")
+	fmt.Println("  serve [flags] <store-dir>")
+	fmt.Println("    serve the blocks in the store directory over HTTP, using the ERIS")
+	fmt.Println("    over HTTP protocol at /uri-res/N2R, and the multiplexed remote")
+	fmt.Println("    protocol at /remote; /healthz responds with status 503 if the")
+	fmt.Println("    store, or its mirror, is unavailable")
+	fmt.Println("")
+	fmt.Println("    flags:")
+	fmt.Println("      -addr <addr>")
+	fmt.Println("        listen on the given address (default localhost:8080)")
+	fmt.Println("      -token-file <path>")
+	fmt.Println("        require the bearer token in the given file to upload blocks")
+	fmt.Println("      -read-only")
+	fmt.Println("        reject all uploads")
+	fmt.Println("      -max-concurrent <n>")
+	fmt.Println("        handle at most n requests at once, rejecting the rest")
+	fmt.Println("        (default 256); 0 is unlimited")
+	fmt.Println("      -mirror <store>")
+	fmt.Println("        write every uploaded block to the given store too, and when a")
+	fmt.Println("        block is missing from the store directory, serve it from the")
+	fmt.Println("        mirror and copy it back")
+	fmt.Println("      -v")
+	fmt.Println("        verbose output")
+	fmt.Println("")
+	fmt.Println("  remote-serve [flags] <store-dir>")
+	fmt.Println("    serve the store directory over stdin and stdout; this is run over")
+	fmt.Println("    ssh when another machine opens an ssh:// store")
+	fmt.Println("")
+	fmt.Println("    flags:")
+	fmt.Println("      -read-only")
+	fmt.Println("        reject all uploads")
+	fmt.Println("")
+	fmt.Println("  new-secret <path>")
+	fmt.Println("    generate a random convergence secret and save it to a new file")
+	fmt.Println("    that only its owner can read, for use with put -secret-file")
 }
 
 type statsReader struct {
@@ -0,0 +1,180 @@
+//go:build js && wasm
+
+// Command wasm is an example of using eris-go from JavaScript, by compiling it
+// to WebAssembly. It can be built with the standard Go toolchain:
+//
+//	GOOS=js GOARCH=wasm go build -o main.wasm ./examples/wasm
+//	cp "$(go env GOROOT)/lib/wasm/wasm_exec.js" .
+//
+// or with TinyGo, which produces a much smaller binary (use the wasm_exec.js
+// from TinyGo's installation in this case):
+//
+//	tinygo build -o main.wasm -target wasm ./examples/wasm
+//
+// Once loaded, it defines two global functions:
+//
+//	erisEncode(data: Uint8Array): {urn: string, blocks: {[ref: string]: Uint8Array}}
+//	erisDecode(urn: string, fetchBlock: (ref: string) => Promise<Uint8Array>): Promise<Uint8Array>
+//
+// References are hex-encoded. The fetchBlock function can return either a
+// Uint8Array or a Promise that resolves to one, so that blocks can be
+// fetched over the network. See index.html for an example of how to use them.
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"syscall/js"
+
+	"github.com/andrew-d/eris-go"
+)
+
+func main() {
+	js.Global().Set("erisEncode", js.FuncOf(encode))
+	js.Global().Set("erisDecode", js.FuncOf(decode))
+
+	// Block forever, so that the functions above remain callable.
+	select {}
+}
+
+// encode implements the erisEncode function, encoding the given content with
+// the zero convergence secret.
+func encode(_ js.Value, args []js.Value) any {
+	if len(args) != 1 || !isUint8Array(args[0]) {
+		return jsError(errors.New("erisEncode: expected a Uint8Array"))
+	}
+	content := make([]byte, args[0].Length())
+	js.CopyBytesToGo(content, args[0])
+
+	var secret [eris.ConvergenceSecretSize]byte
+	enc, err := eris.EncodeAuto(bytes.NewReader(content), secret)
+	if err != nil {
+		return jsError(err)
+	}
+
+	blocks := js.Global().Get("Object").New()
+	for enc.Next() {
+		block := enc.Block()
+		arr := js.Global().Get("Uint8Array").New(len(block))
+		js.CopyBytesToJS(arr, block)
+		blocks.Set(enc.Reference().String(), arr)
+	}
+	if err := enc.Err(); err != nil {
+		return jsError(err)
+	}
+
+	urn, err := enc.Capability().URN()
+	if err != nil {
+		return jsError(err)
+	}
+	return map[string]any{
+		"urn":    urn,
+		"blocks": blocks,
+	}
+}
+
+// decode implements the erisDecode function. It returns a Promise, since
+// fetching blocks may need to wait for JavaScript promises to resolve, which
+// can't be done on the JavaScript event loop.
+func decode(_ js.Value, args []js.Value) any {
+	if len(args) != 2 || args[0].Type() != js.TypeString || args[1].Type() != js.TypeFunction {
+		return jsError(errors.New("erisDecode: expected a URN and a fetch function"))
+	}
+	urn, fetchBlock := args[0].String(), args[1]
+
+	return newPromise(func() (js.Value, error) {
+		rc, err := eris.ParseReadCapabilityURN(urn)
+		if err != nil {
+			return js.Value{}, err
+		}
+
+		fetch := func(_ context.Context, ref eris.Reference, buf []byte) ([]byte, error) {
+			v, err := await(fetchBlock.Invoke(ref.String()))
+			if err != nil {
+				return nil, fmt.Errorf("fetching block %v: %w", ref, err)
+			}
+			if !isUint8Array(v) {
+				return nil, fmt.Errorf("fetching block %v: expected a Uint8Array", ref)
+			}
+			if v.Length() != len(buf) {
+				return nil, eris.ErrInvalidBlockSize
+			}
+			js.CopyBytesToGo(buf, v)
+			return buf, nil
+		}
+
+		var out bytes.Buffer
+		dec := eris.NewDecoder(fetch, rc)
+		for dec.Next(context.Background()) {
+			out.Write(dec.Block())
+		}
+		if err := dec.Err(); err != nil {
+			return js.Value{}, err
+		}
+
+		arr := js.Global().Get("Uint8Array").New(out.Len())
+		js.CopyBytesToJS(arr, out.Bytes())
+		return arr, nil
+	})
+}
+
+// newPromise returns a JavaScript Promise that is settled with the result of
+// calling fn on a new goroutine.
+func newPromise(fn func() (js.Value, error)) js.Value {
+	executor := js.FuncOf(func(_ js.Value, args []js.Value) any {
+		resolve, reject := args[0], args[1]
+		go func() {
+			v, err := fn()
+			if err != nil {
+				reject.Invoke(jsError(err))
+				return
+			}
+			resolve.Invoke(v)
+		}()
+		return nil
+	})
+
+	// The executor is called synchronously by the Promise constructor, so
+	// it can be released as soon as the Promise has been created.
+	defer executor.Release()
+	return js.Global().Get("Promise").New(executor)
+}
+
+// await waits for v to resolve if it is a Promise (or any other thenable),
+// and returns it as-is otherwise. It must not be called on the JavaScript
+// event loop, or it will deadlock.
+func await(v js.Value) (js.Value, error) {
+	if v.Type() != js.TypeObject || v.Get("then").Type() != js.TypeFunction {
+		return v, nil
+	}
+
+	type result struct {
+		v   js.Value
+		err error
+	}
+	ch := make(chan result, 1)
+	onResolve := js.FuncOf(func(_ js.Value, args []js.Value) any {
+		ch <- result{v: args[0]}
+		return nil
+	})
+	defer onResolve.Release()
+	onReject := js.FuncOf(func(_ js.Value, args []js.Value) any {
+		ch <- result{err: errors.New(js.Global().Get("String").Invoke(args[0]).String())}
+		return nil
+	})
+	defer onReject.Release()
+
+	v.Call("then", onResolve, onReject)
+	res := <-ch
+	return res.v, res.err
+}
+
+func isUint8Array(v js.Value) bool {
+	return v.InstanceOf(js.Global().Get("Uint8Array"))
+}
+
+func jsError(err error) js.Value {
+	return js.Global().Get("Error").New(err.Error())
+}
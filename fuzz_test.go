@@ -0,0 +1,172 @@
+package eris
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// The fuzz targets in this file check that hostile read capabilities and
+// blocks are rejected with an error, rather than causing a panic or an
+// excessive allocation. Run them with, e.g.:
+//
+//	go test -fuzz=FuzzParseReadCapabilityURN
+
+func FuzzParseReadCapabilityURN(f *testing.F) {
+	for _, vector := range readTestVectors(f) {
+		if vector.URN != "" {
+			f.Add(vector.URN)
+		}
+	}
+	f.Add("")
+	f.Add("urn")
+	f.Add("urn:eris:")
+	f.Add("urn:eris:====")
+
+	f.Fuzz(func(t *testing.T, urn string) {
+		rc, err := ParseReadCapabilityURN(urn)
+		if err != nil {
+			return
+		}
+
+		// Anything that parses must round-trip.
+		urn2, err := rc.URN()
+		if err != nil {
+			t.Fatalf("URN of parsed capability: %v", err)
+		}
+		rc2, err := ParseReadCapabilityURN(urn2)
+		if err != nil {
+			t.Fatalf("reparsing %q: %v", urn2, err)
+		}
+		if !rc.Equal(rc2) {
+			t.Errorf("capability changed after round trip: %q -> %q", urn, urn2)
+		}
+	})
+}
+
+func FuzzUnmarshalBinary(f *testing.F) {
+	rc := ReadCapability{BlockSize: 1024, Level: 2}
+	data, _ := rc.MarshalBinary()
+	f.Add(data)
+	f.Add([]byte{})
+	f.Add(make([]byte, 66))
+	f.Add(append([]byte{0xff, 0xff}, make([]byte, 64)...))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var rc ReadCapability
+		if err := rc.UnmarshalBinary(data); err != nil {
+			return
+		}
+		got, err := rc.MarshalBinary()
+		if err != nil {
+			t.Fatalf("marshaling unmarshaled capability: %v", err)
+		}
+		if !bytes.Equal(got, data[:len(got)]) {
+			t.Errorf("round trip mismatch:\n  in:  %x\n  out: %x", data, got)
+		}
+	})
+}
+
+func FuzzDecodeInternalNode(f *testing.F) {
+	f.Add([]byte{})
+	f.Add(bytes.Repeat([]byte{1}, referenceKeyLen))
+	f.Add(append(bytes.Repeat([]byte{1}, referenceKeyLen), make([]byte, referenceKeyLen)...))
+	f.Add(append(make([]byte, ReferenceSize), 1))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		// Internal nodes are always a full block, so zero-extend (or
+		// truncate) the input to the block size.
+		const blockSize = 1024
+		node := make([]byte, blockSize)
+		copy(node, data)
+
+		refs, err := decodeInternalNode(node, blockSize)
+		if err != nil {
+			return
+		}
+		if len(refs) > arity(blockSize) {
+			t.Fatalf("decoded %d references from a node with arity %d", len(refs), arity(blockSize))
+		}
+		for i, ref := range refs {
+			off := i * referenceKeyLen
+			if !bytes.Equal(ref.Reference[:], node[off:off+ReferenceSize]) {
+				t.Errorf("reference %d does not match node contents", i)
+			}
+		}
+	})
+}
+
+func FuzzRemovePadding(f *testing.F) {
+	f.Add([]byte{0x80}, 1)
+	f.Add([]byte{1, 2, 3, 0x80, 0, 0, 0, 0}, 8)
+	f.Add([]byte{1, 2, 3, 0x80, 0, 0, 0, 0}, 4)
+	f.Add([]byte{0, 0, 0, 0}, 4)
+	f.Add([]byte{}, -1)
+
+	f.Fuzz(func(t *testing.T, data []byte, blockSize int) {
+		out, err := removePadding(data, blockSize)
+		if err != nil {
+			return
+		}
+
+		// The output must be a prefix of the input, followed by the
+		// marker and then only zeroes.
+		if len(out) >= len(data) || data[len(out)] != 0x80 {
+			t.Fatalf("removePadding(%x, %d) = %x, which isn't followed by a marker", data, blockSize, out)
+		}
+		for _, b := range data[len(out)+1:] {
+			if b != 0 {
+				t.Fatalf("removePadding(%x, %d) = %x, with non-zero padding", data, blockSize, out)
+			}
+		}
+	})
+}
+
+// FuzzDecoder decodes a tree made of arbitrary blocks, to check that the
+// decoder handles hostile (but correctly-addressed) blocks gracefully.
+func FuzzDecoder(f *testing.F) {
+	content := randomContent(5000)
+	rc, blocks := encodeToMap(f, content, 1024)
+	var all []byte
+	all = append(all, blocks[rc.Root.Reference]...)
+	for ref, block := range blocks {
+		if ref != rc.Root.Reference {
+			all = append(all, block...)
+		}
+	}
+	f.Add(all, byte(rc.Level), rc.Root.Key[:])
+	f.Add([]byte{}, byte(255), make([]byte, KeySize))
+
+	f.Fuzz(func(t *testing.T, data []byte, level byte, key []byte) {
+		// Split the input into blocks, and use the first one as the
+		// root of the tree.
+		const blockSize = 1024
+		blocks := make(map[Reference][]byte)
+		var root Reference
+		for i := 0; i+blockSize <= len(data); i += blockSize {
+			block := data[i : i+blockSize]
+			ref := Reference(blake2b.Sum256(block))
+			if i == 0 {
+				root = ref
+			}
+			blocks[ref] = block
+		}
+
+		rc := ReadCapability{BlockSize: blockSize, Level: int(level)}
+		rc.Root.Reference = root
+		copy(rc.Root.Key[:], key)
+
+		dec := NewDecoder(mapFetch(blocks, nil), rc)
+		var total int
+		for dec.Next(context.Background()) {
+			total += len(dec.Block())
+		}
+
+		// We can never decode more content than we have blocks for.
+		if total > len(data) {
+			t.Errorf("decoded %d bytes from %d bytes of blocks", total, len(data))
+		}
+	})
+}
@@ -0,0 +1,60 @@
+package eris
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Well-known keys for the hints carried by URNWithHints.
+const (
+	// HintName is the key of a hint giving a file name for the content.
+	HintName = "name"
+	// HintType is the key of a hint giving the MIME type of the content.
+	HintType = "type"
+)
+
+// URNWithHints is like URN, but appends hints, such as a file name or MIME
+// type, to the URN as an RFC 8141 q-component:
+//
+//	urn:eris:BIAD...?=name=report.pdf&type=application%2Fpdf
+//
+// The hints are not part of the read capability, and aren't covered by any
+// integrity check, so readers should treat them only as suggestions for
+// displaying the content. If hints is empty, the URN has no q-component.
+func (rc ReadCapability) URNWithHints(hints url.Values) (string, error) {
+	urn, err := rc.URN()
+	if err != nil {
+		return "", err
+	}
+	if len(hints) == 0 {
+		return urn, nil
+	}
+	return urn + "?=" + hints.Encode(), nil
+}
+
+// ParseURNWithHints parses a URN returned by URNWithHints, returning the read
+// capability and any hints in its q-component. URNs without a q-component are
+// accepted, and return empty hints.
+//
+// As required by RFC 8141, any r-component (introduced by "?+") or
+// f-component (introduced by "#") is ignored.
+func ParseURNWithHints(urn string) (ReadCapability, url.Values, error) {
+	urn, _, _ = strings.Cut(urn, "#")
+	assigned, query, hasQuery := strings.Cut(urn, "?=")
+	// The r-component, if any, comes before the q-component.
+	assigned, _, _ = strings.Cut(assigned, "?+")
+
+	rc, err := ParseReadCapabilityURN(assigned)
+	if err != nil {
+		return rc, nil, err
+	}
+	hints := url.Values{}
+	if hasQuery {
+		hints, err = url.ParseQuery(query)
+		if err != nil {
+			return rc, nil, fmt.Errorf("invalid URN q-component: %w", err)
+		}
+	}
+	return rc, hints, nil
+}
@@ -0,0 +1,54 @@
+package eris
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestURNWithHints(t *testing.T) {
+	rc, _ := encodeToMap(t, randomContent(2000), BlockSizeSmall)
+	hints := url.Values{
+		HintName: {"quarterly report.pdf"},
+		HintType: {"application/pdf"},
+	}
+
+	urn, err := rc.URNWithHints(hints)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(urn, rc.MustURN()+"?=") {
+		t.Errorf("URNWithHints = %q, want the URN followed by a q-component", urn)
+	}
+
+	for _, s := range []string{urn, urn + "#page=2"} {
+		got, gotHints, err := ParseURNWithHints(s)
+		if err != nil {
+			t.Fatalf("ParseURNWithHints(%q): %v", s, err)
+		}
+		if !got.Equal(rc) {
+			t.Errorf("ParseURNWithHints(%q) returned the wrong capability", s)
+		}
+		if gotHints.Get(HintName) != "quarterly report.pdf" || gotHints.Get(HintType) != "application/pdf" {
+			t.Errorf("ParseURNWithHints(%q) hints = %v, want %v", s, gotHints, hints)
+		}
+	}
+
+	// A URN with an r-component, but no hints.
+	got, gotHints, err := ParseURNWithHints(rc.MustURN() + "?+resolver=x")
+	if err != nil || !got.Equal(rc) || len(gotHints) != 0 {
+		t.Errorf("ParseURNWithHints with r-component = %+v, %v, %v", got, gotHints, err)
+	}
+
+	// Empty hints give a plain URN.
+	if plain, _ := rc.URNWithHints(nil); plain != rc.MustURN() {
+		t.Errorf("URNWithHints(nil) = %q, want %q", plain, rc.MustURN())
+	}
+
+	if _, _, err := ParseURNWithHints(rc.MustURN() + "?=name=%zz"); err == nil {
+		t.Error("ParseURNWithHints accepted an invalid q-component")
+	}
+	if _, err := ParseReadCapabilityURN(urn); err == nil {
+		t.Error("ParseReadCapabilityURN accepted a URN with hints")
+	}
+}
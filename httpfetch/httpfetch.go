@@ -0,0 +1,236 @@
+// Package httpfetch fetches ERIS blocks from a server that implements the ERIS
+// over HTTP protocol, such as one run with the blockserver package, so that
+// content published on a public block endpoint can be decoded with a single
+// call:
+//
+//	fetch, err := httpfetch.New("https://blocks.example.com", httpfetch.Options{})
+//	if err != nil {
+//		// handle error
+//	}
+//	content, err := eris.DecodeRecursive(ctx, fetch, rc)
+//
+// Every block is checked against its reference before it is returned, so the
+// server doesn't need to be trusted, and a block that is corrupted on the way
+// is simply fetched again.
+package httpfetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/blake2b"
+
+	"github.com/andrew-d/eris-go"
+	"github.com/andrew-d/eris-go/blockserver"
+	"github.com/andrew-d/eris-go/store"
+)
+
+// Default values for Options.
+const (
+	DefaultRetries    = 3
+	DefaultRetryDelay = 100 * time.Millisecond
+)
+
+// maxIdleConnsPerHost is the number of idle connections kept open to the
+// server by the default client. The default of the net/http package is 2,
+// which is too few for decoders that fetch blocks in parallel.
+const maxIdleConnsPerHost = 32
+
+// Options contains options for New.
+type Options struct {
+	// Client is the HTTP client used to fetch blocks. If nil, a client is
+	// created with its own connection pool, which keeps connections to
+	// the server open between fetches, and with compression disabled,
+	// since encrypted blocks are incompressible. A client that is given
+	// should be configured similarly.
+	Client *http.Client
+
+	// Header contains additional headers to send with each request; for
+	// example, an Authorization header for a server that requires one.
+	Header http.Header
+
+	// Retries is the number of times that a fetch is retried after a
+	// failure that may be temporary: a network error, a 5xx or 429
+	// response, or a block that doesn't match its reference. Responses
+	// that say that a block doesn't exist, or that it may not be read,
+	// aren't retried. If zero, DefaultRetries is used; if negative,
+	// fetches aren't retried.
+	Retries int
+
+	// RetryDelay is the time to wait before the first retry of a fetch,
+	// which doubles for each further retry. If zero, DefaultRetryDelay is
+	// used.
+	RetryDelay time.Duration
+
+	// CacheDir, if non-empty, is a directory in which fetched blocks are
+	// kept, in the format of store.Dir, so that they don't need to be
+	// fetched again; it is created if it doesn't exist. Cached blocks are
+	// checked like fetched ones, and failures to write to the cache are
+	// ignored. Nothing is ever removed from the cache.
+	CacheDir string
+}
+
+// fetcher holds the state of a FetchFunc returned by New.
+type fetcher struct {
+	url        string // of the block path, without the query
+	client     *http.Client
+	header     http.Header
+	retries    int
+	retryDelay time.Duration
+	cache      *store.Dir
+}
+
+// New returns an eris.FetchFunc that fetches blocks from the ERIS over HTTP
+// server at baseURL, which is the URL that blockserver.Path is relative to;
+// for example, "https://example.com" or, for a namespace, the URL
+// "https://example.com/alice". The FetchFunc is safe for concurrent use.
+//
+// Blocks that don't exist on the server are reported with an error wrapping
+// store.ErrNotFound.
+func New(baseURL string, opts Options) (eris.FetchFunc, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("httpfetch: unsupported URL scheme %q", u.Scheme)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + blockserver.Path
+	u.RawPath = ""
+	u.RawQuery = ""
+	u.Fragment = ""
+
+	f := &fetcher{
+		url:        u.String(),
+		client:     opts.Client,
+		header:     opts.Header,
+		retries:    opts.Retries,
+		retryDelay: opts.RetryDelay,
+	}
+	if f.client == nil {
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		t.DisableCompression = true
+		t.MaxIdleConnsPerHost = maxIdleConnsPerHost
+		f.client = &http.Client{Transport: t}
+	}
+	if f.retries == 0 {
+		f.retries = DefaultRetries
+	}
+	if f.retryDelay == 0 {
+		f.retryDelay = DefaultRetryDelay
+	}
+	if opts.CacheDir != "" {
+		if err := os.MkdirAll(opts.CacheDir, 0o755); err != nil {
+			return nil, err
+		}
+		if f.cache, err = store.NewDir(opts.CacheDir); err != nil {
+			return nil, err
+		}
+	}
+	return f.fetch, nil
+}
+
+// permanentError is an error from a fetch that isn't worth retrying.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// fetch implements eris.FetchFunc.
+func (f *fetcher) fetch(ctx context.Context, ref eris.Reference, buf []byte) ([]byte, error) {
+	if f.cache != nil {
+		if block, err := f.cache.Get(ctx, ref, buf); err == nil && validBlock(block, ref) {
+			return block, nil
+		}
+	}
+
+	delay := f.retryDelay
+	for attempt := 0; ; attempt++ {
+		block, err := f.get(ctx, ref, buf)
+		if err == nil {
+			if f.cache != nil {
+				f.cache.Put(ctx, ref, block)
+			}
+			return block, nil
+		}
+
+		var perr *permanentError
+		if errors.As(err, &perr) || ctx.Err() != nil || attempt >= f.retries {
+			return nil, err
+		}
+		t := time.NewTimer(delay)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return nil, err
+		}
+		delay *= 2
+	}
+}
+
+// get makes a single request for the block with reference ref.
+func (f *fetcher) get(ctx context.Context, ref eris.Reference, buf []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.url+"?"+blockserver.BlockURN(ref), nil)
+	if err != nil {
+		return nil, &permanentError{err}
+	}
+	for k, v := range f.header {
+		req.Header[k] = v
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		// Drain what's left of the body, so that the connection can
+		// be reused.
+		io.Copy(io.Discard, io.LimitReader(resp.Body, eris.BlockSizeLarge))
+		resp.Body.Close()
+	}()
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, &permanentError{fmt.Errorf("%w: %v", store.ErrNotFound, ref)}
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return nil, fmt.Errorf("httpfetch: fetching %v: %s", ref, resp.Status)
+	default:
+		return nil, &permanentError{fmt.Errorf("httpfetch: fetching %v: %s", ref, resp.Status)}
+	}
+
+	if cap(buf) < eris.BlockSizeLarge {
+		buf = make([]byte, eris.BlockSizeLarge)
+	}
+	n, err := io.ReadFull(resp.Body, buf[:eris.BlockSizeLarge])
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, fmt.Errorf("httpfetch: reading %v: %w", ref, err)
+	}
+	block := buf[:n]
+
+	// Check for a body that is larger than any block, without reading
+	// all of it.
+	var extra [1]byte
+	if m, _ := io.ReadFull(resp.Body, extra[:]); m > 0 || !validBlock(block, ref) {
+		return nil, fmt.Errorf("httpfetch: server returned an invalid block for %v", ref)
+	}
+	return block, nil
+}
+
+// validBlock reports whether block has a valid size and matches ref.
+func validBlock(block []byte, ref eris.Reference) bool {
+	if len(block) != eris.BlockSizeSmall && len(block) != eris.BlockSizeLarge {
+		return false
+	}
+	return blake2b.Sum256(block) == ref
+}
@@ -0,0 +1,156 @@
+package httpfetch
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/andrew-d/eris-go"
+	"github.com/andrew-d/eris-go/blockserver"
+	"github.com/andrew-d/eris-go/store"
+	"github.com/andrew-d/eris-go/store/storetest"
+)
+
+// newServer starts a block server for st, with wrap (if non-nil) in front of
+// it, and returns its URL and a count of the requests it has served. wrap is
+// passed the number of each request, counting from 1.
+func newServer(t *testing.T, st store.Store, wrap func(n int64, w http.ResponseWriter, r *http.Request, h http.Handler)) (string, *atomic.Int64) {
+	t.Helper()
+	var requests atomic.Int64
+	mux := http.NewServeMux()
+	h := blockserver.NewHandler(st, blockserver.Options{})
+	mux.Handle("/ns"+blockserver.Path, http.StripPrefix("/ns", h))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := requests.Add(1)
+		if wrap != nil {
+			wrap(n, w, r, mux)
+		} else {
+			mux.ServeHTTP(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL + "/ns/", &requests
+}
+
+func TestFetch(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemory()
+	content := bytes.Repeat([]byte("fetch me over HTTP "), 1000)
+	enc := eris.NewEncoder(bytes.NewReader(content), [32]byte{}, eris.BlockSizeSmall)
+	rc, _, err := store.EncodeToStore(ctx, st, enc, store.EncodeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	baseURL, _ := newServer(t, st, nil)
+
+	fetch, err := New(baseURL, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := eris.DecodeRecursive(ctx, fetch, rc)
+	if err != nil {
+		t.Fatalf("DecodeRecursive: %v", err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Errorf("decoded content doesn't match")
+	}
+
+	missing, _ := storetest.MakeBlock(1, eris.BlockSizeSmall)
+	if _, err := fetch(ctx, missing, make([]byte, eris.BlockSizeLarge)); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("fetching missing block: got %v, want ErrNotFound", err)
+	}
+
+	if _, err := New("ftp://example.com", Options{}); err == nil {
+		t.Errorf("New with ftp URL: expected error")
+	}
+}
+
+func TestFetch_Retry(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemory()
+	ref, block := storetest.MakeBlock(1, eris.BlockSizeSmall)
+	st.Put(ctx, ref, block)
+
+	// Fail the first two requests, then serve a corrupted block, then
+	// the real one.
+	baseURL, requests := newServer(t, st, func(n int64, w http.ResponseWriter, r *http.Request, h http.Handler) {
+		switch {
+		case n <= 2:
+			http.Error(w, "try again", http.StatusServiceUnavailable)
+		case n == 3:
+			w.Write(bytes.Repeat([]byte{0}, eris.BlockSizeSmall))
+		default:
+			h.ServeHTTP(w, r)
+		}
+	})
+
+	fetch, err := New(baseURL, Options{RetryDelay: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := fetch(ctx, ref, make([]byte, eris.BlockSizeLarge))
+	if err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	if !bytes.Equal(got, block) || requests.Load() != 4 {
+		t.Errorf("got %d byte block after %d requests, want block after 4", len(got), requests.Load())
+	}
+
+	// With retries disabled, the first failure is returned.
+	requests.Store(0)
+	fetch, _ = New(baseURL, Options{Retries: -1})
+	if _, err := fetch(ctx, ref, nil); err == nil || requests.Load() != 1 {
+		t.Errorf("fetch without retries: err = %v after %d requests, want error after 1", err, requests.Load())
+	}
+
+	// Client errors aren't retried.
+	baseURL, requests = newServer(t, st, func(_ int64, w http.ResponseWriter, r *http.Request, h http.Handler) {
+		http.Error(w, "go away", http.StatusForbidden)
+	})
+	fetch, _ = New(baseURL, Options{RetryDelay: time.Millisecond})
+	if _, err := fetch(ctx, ref, nil); err == nil || requests.Load() != 1 {
+		t.Errorf("fetch with 403: err = %v after %d requests, want error after 1", err, requests.Load())
+	}
+}
+
+func TestFetch_Cache(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemory()
+	ref, block := storetest.MakeBlock(1, eris.BlockSizeLarge)
+	st.Put(ctx, ref, block)
+	baseURL, requests := newServer(t, st, nil)
+
+	cacheDir := filepath.Join(t.TempDir(), "cache")
+	fetch, err := New(baseURL, Options{CacheDir: cacheDir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		got, err := fetch(ctx, ref, make([]byte, eris.BlockSizeLarge))
+		if err != nil {
+			t.Fatalf("fetch %d: %v", i, err)
+		}
+		if !bytes.Equal(got, block) {
+			t.Fatalf("fetch %d: wrong block", i)
+		}
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("made %d requests, want 1", n)
+	}
+
+	// A corrupted cache entry is ignored, and the block is fetched again.
+	if err := os.WriteFile(filepath.Join(cacheDir, ref.Base32()), make([]byte, eris.BlockSizeLarge), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := fetch(ctx, ref, nil)
+	if err != nil || !bytes.Equal(got, block) || requests.Load() != 2 {
+		t.Errorf("fetch with corrupted cache: err = %v after %d requests", err, requests.Load())
+	}
+}
@@ -0,0 +1,222 @@
+package eris
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// indexVersion is the version byte at the start of a marshaled Index.
+const indexVersion = 1
+
+// Index maps the content of an ERIS tree to the leaf blocks that contain it,
+// allowing random access to the content with a single fetch per leaf, without
+// fetching any internal nodes.
+//
+// Leaf i contains bytes [i*BlockSize, (i+1)*BlockSize) of the content; the
+// final leaf is padded and is the only one that can contain fewer than
+// BlockSize bytes of content (possibly none at all).
+//
+// An index contains the keys of every leaf, so it grants access to the
+// content just like the read capability does; it should be protected
+// accordingly. Since an index can be serialized with MarshalBinary, it can
+// itself be encoded and stored with ERIS.
+type Index struct {
+	// BlockSize is the block size of the tree.
+	BlockSize int
+	// Level is the level of the root of the tree.
+	Level int
+	// Size is the size of the content, in bytes.
+	Size int64
+	// Leaves contains the reference-key pair of every leaf in the tree,
+	// in order.
+	Leaves []ReferenceKeyPair
+}
+
+// WithIndex returns an EncoderOption that records an Index of the content as
+// it is encoded. Once encoding has finished, it is available from
+// Encoder.Index.
+func WithIndex() EncoderOption {
+	return func(e *Encoder) {
+		e.index = &Index{BlockSize: e.blockSize}
+	}
+}
+
+// Index returns the index of the encoded content, if the encoder was created
+// with the WithIndex option. It is only valid to call this method after a
+// call to the Next method has returned false, and if there was no error.
+func (e *Encoder) Index() (*Index, error) {
+	if e.index == nil {
+		return nil, errors.New("encoder was not created with WithIndex")
+	}
+	if e.err != nil {
+		return nil, e.err
+	}
+	if e.state != 2 {
+		return nil, errors.New("encoder has not finished")
+	}
+	idx := *e.index
+	idx.Level = e.level
+	idx.Leaves = append([]ReferenceKeyPair(nil), e.index.Leaves...)
+	return &idx, nil
+}
+
+// BuildIndex creates an Index for the ERIS tree rooted at rc, by walking its
+// internal nodes. Only the internal nodes and the final leaf (to determine
+// the size of the content) are fetched.
+func BuildIndex(ctx context.Context, fetch FetchFunc, rc ReadCapability) (*Index, error) {
+	if rc.BlockSize <= 0 || rc.BlockSize%referenceKeyLen != 0 {
+		return nil, ErrInvalidBlockSize
+	}
+
+	idx := &Index{BlockSize: rc.BlockSize, Level: rc.Level}
+	err := walkLeaves(ctx, fetch, rc, func(job leafJob) error {
+		idx.Leaves = append(idx.Leaves, job.ref)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Determine the size from the final leaf.
+	last := len(idx.Leaves) - 1
+	block, err := idx.leaf(ctx, fetch, last, make([]byte, rc.BlockSize))
+	if err != nil {
+		return nil, err
+	}
+	idx.Size = int64(last)*int64(rc.BlockSize) + int64(len(block))
+	return idx, nil
+}
+
+// Locate returns the index of the leaf containing the given offset of the
+// content, and the offset within that leaf. It returns io.EOF if the offset is
+// at or past the end of the content.
+func (idx *Index) Locate(offset int64) (leaf int, within int, err error) {
+	if offset < 0 {
+		return 0, 0, fmt.Errorf("negative offset: %d", offset)
+	}
+	if offset >= idx.Size {
+		return 0, 0, io.EOF
+	}
+
+	// Check the leaf against the number of leaves before converting it
+	// to an int, which may be only 32 bits.
+	l := offset / int64(idx.BlockSize)
+	if l >= int64(len(idx.Leaves)) {
+		return 0, 0, fmt.Errorf("offset %d is in leaf %d, but index only has %d leaves", offset, l, len(idx.Leaves))
+	}
+	return int(l), int(offset % int64(idx.BlockSize)), nil
+}
+
+// Path returns the path from the root of the tree to the given leaf, as the
+// index of the child to follow in each internal node, starting from the root.
+// This can be used to locate the leaf in the tree without the index.
+func (idx *Index) Path(leaf int) []int {
+	arity := arity(idx.BlockSize)
+	path := make([]int, idx.Level)
+	for i := idx.Level - 1; i >= 0; i-- {
+		path[i] = leaf % arity
+		leaf /= arity
+	}
+	return path
+}
+
+// ReadAt reads len(p) bytes of content starting at the given offset into p,
+// fetching only the leaves that contain it. It has the same semantics as
+// io.ReaderAt.
+func (idx *Index) ReadAt(ctx context.Context, fetch FetchFunc, p []byte, off int64) (int, error) {
+	buf := make([]byte, idx.BlockSize)
+	var n int
+	for n < len(p) {
+		leaf, within, err := idx.Locate(off + int64(n))
+		if err != nil {
+			return n, err
+		}
+		block, err := idx.leaf(ctx, fetch, leaf, buf)
+		if err != nil {
+			return n, err
+		}
+		n += copy(p[n:], block[within:])
+	}
+	return n, nil
+}
+
+// leaf fetches and decrypts the given leaf, removing the padding from the
+// final leaf, and returns its content.
+func (idx *Index) leaf(ctx context.Context, fetch FetchFunc, i int, buf []byte) ([]byte, error) {
+	block, err := dereferenceNode(ctx, fetch, buf, idx.Leaves[i], 0, idx.BlockSize)
+	if err != nil {
+		return nil, err
+	}
+	if i == len(idx.Leaves)-1 {
+		return removePadding(block, idx.BlockSize)
+	}
+	return block, nil
+}
+
+// AppendBinary appends the binary representation of the index to the given
+// byte slice and returns it.
+func (idx *Index) AppendBinary(data []byte) ([]byte, error) {
+	data = append(data, indexVersion)
+	data = binary.AppendUvarint(data, uint64(idx.BlockSize))
+	data = binary.AppendUvarint(data, uint64(idx.Level))
+	data = binary.AppendUvarint(data, uint64(idx.Size))
+	for _, rk := range idx.Leaves {
+		data = append(data, rk.Reference[:]...)
+		data = append(data, rk.Key[:]...)
+	}
+	return data, nil
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface.
+func (idx *Index) MarshalBinary() ([]byte, error) {
+	return idx.AppendBinary(nil)
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
+func (idx *Index) UnmarshalBinary(data []byte) error {
+	if len(data) < 1 {
+		return errors.New("index data too short")
+	}
+	if data[0] != indexVersion {
+		return fmt.Errorf("unsupported index version: %d", data[0])
+	}
+	data = data[1:]
+
+	var fields [3]uint64
+	for i := range fields {
+		v, n := binary.Uvarint(data)
+		if n <= 0 {
+			return errors.New("invalid index header")
+		}
+		fields[i] = v
+		data = data[n:]
+	}
+	blockSize, level, size := fields[0], fields[1], fields[2]
+	if blockSize == 0 || blockSize%referenceKeyLen != 0 || blockSize > 1<<30 {
+		return fmt.Errorf("invalid index block size: %d", blockSize)
+	}
+	if level > 255 || size > 1<<62 {
+		return errors.New("invalid index header")
+	}
+
+	// Every leaf is full except for the final, padded one, so the number
+	// of leaves is determined by the size.
+	count := size/blockSize + 1
+	if uint64(len(data)) != count*referenceKeyLen {
+		return fmt.Errorf("index has %d bytes of leaf data for %d leaves", len(data), count)
+	}
+
+	idx.BlockSize = int(blockSize)
+	idx.Level = int(level)
+	idx.Size = int64(size)
+	idx.Leaves = make([]ReferenceKeyPair, count)
+	for i := range idx.Leaves {
+		copy(idx.Leaves[i].Reference[:], data[:ReferenceSize])
+		copy(idx.Leaves[i].Key[:], data[ReferenceSize:referenceKeyLen])
+		data = data[referenceKeyLen:]
+	}
+	return nil
+}
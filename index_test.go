@@ -0,0 +1,155 @@
+package eris
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"testing"
+)
+
+func TestIndex(t *testing.T) {
+	var secret [ConvergenceSecretSize]byte
+	for _, size := range []int{0, 1, 1023, 1024, 1025, 17 * 1024, 300 * 1024} {
+		content := randomContent(size)
+
+		enc := NewEncoder(bytes.NewReader(content), secret, 1024, WithIndex())
+		blocks := make(map[Reference][]byte)
+		for enc.Next() {
+			blocks[enc.Reference()] = bytes.Clone(enc.Block())
+		}
+		if err := enc.Err(); err != nil {
+			t.Fatal(err)
+		}
+		idx, err := enc.Index()
+		if err != nil {
+			t.Fatalf("size %d: Index: %v", size, err)
+		}
+		if idx.Size != int64(size) || len(idx.Leaves) != size/1024+1 {
+			t.Errorf("size %d: index has size %d and %d leaves", size, idx.Size, len(idx.Leaves))
+		}
+
+		// Building the index from the stored tree should give the
+		// same result.
+		ctx := context.Background()
+		built, err := BuildIndex(ctx, mapFetch(blocks, nil), enc.Capability())
+		if err != nil {
+			t.Fatalf("size %d: BuildIndex: %v", size, err)
+		}
+		assertIndexEqual(t, built, idx)
+
+		// As should a marshaling round trip.
+		data, err := idx.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var unmarshaled Index
+		if err := unmarshaled.UnmarshalBinary(data); err != nil {
+			t.Fatalf("size %d: UnmarshalBinary: %v", size, err)
+		}
+		assertIndexEqual(t, &unmarshaled, idx)
+
+		// Read some random ranges of content, and check that only the
+		// leaves containing them are fetched.
+		rng := rand.New(rand.NewSource(int64(size)))
+		for i := 0; i < 10 && size > 0; i++ {
+			off := rng.Int63n(int64(size))
+			buf := make([]byte, rng.Intn(3000)+1)
+
+			var calls int
+			n, err := idx.ReadAt(ctx, mapFetch(blocks, &calls), buf, off)
+			want := content[off:min(int(off)+len(buf), size)]
+			if n != len(want) || !bytes.Equal(buf[:n], want) {
+				t.Errorf("size %d: ReadAt(%d, %d) = %d bytes, want %d", size, len(buf), off, n, len(want))
+			}
+			if n < len(buf) && !errors.Is(err, io.EOF) {
+				t.Errorf("size %d: short ReadAt returned error %v, want io.EOF", size, err)
+			}
+			if wantCalls := int((off+int64(n)-1)/1024 - off/1024 + 1); calls != wantCalls {
+				t.Errorf("size %d: ReadAt fetched %d blocks, want %d", size, calls, wantCalls)
+			}
+		}
+	}
+}
+
+func TestIndex_Path(t *testing.T) {
+	rc, blocks := encodeToMap(t, randomContent(300*1024), 1024)
+	idx, err := BuildIndex(context.Background(), mapFetch(blocks, nil), rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Following the path from the root should lead to the leaf.
+	buf := make([]byte, 1024)
+	for _, leaf := range []int{0, 1, 15, 16, 255, 256, len(idx.Leaves) - 1} {
+		path := idx.Path(leaf)
+		if len(path) != rc.Level {
+			t.Fatalf("path for leaf %d has length %d, want %d", leaf, len(path), rc.Level)
+		}
+
+		curr := rc.Root
+		for depth, child := range path {
+			node, err := dereferenceNode(context.Background(), mapFetch(blocks, nil), buf, curr, rc.Level-depth, 1024)
+			if err != nil {
+				t.Fatal(err)
+			}
+			refs, err := decodeInternalNode(node, 1024)
+			if err != nil {
+				t.Fatal(err)
+			}
+			curr = refs[child]
+		}
+		if !curr.Equal(idx.Leaves[leaf]) {
+			t.Errorf("path %v for leaf %d leads to the wrong block", path, leaf)
+		}
+	}
+}
+
+func TestIndex_UnmarshalInvalid(t *testing.T) {
+	idx := &Index{BlockSize: 1024, Level: 1, Size: 2000, Leaves: make([]ReferenceKeyPair, 2)}
+	valid, _ := idx.MarshalBinary()
+
+	testCases := map[string][]byte{
+		"empty":           {},
+		"bad version":     append([]byte{2}, valid[1:]...),
+		"truncated":       valid[:len(valid)-1],
+		"extra leaf data": append(bytes.Clone(valid), make([]byte, referenceKeyLen)...),
+		"bad block size":  {indexVersion, 100, 1, 0},
+		"huge size":       {indexVersion, 0x80, 0x08, 1, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f},
+	}
+	for name, data := range testCases {
+		var got Index
+		if err := got.UnmarshalBinary(data); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func assertIndexEqual(t *testing.T, got, want *Index) {
+	t.Helper()
+	if got.BlockSize != want.BlockSize || got.Level != want.Level || got.Size != want.Size {
+		t.Errorf("index header mismatch: got %d/%d/%d, want %d/%d/%d",
+			got.BlockSize, got.Level, got.Size, want.BlockSize, want.Level, want.Size)
+	}
+	if len(got.Leaves) != len(want.Leaves) {
+		t.Fatalf("got %d leaves, want %d", len(got.Leaves), len(want.Leaves))
+	}
+	for i := range got.Leaves {
+		if !got.Leaves[i].Equal(want.Leaves[i]) {
+			t.Errorf("leaf %d mismatch", i)
+		}
+	}
+}
+
+func TestIndex_LocateGiant(t *testing.T) {
+	// An index whose size doesn't match its leaves, with a leaf number
+	// that doesn't fit in 32 bits, must not be truncated.
+	idx := &Index{BlockSize: 1024, Level: 9, Size: 1 << 45, Leaves: make([]ReferenceKeyPair, 2)}
+	if _, _, err := idx.Locate(1<<42 + 1); err == nil {
+		t.Error("expected error locating offset past the index's leaves")
+	}
+	if leaf, within, err := idx.Locate(1030); err != nil || leaf != 1 || within != 6 {
+		t.Errorf("Locate(1030) = %d, %d, %v; want 1, 6, nil", leaf, within, err)
+	}
+}
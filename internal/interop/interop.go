@@ -0,0 +1,108 @@
+// Package interop contains a test harness that checks this package against
+// other ERIS implementations, by round-tripping content between them.
+//
+// Since every implementation has its own command-line interface, the harness
+// runs external implementations through small adapter commands, which are
+// configured with environment variables; if they are not set, the tests are
+// skipped. Each command is run with "sh -c", and communicates through the
+// following environment variables:
+//
+//	ERIS_BLOCK_DIR   a directory of blocks, in the layout used by store.Dir
+//	ERIS_BLOCK_SIZE  the block size to encode with (1024 or 32768)
+//	ERIS_SECRET      the convergence secret to encode with, in hex
+//	ERIS_URN         the read capability URN to decode
+//
+// ERIS_INTEROP_ENCODE is the command used to encode content: it reads content
+// from stdin, writes its blocks to ERIS_BLOCK_DIR, and prints the URN of the
+// content to stdout.
+//
+// ERIS_INTEROP_DECODE is the command used to decode content: it reads the
+// blocks of ERIS_URN from ERIS_BLOCK_DIR and writes the content to stdout.
+package interop
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/andrew-d/eris-go"
+)
+
+// Impl describes an external ERIS implementation.
+type Impl struct {
+	// Encode is the command used to encode content; see the package
+	// documentation.
+	Encode string
+	// Decode is the command used to decode content; see the package
+	// documentation.
+	Decode string
+}
+
+// FromEnv returns the external implementation configured by the
+// ERIS_INTEROP_ENCODE and ERIS_INTEROP_DECODE environment variables. Either
+// command may be empty if it is not configured.
+func FromEnv() Impl {
+	return Impl{
+		Encode: os.Getenv("ERIS_INTEROP_ENCODE"),
+		Decode: os.Getenv("ERIS_INTEROP_DECODE"),
+	}
+}
+
+// EncodeTo encodes content with the external implementation, writing its
+// blocks to dir, and returns the resulting read capability.
+func (i Impl) EncodeTo(ctx context.Context, dir string, content []byte, secret [eris.ConvergenceSecretSize]byte, blockSize int) (eris.ReadCapability, error) {
+	cmd := i.command(ctx, i.Encode, dir,
+		"ERIS_BLOCK_SIZE="+strconv.Itoa(blockSize),
+		"ERIS_SECRET="+hex.EncodeToString(secret[:]),
+	)
+	cmd.Stdin = bytes.NewReader(content)
+	out, err := run(cmd)
+	if err != nil {
+		return eris.ReadCapability{}, fmt.Errorf("external encode: %w", err)
+	}
+
+	urn := strings.TrimSpace(string(out))
+	rc, err := eris.ParseReadCapabilityURN(urn)
+	if err != nil {
+		return eris.ReadCapability{}, fmt.Errorf("external encode returned invalid URN %q: %w", urn, err)
+	}
+	return rc, nil
+}
+
+// DecodeFrom decodes the content identified by rc with the external
+// implementation, reading its blocks from dir.
+func (i Impl) DecodeFrom(ctx context.Context, dir string, rc eris.ReadCapability) ([]byte, error) {
+	urn, err := rc.URN()
+	if err != nil {
+		return nil, err
+	}
+	out, err := run(i.command(ctx, i.Decode, dir, "ERIS_URN="+urn))
+	if err != nil {
+		return nil, fmt.Errorf("external decode: %w", err)
+	}
+	return out, nil
+}
+
+// command returns an exec.Cmd that runs the given shell command.
+func (i Impl) command(ctx context.Context, command, dir string, env ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Env = append(os.Environ(), "ERIS_BLOCK_DIR="+dir)
+	cmd.Env = append(cmd.Env, env...)
+	return cmd
+}
+
+// run runs cmd and returns its output, including its stderr in any error.
+func run(cmd *exec.Cmd) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return out, nil
+}
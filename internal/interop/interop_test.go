@@ -0,0 +1,105 @@
+package interop
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/andrew-d/eris-go"
+	"github.com/andrew-d/eris-go/store"
+)
+
+// sizes are the content sizes that are round-tripped; they cover empty
+// content and the boundaries around block and tree sizes for both block
+// sizes.
+var sizes = []int{0, 1, 1023, 1024, 1025, 16 * 1024, 32*1024 - 1, 32 * 1024, 512 * 1024, 512*1024 + 1}
+
+func testContent(size int) []byte {
+	content := make([]byte, size)
+	rand.New(rand.NewSource(int64(size))).Read(content)
+	return content
+}
+
+func forEachCase(t *testing.T, fn func(t *testing.T, content []byte, secret [eris.ConvergenceSecretSize]byte, blockSize int)) {
+	for _, blockSize := range []int{eris.BlockSizeSmall, eris.BlockSizeLarge} {
+		for _, size := range sizes {
+			t.Run(fmt.Sprintf("%d/%d", blockSize, size), func(t *testing.T) {
+				var secret [eris.ConvergenceSecretSize]byte
+				secret[0] = byte(size)
+				fn(t, testContent(size), secret, blockSize)
+			})
+		}
+	}
+}
+
+// TestGoToExternal encodes content with this package and decodes it with the
+// external implementation.
+func TestGoToExternal(t *testing.T) {
+	impl := FromEnv()
+	if impl.Decode == "" {
+		t.Skip("ERIS_INTEROP_DECODE not set")
+	}
+	ctx := context.Background()
+
+	forEachCase(t, func(t *testing.T, content []byte, secret [eris.ConvergenceSecretSize]byte, blockSize int) {
+		dir := t.TempDir()
+		st, err := store.NewDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		enc := eris.NewEncoder(bytes.NewReader(content), secret, blockSize)
+		rc, _, err := store.EncodeToStore(ctx, st, enc, store.EncodeOptions{})
+		if err != nil {
+			t.Fatalf("encoding: %v", err)
+		}
+
+		got, err := impl.DecodeFrom(ctx, dir, rc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, content) {
+			t.Errorf("external implementation decoded %d bytes that differ from the %d bytes of content", len(got), len(content))
+		}
+	})
+}
+
+// TestExternalToGo encodes content with the external implementation and
+// decodes it with this package, and checks that both implementations produce
+// the same read capability.
+func TestExternalToGo(t *testing.T) {
+	impl := FromEnv()
+	if impl.Encode == "" {
+		t.Skip("ERIS_INTEROP_ENCODE not set")
+	}
+	ctx := context.Background()
+
+	forEachCase(t, func(t *testing.T, content []byte, secret [eris.ConvergenceSecretSize]byte, blockSize int) {
+		dir := t.TempDir()
+		rc, err := impl.EncodeTo(ctx, dir, content, secret, blockSize)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want, err := eris.ComputeCapability(bytes.NewReader(content), secret, blockSize)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !rc.Equal(want) {
+			t.Errorf("capability mismatch:\n  external: %s\n  eris-go:  %s", rc.MustURN(), want.MustURN())
+		}
+
+		st, err := store.NewDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := eris.DecodeRecursive(ctx, st.Get, rc)
+		if err != nil {
+			t.Fatalf("decoding: %v", err)
+		}
+		if !bytes.Equal(got, content) {
+			t.Errorf("decoded %d bytes that differ from the %d bytes of content", len(got), len(content))
+		}
+	})
+}
@@ -0,0 +1,114 @@
+package eris
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+)
+
+// LeafRecord describes a single leaf of encoded content; see WithLeafLog.
+type LeafRecord struct {
+	// Reference is the reference of the leaf's block.
+	Reference Reference
+	// Offset is the offset of the leaf's content in the encoded content.
+	Offset int64
+	// Size is the number of bytes of content in the leaf, not including
+	// padding.
+	Size int
+	// Duplicate reports whether an earlier leaf of the same content had
+	// the same block, so that the leaf didn't need to be stored again.
+	Duplicate bool
+}
+
+// LeafLog lists the leaves of some encoded content, in order.
+type LeafLog []LeafRecord
+
+// leafLogger records a LeafLog for an Encoder.
+type leafLogger struct {
+	log  LeafLog
+	seen map[Reference]bool
+}
+
+// WithLeafLog returns an EncoderOption that records the leaves of the content
+// as it is encoded: where each leaf starts in the content, how much content it
+// holds, and whether it duplicates an earlier leaf. Once encoding has
+// finished, the log is available from Encoder.LeafLog.
+//
+// Since leaf boundaries and references only depend on the content, the block
+// size and the convergence secret, the log is deterministic, which makes it
+// useful for analyzing how well a dataset deduplicates. Only duplicates within
+// the content are detected; leaves that happen to be in a store already are
+// not. Unlike an Index, the log doesn't contain any keys, so it can be shared
+// without granting access to the content.
+func WithLeafLog() EncoderOption {
+	return func(e *Encoder) {
+		e.leafLog = &leafLogger{seen: make(map[Reference]bool)}
+	}
+}
+
+// add records the next leaf.
+func (l *leafLogger) add(ref Reference, size int) {
+	var offset int64
+	if n := len(l.log); n > 0 {
+		offset = l.log[n-1].Offset + int64(l.log[n-1].Size)
+	}
+	l.log = append(l.log, LeafRecord{
+		Reference: ref,
+		Offset:    offset,
+		Size:      size,
+		Duplicate: l.seen[ref],
+	})
+	l.seen[ref] = true
+}
+
+// LeafLog returns the log of leaves in the encoded content, if the encoder was
+// created with the WithLeafLog option. It is only valid to call this method
+// after a call to the Next method has returned false, and if there was no
+// error.
+func (e *Encoder) LeafLog() (LeafLog, error) {
+	if e.leafLog == nil {
+		return nil, errors.New("encoder was not created with WithLeafLog")
+	}
+	if e.err != nil {
+		return nil, e.err
+	}
+	if e.state != 2 {
+		return nil, errors.New("encoder has not finished")
+	}
+	return append(LeafLog(nil), e.leafLog.log...), nil
+}
+
+// WriteCSV writes the log to w as CSV, with a header line followed by one line
+// per leaf; references are encoded in unpadded base32.
+func (l LeafLog) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"reference", "offset", "size", "duplicate"})
+	for _, r := range l {
+		cw.Write([]string{
+			r.Reference.Base32(),
+			strconv.FormatInt(r.Offset, 10),
+			strconv.Itoa(r.Size),
+			strconv.FormatBool(r.Duplicate),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteJSON writes the log to w as a JSON array, with one object per leaf;
+// references are encoded in unpadded base32.
+func (l LeafLog) WriteJSON(w io.Writer) error {
+	type jsonRecord struct {
+		Reference string `json:"reference"`
+		Offset    int64  `json:"offset"`
+		Size      int    `json:"size"`
+		Duplicate bool   `json:"duplicate"`
+	}
+	records := make([]jsonRecord, len(l))
+	for i, r := range l {
+		records[i] = jsonRecord{r.Reference.Base32(), r.Offset, r.Size, r.Duplicate}
+	}
+	return json.NewEncoder(w).Encode(records)
+}
@@ -0,0 +1,65 @@
+package eris
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestEncoder_LeafLog(t *testing.T) {
+	// Three identical leaves, then a partial one.
+	chunk := randomContent(BlockSizeSmall)
+	content := bytes.Join([][]byte{chunk, chunk, chunk, randomContent(500)}, nil)
+
+	enc := NewEncoder(bytes.NewReader(content), [ConvergenceSecretSize]byte{}, BlockSizeSmall, WithLeafLog(), WithIndex())
+	if _, err := enc.LeafLog(); err == nil {
+		t.Error("LeafLog succeeded before encoding finished")
+	}
+	for enc.Next() {
+	}
+	log, err := enc.LeafLog()
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx, _ := enc.Index()
+
+	wantSizes := []int{1024, 1024, 1024, 500}
+	wantDup := []bool{false, true, true, false}
+	if len(log) != len(wantSizes) {
+		t.Fatalf("log has %d leaves, want %d", len(log), len(wantSizes))
+	}
+	for i, r := range log {
+		if r.Reference != idx.Leaves[i].Reference {
+			t.Errorf("leaf %d: reference doesn't match index", i)
+		}
+		if r.Offset != int64(i*BlockSizeSmall) || r.Size != wantSizes[i] || r.Duplicate != wantDup[i] {
+			t.Errorf("leaf %d = {offset %d, size %d, duplicate %v}, want {%d, %d, %v}",
+				i, r.Offset, r.Size, r.Duplicate, i*BlockSizeSmall, wantSizes[i], wantDup[i])
+		}
+	}
+
+	var csv bytes.Buffer
+	if err := log.WriteCSV(&csv); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(csv.String()), "\n")
+	if len(lines) != 5 || lines[0] != "reference,offset,size,duplicate" {
+		t.Errorf("unexpected CSV:\n%s", csv.String())
+	}
+	if want := log[1].Reference.Base32() + ",1024,1024,true"; lines[2] != want {
+		t.Errorf("CSV line 2 = %q, want %q", lines[2], want)
+	}
+
+	var js bytes.Buffer
+	if err := log.WriteJSON(&js); err != nil {
+		t.Fatal(err)
+	}
+	var records []map[string]any
+	if err := json.Unmarshal(js.Bytes(), &records); err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 4 || records[3]["reference"] != log[3].Reference.Base32() || records[3]["size"] != 500.0 {
+		t.Errorf("unexpected JSON: %s", js.String())
+	}
+}
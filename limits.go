@@ -0,0 +1,55 @@
+package eris
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrLimitExceeded is matched by every *LimitError, so that callers can check
+// for any exceeded limit with errors.Is.
+var ErrLimitExceeded = errors.New("decoder limit exceeded")
+
+// Limit identifies one of the limits that can be set on a Decoder.
+type Limit int
+
+const (
+	// LimitLevel is the limit set by WithMaxLevel.
+	LimitLevel Limit = iota + 1
+	// LimitBytes is the limit set by WithMaxBytes.
+	LimitBytes
+	// LimitBlocks is the limit set by WithMaxBlocks.
+	LimitBlocks
+)
+
+// String implements the fmt.Stringer interface.
+func (l Limit) String() string {
+	switch l {
+	case LimitLevel:
+		return "tree level"
+	case LimitBytes:
+		return "decoded bytes"
+	case LimitBlocks:
+		return "fetched blocks"
+	default:
+		return fmt.Sprintf("Limit(%d)", int(l))
+	}
+}
+
+// LimitError is returned by a Decoder when decoding would exceed one of the
+// limits set with WithMaxLevel, WithMaxBytes or WithMaxBlocks.
+type LimitError struct {
+	// Limit is the limit that was exceeded.
+	Limit Limit
+	// Max is the configured value of the limit.
+	Max int64
+}
+
+// Error implements the error interface.
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%v: maximum %v is %d", ErrLimitExceeded, e.Limit, e.Max)
+}
+
+// Is reports whether target is ErrLimitExceeded, for use with errors.Is.
+func (e *LimitError) Is(target error) bool {
+	return target == ErrLimitExceeded
+}
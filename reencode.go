@@ -0,0 +1,98 @@
+package eris
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+// decoderReader adapts a Decoder to the io.Reader interface.
+type decoderReader struct {
+	ctx context.Context
+	dec *Decoder
+
+	// buf is the unread portion of the current block.
+	buf []byte
+}
+
+func (r *decoderReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		if !r.dec.Next(r.ctx) {
+			if err := r.dec.Err(); err != nil {
+				return 0, err
+			}
+			return 0, io.EOF
+		}
+		r.buf = r.dec.Block()
+	}
+
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+// Reencode decodes the content of the ERIS tree rooted at rc and re-encodes it
+// with the given convergence secret and block size, calling emit for every
+// block of the new encoding. It returns the read capability for the new
+// encoding.
+//
+// The content is streamed from the decoder to the encoder and is never held in
+// memory in its entirety, which makes this suitable for migrating large
+// content to a different convergence secret or block size.
+//
+// The block passed to emit is only valid for the duration of the call. If emit
+// returns an error, Reencode stops and returns that error.
+func Reencode(
+	ctx context.Context,
+	fetch FetchFunc,
+	rc ReadCapability,
+	newSecret [ConvergenceSecretSize]byte,
+	newBlockSize int,
+	emit func(Reference, []byte) error,
+) (ReadCapability, error) {
+	if newBlockSize != BlockSizeSmall && newBlockSize != BlockSizeLarge {
+		return ReadCapability{}, fmt.Errorf("unsupported block size: %d", newBlockSize)
+	}
+
+	r := &decoderReader{ctx: ctx, dec: NewDecoder(fetch, rc)}
+	enc := NewEncoder(r, newSecret, newBlockSize)
+	for enc.Next() {
+		if err := emit(enc.Reference(), enc.Block()); err != nil {
+			return ReadCapability{}, err
+		}
+	}
+	if err := enc.Err(); err != nil {
+		return ReadCapability{}, err
+	}
+	return enc.Capability(), nil
+}
+
+// TranscodeBlockSize re-encodes the content of the ERIS tree rooted at rc with
+// the other block size defined by the specification, keeping the same
+// convergence secret: content encoded with 1KiB blocks is re-encoded with
+// 32KiB blocks, and vice versa. It calls emit for every block of the new
+// encoding and returns the new read capability.
+//
+// The secret must be the convergence secret that was used to encode the
+// original content for the new encoding to deduplicate against other content
+// encoded with that secret; it cannot be recovered from rc.
+//
+// See Reencode for details on streaming and the semantics of emit.
+func TranscodeBlockSize(
+	ctx context.Context,
+	fetch FetchFunc,
+	rc ReadCapability,
+	secret [ConvergenceSecretSize]byte,
+	emit func(Reference, []byte) error,
+) (ReadCapability, error) {
+	var newBlockSize int
+	switch rc.BlockSize {
+	case BlockSizeSmall:
+		newBlockSize = BlockSizeLarge
+	case BlockSizeLarge:
+		newBlockSize = BlockSizeSmall
+	default:
+		return ReadCapability{}, fmt.Errorf("unsupported block size: %d", rc.BlockSize)
+	}
+	return Reencode(ctx, fetch, rc, secret, newBlockSize, emit)
+}
@@ -0,0 +1,74 @@
+package eris
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func TestReencode(t *testing.T) {
+	content := randomContent(100*1024 + 5)
+	rc, blocks := encodeToMap(t, content, BlockSizeSmall)
+
+	var newSecret [ConvergenceSecretSize]byte
+	newSecret[0] = 1
+
+	newBlocks := make(map[Reference][]byte)
+	newRC, err := Reencode(context.Background(), mapFetch(blocks, nil), rc, newSecret, BlockSizeLarge, func(ref Reference, block []byte) error {
+		newBlocks[ref] = bytes.Clone(block)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Reencode: %v", err)
+	}
+	if newRC.BlockSize != BlockSizeLarge {
+		t.Errorf("new block size = %d, want %d", newRC.BlockSize, BlockSizeLarge)
+	}
+
+	// The re-encoded content should match a fresh encode with the new
+	// parameters.
+	want := NewEncoder(bytes.NewReader(content), newSecret, BlockSizeLarge)
+	for want.Next() {
+	}
+	if !newRC.Equal(want.Capability()) {
+		t.Errorf("capability mismatch")
+	}
+
+	got, err := DecodeRecursive(context.Background(), mapFetch(newBlocks, nil), newRC)
+	if err != nil {
+		t.Fatalf("DecodeRecursive: %v", err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Errorf("decoded content mismatch")
+	}
+}
+
+func TestTranscodeBlockSize(t *testing.T) {
+	var secret [ConvergenceSecretSize]byte
+	content := randomContent(40 * 1024)
+	rc, blocks := encodeToMap(t, content, BlockSizeLarge)
+
+	// Transcode to small blocks, then back again; we should end up with
+	// the original capability since the secret is unchanged.
+	smallBlocks := make(map[Reference][]byte)
+	smallRC, err := TranscodeBlockSize(context.Background(), mapFetch(blocks, nil), rc, secret, func(ref Reference, block []byte) error {
+		smallBlocks[ref] = bytes.Clone(block)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("TranscodeBlockSize: %v", err)
+	}
+	if smallRC.BlockSize != BlockSizeSmall {
+		t.Fatalf("block size = %d, want %d", smallRC.BlockSize, BlockSizeSmall)
+	}
+
+	largeRC, err := TranscodeBlockSize(context.Background(), mapFetch(smallBlocks, nil), smallRC, secret, func(Reference, []byte) error {
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("TranscodeBlockSize: %v", err)
+	}
+	if !largeRC.Equal(rc) {
+		t.Errorf("capability mismatch after round-trip")
+	}
+}
@@ -0,0 +1,213 @@
+package remote
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/andrew-d/eris-go"
+	"github.com/andrew-d/eris-go/store"
+)
+
+// ErrClosed is returned by the methods of a Client after it has been closed.
+var ErrClosed = errors.New("remote: client closed")
+
+// Client is a store.Store that sends requests to a server over a stream; see
+// NewClient.
+type Client struct {
+	conn io.ReadWriteCloser
+
+	// wmu protects w.
+	wmu sync.Mutex
+	w   *bufio.Writer
+
+	// mu protects the fields below.
+	mu      sync.Mutex
+	nextID  uint32
+	pending map[uint32]chan *frame
+	err     error         // set once the connection has failed
+	done    chan struct{} // closed once err is set
+}
+
+// NewClient creates a Client that sends requests over conn, which must be
+// connected to a server running Serve. The client takes ownership of conn, and
+// closes it when Close is called.
+//
+// A Client is safe for concurrent use, and concurrent calls are pipelined over
+// conn: a call does not wait for any other call to complete.
+func NewClient(conn io.ReadWriteCloser) *Client {
+	c := &Client{
+		conn:    conn,
+		w:       bufio.NewWriter(conn),
+		pending: make(map[uint32]chan *frame),
+		done:    make(chan struct{}),
+	}
+	go c.readLoop()
+	return c
+}
+
+// Close closes the connection to the server. Any outstanding calls fail with
+// ErrClosed.
+func (c *Client) Close() error {
+	c.fail(ErrClosed)
+	return c.conn.Close()
+}
+
+// readLoop reads responses from the server and delivers them to the waiting
+// calls, until the connection fails.
+func (c *Client) readLoop() {
+	r := bufio.NewReader(c.conn)
+	for {
+		resp, err := readFrame(r, false)
+		if err != nil {
+			c.fail(fmt.Errorf("remote: reading response: %w", noEOF(err)))
+			return
+		}
+
+		c.mu.Lock()
+		ch, ok := c.pending[resp.id]
+		delete(c.pending, resp.id)
+		c.mu.Unlock()
+
+		// If the call is no longer pending, its context was canceled;
+		// drop the response.
+		if ok {
+			ch <- resp
+		}
+	}
+}
+
+// fail records that the connection has failed with err, and wakes all
+// outstanding calls.
+func (c *Client) fail(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err == nil {
+		c.err = err
+		close(c.done)
+	}
+}
+
+// roundTrip sends a request and waits for its response.
+func (c *Client) roundTrip(ctx context.Context, req *frame) (*frame, error) {
+	ch := make(chan *frame, 1)
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return nil, c.err
+	}
+	req.id = c.nextID
+	c.nextID++
+	c.pending[req.id] = ch
+	c.mu.Unlock()
+
+	forget := func() {
+		c.mu.Lock()
+		delete(c.pending, req.id)
+		c.mu.Unlock()
+	}
+
+	c.wmu.Lock()
+	err := writeFrame(c.w, req, true)
+	if err == nil {
+		err = c.w.Flush()
+	}
+	c.wmu.Unlock()
+	if err != nil {
+		forget()
+		err = fmt.Errorf("remote: writing request: %w", err)
+		c.fail(err)
+		return nil, err
+	}
+
+	select {
+	case resp := <-ch:
+		if resp.kind == statusError {
+			return nil, fmt.Errorf("remote: %s", resp.payload)
+		}
+		return resp, nil
+	case <-c.done:
+		return nil, c.err
+	case <-ctx.Done():
+		forget()
+		return nil, ctx.Err()
+	}
+}
+
+// Get implements the store.Store interface.
+func (c *Client) Get(ctx context.Context, ref eris.Reference, buf []byte) ([]byte, error) {
+	resp, err := c.roundTrip(ctx, &frame{kind: opGet, ref: ref})
+	if err != nil {
+		return nil, err
+	}
+	switch resp.kind {
+	case statusBlock:
+		return resp.payload, nil
+	case statusNotFound:
+		return nil, fmt.Errorf("%w: %v", store.ErrNotFound, ref)
+	}
+	return nil, fmt.Errorf("remote: unexpected status %q for get", resp.kind)
+}
+
+// Put implements the store.Store interface.
+func (c *Client) Put(ctx context.Context, ref eris.Reference, block []byte) error {
+	if len(block) > maxPayloadLen {
+		return fmt.Errorf("remote: block too large: %d bytes", len(block))
+	}
+	resp, err := c.roundTrip(ctx, &frame{kind: opPut, ref: ref, payload: block})
+	if err != nil {
+		return err
+	}
+	if resp.kind != statusOK {
+		return fmt.Errorf("remote: unexpected status %q for put", resp.kind)
+	}
+	return nil
+}
+
+// Has implements the store.Store interface.
+func (c *Client) Has(ctx context.Context, ref eris.Reference) (bool, error) {
+	resp, err := c.roundTrip(ctx, &frame{kind: opHas, ref: ref})
+	if err != nil {
+		return false, err
+	}
+	switch resp.kind {
+	case statusOK:
+		return true, nil
+	case statusNotFound:
+		return false, nil
+	}
+	return false, fmt.Errorf("remote: unexpected status %q for has", resp.kind)
+}
+
+// Ping implements the store.Pinger interface, by making a request that checks
+// whether the server has a block. This works with any server, and checks
+// both the connection and the server's store.
+func (c *Client) Ping(ctx context.Context) error {
+	_, err := c.Has(ctx, eris.Reference{})
+	return err
+}
+
+// HasMany implements the store.BatchHaser interface, by sending all of the
+// requests before waiting for any of the responses.
+func (c *Client) HasMany(ctx context.Context, refs []eris.Reference) ([]bool, error) {
+	has := make([]bool, len(refs))
+	errs := make([]error, len(refs))
+	var wg sync.WaitGroup
+	for i, ref := range refs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			has[i], errs[i] = c.Has(ctx, ref)
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return has, nil
+}
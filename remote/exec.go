@@ -0,0 +1,47 @@
+package remote
+
+import (
+	"errors"
+	"io"
+	"os/exec"
+)
+
+// Command starts cmd and returns a Client that communicates with it over its
+// standard input and output, which must be connected to Serve; for example, a
+// command that runs a server on another machine over ssh. The command's
+// standard error is left as configured in cmd.
+//
+// Closing the client closes the command's standard input, which causes Serve
+// to return, and then waits for the command to exit.
+func Command(cmd *exec.Cmd) (*Client, error) {
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		stdin.Close()
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return NewClient(&cmdConn{stdout, stdin, cmd}), nil
+}
+
+// cmdConn is a connection to a command over its standard input and output.
+type cmdConn struct {
+	io.Reader
+	stdin io.WriteCloser
+	cmd   *exec.Cmd
+}
+
+func (c *cmdConn) Write(p []byte) (int, error) {
+	return c.stdin.Write(p)
+}
+
+// Close closes the command's standard input and waits for it to exit.
+func (c *cmdConn) Close() error {
+	err := c.stdin.Close()
+	return errors.Join(err, c.cmd.Wait())
+}
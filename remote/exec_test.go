@@ -0,0 +1,56 @@
+//go:build !js && !wasip1
+
+package remote
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"testing"
+
+	"github.com/andrew-d/eris-go"
+	"github.com/andrew-d/eris-go/store"
+	"github.com/andrew-d/eris-go/store/storetest"
+)
+
+// TestHelperServe isn't a real test; it's run as a subprocess by
+// TestCommand, and serves a memory store over its standard input and output.
+func TestHelperServe(t *testing.T) {
+	if os.Getenv("ERIS_REMOTE_HELPER") != "1" {
+		t.Skip("only run as a subprocess")
+	}
+	rw := &stream{os.Stdin, os.Stdout, os.Stdin}
+	if err := Serve(context.Background(), store.NewMemory(), rw, ServeOptions{}); err != nil {
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+func TestCommand(t *testing.T) {
+	cmd := exec.Command(os.Args[0], "-test.run=^TestHelperServe$")
+	cmd.Env = append(os.Environ(), "ERIS_REMOTE_HELPER=1")
+	c, err := Command(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	ref, block := storetest.MakeBlock(1, eris.BlockSizeSmall)
+	if err := c.Put(ctx, ref, block); err != nil {
+		t.Fatal(err)
+	}
+	got, err := c.Get(ctx, ref, nil)
+	if err != nil || !bytes.Equal(got, block) {
+		t.Errorf("Get: %v", err)
+	}
+
+	// Closing the client stops the server, which exits cleanly.
+	if err := c.Close(); err != nil {
+		t.Errorf("Close: %v", err)
+	}
+	if _, err := c.Has(ctx, ref); !errors.Is(err, ErrClosed) {
+		t.Errorf("Has after Close: got %v, want ErrClosed", err)
+	}
+}
@@ -0,0 +1,179 @@
+package remote
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/andrew-d/eris-go/store"
+)
+
+// ProtocolName is the protocol named in the Upgrade header of the HTTP
+// requests made by Dial and accepted by Handler.
+const ProtocolName = "eris-remote/1"
+
+// Handler returns an http.Handler that upgrades each request to the remote
+// protocol and serves blocks from st with Serve. Requests that don't ask to
+// upgrade to ProtocolName are rejected with status 426 (Upgrade Required).
+//
+// Since the upgrade is a normal HTTP request, the handler can be wrapped with
+// middleware that authenticates the request before the connection is
+// upgraded. The handler requires HTTP/1.1; it can't be used with HTTP/2.
+func Handler(st store.Store, opts ServeOptions) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !headerContains(r.Header, "Connection", "upgrade") || !headerContains(r.Header, "Upgrade", ProtocolName) {
+			w.Header().Set("Connection", "Upgrade")
+			w.Header().Set("Upgrade", ProtocolName)
+			http.Error(w, "expected upgrade to "+ProtocolName, http.StatusUpgradeRequired)
+			return
+		}
+
+		rc := http.NewResponseController(w)
+		conn, brw, err := rc.Hijack()
+		if err != nil {
+			http.Error(w, "upgrade not supported", http.StatusInternalServerError)
+			return
+		}
+		defer conn.Close()
+
+		// Clear any deadlines set by the http.Server for the upgrade
+		// request, since the connection is now long-lived.
+		conn.SetDeadline(time.Time{})
+
+		brw.WriteString("HTTP/1.1 101 Switching Protocols\r\n")
+		brw.WriteString("Connection: Upgrade\r\n")
+		brw.WriteString("Upgrade: " + ProtocolName + "\r\n\r\n")
+		if err := brw.Flush(); err != nil {
+			return
+		}
+
+		// The client may have sent requests immediately after the
+		// upgrade request, in which case they're buffered in brw.
+		Serve(r.Context(), st, &stream{brw.Reader, conn, conn}, opts)
+	})
+}
+
+// DialOptions contains options for Dial.
+type DialOptions struct {
+	// Header contains additional headers to send with the upgrade
+	// request; for example, an Authorization header.
+	Header http.Header
+
+	// TLSConfig is the TLS configuration to use for https URLs. If nil,
+	// the default configuration is used.
+	TLSConfig *tls.Config
+}
+
+// Dial connects to a server at the given http or https URL that is running
+// Handler, and returns a Client that uses the connection.
+func Dial(ctx context.Context, rawURL string, opts DialOptions) (*Client, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	addr := u.Host
+	switch u.Scheme {
+	case "http":
+		if u.Port() == "" {
+			addr = net.JoinHostPort(u.Hostname(), "80")
+		}
+	case "https":
+		if u.Port() == "" {
+			addr = net.JoinHostPort(u.Hostname(), "443")
+		}
+	default:
+		return nil, fmt.Errorf("remote: unsupported URL scheme %q", u.Scheme)
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "https" {
+		cfg := opts.TLSConfig.Clone()
+		if cfg == nil {
+			cfg = &tls.Config{}
+		}
+		if cfg.ServerName == "" {
+			cfg.ServerName = u.Hostname()
+		}
+		tconn := tls.Client(conn, cfg)
+		if err := tconn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = tconn
+	}
+
+	c, err := upgrade(ctx, conn, u, opts.Header)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// upgrade sends an upgrade request over conn and waits for the server to
+// accept it.
+func upgrade(ctx context.Context, conn net.Conn, u *url.URL, header http.Header) (*Client, error) {
+	// Don't wait forever for a server that never responds.
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", ProtocolName)
+	if err := req.Write(conn); err != nil {
+		return nil, fmt.Errorf("remote: sending upgrade request: %w", err)
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		return nil, fmt.Errorf("remote: reading upgrade response: %w", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		return nil, fmt.Errorf("remote: upgrade failed: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	if !stop() {
+		return nil, ctx.Err()
+	}
+	return NewClient(&stream{br, conn, conn}), nil
+}
+
+// stream combines a reader that may contain buffered data with the underlying
+// connection.
+type stream struct {
+	io.Reader
+	io.Writer
+	io.Closer
+}
+
+// headerContains reports whether the comma-separated header values for key
+// contain token, ignoring case.
+func headerContains(h http.Header, key, token string) bool {
+	for _, v := range h.Values(key) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,159 @@
+// Package remote implements a simple protocol for accessing an ERIS block
+// store over a single bidirectional stream, such as a TCP connection or a
+// pipe.
+//
+// Unlike the ERIS over HTTP protocol (see the blockserver package), which
+// needs a separate request for each block, the protocol is multiplexed: a
+// client can have many requests outstanding on the same stream, and the server
+// answers them in whatever order they complete. On high-latency links, this
+// avoids waiting for a round trip per block.
+//
+// A Client is a store.Store that sends requests to a server, which is run with
+// Serve. Handler and Dial carry the protocol over an HTTP connection, using
+// the same upgrade mechanism as WebSockets, so that it can share a port (and
+// authentication) with other HTTP handlers.
+//
+// # Protocol
+//
+// Each request consists of a one-byte operation, a four-byte big-endian
+// request ID chosen by the client, and the 32-byte reference of a block. Put
+// requests are followed by a four-byte big-endian length and the block
+// itself:
+//
+//	'G' id reference                 get a block
+//	'H' id reference                 check whether a block exists
+//	'P' id reference length block    store a block
+//
+// Each response consists of a one-byte status and the ID of the request that
+// it answers. Block and error responses are followed by a four-byte length and
+// the block or a UTF-8 error message:
+//
+//	'B' id length block      the requested block
+//	'Y' id                   the block exists, or was stored
+//	'N' id                   the block does not exist
+//	'E' id length message    the request failed
+package remote
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/andrew-d/eris-go"
+)
+
+// Operations in requests.
+const (
+	opGet = 'G'
+	opHas = 'H'
+	opPut = 'P'
+)
+
+// Statuses in responses.
+const (
+	statusBlock    = 'B'
+	statusOK       = 'Y'
+	statusNotFound = 'N'
+	statusError    = 'E'
+)
+
+const (
+	// headerLen is the length of the operation or status and the
+	// request ID that start every frame.
+	headerLen = 1 + 4
+
+	// maxPayloadLen is the maximum length of a block or error message.
+	maxPayloadLen = eris.BlockSizeLarge
+)
+
+// frame is a single request or response.
+type frame struct {
+	kind    byte
+	id      uint32
+	ref     eris.Reference // requests only
+	payload []byte
+}
+
+// hasPayload reports whether a frame of the given kind is followed by a
+// payload.
+func hasPayload(kind byte) bool {
+	return kind == opPut || kind == statusBlock || kind == statusError
+}
+
+// writeFrame writes f to w, without flushing it. If request is set, the frame
+// is a request and includes a reference.
+func writeFrame(w *bufio.Writer, f *frame, request bool) error {
+	var hdr [headerLen + eris.ReferenceSize + 4]byte
+	hdr[0] = f.kind
+	binary.BigEndian.PutUint32(hdr[1:], f.id)
+	n := headerLen
+	if request {
+		n += copy(hdr[n:], f.ref[:])
+	}
+	if hasPayload(f.kind) {
+		binary.BigEndian.PutUint32(hdr[n:], uint32(len(f.payload)))
+		n += 4
+	}
+	if _, err := w.Write(hdr[:n]); err != nil {
+		return err
+	}
+	_, err := w.Write(f.payload)
+	return err
+}
+
+// readFrame reads a frame from r. If request is set, the frame is a request
+// and includes a reference. It returns io.EOF only if r ends before the
+// start of a frame.
+func readFrame(r io.Reader, request bool) (*frame, error) {
+	var hdr [headerLen]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return nil, err
+	}
+	f := &frame{
+		kind: hdr[0],
+		id:   binary.BigEndian.Uint32(hdr[1:]),
+	}
+	if request {
+		switch f.kind {
+		case opGet, opHas, opPut:
+		default:
+			return nil, fmt.Errorf("unknown operation %q", f.kind)
+		}
+		if _, err := io.ReadFull(r, f.ref[:]); err != nil {
+			return nil, noEOF(err)
+		}
+	} else {
+		switch f.kind {
+		case statusBlock, statusOK, statusNotFound, statusError:
+		default:
+			return nil, fmt.Errorf("unknown status %q", f.kind)
+		}
+	}
+	if !hasPayload(f.kind) {
+		return f, nil
+	}
+
+	var lenBuf [4]byte
+	if _, err := io.ReadFull(r, lenBuf[:]); err != nil {
+		return nil, noEOF(err)
+	}
+	n := binary.BigEndian.Uint32(lenBuf[:])
+	if n > maxPayloadLen {
+		return nil, fmt.Errorf("payload too large: %d bytes", n)
+	}
+	f.payload = make([]byte, n)
+	if _, err := io.ReadFull(r, f.payload); err != nil {
+		return nil, noEOF(err)
+	}
+	return f, nil
+}
+
+// noEOF converts io.EOF to io.ErrUnexpectedEOF, for use when the stream ends
+// in the middle of a frame.
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,272 @@
+package remote
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/andrew-d/eris-go"
+	"github.com/andrew-d/eris-go/store"
+	"github.com/andrew-d/eris-go/store/storetest"
+)
+
+// newPipe returns a Client connected to a server for st over an in-memory
+// pipe. The client is closed, and the server stopped, when the test ends.
+func newPipe(t *testing.T, st store.Store, opts ServeOptions) *Client {
+	t.Helper()
+	cconn, sconn := net.Pipe()
+	errc := make(chan error, 1)
+	go func() { errc <- Serve(context.Background(), st, sconn, opts) }()
+
+	c := NewClient(cconn)
+	t.Cleanup(func() {
+		c.Close()
+		if err := <-errc; err != nil && !errors.Is(err, net.ErrClosed) && !errors.Is(err, io.ErrClosedPipe) {
+			t.Errorf("Serve: %v", err)
+		}
+	})
+	return c
+}
+
+func TestClient(t *testing.T) {
+	storetest.TestStore(t, func() store.Store {
+		return newPipe(t, store.NewMemory(), ServeOptions{})
+	})
+}
+
+func TestClient_HasMany(t *testing.T) {
+	mem := store.NewMemory()
+	c := newPipe(t, mem, ServeOptions{})
+
+	var refs []eris.Reference
+	for i := range 10 {
+		ref, block := storetest.MakeBlock(i, eris.BlockSizeSmall)
+		if i%2 == 0 {
+			mem.Put(context.Background(), ref, block)
+		}
+		refs = append(refs, ref)
+	}
+	has, err := c.HasMany(context.Background(), refs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, ok := range has {
+		if ok != (i%2 == 0) {
+			t.Errorf("HasMany()[%d] = %v", i, ok)
+		}
+	}
+}
+
+// barrierStore is a store whose Get doesn't return until n calls are in
+// progress at once.
+type barrierStore struct {
+	store.Store
+	n int
+
+	mu      sync.Mutex
+	waiting int
+	ready   chan struct{}
+}
+
+func (s *barrierStore) Get(ctx context.Context, ref eris.Reference, buf []byte) ([]byte, error) {
+	s.mu.Lock()
+	s.waiting++
+	if s.waiting == s.n {
+		close(s.ready)
+	}
+	s.mu.Unlock()
+
+	select {
+	case <-s.ready:
+	case <-time.After(5 * time.Second):
+		return nil, errors.New("requests were not pipelined")
+	}
+	return s.Store.Get(ctx, ref, buf)
+}
+
+func TestClient_Pipelined(t *testing.T) {
+	const n = 20
+	st := &barrierStore{Store: store.NewMemory(), n: n, ready: make(chan struct{})}
+	c := newPipe(t, st, ServeOptions{MaxInFlight: n})
+
+	var wg sync.WaitGroup
+	for i := range n {
+		ref, block := storetest.MakeBlock(i, eris.BlockSizeSmall)
+		st.Put(context.Background(), ref, block)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got, err := c.Get(context.Background(), ref, make([]byte, eris.BlockSizeSmall))
+			if err != nil {
+				t.Error(err)
+			} else if !bytes.Equal(got, block) {
+				t.Errorf("Get(%v) returned the wrong block", ref)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestServe_InvalidPut(t *testing.T) {
+	mem := store.NewMemory()
+	c := newPipe(t, mem, ServeOptions{})
+	ctx := context.Background()
+
+	ref, block := storetest.MakeBlock(1, eris.BlockSizeSmall)
+	other, _ := storetest.MakeBlock(2, eris.BlockSizeSmall)
+	if err := c.Put(ctx, other, block); err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Errorf("Put with wrong reference: got %v", err)
+	}
+	oddRef, odd := storetest.MakeBlock(3, 100)
+	if err := c.Put(ctx, oddRef, odd); err == nil || !strings.Contains(err.Error(), "block size") {
+		t.Errorf("Put with invalid size: got %v", err)
+	}
+	if mem.Len() != 0 {
+		t.Errorf("invalid blocks were stored")
+	}
+
+	// The connection is still usable after an error.
+	if err := c.Put(ctx, ref, block); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestServe_ReadOnly(t *testing.T) {
+	c := newPipe(t, store.NewMemory(), ServeOptions{ReadOnly: true})
+	ref, block := storetest.MakeBlock(1, eris.BlockSizeSmall)
+	if err := c.Put(context.Background(), ref, block); err == nil {
+		t.Error("expected error writing to read-only server")
+	}
+}
+
+func TestClient_ServerGone(t *testing.T) {
+	cconn, sconn := net.Pipe()
+	c := NewClient(cconn)
+	defer c.Close()
+	sconn.Close()
+
+	ref, _ := storetest.MakeBlock(1, eris.BlockSizeSmall)
+	if _, err := c.Has(context.Background(), ref); err == nil {
+		t.Error("expected error after server closed connection")
+	}
+}
+
+func TestClient_Ping(t *testing.T) {
+	c := newPipe(t, store.NewMemory(), ServeOptions{})
+	if err := store.Ping(context.Background(), c); err != nil {
+		t.Errorf("Ping: %v", err)
+	}
+
+	// Errors from the server's store are reported.
+	c = newPipe(t, failingStore{}, ServeOptions{})
+	if err := store.Ping(context.Background(), c); err == nil {
+		t.Error("Ping succeeded with a failing store")
+	}
+
+	c.Close()
+	if err := store.Ping(context.Background(), c); !errors.Is(err, ErrClosed) {
+		t.Errorf("Ping after Close = %v, want ErrClosed", err)
+	}
+}
+
+// failingStore is a store whose Has always fails.
+type failingStore struct {
+	store.Store
+}
+
+func (failingStore) Has(context.Context, eris.Reference) (bool, error) {
+	return false, errors.New("disk on fire")
+}
+
+func TestClient_Canceled(t *testing.T) {
+	st := &barrierStore{Store: store.NewMemory(), n: 2, ready: make(chan struct{})}
+	c := newPipe(t, st, ServeOptions{})
+
+	// The first Get blocks until a second one arrives; cancel it.
+	ref, block := storetest.MakeBlock(1, eris.BlockSizeSmall)
+	st.Put(context.Background(), ref, block)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := c.Get(ctx, ref, nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Get: got %v, want context.DeadlineExceeded", err)
+	}
+
+	// The late response to the canceled call doesn't confuse the next.
+	got, err := c.Get(context.Background(), ref, nil)
+	if err != nil || !bytes.Equal(got, block) {
+		t.Errorf("Get after cancellation: %v", err)
+	}
+}
+
+func TestDial(t *testing.T) {
+	mem := store.NewMemory()
+	handler := Handler(mem, ServeOptions{})
+	auth := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+
+	for _, tls := range []bool{false, true} {
+		name := "HTTP"
+		srv := httptest.NewUnstartedServer(auth)
+		if tls {
+			name = "HTTPS"
+			srv.StartTLS()
+		} else {
+			srv.Start()
+		}
+		defer srv.Close()
+
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			opts := DialOptions{
+				Header: http.Header{"Authorization": {"Bearer secret"}},
+			}
+			if tls {
+				opts.TLSConfig = srv.Client().Transport.(*http.Transport).TLSClientConfig
+			}
+			c, err := Dial(ctx, srv.URL+"/remote", opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer c.Close()
+
+			ref, block := storetest.MakeBlock(1, eris.BlockSizeSmall)
+			if err := c.Put(ctx, ref, block); err != nil {
+				t.Fatal(err)
+			}
+			got, err := c.Get(ctx, ref, nil)
+			if err != nil || !bytes.Equal(got, block) {
+				t.Errorf("Get: %v", err)
+			}
+
+			_, err = Dial(ctx, srv.URL+"/remote", DialOptions{TLSConfig: opts.TLSConfig})
+			if err == nil || !strings.Contains(err.Error(), "401") {
+				t.Errorf("Dial without token: got %v", err)
+			}
+		})
+	}
+
+	// A plain request is rejected.
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUpgradeRequired {
+		t.Errorf("plain request: status %d, want 426", resp.StatusCode)
+	}
+}
@@ -0,0 +1,174 @@
+package remote
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"sync"
+
+	"golang.org/x/crypto/blake2b"
+
+	"github.com/andrew-d/eris-go"
+	"github.com/andrew-d/eris-go/store"
+)
+
+// ServeOptions contains options for Serve.
+type ServeOptions struct {
+	// ReadOnly, if set, rejects all Put requests.
+	ReadOnly bool
+
+	// MaxInFlight is the maximum number of requests that are handled
+	// concurrently; once it is reached, no more requests are read from
+	// the stream until one completes. If it is less than 1, a default of
+	// 64 is used.
+	MaxInFlight int
+}
+
+// defaultMaxInFlight is the default value of ServeOptions.MaxInFlight.
+const defaultMaxInFlight = 64
+
+// Serve reads requests from rw and answers them from st, which must be safe
+// for concurrent use. Requests are handled concurrently, and responses are
+// written as each request completes.
+//
+// Blocks stored with Put are verified against their reference and must be one
+// of the block sizes defined by the specification.
+//
+// Serve returns nil once rw reaches EOF and every outstanding request has been
+// answered. It returns an error if a malformed request is read, or if reading
+// from or writing to rw fails. If rw implements io.Closer, it is closed when
+// ctx is canceled, which causes Serve to return.
+func Serve(ctx context.Context, st store.Store, rw io.ReadWriter, opts ServeOptions) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	if c, ok := rw.(io.Closer); ok {
+		stop := context.AfterFunc(ctx, func() { c.Close() })
+		defer stop()
+	}
+
+	s := &server{
+		st:   st,
+		opts: opts,
+		w:    bufio.NewWriter(rw),
+		sem:  make(chan struct{}, defaultMaxInFlight),
+	}
+	if opts.MaxInFlight > 0 {
+		s.sem = make(chan struct{}, opts.MaxInFlight)
+	}
+
+	r := bufio.NewReader(rw)
+	var err error
+	for {
+		var req *frame
+		req, err = readFrame(r, true)
+		if err != nil {
+			break
+		}
+
+		s.sem <- struct{}{}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer func() { <-s.sem }()
+			s.handle(ctx, req)
+		}()
+	}
+	s.wg.Wait()
+
+	if errors.Is(err, io.EOF) {
+		err = nil
+	}
+	// A write error is the more useful one, since it will usually have
+	// caused the read error.
+	if s.err != nil {
+		err = s.err
+	}
+	return err
+}
+
+// server holds the state of a single call to Serve.
+type server struct {
+	st   store.Store
+	opts ServeOptions
+	sem  chan struct{}
+	wg   sync.WaitGroup
+
+	// mu protects w and err.
+	mu  sync.Mutex
+	w   *bufio.Writer
+	err error
+}
+
+// handle answers a single request.
+func (s *server) handle(ctx context.Context, req *frame) {
+	resp := &frame{id: req.id}
+	switch req.kind {
+	case opGet:
+		block, err := s.st.Get(ctx, req.ref, make([]byte, eris.BlockSizeLarge))
+		if err != nil {
+			setError(resp, err)
+		} else {
+			resp.kind = statusBlock
+			resp.payload = block
+		}
+
+	case opHas:
+		has, err := s.st.Has(ctx, req.ref)
+		switch {
+		case err != nil:
+			setError(resp, err)
+		case has:
+			resp.kind = statusOK
+		default:
+			resp.kind = statusNotFound
+		}
+
+	case opPut:
+		block := req.payload
+		switch {
+		case s.opts.ReadOnly:
+			setError(resp, store.ErrReadOnly)
+		case len(block) != eris.BlockSizeSmall && len(block) != eris.BlockSizeLarge:
+			setError(resp, errors.New("invalid block size"))
+		case blake2b.Sum256(block) != req.ref:
+			setError(resp, errors.New("block does not match reference"))
+		default:
+			if err := s.st.Put(ctx, req.ref, block); err != nil {
+				setError(resp, err)
+			} else {
+				resp.kind = statusOK
+			}
+		}
+	}
+	s.write(resp)
+}
+
+// setError makes resp an error response for err. Missing blocks have their own
+// status, so that clients can report them with store.ErrNotFound.
+func setError(resp *frame, err error) {
+	if errors.Is(err, store.ErrNotFound) {
+		resp.kind = statusNotFound
+		return
+	}
+	msg := err.Error()
+	if len(msg) > maxPayloadLen {
+		msg = msg[:maxPayloadLen]
+	}
+	resp.kind = statusError
+	resp.payload = []byte(msg)
+}
+
+// write writes a response, recording the first error that occurs.
+func (s *server) write(resp *frame) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return
+	}
+	if err := writeFrame(s.w, resp, false); err != nil {
+		s.err = err
+		return
+	}
+	s.err = s.w.Flush()
+}
@@ -0,0 +1,166 @@
+package eris
+
+import (
+	"crypto/rand"
+	"fmt"
+	"hash"
+	"io"
+
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/hkdf"
+)
+
+// ConvergenceSecret is the secret that an Encoder uses to derive the
+// encryption key of each leaf. It is the top of a small key hierarchy:
+//
+//   - the key of every leaf is the BLAKE2b-256 hash of its content, keyed with
+//     the convergence secret, so the same content encoded with the same secret
+//     always produces the same blocks;
+//   - the key of every internal node is the unkeyed hash of its contents,
+//     which are the references and keys of its children;
+//   - the reference of every block is the hash of its encrypted contents; and
+//   - the read capability holds the reference and key of the root node,
+//     which is all that is needed to decrypt the whole tree.
+//
+// Knowing the convergence secret doesn't allow decrypting anything, but it
+// does allow confirming whether guessed content is stored (see
+// DeriveConvergenceSecret), so non-zero secrets should be kept private. The
+// zero secret is the default used by the specification's test vectors, and
+// deduplicates content with anyone else who uses it.
+//
+// ConvergenceSecret has the same underlying type as the
+// [ConvergenceSecretSize]byte arrays taken by NewEncoder and other functions,
+// so either can be passed to them. Unlike a plain array, it isn't printed by
+// the fmt package; use Hex or Base32 to encode it deliberately.
+type ConvergenceSecret [ConvergenceSecretSize]byte
+
+// ConvergenceSecretFromHex decodes a convergence secret from its hexadecimal
+// encoding, in either case.
+func ConvergenceSecretFromHex(s string) (ConvergenceSecret, error) {
+	return decodeSecret(s, EncodingHex)
+}
+
+// ConvergenceSecretFromBase32 decodes a convergence secret from its unpadded
+// Base32 encoding, as returned by Base32.
+func ConvergenceSecretFromBase32(s string) (ConvergenceSecret, error) {
+	return decodeSecret(s, EncodingBase32)
+}
+
+// ParseConvergenceSecret decodes a convergence secret from either its
+// unpadded Base32 or its hexadecimal encoding, which have different lengths.
+func ParseConvergenceSecret(s string) (ConvergenceSecret, error) {
+	if len(s) == EncodingHex.encodedLen(ConvergenceSecretSize) {
+		return ConvergenceSecretFromHex(s)
+	}
+	return ConvergenceSecretFromBase32(s)
+}
+
+// decodeSecret decodes a convergence secret in the given encoding. Since it's
+// easy to mix up encodings, the error says so if s has the length of another
+// one.
+func decodeSecret(s string, e Encoding) (ConvergenceSecret, error) {
+	var secret ConvergenceSecret
+	if e.decode(secret[:], s) {
+		return secret, nil
+	}
+	for _, other := range []Encoding{EncodingBase32, EncodingHex} {
+		if other != e && len(s) == other.encodedLen(ConvergenceSecretSize) {
+			return ConvergenceSecret{}, fmt.Errorf("invalid %v convergence secret: has the length of a %v secret", e, other)
+		}
+	}
+	return ConvergenceSecret{}, fmt.Errorf("invalid %v convergence secret: not a valid encoding of %d bytes", e, ConvergenceSecretSize)
+}
+
+// RandomConvergenceSecret returns a new convergence secret generated by a
+// cryptographically secure random number generator.
+func RandomConvergenceSecret() (ConvergenceSecret, error) {
+	var secret ConvergenceSecret
+	if _, err := rand.Read(secret[:]); err != nil {
+		return ConvergenceSecret{}, err
+	}
+	return secret, nil
+}
+
+// Derive derives a convergence secret for the given label from s, as
+// DeriveConvergenceSecret does.
+func (s ConvergenceSecret) Derive(label string) ConvergenceSecret {
+	return DeriveConvergenceSecret(s, label)
+}
+
+// IsZero reports whether s is the zero secret.
+func (s ConvergenceSecret) IsZero() bool {
+	return s == ConvergenceSecret{}
+}
+
+// Hex returns the lowercase hexadecimal encoding of s.
+func (s ConvergenceSecret) Hex() string {
+	return EncodingHex.encode(s[:])
+}
+
+// Base32 returns the unpadded Base32 encoding of s.
+func (s ConvergenceSecret) Base32() string {
+	return EncodingBase32.encode(s[:])
+}
+
+// String implements the fmt.Stringer interface. It doesn't reveal the secret,
+// so that it isn't leaked into logs by accident.
+func (s ConvergenceSecret) String() string {
+	if s.IsZero() {
+		return "ConvergenceSecret(zero)"
+	}
+	return "ConvergenceSecret(redacted)"
+}
+
+// GoString implements the fmt.GoStringer interface, for the %#v verb. Like
+// String, it doesn't reveal the secret.
+func (s ConvergenceSecret) GoString() string {
+	return "eris." + s.String()
+}
+
+// Wipe overwrites s with zeroes, once it is no longer needed. Since a
+// ConvergenceSecret is passed by value, other copies of it may remain in
+// memory, so this is only a best effort.
+func (s *ConvergenceSecret) Wipe() {
+	clear(s[:])
+}
+
+// secretDerivationInfo is the HKDF info prefix used by
+// DeriveConvergenceSecret, for domain separation from other uses of the
+// master secret.
+const secretDerivationInfo = "eris-go convergence secret v1\x00"
+
+// DeriveConvergenceSecret derives a convergence secret from a master secret
+// and a caller-provided label, such as a tenant ID or directory path, using
+// HKDF with BLAKE2b-256.
+//
+// Convergent encryption means that anyone who knows the convergence secret
+// can confirm whether some guessed content is stored, by encoding it and
+// checking for its blocks. Using a different secret for each partition of a
+// dataset limits that to content within the same partition, at the cost of
+// only deduplicating content within a partition: identical content with
+// different labels produces unrelated blocks.
+//
+// The derived secret is deterministic, so the same master secret and label
+// always produce the same secret, and distinct labels produce independent
+// secrets; knowing a derived secret reveals nothing about the master secret
+// or about secrets derived for other labels. The master secret should be
+// uniformly random and kept private.
+func DeriveConvergenceSecret(master [ConvergenceSecretSize]byte, label string) [ConvergenceSecretSize]byte {
+	info := make([]byte, 0, len(secretDerivationInfo)+len(label))
+	info = append(info, secretDerivationInfo...)
+	info = append(info, label...)
+
+	newHash := func() hash.Hash {
+		h, _ := blake2b.New256(nil)
+		return h
+	}
+	r := hkdf.New(newHash, master[:], nil, info)
+
+	var secret [ConvergenceSecretSize]byte
+	if _, err := io.ReadFull(r, secret[:]); err != nil {
+		// This can only happen if we ask for more than 255 times the
+		// hash size, which we don't.
+		panic("eris: deriving convergence secret: " + err.Error())
+	}
+	return secret
+}
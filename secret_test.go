@@ -0,0 +1,106 @@
+package eris
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestDeriveConvergenceSecret(t *testing.T) {
+	var master [ConvergenceSecretSize]byte
+	for i := range master {
+		master[i] = byte(i)
+	}
+
+	a := DeriveConvergenceSecret(master, "tenant-a")
+	if a != DeriveConvergenceSecret(master, "tenant-a") {
+		t.Errorf("derivation is not deterministic")
+	}
+	if a == DeriveConvergenceSecret(master, "tenant-b") {
+		t.Errorf("different labels produced the same secret")
+	}
+	if a == master {
+		t.Errorf("derived secret is the master secret")
+	}
+
+	var otherMaster [ConvergenceSecretSize]byte
+	if a == DeriveConvergenceSecret(otherMaster, "tenant-a") {
+		t.Errorf("different master secrets produced the same secret")
+	}
+
+	// Guard against accidental changes to the derivation, which would
+	// silently break deduplication with previously-stored content.
+	const want = "a6531094514491f3b7f0b187545117e13f20dfb8e5096974721b9d83943731f9"
+	if got := hex.EncodeToString(a[:]); got != want {
+		t.Errorf("derived secret = %s, want %s", got, want)
+	}
+}
+
+func TestConvergenceSecret(t *testing.T) {
+	secret, err := RandomConvergenceSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secret.IsZero() {
+		t.Fatal("random secret is zero")
+	}
+
+	for _, tc := range []struct {
+		encoded string
+		parse   func(string) (ConvergenceSecret, error)
+	}{
+		{secret.Hex(), ConvergenceSecretFromHex},
+		{strings.ToUpper(secret.Hex()), ConvergenceSecretFromHex},
+		{secret.Base32(), ConvergenceSecretFromBase32},
+		{secret.Hex(), ParseConvergenceSecret},
+		{secret.Base32(), ParseConvergenceSecret},
+	} {
+		got, err := tc.parse(tc.encoded)
+		if err != nil || got != secret {
+			t.Errorf("parsing %q = %v, %v; want the original secret", tc.encoded, got, err)
+		}
+	}
+
+	// Mixing up the encodings is reported as such.
+	if _, err := ConvergenceSecretFromHex(secret.Base32()); err == nil || !strings.Contains(err.Error(), "base32") {
+		t.Errorf("ConvergenceSecretFromHex(base32) = %v, want an error mentioning base32", err)
+	}
+	if _, err := ConvergenceSecretFromBase32(secret.Hex()); err == nil || !strings.Contains(err.Error(), "hex") {
+		t.Errorf("ConvergenceSecretFromBase32(hex) = %v, want an error mentioning hex", err)
+	}
+
+	// Values that are too long are rejected, rather than overrunning the
+	// secret.
+	for _, tc := range []struct {
+		encoded string
+		parse   func(string) (ConvergenceSecret, error)
+	}{
+		{strings.Repeat("00", 64), ConvergenceSecretFromHex},
+		{secret.Hex() + "00", ConvergenceSecretFromHex},
+		{strings.Repeat("A", 100), ConvergenceSecretFromBase32},
+		{secret.Base32() + "A", ConvergenceSecretFromBase32},
+	} {
+		if _, err := tc.parse(tc.encoded); err == nil {
+			t.Errorf("parsing %q succeeded, want error", tc.encoded)
+		}
+	}
+
+	// The secret is never printed.
+	for _, verb := range []string{"%v", "%s", "%x", "%#v", "%+v"} {
+		if out := fmt.Sprintf(verb, secret); strings.Contains(out, secret.Hex()) || strings.Contains(out, secret.Base32()) {
+			t.Errorf("%s printed the secret: %s", verb, out)
+		}
+	}
+
+	// The secret can be passed wherever an array is expected.
+	master := [ConvergenceSecretSize]byte(secret)
+	if secret.Derive("label") != DeriveConvergenceSecret(master, "label") {
+		t.Errorf("Derive differs from DeriveConvergenceSecret")
+	}
+
+	secret.Wipe()
+	if !secret.IsZero() {
+		t.Errorf("secret is not zero after Wipe")
+	}
+}
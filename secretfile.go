@@ -0,0 +1,101 @@
+package eris
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+)
+
+// secretFileHeader is the comment written at the start of a secret file by
+// SaveConvergenceSecret.
+const secretFileHeader = "# ERIS convergence secret; keep this file private.\n"
+
+// ErrInsecureSecretFile is returned (possibly wrapped) by LoadConvergenceSecret
+// if the secret file can be read or written by users other than its owner.
+var ErrInsecureSecretFile = errors.New("secret file is accessible by other users")
+
+// LoadConvergenceSecret reads a convergence secret from a file in the format
+// written by SaveConvergenceSecret: a text file containing the secret's
+// unpadded Base32 encoding on a line of its own. The hexadecimal encoding is
+// also accepted, so that existing secrets can be moved into files. Blank lines,
+// lines starting with '#', and whitespace around the secret are ignored.
+//
+// On systems with Unix permissions, the file must not be accessible by anyone
+// but its owner (that is, its mode must be 0600 or stricter); otherwise,
+// LoadConvergenceSecret returns an error wrapping ErrInsecureSecretFile
+// without using the secret, as SSH does for private keys.
+func LoadConvergenceSecret(path string) (ConvergenceSecret, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return ConvergenceSecret{}, err
+	}
+	defer f.Close()
+
+	if runtime.GOOS != "windows" {
+		fi, err := f.Stat()
+		if err != nil {
+			return ConvergenceSecret{}, err
+		}
+		if perm := fi.Mode().Perm(); perm&0o077 != 0 {
+			return ConvergenceSecret{}, fmt.Errorf("%w: %s has mode %04o, want 0600", ErrInsecureSecretFile, path, perm)
+		}
+	}
+
+	var encoded string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if encoded != "" {
+			return ConvergenceSecret{}, fmt.Errorf("%s: more than one secret in file", path)
+		}
+		encoded = line
+	}
+	if err := sc.Err(); err != nil {
+		return ConvergenceSecret{}, err
+	}
+	if encoded == "" {
+		return ConvergenceSecret{}, fmt.Errorf("%s: no secret in file", path)
+	}
+
+	secret, err := ParseConvergenceSecret(encoded)
+	if err != nil {
+		return ConvergenceSecret{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return secret, nil
+}
+
+// SaveConvergenceSecret writes secret to a new file at path, in the format read
+// by LoadConvergenceSecret, with mode 0600. It fails if the file already
+// exists, so that a secret that is in use is never overwritten by accident;
+// content encoded with a lost secret can still be read, but is no longer
+// deduplicated against new content.
+func SaveConvergenceSecret(path string, secret ConvergenceSecret) (err error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(path)
+		}
+	}()
+
+	var buf bytes.Buffer
+	buf.WriteString(secretFileHeader)
+	buf.WriteString(secret.Base32())
+	buf.WriteByte('\n')
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	return f.Sync()
+}
@@ -0,0 +1,70 @@
+package eris
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestConvergenceSecretFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "secret")
+	secret, err := RandomConvergenceSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := SaveConvergenceSecret(path, secret); err != nil {
+		t.Fatalf("SaveConvergenceSecret: %v", err)
+	}
+	got, err := LoadConvergenceSecret(path)
+	if err != nil || got != secret {
+		t.Fatalf("LoadConvergenceSecret = %v, %v; want the saved secret", got, err)
+	}
+
+	// An existing secret is never overwritten.
+	if err := SaveConvergenceSecret(path, ConvergenceSecret{}); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("SaveConvergenceSecret over existing file = %v, want ErrExist", err)
+	}
+
+	// Hex is also accepted, along with comments and blank lines.
+	hexPath := filepath.Join(dir, "hex")
+	os.WriteFile(hexPath, []byte("# comment\n\n  "+secret.Hex()+"  \n"), 0o600)
+	if got, err := LoadConvergenceSecret(hexPath); err != nil || got != secret {
+		t.Errorf("LoadConvergenceSecret(hex) = %v, %v; want the secret", got, err)
+	}
+
+	for name, content := range map[string]string{
+		"empty":   "# nothing here\n",
+		"two":     secret.Base32() + "\n" + secret.Base32() + "\n",
+		"invalid": "not a secret\n",
+		"long":    strings.Repeat("A", 90) + "\n",
+		"garbage": strings.Repeat("\xff", 64) + "\n",
+	} {
+		p := filepath.Join(dir, name)
+		os.WriteFile(p, []byte(content), 0o600)
+		if _, err := LoadConvergenceSecret(p); err == nil {
+			t.Errorf("%s: LoadConvergenceSecret succeeded, want error", name)
+		}
+	}
+}
+
+func TestConvergenceSecretFile_Permissions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permissions aren't checked on Windows")
+	}
+	path := filepath.Join(t.TempDir(), "secret")
+	if err := SaveConvergenceSecret(path, ConvergenceSecret{1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(path, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConvergenceSecret(path); !errors.Is(err, ErrInsecureSecretFile) {
+		t.Errorf("LoadConvergenceSecret = %v, want ErrInsecureSecretFile", err)
+	}
+}
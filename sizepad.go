@@ -0,0 +1,121 @@
+package eris
+
+import (
+	"io"
+	"math"
+)
+
+// PaddingPolicy determines the size that content is padded to by
+// WithSizePadding. It is called with the minimum padded size of the content,
+// and returns the size that the content should be padded to; if it returns a
+// smaller value, the minimum size is used.
+type PaddingPolicy func(size int64) int64
+
+// PadToPowerOfTwo is a PaddingPolicy that pads content to the next power of
+// two. This hides all but the order of magnitude of the content's size, at the
+// cost of up to doubling the size of the encoded content.
+func PadToPowerOfTwo(size int64) int64 {
+	if size <= 1 {
+		return 1
+	}
+	if size > math.MaxInt64/2 {
+		return size
+	}
+	p := int64(1)
+	for p < size {
+		p <<= 1
+	}
+	return p
+}
+
+// PadToMultiple returns a PaddingPolicy that pads content to the next multiple
+// of quantum bytes. If quantum is not positive, content is not padded beyond
+// the minimum.
+func PadToMultiple(quantum int64) PaddingPolicy {
+	return func(size int64) int64 {
+		if quantum <= 0 || size > math.MaxInt64-quantum {
+			return size
+		}
+		return (size + quantum - 1) / quantum * quantum
+	}
+}
+
+// WithSizePadding returns an EncoderOption that pads the content up to a size
+// determined by policy before encoding it, to make it harder to identify
+// well-known content by the size of its encoding (which is visible to anyone
+// that can see the blocks, even without the read capability).
+//
+// The padding uses the same scheme as ERIS itself: a single 0x80 byte
+// followed by zero or more 0x00 bytes. Since this padding is part of the
+// content, decoding is unchanged and returns the padded content; use
+// RemoveSizePadding to recover the original content. Padded content can still
+// be deduplicated, but only with other content padded using the same policy.
+func WithSizePadding(policy PaddingPolicy) EncoderOption {
+	return func(e *Encoder) {
+		e.sizePadding = policy
+	}
+}
+
+// RemoveSizePadding removes the padding added by WithSizePadding from decoded
+// content, returning a sub-slice of content. It returns ErrInvalidPadding if
+// the content is not padded.
+func RemoveSizePadding(content []byte) ([]byte, error) {
+	for i := len(content) - 1; i >= 0; i-- {
+		switch content[i] {
+		case 0x80:
+			return content[:i], nil
+		case 0x00:
+			continue
+		default:
+			return nil, ErrInvalidPadding
+		}
+	}
+	return nil, ErrInvalidPadding
+}
+
+// sizePaddingReader is an io.Reader that reads from an underlying reader and
+// then appends padding according to a PaddingPolicy.
+type sizePaddingReader struct {
+	r      io.Reader
+	policy PaddingPolicy
+	n      int64 // bytes read from r
+	pad    int64 // padding bytes remaining, or -1 if r isn't done yet
+	marker bool  // whether the 0x80 marker has been written
+}
+
+func newSizePaddingReader(r io.Reader, policy PaddingPolicy) *sizePaddingReader {
+	return &sizePaddingReader{r: r, policy: policy, pad: -1}
+}
+
+// Read implements the io.Reader interface.
+func (s *sizePaddingReader) Read(p []byte) (int, error) {
+	if s.pad < 0 {
+		n, err := s.r.Read(p)
+		s.n += int64(n)
+		if err != io.EOF {
+			return n, err
+		}
+
+		// We've read all the content; calculate how much padding
+		// we need, which always includes at least the marker byte.
+		minSize := s.n + 1
+		s.pad = max(s.policy(minSize), minSize) - s.n
+		if n > 0 {
+			return n, nil
+		}
+	}
+
+	if s.pad == 0 {
+		return 0, io.EOF
+	}
+	n := int(min(int64(len(p)), s.pad))
+	for i := range p[:n] {
+		p[i] = 0x00
+	}
+	if !s.marker && n > 0 {
+		p[0] = 0x80
+		s.marker = true
+	}
+	s.pad -= int64(n)
+	return n, nil
+}
@@ -0,0 +1,142 @@
+package eris
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"testing/iotest"
+)
+
+func TestPaddingPolicies(t *testing.T) {
+	testCases := []struct {
+		name   string
+		policy PaddingPolicy
+		size   int64
+		want   int64
+	}{
+		{"pow2 zero", PadToPowerOfTwo, 0, 1},
+		{"pow2 one", PadToPowerOfTwo, 1, 1},
+		{"pow2 exact", PadToPowerOfTwo, 4096, 4096},
+		{"pow2 round up", PadToPowerOfTwo, 4097, 8192},
+		{"multiple exact", PadToMultiple(1000), 3000, 3000},
+		{"multiple round up", PadToMultiple(1000), 3001, 4000},
+		{"multiple invalid", PadToMultiple(0), 3001, 3001},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.policy(tc.size); got != tc.want {
+				t.Errorf("got %d, want %d", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestWithSizePadding(t *testing.T) {
+	var secret [ConvergenceSecretSize]byte
+	for _, size := range []int{0, 1, 1023, 1024, 5000, 8191} {
+		content := randomContent(size)
+
+		// Use a reader that returns one byte at a time, to exercise
+		// the padding reader's handling of short reads.
+		enc := NewEncoder(iotest.OneByteReader(bytes.NewReader(content)), secret, 1024, WithSizePadding(PadToPowerOfTwo))
+		blocks := make(map[Reference][]byte)
+		for enc.Next() {
+			blocks[enc.Reference()] = bytes.Clone(enc.Block())
+		}
+		if err := enc.Err(); err != nil {
+			t.Fatalf("size %d: encoding: %v", size, err)
+		}
+
+		// The decoded content contains the size padding.
+		decoded, err := DecodeRecursive(context.Background(), mapFetch(blocks, nil), enc.Capability())
+		if err != nil {
+			t.Fatalf("size %d: decoding: %v", size, err)
+		}
+		if want := PadToPowerOfTwo(int64(size) + 1); int64(len(decoded)) != want {
+			t.Errorf("size %d: decoded %d bytes, want %d", size, len(decoded), want)
+		}
+
+		got, err := RemoveSizePadding(decoded)
+		if err != nil {
+			t.Fatalf("size %d: RemoveSizePadding: %v", size, err)
+		}
+		if !bytes.Equal(got, content) {
+			t.Errorf("size %d: content mismatch after removing padding", size)
+		}
+	}
+}
+
+func TestWithSizePadding_Reset(t *testing.T) {
+	var secret [ConvergenceSecretSize]byte
+	content := randomContent(3000)
+
+	encode := func(enc *Encoder) ReadCapability {
+		for enc.Next() {
+		}
+		if err := enc.Err(); err != nil {
+			t.Fatal(err)
+		}
+		return enc.Capability()
+	}
+
+	enc := NewEncoder(bytes.NewReader(content), secret, 1024, WithSizePadding(PadToMultiple(4096)))
+	want := encode(enc)
+	enc.reset(bytes.NewReader(content))
+	if got := encode(enc); !got.Equal(want) {
+		t.Errorf("capability changed after reset")
+	}
+
+	unpadded, err := ComputeCapability(bytes.NewReader(content), secret, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unpadded.Equal(want) {
+		t.Errorf("padded and unpadded content have the same capability")
+	}
+}
+
+func TestRemoveSizePadding(t *testing.T) {
+	testCases := []struct {
+		in      []byte
+		want    []byte
+		wantErr error
+	}{
+		{[]byte{0x80}, []byte{}, nil},
+		{[]byte{1, 2, 0x80, 0, 0}, []byte{1, 2}, nil},
+		{[]byte{1, 0x80, 0x80}, []byte{1, 0x80}, nil},
+		{[]byte{}, nil, ErrInvalidPadding},
+		{[]byte{0, 0, 0}, nil, ErrInvalidPadding},
+		{[]byte{1, 2, 3}, nil, ErrInvalidPadding},
+	}
+	for _, tc := range testCases {
+		got, err := RemoveSizePadding(tc.in)
+		if !errors.Is(err, tc.wantErr) {
+			t.Errorf("RemoveSizePadding(%v): got error %v, want %v", tc.in, err, tc.wantErr)
+			continue
+		}
+		if err == nil && !bytes.Equal(got, tc.want) {
+			t.Errorf("RemoveSizePadding(%v) = %v, want %v", tc.in, got, tc.want)
+		}
+	}
+}
+
+// nullReader is an io.Reader that returns len(p) bytes without writing
+// anything, so that it can cheaply produce huge amounts of content.
+type nullReader struct{}
+
+func (nullReader) Read(p []byte) (int, error) { return len(p), nil }
+
+func TestWithSizePadding_Giant(t *testing.T) {
+	// Content just over 4GiB, which overflows a 32-bit size.
+	const size = 1<<32 + 3
+	r := newSizePaddingReader(&io.LimitedReader{R: nullReader{}, N: size}, PadToMultiple(1<<20))
+	n, err := io.CopyBuffer(io.Discard, r, make([]byte, 1<<20))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := int64(1<<32 + 1<<20); n != want {
+		t.Errorf("padded size = %d, want %d", n, want)
+	}
+}
@@ -0,0 +1,37 @@
+//go:build !tinygo
+
+package eris
+
+import (
+	"context"
+	"net/http"
+)
+
+// sniffLen is the maximum number of bytes considered by
+// http.DetectContentType.
+const sniffLen = 512
+
+// SniffContentType determines the MIME type of the content of an ERIS tree
+// rooted at rc using http.DetectContentType, without decoding the whole
+// content. Only the blocks on the path to the first leaf are fetched, which
+// is enough for any valid block size since leaves are at least as large as
+// the 512 bytes that DetectContentType considers.
+//
+// This is intended for gateways that need to set a Content-Type header before
+// streaming content to a client. It always returns a valid MIME type if err
+// is nil; see http.DetectContentType.
+func SniffContentType(ctx context.Context, fetch FetchFunc, rc ReadCapability) (string, error) {
+	dec := NewDecoder(fetch, rc)
+	if !dec.Next(ctx) {
+		if err := dec.Err(); err != nil {
+			return "", err
+		}
+		return http.DetectContentType(nil), nil
+	}
+
+	block := dec.Block()
+	if len(block) > sniffLen {
+		block = block[:sniffLen]
+	}
+	return http.DetectContentType(block), nil
+}
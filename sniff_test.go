@@ -0,0 +1,46 @@
+//go:build !tinygo
+
+package eris
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func TestSniffContentType(t *testing.T) {
+	html := []byte("<!DOCTYPE html><html><body>hello</body></html>")
+	png := append([]byte("\x89PNG\x0D\x0A\x1A\x0A"), randomContent(100000)...)
+
+	testCases := []struct {
+		name      string
+		content   []byte
+		blockSize int
+		want      string
+	}{
+		{"empty", nil, 1024, "text/plain; charset=utf-8"},
+		{"small html", html, 1024, "text/html; charset=utf-8"},
+		{"large html", append(bytes.Clone(html), bytes.Repeat([]byte("a"), 100000)...), 1024, "text/html; charset=utf-8"},
+		{"png small blocks", png, 1024, "image/png"},
+		{"png large blocks", png, 32 * 1024, "image/png"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rc, blocks := encodeToMap(t, tc.content, tc.blockSize)
+
+			var calls int
+			got, err := SniffContentType(context.Background(), mapFetch(blocks, &calls), rc)
+			if err != nil {
+				t.Fatalf("SniffContentType: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+
+			// Only the path to the first leaf should be fetched.
+			if calls != rc.Level+1 {
+				t.Errorf("fetched %d blocks, want %d", calls, rc.Level+1)
+			}
+		})
+	}
+}
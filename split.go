@@ -17,6 +17,13 @@ type splitter struct {
 	// buf is the working buffer for reading
 	buf []byte
 
+	// n is the number of bytes of content in buf, not including any
+	// padding.
+	n int
+
+	// off is the total number of bytes read from r.
+	off int64
+
 	// done is whether the iterator has finished. This is set when the
 	// iterator needs to yield a final (padded) block, and then not
 	// continue to read from the underlying reader.
@@ -49,6 +56,8 @@ func (s *splitter) Next() bool {
 	//
 	// Any other return value is an error.
 	n, err := io.ReadFull(s.r, s.buf)
+	s.n = n
+	s.off += int64(n)
 	if n == s.blockSize {
 		return true
 	}
@@ -101,12 +110,24 @@ func (s *splitter) Err() error {
 	return s.err
 }
 
+// Offset returns the total number of bytes that have been read from the
+// underlying reader.
+func (s *splitter) Offset() int64 {
+	return s.off
+}
+
 // Block returns the current block of bytes from the splitter. The returned
 // buffer is only valid until the next call to Next.
 func (s *splitter) Block() []byte {
 	return s.buf
 }
 
+// ContentLen returns the number of bytes of content in the current block, not
+// including any padding.
+func (s *splitter) ContentLen() int {
+	return s.n
+}
+
 // Reset will reset the splitter to read from the beginning of the given reader.
 // This will clear any error state and allow the splitter to be reused.
 //
@@ -115,4 +136,6 @@ func (s *splitter) Reset(r io.Reader) {
 	s.r = r
 	s.err = nil
 	s.done = false
+	s.n = 0
+	s.off = 0
 }
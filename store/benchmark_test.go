@@ -0,0 +1,87 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/andrew-d/eris-go"
+)
+
+// storeBackends are the Store implementations that the store benchmarks
+// compare; each function returns a new, empty store.
+var storeBackends = []struct {
+	name string
+	new  func(b *testing.B) Store
+}{
+	{"Memory", func(*testing.B) Store { return NewMemory() }},
+	{"Dir", func(b *testing.B) Store {
+		d, err := NewDir(b.TempDir())
+		if err != nil {
+			b.Fatal(err)
+		}
+		return d
+	}},
+	{"Cache", func(*testing.B) Store { return NewCache(CacheOptions{MaxBytes: 1 << 30}) }},
+}
+
+func BenchmarkStorePut(b *testing.B) {
+	for _, backend := range storeBackends {
+		for _, size := range []int{1024, 32 * 1024} {
+			b.Run(fmt.Sprintf("%s/BlockSize=%d", backend.name, size), func(b *testing.B) {
+				s := backend.new(b)
+				refs, blocks := makeBlocks(b.N, size)
+
+				ctx := context.Background()
+				b.SetBytes(int64(size))
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					if err := s.Put(ctx, refs[i], blocks[i]); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkStoreGet(b *testing.B) {
+	const numBlocks = 256
+	for _, backend := range storeBackends {
+		for _, size := range []int{1024, 32 * 1024} {
+			b.Run(fmt.Sprintf("%s/BlockSize=%d", backend.name, size), func(b *testing.B) {
+				s := backend.new(b)
+				refs, blocks := makeBlocks(numBlocks, size)
+
+				ctx := context.Background()
+				for i := range refs {
+					if err := s.Put(ctx, refs[i], blocks[i]); err != nil {
+						b.Fatal(err)
+					}
+				}
+
+				buf := make([]byte, size)
+				b.SetBytes(int64(size))
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					if _, err := s.Get(ctx, refs[i%numBlocks], buf); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+// makeBlocks returns n distinct blocks of the given size and their
+// references.
+func makeBlocks(n, size int) ([]eris.Reference, [][]byte) {
+	refs := make([]eris.Reference, n)
+	blocks := make([][]byte, n)
+	for i := range blocks {
+		refs[i], blocks[i] = makeBlock(i, size)
+	}
+	return refs, blocks
+}
@@ -0,0 +1,224 @@
+package store
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/andrew-d/eris-go"
+)
+
+// CacheOptions contains options for NewCache.
+type CacheOptions struct {
+	// MaxBytes is the maximum total size of the blocks in the cache. When
+	// adding a block would exceed this size, the least-recently used
+	// blocks are evicted. If zero, the cache size is unlimited.
+	MaxBytes int64
+
+	// MaxAge is the maximum time that a block is retained in the cache
+	// after it was last accessed. If zero, blocks never expire.
+	MaxAge time.Duration
+}
+
+// cacheEntry is a single block stored in a Cache.
+type cacheEntry struct {
+	ref        eris.Reference
+	block      []byte
+	lastAccess time.Time
+}
+
+// Cache is an in-memory Store that evicts blocks based on when they were last
+// accessed, intended for caching popular content in front of a slower store
+// (for example, with Union). The zero value is not valid; use NewCache to
+// create one.
+//
+// Since blocks may be evicted at any time, a Cache should not be used as the
+// only copy of any content.
+type Cache struct {
+	opts CacheOptions
+	now  func() time.Time // for testing
+
+	mu      sync.Mutex
+	size    int64
+	entries map[eris.Reference]*list.Element
+	lru     *list.List // of *cacheEntry; front is most recently used
+}
+
+// NewCache creates a new, empty Cache with the given options.
+func NewCache(opts CacheOptions) *Cache {
+	return &Cache{
+		opts:    opts,
+		now:     time.Now,
+		entries: make(map[eris.Reference]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// lookup returns the entry for ref, removing it if it has expired, and marks
+// it as recently used. The caller must hold c.mu.
+func (c *Cache) lookup(ref eris.Reference) *cacheEntry {
+	elem, ok := c.entries[ref]
+	if !ok {
+		return nil
+	}
+	ent := elem.Value.(*cacheEntry)
+
+	now := c.now()
+	if c.expired(ent, now) {
+		c.remove(elem)
+		return nil
+	}
+	ent.lastAccess = now
+	c.lru.MoveToFront(elem)
+	return ent
+}
+
+// expired reports whether ent has not been accessed within MaxAge.
+func (c *Cache) expired(ent *cacheEntry, now time.Time) bool {
+	return c.opts.MaxAge > 0 && now.Sub(ent.lastAccess) > c.opts.MaxAge
+}
+
+// remove removes elem from the cache. The caller must hold c.mu.
+func (c *Cache) remove(elem *list.Element) {
+	ent := c.lru.Remove(elem).(*cacheEntry)
+	delete(c.entries, ent.ref)
+	c.size -= int64(len(ent.block))
+}
+
+// Get implements the Store interface. A successful Get marks the block as
+// recently used.
+func (c *Cache) Get(_ context.Context, ref eris.Reference, buf []byte) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ent := c.lookup(ref)
+	if ent == nil {
+		return nil, fmt.Errorf("%w: %v", ErrNotFound, ref)
+	}
+	return append(buf[:0], ent.block...), nil
+}
+
+// Put implements the Store interface. If the cache is full, the
+// least-recently used blocks are evicted to make room; a block that is larger
+// than MaxBytes is not stored at all.
+func (c *Cache) Put(_ context.Context, ref eris.Reference, block []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.lookup(ref) != nil {
+		return nil
+	}
+
+	size := int64(len(block))
+	if c.opts.MaxBytes > 0 && size > c.opts.MaxBytes {
+		return nil
+	}
+	for c.opts.MaxBytes > 0 && c.size+size > c.opts.MaxBytes {
+		c.remove(c.lru.Back())
+	}
+
+	ent := &cacheEntry{
+		ref:        ref,
+		block:      append([]byte(nil), block...),
+		lastAccess: c.now(),
+	}
+	c.entries[ref] = c.lru.PushFront(ent)
+	c.size += size
+	return nil
+}
+
+// Has implements the Store interface. Unlike Get, Has does not mark the block
+// as recently used.
+func (c *Cache) Has(_ context.Context, ref eris.Reference) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[ref]
+	if !ok {
+		return false, nil
+	}
+	return !c.expired(elem.Value.(*cacheEntry), c.now()), nil
+}
+
+// Delete implements the Deleter interface.
+func (c *Cache) Delete(_ context.Context, ref eris.Reference) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[ref]; ok {
+		c.remove(elem)
+	}
+	return nil
+}
+
+// List implements the Lister interface. Listing does not mark blocks as
+// recently used.
+func (c *Cache) List(ctx context.Context, fn func(eris.Reference) error) error {
+	c.mu.Lock()
+	now := c.now()
+	refs := make([]eris.Reference, 0, len(c.entries))
+	for ref, elem := range c.entries {
+		if !c.expired(elem.Value.(*cacheEntry), now) {
+			refs = append(refs, ref)
+		}
+	}
+	c.mu.Unlock()
+
+	slices.SortFunc(refs, func(a, b eris.Reference) int {
+		return bytes.Compare(a[:], b[:])
+	})
+	for _, ref := range refs {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(ref); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Expire removes all blocks that have not been accessed within MaxAge, and
+// returns the number of blocks removed. Expired blocks are never returned
+// from the cache, but are otherwise only removed lazily; callers can call
+// Expire periodically to free their memory sooner.
+func (c *Cache) Expire() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.opts.MaxAge <= 0 {
+		return 0
+	}
+
+	// Since the list is ordered by last access, the expired blocks are
+	// all at the back.
+	now := c.now()
+	var n int
+	for elem := c.lru.Back(); elem != nil; elem = c.lru.Back() {
+		if !c.expired(elem.Value.(*cacheEntry), now) {
+			break
+		}
+		c.remove(elem)
+		n++
+	}
+	return n
+}
+
+// Len returns the number of blocks in the cache, including any expired
+// blocks that have not yet been removed.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// Size returns the total size of the blocks in the cache, in bytes.
+func (c *Cache) Size() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.size
+}
@@ -0,0 +1,115 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/andrew-d/eris-go"
+)
+
+func TestCache_MaxBytes(t *testing.T) {
+	ctx := context.Background()
+	c := NewCache(CacheOptions{MaxBytes: 3 * 1024})
+
+	ref1, block1 := makeBlock(1, 1024)
+	ref2, block2 := makeBlock(2, 1024)
+	ref3, block3 := makeBlock(3, 1024)
+	ref4, block4 := makeBlock(4, 1024)
+	c.Put(ctx, ref1, block1)
+	c.Put(ctx, ref2, block2)
+	c.Put(ctx, ref3, block3)
+
+	// Touch the first block, so that the second is the least-recently
+	// used and is evicted when we add a fourth.
+	if _, err := c.Get(ctx, ref1, make([]byte, 1024)); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if err := c.Put(ctx, ref4, block4); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	if c.Len() != 3 || c.Size() != 3*1024 {
+		t.Errorf("Len, Size = %d, %d; want 3, %d", c.Len(), c.Size(), 3*1024)
+	}
+	for ref, want := range map[eris.Reference]bool{ref1: true, ref2: false, ref3: true, ref4: true} {
+		if has, _ := c.Has(ctx, ref); has != want {
+			t.Errorf("Has(%x) = %v, want %v", ref[:4], has, want)
+		}
+	}
+
+	// A block that can never fit is silently dropped.
+	big, bigBlock := makeBlock(5, 32*1024)
+	if err := c.Put(ctx, big, bigBlock); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if has, _ := c.Has(ctx, big); has {
+		t.Errorf("oversized block was stored")
+	}
+}
+
+func TestCache_MaxAge(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1_700_000_000, 0)
+	c := NewCache(CacheOptions{MaxAge: time.Minute})
+	c.now = func() time.Time { return now }
+
+	ref1, block1 := makeBlock(1, 1024)
+	ref2, block2 := makeBlock(2, 1024)
+	c.Put(ctx, ref1, block1)
+	c.Put(ctx, ref2, block2)
+
+	// Accessing the first block resets its age.
+	now = now.Add(45 * time.Second)
+	if _, err := c.Get(ctx, ref1, make([]byte, 1024)); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	now = now.Add(30 * time.Second)
+	if _, err := c.Get(ctx, ref2, make([]byte, 1024)); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get of expired block: got %v, want ErrNotFound", err)
+	}
+	if _, err := c.Get(ctx, ref1, make([]byte, 1024)); err != nil {
+		t.Errorf("Get of recently-used block: %v", err)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if n := c.Expire(); n != 1 {
+		t.Errorf("Expire removed %d blocks, want 1", n)
+	}
+	if c.Len() != 0 || c.Size() != 0 {
+		t.Errorf("Len, Size = %d, %d after expiring everything", c.Len(), c.Size())
+	}
+}
+
+func TestCache_ConcurrentEviction(t *testing.T) {
+	ctx := context.Background()
+	c := NewCache(CacheOptions{MaxBytes: 8 * 1024})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			buf := make([]byte, 1024)
+			for j := 0; j < 200; j++ {
+				ref, block := makeBlock(j%32, 1024)
+				if err := c.Put(ctx, ref, block); err != nil {
+					t.Error(err)
+					return
+				}
+				if got, err := c.Get(ctx, ref, buf); err == nil && len(got) != len(block) {
+					t.Errorf("Get returned %d bytes, want %d", len(got), len(block))
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if c.Size() > 8*1024 {
+		t.Errorf("cache size %d exceeds limit", c.Size())
+	}
+}
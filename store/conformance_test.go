@@ -0,0 +1,60 @@
+package store_test
+
+import (
+	"testing"
+
+	"github.com/andrew-d/eris-go/store"
+	"github.com/andrew-d/eris-go/store/storetest"
+)
+
+func TestMemory(t *testing.T) {
+	storetest.TestStore(t, func() store.Store {
+		return store.NewMemory()
+	})
+}
+
+func TestDir(t *testing.T) {
+	storetest.TestStore(t, func() store.Store {
+		s, err := store.NewDir(t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	})
+}
+
+func TestMirrorConformance(t *testing.T) {
+	storetest.TestStore(t, func() store.Store {
+		return store.Mirror(store.NewMemory(), store.NewMemory())
+	})
+}
+
+func TestUnionConformance(t *testing.T) {
+	storetest.TestStore(t, func() store.Store {
+		return store.Union(store.NewMemory(), store.ReadOnly(store.NewMemory()))
+	})
+}
+
+func TestCacheConformance(t *testing.T) {
+	storetest.TestStore(t, func() store.Store {
+		return store.NewCache(store.CacheOptions{})
+	})
+}
+
+func TestWriteOnceConformance(t *testing.T) {
+	storetest.TestStore(t, func() store.Store {
+		return store.NewWriteOnce(store.NewMemory())
+	})
+}
+
+func TestReadRepairConformance(t *testing.T) {
+	storetest.TestStore(t, func() store.Store {
+		return store.NewReadRepair(store.NewMemory(), store.NewMemory())
+	})
+}
+
+func TestInstrumentedConformance(t *testing.T) {
+	storetest.TestStore(t, func() store.Store {
+		return store.NewInstrumented(store.NewMemory(), nil)
+	})
+}
@@ -0,0 +1,270 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/andrew-d/eris-go"
+)
+
+var base32Enc = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// tempPrefix is the prefix of the names of the temporary files that Put
+// writes blocks to before renaming them into place.
+const tempPrefix = ".tmp-"
+
+// defaultCompactMinAge is the default value for CompactOptions.MinAge.
+const defaultCompactMinAge = time.Hour
+
+// Dir is a Store that keeps each block in a separate file in a directory on
+// disk. Each file is named with the unpadded base32 encoding of the block's
+// reference, which mimics the upstream ERIS specification for cloud storage.
+type Dir struct {
+	path string
+}
+
+// NewDir creates a Store that keeps blocks in the given directory, which must
+// already exist.
+func NewDir(path string) (*Dir, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", path)
+	}
+	return &Dir{path: path}, nil
+}
+
+// pathFor returns the path of the file that stores the block with the given
+// reference.
+func (d *Dir) pathFor(ref eris.Reference) string {
+	return filepath.Join(d.path, ref.Base32())
+}
+
+// Get implements the Store interface.
+func (d *Dir) Get(_ context.Context, ref eris.Reference, buf []byte) ([]byte, error) {
+	data, err := os.ReadFile(d.pathFor(ref))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %v", ErrNotFound, ref)
+	} else if err != nil {
+		return nil, err
+	}
+
+	// Use the provided buffer if it's large enough, to match the
+	// semantics of eris.FetchFunc.
+	if cap(buf) >= len(data) {
+		return append(buf[:0], data...), nil
+	}
+	return data, nil
+}
+
+// Put implements the Store interface.
+//
+// The block is written to a temporary file which is then renamed into place,
+// so that a crash part-way through a write never leaves a partial block behind
+// under the block's name.
+func (d *Dir) Put(ctx context.Context, ref eris.Reference, block []byte) error {
+	path := d.pathFor(ref)
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+
+	f, err := os.CreateTemp(d.path, tempPrefix+"*")
+	if err != nil {
+		return err
+	}
+	tmpName := f.Name()
+
+	_, err = f.Write(block)
+	err2 := f.Close()
+	if err := errors.Join(err, err2); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	if err := os.Rename(tmpName, path); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	return nil
+}
+
+// Has implements the Store interface.
+func (d *Dir) Has(_ context.Context, ref eris.Reference) (bool, error) {
+	_, err := os.Stat(d.pathFor(ref))
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Ping implements the Pinger interface, by checking that the directory still
+// exists.
+func (d *Dir) Ping(_ context.Context) error {
+	fi, err := os.Stat(d.path)
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		return fmt.Errorf("%s is not a directory", d.path)
+	}
+	return nil
+}
+
+// Stat implements the Stater interface, using the size and modification time
+// of the block's file.
+func (d *Dir) Stat(_ context.Context, ref eris.Reference) (BlockInfo, error) {
+	fi, err := os.Stat(d.pathFor(ref))
+	if errors.Is(err, fs.ErrNotExist) {
+		return BlockInfo{}, fmt.Errorf("%w: %v", ErrNotFound, ref)
+	} else if err != nil {
+		return BlockInfo{}, err
+	}
+	return BlockInfo{Size: fi.Size(), ModTime: fi.ModTime()}, nil
+}
+
+// List implements the Lister interface. Files in the directory that aren't
+// named like a block are ignored.
+func (d *Dir) List(ctx context.Context, fn func(eris.Reference) error) error {
+	entries, err := os.ReadDir(d.path)
+	if err != nil {
+		return err
+	}
+
+	// The order of the file names doesn't match the order of the
+	// references' bytes, so decode and sort them first.
+	refs := make([]eris.Reference, 0, len(entries))
+	for _, ent := range entries {
+		if ref, ok := parseBlockName(ent.Name()); ok && ent.Type().IsRegular() {
+			refs = append(refs, ref)
+		}
+	}
+	slices.SortFunc(refs, func(a, b eris.Reference) int {
+		return bytes.Compare(a[:], b[:])
+	})
+
+	for _, ref := range refs {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(ref); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete implements the Deleter interface.
+func (d *Dir) Delete(_ context.Context, ref eris.Reference) error {
+	err := os.Remove(d.pathFor(ref))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// parseBlockName parses the name of a file in a Dir store into a reference,
+// returning false if the name isn't a valid block name.
+func parseBlockName(name string) (ref eris.Reference, ok bool) {
+	if base32Enc.EncodedLen(len(ref)) != len(name) {
+		return ref, false
+	}
+	n, err := base32Enc.Decode(ref[:], []byte(name))
+	if err != nil || n != len(ref) {
+		return ref, false
+	}
+	return ref, true
+}
+
+// CompactOptions contains options for Dir.Compact.
+type CompactOptions struct {
+	// MinAge is the minimum age of a temporary file that is removed, so
+	// that the temporary files of writes that are still in progress are
+	// left alone. If zero, a default of one hour is used.
+	MinAge time.Duration
+
+	// Progress, if non-nil, is called after each file in the directory
+	// has been examined, with the results so far.
+	Progress func(CompactResult)
+}
+
+// CompactResult contains the results of a Dir.Compact.
+type CompactResult struct {
+	// Scanned is the number of files that were examined.
+	Scanned int64
+	// Removed is the number of temporary files that were removed.
+	Removed int64
+	// RemovedBytes is the total size of the files counted in Removed.
+	RemovedBytes int64
+}
+
+// Compact removes the temporary files that are left behind in the directory
+// when a Put is interrupted, for example by a crash or a full disk, and
+// returns how much space was reclaimed. Block files, and any other files,
+// are never touched.
+//
+// Compact is safe to run while the store is in use, as long as no Put takes
+// longer than CompactOptions.MinAge.
+func (d *Dir) Compact(ctx context.Context, opts CompactOptions) (CompactResult, error) {
+	var res CompactResult
+	minAge := opts.MinAge
+	if minAge <= 0 {
+		minAge = defaultCompactMinAge
+	}
+	cutoff := time.Now().Add(-minAge)
+
+	entries, err := os.ReadDir(d.path)
+	if err != nil {
+		return res, err
+	}
+	for _, ent := range entries {
+		if err := ctx.Err(); err != nil {
+			return res, err
+		}
+		res.Scanned++
+		if strings.HasPrefix(ent.Name(), tempPrefix) && ent.Type().IsRegular() {
+			if err := d.removeTemp(ent, cutoff, &res); err != nil {
+				return res, err
+			}
+		}
+		if opts.Progress != nil {
+			opts.Progress(res)
+		}
+	}
+	return res, nil
+}
+
+// removeTemp removes a temporary file if it was last modified before cutoff.
+func (d *Dir) removeTemp(ent fs.DirEntry, cutoff time.Time, res *CompactResult) error {
+	fi, err := ent.Info()
+	if errors.Is(err, fs.ErrNotExist) {
+		// The write finished (or failed) since the directory was read.
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if !fi.ModTime().Before(cutoff) {
+		return nil
+	}
+
+	err = os.Remove(filepath.Join(d.path, ent.Name()))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	res.Removed++
+	res.RemovedBytes += fi.Size()
+	return nil
+}
@@ -0,0 +1,183 @@
+package store
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andrew-d/eris-go"
+)
+
+func TestDir_Compact(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	d, err := NewDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, block := makeBlock(1, 1024)
+	if err := d.Put(ctx, ref, block); err != nil {
+		t.Fatal(err)
+	}
+
+	// An old temporary file, as left by an interrupted Put, a recent one
+	// that may belong to a Put in progress, and an unrelated file.
+	old := time.Now().Add(-2 * time.Hour)
+	for name, mtime := range map[string]time.Time{
+		tempPrefix + "old":    old,
+		tempPrefix + "recent": time.Now(),
+		"notes.txt":           old,
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, make([]byte, 100), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var progress int
+	res, err := d.Compact(ctx, CompactOptions{
+		Progress: func(CompactResult) { progress++ },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := CompactResult{Scanned: 4, Removed: 1, RemovedBytes: 100}
+	if res != want {
+		t.Errorf("result = %+v, want %+v", res, want)
+	}
+	if progress != 4 {
+		t.Errorf("Progress called %d times, want 4", progress)
+	}
+
+	for name, wantExist := range map[string]bool{
+		tempPrefix + "old":    false,
+		tempPrefix + "recent": true,
+		"notes.txt":           true,
+	} {
+		_, err := os.Stat(filepath.Join(dir, name))
+		if exists := err == nil; exists != wantExist {
+			t.Errorf("%s exists = %v, want %v", name, exists, wantExist)
+		}
+	}
+	if ok, _ := d.Has(ctx, ref); !ok {
+		t.Error("block was removed")
+	}
+
+	// A short MinAge also removes the recent file.
+	res, err = d.Compact(ctx, CompactOptions{MinAge: time.Nanosecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Removed != 1 {
+		t.Errorf("Removed = %d with short MinAge, want 1", res.Removed)
+	}
+}
+
+func TestDir_Check(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	d, err := NewDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var refs [5]eris.Reference
+	for i := range refs {
+		ref, block := makeBlock(i, 1024)
+		if err := d.Put(ctx, ref, block); err != nil {
+			t.Fatal(err)
+		}
+		refs[i] = ref
+	}
+	write := func(name string, data []byte) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	read := func(ref eris.Reference) []byte {
+		t.Helper()
+		data, err := os.ReadFile(d.pathFor(ref))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+
+	// refs[0] is intact. refs[1] is torn, and refs[2] has a flipped bit.
+	write(refs[1].Base32(), read(refs[1])[:100])
+	rotten := read(refs[2])
+	rotten[10] ^= 1
+	write(refs[2].Base32(), rotten)
+
+	// refs[3] is stored under a lower-case name instead, and refs[4]
+	// under both its canonical name and a padded one.
+	lower := strings.ToLower(refs[3].Base32())
+	block3 := read(refs[3])
+	os.Remove(d.pathFor(refs[3]))
+	write(lower, block3)
+	padded := refs[4].Base32() + "===="
+	write(padded, read(refs[4]))
+
+	write("README", []byte("hello"))
+	write(tempPrefix+"123", nil)
+
+	res, err := d.Check(ctx, CheckOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := CheckResult{
+		Checked:  6,
+		Torn:     []eris.Reference{refs[1]},
+		Corrupt:  []eris.Reference{refs[2]},
+		Misnamed: []string{lower, padded},
+		Unknown:  []string{"README"},
+	}
+	slices.Sort(res.Misnamed)
+	slices.Sort(want.Misnamed)
+	if !reflect.DeepEqual(res, want) {
+		t.Errorf("Check:\ngot  %+v\nwant %+v", res, want)
+	}
+	if ok, _ := d.Has(ctx, refs[3]); ok {
+		t.Error("check without repair renamed a file")
+	}
+
+	res, err = d.Check(ctx, CheckOptions{Repair: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Removed != 1 || res.Renamed != 2 {
+		t.Errorf("repair: removed %d, renamed %d; want 1, 2", res.Removed, res.Renamed)
+	}
+
+	// After repairing, only the corrupt block is left to report, and
+	// every misnamed block is available under its reference.
+	res, err = d.Check(ctx, CheckOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = CheckResult{
+		Checked: 4,
+		Corrupt: []eris.Reference{refs[2]},
+		Unknown: []string{"README"},
+	}
+	if !reflect.DeepEqual(res, want) {
+		t.Errorf("Check after repair:\ngot  %+v\nwant %+v", res, want)
+	}
+	for _, ref := range []eris.Reference{refs[3], refs[4]} {
+		if got, err := d.Get(ctx, ref, nil); err != nil || checkBlock(ref, got) != nil {
+			t.Errorf("block %v not restored: %v", ref, err)
+		}
+	}
+	if ok, _ := d.Has(ctx, refs[1]); ok {
+		t.Error("torn block still present")
+	}
+}
@@ -0,0 +1,156 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/andrew-d/eris-go"
+)
+
+// CheckOptions contains options for Dir.Check.
+type CheckOptions struct {
+	// Repair fixes the problems that can be fixed without another copy
+	// of the blocks: torn blocks are removed, and block files with
+	// non-canonical names are renamed.
+	Repair bool
+
+	// Progress, if non-nil, is called after each file in the directory
+	// has been checked, with the results so far.
+	Progress func(CheckResult)
+}
+
+// CheckResult contains the results of a Dir.Check.
+type CheckResult struct {
+	// Checked is the number of block files that were checked.
+	Checked int64
+
+	// Torn contains the references of blocks whose file has the wrong
+	// size, as left by a write that was interrupted by a crash before it
+	// reached the disk.
+	Torn []eris.Reference
+
+	// Corrupt contains the references of blocks whose file has the right
+	// size but the wrong contents, which is the signature of bit rot.
+	Corrupt []eris.Reference
+
+	// Misnamed contains the names of files that hold a valid block, but
+	// under a name other than the canonical name for its reference (for
+	// example, in lower case, or with base32 padding).
+	Misnamed []string
+
+	// Unknown contains the names of entries in the directory that are
+	// neither blocks nor temporary files.
+	Unknown []string
+
+	// Removed is the number of torn blocks that were removed, and Renamed
+	// the number of misnamed files that were renamed (or removed, if the
+	// block already existed under its canonical name).
+	Removed, Renamed int64
+}
+
+// Check examines every file in the directory, like fsck, and reports the ways
+// in which it differs from what Put writes: blocks with the wrong size or
+// contents, blocks under non-canonical names, and unexpected files. With
+// CheckOptions.Repair set, it also fixes what it can.
+//
+// Put doesn't sync blocks to disk, so a crash shortly after a write can leave
+// a block's file empty or truncated. Such torn blocks are reported separately
+// from corrupt ones, whose size is right but whose hash is not, since bit rot
+// is a sign of failing hardware while torn writes are not. Both kinds of block
+// prevent the block from being written again, since Put skips blocks that
+// already exist; a torn block has no useful contents, so Repair removes it,
+// while corrupt blocks are left for Scrub or Repair to deal with.
+//
+// Temporary files are ignored; see Compact.
+func (d *Dir) Check(ctx context.Context, opts CheckOptions) (CheckResult, error) {
+	var res CheckResult
+	entries, err := os.ReadDir(d.path)
+	if err != nil {
+		return res, err
+	}
+	for _, ent := range entries {
+		if err := ctx.Err(); err != nil {
+			return res, err
+		}
+		name := ent.Name()
+		if strings.HasPrefix(name, tempPrefix) {
+			continue
+		}
+		if err := d.checkFile(name, ent, opts.Repair, &res); err != nil {
+			return res, err
+		}
+		if opts.Progress != nil {
+			opts.Progress(res)
+		}
+	}
+	return res, nil
+}
+
+// checkFile checks a single entry in the directory for Check.
+func (d *Dir) checkFile(name string, ent fs.DirEntry, repair bool, res *CheckResult) error {
+	ref, canonical := parseBlockName(name)
+	if !canonical {
+		var ok bool
+		ref, ok = parseBlockName(strings.ToUpper(strings.TrimRight(name, "=")))
+		if !ok {
+			res.Unknown = append(res.Unknown, name)
+			return nil
+		}
+	}
+	if !ent.Type().IsRegular() {
+		res.Unknown = append(res.Unknown, name)
+		return nil
+	}
+
+	path := filepath.Join(d.path, name)
+	block, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		// Deleted since the directory was read.
+		return nil
+	} else if err != nil {
+		return err
+	}
+	res.Checked++
+
+	err = checkBlock(ref, block)
+	switch {
+	case !canonical && err == nil:
+		res.Misnamed = append(res.Misnamed, name)
+		if repair {
+			return d.rename(path, ref, res)
+		}
+	case !canonical:
+		// Something that looks like a block, but isn't one.
+		res.Unknown = append(res.Unknown, name)
+	case errors.Is(err, eris.ErrInvalidBlockSize):
+		res.Torn = append(res.Torn, ref)
+		if repair {
+			if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return err
+			}
+			res.Removed++
+		}
+	case err != nil:
+		res.Corrupt = append(res.Corrupt, ref)
+	}
+	return nil
+}
+
+// rename moves a valid block with a non-canonical name to its canonical name,
+// or removes it if the block is already stored there.
+func (d *Dir) rename(path string, ref eris.Reference, res *CheckResult) error {
+	target := d.pathFor(ref)
+	if _, err := os.Stat(target); err == nil {
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	} else if err := os.Rename(path, target); err != nil {
+		return err
+	}
+	res.Renamed++
+	return nil
+}
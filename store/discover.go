@@ -0,0 +1,64 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/andrew-d/eris-go"
+)
+
+// Discoverer is implemented by types that can find sources for a block that
+// aren't known in advance; for example, by looking up which peers in a
+// peer-to-peer network have announced the block, or by querying a directory
+// of block servers.
+type Discoverer interface {
+	// Discover returns functions that fetch the block with the given
+	// reference from each of the sources that were found, in order of
+	// preference. If no sources are found, it returns an empty slice and
+	// a nil error.
+	Discover(ctx context.Context, ref eris.Reference) ([]eris.FetchFunc, error)
+}
+
+// DiscovererFunc is an adapter to allow the use of ordinary functions as
+// Discoverers.
+type DiscovererFunc func(ctx context.Context, ref eris.Reference) ([]eris.FetchFunc, error)
+
+// Discover implements the Discoverer interface by calling f.
+func (f DiscovererFunc) Discover(ctx context.Context, ref eris.Reference) ([]eris.FetchFunc, error) {
+	return f(ctx, ref)
+}
+
+// RaceDiscover returns an eris.FetchFunc that fetches blocks from the given
+// sources like Race, and falls back to sources found by d.
+//
+// Discovery is usually much slower than fetching from a known source, so d is
+// only consulted for a block once every one of the given sources has failed
+// to return a valid copy of it. The discovered sources are then raced in the
+// same way, with the same hedge delay. If no source returns a valid block, the
+// returned error joins the errors from all sources and from discovery; in
+// particular, it wraps ErrNotFound if no sources were discovered.
+func RaceDiscover(hedgeDelay time.Duration, d Discoverer, fetches ...eris.FetchFunc) eris.FetchFunc {
+	known := Race(hedgeDelay, fetches...)
+	return func(ctx context.Context, ref eris.Reference, buf []byte) ([]byte, error) {
+		var knownErr error
+		if len(fetches) > 0 {
+			block, err := known(ctx, ref, buf)
+			if err == nil || ctx.Err() != nil {
+				return block, err
+			}
+			knownErr = err
+		}
+
+		discovered, err := d.Discover(ctx, ref)
+		if err != nil {
+			return nil, errors.Join(knownErr, fmt.Errorf("discovering sources: %w", err))
+		}
+		block, err := Race(hedgeDelay, discovered...)(ctx, ref, buf)
+		if err != nil {
+			return nil, errors.Join(knownErr, fmt.Errorf("discovered: %w", err))
+		}
+		return block, nil
+	}
+}
@@ -0,0 +1,89 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/blake2b"
+
+	"github.com/andrew-d/eris-go"
+	"github.com/andrew-d/eris-go/internal/result"
+)
+
+func TestRaceDiscover(t *testing.T) {
+	ctx := context.Background()
+	block := []byte("some block contents")
+	ref := eris.Reference(blake2b.Sum256(block))
+	good := result.Of(block)
+	notFound := result.Error[[]byte](ErrNotFound)
+
+	// discoverer returns a Discoverer that finds the given sources, and
+	// counts the number of times it was called.
+	discoverer := func(calls *atomic.Int32, fetches ...eris.FetchFunc) Discoverer {
+		return DiscovererFunc(func(context.Context, eris.Reference) ([]eris.FetchFunc, error) {
+			calls.Add(1)
+			return fetches, nil
+		})
+	}
+
+	t.Run("KnownFirst", func(t *testing.T) {
+		var calls atomic.Int32
+		fetch := RaceDiscover(time.Hour, discoverer(&calls, constFetch(0, good, nil)),
+			constFetch(0, good, nil),
+		)
+		got, err := fetch(ctx, ref, make([]byte, 32))
+		if err != nil || string(got) != string(block) {
+			t.Fatalf("got %q, %v", got, err)
+		}
+		if calls.Load() != 0 {
+			t.Errorf("discoverer was called although a known source had the block")
+		}
+	})
+
+	t.Run("Fallback", func(t *testing.T) {
+		var calls atomic.Int32
+		fetch := RaceDiscover(time.Hour, discoverer(&calls, constFetch(0, notFound, nil), constFetch(0, good, nil)),
+			constFetch(0, notFound, nil),
+		)
+		got, err := fetch(ctx, ref, make([]byte, 32))
+		if err != nil || string(got) != string(block) {
+			t.Fatalf("got %q, %v", got, err)
+		}
+		if calls.Load() != 1 {
+			t.Errorf("discoverer called %d times, want 1", calls.Load())
+		}
+	})
+
+	t.Run("NoKnownSources", func(t *testing.T) {
+		var calls atomic.Int32
+		fetch := RaceDiscover(time.Hour, discoverer(&calls, constFetch(0, good, nil)))
+		got, err := fetch(ctx, ref, make([]byte, 32))
+		if err != nil || string(got) != string(block) {
+			t.Fatalf("got %q, %v", got, err)
+		}
+	})
+
+	t.Run("NothingDiscovered", func(t *testing.T) {
+		var calls atomic.Int32
+		fetch := RaceDiscover(time.Hour, discoverer(&calls), constFetch(0, notFound, nil))
+		_, err := fetch(ctx, ref, make([]byte, 32))
+		if !errors.Is(err, ErrNotFound) {
+			t.Errorf("got %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("DiscoveryFails", func(t *testing.T) {
+		errBroken := errors.New("broken")
+		d := DiscovererFunc(func(context.Context, eris.Reference) ([]eris.FetchFunc, error) {
+			return nil, errBroken
+		})
+		fetch := RaceDiscover(time.Hour, d, constFetch(0, notFound, nil))
+		_, err := fetch(ctx, ref, make([]byte, 32))
+		if !errors.Is(err, errBroken) || !errors.Is(err, ErrNotFound) {
+			t.Errorf("got %v, want errors from both the source and discovery", err)
+		}
+	})
+}
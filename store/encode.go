@@ -0,0 +1,211 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	"github.com/andrew-d/eris-go"
+)
+
+// defaultBatchSize is the default value for EncodeOptions.BatchSize.
+const defaultBatchSize = 64
+
+// partialBatchDelay is how long EncodeToStore waits for the encoder, when
+// MaxInFlightBytes is set, before writing a batch that isn't full.
+const partialBatchDelay = 20 * time.Millisecond
+
+// EncodeOptions contains options for EncodeToStore.
+type EncodeOptions struct {
+	// BatchSize is the number of blocks that are buffered before checking
+	// which of them already exist in the store. If zero, a default of 64
+	// is used.
+	BatchSize int
+
+	// MaxInFlightBytes, if positive, runs the encoder in its own
+	// goroutine, so that encrypting blocks and writing them to the store
+	// happen concurrently rather than in turn. The encoder runs ahead of
+	// the writes by at most MaxInFlightBytes of blocks (but at least one
+	// block), in addition to the batch that is being written. If the
+	// encoder falls behind, a batch that isn't full is written once no
+	// block has arrived for a short time, so that the store doesn't sit
+	// idle either.
+	//
+	// This is most useful for remote stores, where the encoder would
+	// otherwise sit idle while each batch is uploaded.
+	MaxInFlightBytes int64
+}
+
+// EncodeStats contains statistics about the blocks written by EncodeToStore.
+type EncodeStats struct {
+	// Uploaded is the number of blocks that were written to the store.
+	Uploaded int64
+	// UploadedBytes is the total size of the blocks that were written.
+	UploadedBytes int64
+	// Skipped is the number of blocks that were not written because they
+	// already existed in the store.
+	Skipped int64
+}
+
+// EncodeToStore runs enc to completion, writing every block it emits to s,
+// and returns the read capability for the encoded content.
+//
+// Blocks are buffered in batches, and the existence of every block in a batch
+// is checked (using the BatchHaser interface if s implements it) before any
+// are written, so that blocks that are already present aren't uploaded again.
+// This is particularly useful for remote stores, where writes are expensive.
+func EncodeToStore(ctx context.Context, s Store, enc *eris.Encoder, opts EncodeOptions) (eris.ReadCapability, EncodeStats, error) {
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+	u := &batchUploader{
+		s:      s,
+		refs:   make([]eris.Reference, 0, batchSize),
+		blocks: make([][]byte, 0, batchSize),
+	}
+	if opts.MaxInFlightBytes > 0 {
+		return encodePipelined(ctx, u, enc, batchSize, opts.MaxInFlightBytes)
+	}
+
+	for enc.Next() {
+		// Copy the block, since we hold on to it past the next call
+		// to Next.
+		u.add(enc.Reference(), bytes.Clone(enc.Block()))
+		if len(u.refs) >= batchSize {
+			if err := u.flush(ctx); err != nil {
+				return eris.ReadCapability{}, u.stats, err
+			}
+		}
+	}
+	if err := enc.Err(); err != nil {
+		return eris.ReadCapability{}, u.stats, err
+	}
+	if err := u.flush(ctx); err != nil {
+		return eris.ReadCapability{}, u.stats, err
+	}
+	return enc.Capability(), u.stats, nil
+}
+
+// encodePipelined implements EncodeToStore when MaxInFlightBytes is set. The
+// encoder sends blocks to the uploader over a channel, which is sized to hold
+// maxInFlight bytes of blocks.
+func encodePipelined(ctx context.Context, u *batchUploader, enc *eris.Encoder, batchSize int, maxInFlight int64) (eris.ReadCapability, EncodeStats, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type encodedBlock struct {
+		ref   eris.Reference
+		block []byte
+	}
+	blocks := make(chan encodedBlock, max(1, maxInFlight/int64(enc.BlockSize())))
+	encErr := make(chan error, 1)
+	go func() {
+		defer close(blocks)
+		for enc.Next() {
+			b := encodedBlock{enc.Reference(), bytes.Clone(enc.Block())}
+			select {
+			case blocks <- b:
+			case <-ctx.Done():
+				encErr <- ctx.Err()
+				return
+			}
+		}
+		encErr <- enc.Err()
+	}()
+
+	idle := time.NewTimer(partialBatchDelay)
+	defer idle.Stop()
+	var err error
+loop:
+	for {
+		var (
+			b  encodedBlock
+			ok bool
+		)
+		if len(u.refs) == 0 {
+			b, ok = <-blocks
+		} else {
+			// Don't wait long for the encoder with blocks ready to
+			// write, but long enough that a slow encoder still
+			// fills batches.
+			idle.Reset(partialBatchDelay)
+			select {
+			case b, ok = <-blocks:
+			case <-idle.C:
+				if err = u.flush(ctx); err != nil {
+					break loop
+				}
+				continue
+			}
+		}
+		if !ok {
+			break
+		}
+
+		u.add(b.ref, b.block)
+		if len(u.refs) >= batchSize {
+			if err = u.flush(ctx); err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		// Stop the encoder, and wait for it so that enc isn't in use
+		// once we return.
+		cancel()
+		<-encErr
+		return eris.ReadCapability{}, u.stats, err
+	}
+	if err := <-encErr; err != nil {
+		return eris.ReadCapability{}, u.stats, err
+	}
+	if err := u.flush(ctx); err != nil {
+		return eris.ReadCapability{}, u.stats, err
+	}
+	return enc.Capability(), u.stats, nil
+}
+
+// batchUploader writes batches of blocks to a store for EncodeToStore,
+// skipping those that the store already has.
+type batchUploader struct {
+	s      Store
+	stats  EncodeStats
+	refs   []eris.Reference
+	blocks [][]byte
+}
+
+// add adds a block to the current batch.
+func (u *batchUploader) add(ref eris.Reference, block []byte) {
+	u.refs = append(u.refs, ref)
+	u.blocks = append(u.blocks, block)
+}
+
+// flush writes the blocks in the current batch that aren't already in the
+// store, and starts a new batch.
+func (u *batchUploader) flush(ctx context.Context) error {
+	if len(u.refs) == 0 {
+		return nil
+	}
+
+	has, err := HasMany(ctx, u.s, u.refs)
+	if err != nil {
+		return err
+	}
+	for i, ref := range u.refs {
+		if has[i] {
+			u.stats.Skipped++
+			continue
+		}
+		if err := u.s.Put(ctx, ref, u.blocks[i]); err != nil {
+			return err
+		}
+		u.stats.Uploaded++
+		u.stats.UploadedBytes += int64(len(u.blocks[i]))
+	}
+
+	clear(u.blocks)
+	u.refs = u.refs[:0]
+	u.blocks = u.blocks[:0]
+	return nil
+}
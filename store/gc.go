@@ -0,0 +1,130 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/andrew-d/eris-go"
+)
+
+// GCOptions contains options for GC.
+type GCOptions struct {
+	// Grace is the minimum age of a block that can be deleted; blocks
+	// written less than Grace before GC started are kept even if they
+	// are unreachable, so that content that is being written while GC
+	// runs isn't deleted before it has been pinned. A non-zero grace
+	// period requires the store to implement Stater.
+	Grace time.Duration
+	// DryRun, if set, only determines which blocks would be deleted,
+	// without deleting them.
+	DryRun bool
+}
+
+// GCResult contains the results of a GC.
+type GCResult struct {
+	// Marked is the number of distinct blocks that are reachable from
+	// the roots.
+	Marked int64
+	// Deleted is the number of unreachable blocks that were deleted; in
+	// a dry run, it is the number of blocks that would have been
+	// deleted.
+	Deleted int64
+	// DeletedBytes is the total size of the blocks counted in Deleted.
+	DeletedBytes int64
+	// Recent is the number of unreachable blocks that were kept because
+	// they were written within the grace period.
+	Recent int64
+}
+
+// GC deletes every block in st that is not part of the content identified by
+// one of the given root read capabilities. The store must implement Lister
+// and, unless GCOptions.DryRun is set, Deleter.
+//
+// GC first marks every block reachable from the roots, and then sweeps the
+// store, deleting unmarked blocks. If any internal node of a root can't be
+// fetched, GC returns an error before deleting anything, since the blocks
+// underneath it can't be marked.
+//
+// Blocks that are written while GC runs are only protected by the grace
+// period; in particular, content that is encoded into the store while GC runs
+// and reuses an existing, unreachable block may lose that block. Such content
+// should be added to the roots of the next GC before it is relied upon.
+func GC(ctx context.Context, st Store, roots []eris.ReadCapability, opts GCOptions) (GCResult, error) {
+	var res GCResult
+	start := time.Now()
+
+	lister, ok := st.(Lister)
+	if !ok {
+		return res, errors.New("store does not support listing blocks")
+	}
+	deleter, ok := st.(Deleter)
+	if !ok && !opts.DryRun {
+		return res, errors.New("store does not support deleting blocks")
+	}
+	stater, ok := st.(Stater)
+	if !ok && opts.Grace > 0 {
+		return res, errors.New("store does not record when blocks were written, so a grace period can't be used")
+	}
+
+	// Mark every block reachable from the roots.
+	refs, err := eris.Closure(ctx, st.Get, roots...)
+	if err != nil {
+		return res, fmt.Errorf("marking blocks: %w", err)
+	}
+	marked := make(map[eris.Reference]bool, len(refs))
+	for _, ref := range refs {
+		marked[ref] = true
+	}
+	res.Marked = int64(len(marked))
+
+	// Collect the unmarked blocks before deleting any, since not every
+	// store supports deleting blocks while listing.
+	var unmarked []eris.Reference
+	err = lister.List(ctx, func(ref eris.Reference) error {
+		if !marked[ref] {
+			unmarked = append(unmarked, ref)
+		}
+		return nil
+	})
+	if err != nil {
+		return res, fmt.Errorf("listing blocks: %w", err)
+	}
+
+	cutoff := start.Add(-opts.Grace)
+	buf := make([]byte, eris.BlockSizeLarge)
+	for _, ref := range unmarked {
+		var size int64
+		if stater != nil {
+			info, err := stater.Stat(ctx, ref)
+			if errors.Is(err, ErrNotFound) {
+				continue
+			} else if err != nil {
+				return res, err
+			}
+			if opts.Grace > 0 && info.ModTime.After(cutoff) {
+				res.Recent++
+				continue
+			}
+			size = info.Size
+		} else {
+			block, err := st.Get(ctx, ref, buf)
+			if errors.Is(err, ErrNotFound) {
+				continue
+			} else if err != nil {
+				return res, err
+			}
+			size = int64(len(block))
+		}
+
+		if !opts.DryRun {
+			if err := deleter.Delete(ctx, ref); err != nil {
+				return res, fmt.Errorf("deleting block %v: %w", ref, err)
+			}
+		}
+		res.Deleted++
+		res.DeletedBytes += size
+	}
+	return res, nil
+}
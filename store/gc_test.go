@@ -0,0 +1,135 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"math/rand"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/andrew-d/eris-go"
+)
+
+// ageBlocks sets the modification time of every block in d to the given
+// time.
+func ageBlocks(t *testing.T, d *Dir, mtime time.Time) {
+	t.Helper()
+	err := d.List(context.Background(), func(ref eris.Reference) error {
+		return os.Chtimes(d.pathFor(ref), mtime, mtime)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGC(t *testing.T) {
+	ctx := context.Background()
+	d, err := NewDir(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Two files that share their first 20KiB.
+	shared := make([]byte, 20*1024)
+	rand.New(rand.NewSource(1)).Read(shared)
+	tail := make([]byte, 30*1024)
+	rand.New(rand.NewSource(2)).Read(tail)
+	pinned := encodeToStore(t, d, append(bytes.Clone(shared), tail[:10*1024]...))
+	garbage := encodeToStore(t, d, append(bytes.Clone(shared), tail...))
+	ageBlocks(t, d, time.Now().Add(-time.Hour))
+
+	var before int
+	d.List(ctx, func(eris.Reference) error { before++; return nil })
+
+	// A dry run doesn't delete anything.
+	res, err := GC(ctx, d, []eris.ReadCapability{pinned}, GCOptions{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Deleted == 0 {
+		t.Errorf("dry run found nothing to delete: %+v", res)
+	}
+	if report, _ := eris.Verify(ctx, d.Get, garbage); !report.OK() {
+		t.Errorf("dry run deleted blocks")
+	}
+
+	res, err = GC(ctx, d, []eris.ReadCapability{pinned}, GCOptions{Grace: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Marked+res.Deleted != int64(before) || res.Deleted == 0 || res.Recent != 0 {
+		t.Errorf("result = %+v with %d blocks before", res, before)
+	}
+	if res.DeletedBytes != res.Deleted*eris.BlockSizeSmall {
+		t.Errorf("DeletedBytes = %d, want %d", res.DeletedBytes, res.Deleted*eris.BlockSizeSmall)
+	}
+
+	// The pinned content is intact, including the blocks that it shared
+	// with the deleted content.
+	if report, err := eris.Verify(ctx, d.Get, pinned); err != nil || !report.OK() {
+		t.Errorf("pinned content damaged: %+v, %v", report, err)
+	}
+	if report, _ := eris.Verify(ctx, d.Get, garbage); report.OK() {
+		t.Errorf("unpinned content was not deleted")
+	}
+}
+
+func TestGC_Grace(t *testing.T) {
+	ctx := context.Background()
+	d, err := NewDir(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content := make([]byte, 10*1024)
+	rand.New(rand.NewSource(3)).Read(content)
+	rc := encodeToStore(t, d, content)
+
+	// The unpinned content was just written, so it's kept.
+	res, err := GC(ctx, d, nil, GCOptions{Grace: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Deleted != 0 || res.Recent == 0 {
+		t.Errorf("result = %+v; want all blocks kept as recent", res)
+	}
+	if report, _ := eris.Verify(ctx, d.Get, rc); !report.OK() {
+		t.Errorf("recent content was deleted")
+	}
+
+	// Without a grace period, it's deleted.
+	res, err = GC(ctx, d, nil, GCOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Deleted == 0 || res.Recent != 0 {
+		t.Errorf("result = %+v; want all blocks deleted", res)
+	}
+
+	// Stores that don't record modification times can't use a grace
+	// period.
+	if _, err := GC(ctx, NewMemory(), nil, GCOptions{Grace: time.Hour}); err == nil {
+		t.Error("expected error using a grace period with a Memory store")
+	}
+}
+
+func TestGC_MissingRoot(t *testing.T) {
+	ctx := context.Background()
+	st := NewMemory()
+
+	content := make([]byte, 100*1024)
+	rand.New(rand.NewSource(4)).Read(content)
+	rc := encodeToStore(t, st, content)
+	other := encodeToStore(t, st, content[:50*1024])
+	st.Delete(ctx, other.Root.Reference)
+
+	// If any root is incomplete, nothing is deleted.
+	before := st.Len()
+	if _, err := GC(ctx, st, []eris.ReadCapability{rc, other}, GCOptions{}); err == nil {
+		t.Error("expected error for missing root")
+	}
+	if st.Len() != before {
+		t.Errorf("GC deleted %d blocks after failing", before-st.Len())
+	}
+}
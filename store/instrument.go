@@ -0,0 +1,202 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/andrew-d/eris-go"
+)
+
+// Op is a kind of operation on a Store, as reported by an InstrumentedStore.
+type Op string
+
+// The operations reported by an InstrumentedStore. The values are suitable
+// for use as metric labels.
+const (
+	OpGet Op = "get"
+	OpPut Op = "put"
+	OpHas Op = "has" // including HasMany
+)
+
+// Event describes a single call to a method of an InstrumentedStore.
+type Event struct {
+	Op Op
+	// Duration is how long the call took.
+	Duration time.Duration
+	// Blocks is the number of blocks that the call was about: one, or
+	// the number of references passed to HasMany.
+	Blocks int
+	// Found is the number of those blocks that the store had, for Get
+	// and Has; it's zero for Put, and for calls that failed.
+	Found int
+	// Bytes is the size of the block returned by Get or written by Put.
+	Bytes int
+	// Err is the error that the call failed with. A Get of a block that
+	// the store doesn't have isn't counted as a failure, since it's
+	// expected when the store is a cache; its Err is nil and Found is
+	// zero.
+	Err error
+}
+
+// OpStats contains counts of the calls of one kind of operation on an
+// InstrumentedStore, which are the sums of the corresponding fields of their
+// Events.
+type OpStats struct {
+	// Calls is the number of calls, and Errors the number of those that
+	// failed.
+	Calls  int64
+	Errors int64
+	// Blocks, Found and Bytes are as in Event.
+	Blocks int64
+	Found  int64
+	Bytes  int64
+	// Duration is the total time spent in calls.
+	Duration time.Duration
+}
+
+// InstrumentedStats contains counts of the calls made to an InstrumentedStore;
+// see InstrumentedStore.Stats.
+type InstrumentedStats struct {
+	Get, Put, Has OpStats
+}
+
+// opCounters holds the OpStats of one operation.
+type opCounters struct {
+	calls, errors, blocks, found, bytes, nanos atomic.Int64
+}
+
+func (c *opCounters) add(ev Event) {
+	c.calls.Add(1)
+	if ev.Err != nil {
+		c.errors.Add(1)
+	}
+	c.blocks.Add(int64(ev.Blocks))
+	c.found.Add(int64(ev.Found))
+	c.bytes.Add(int64(ev.Bytes))
+	c.nanos.Add(int64(ev.Duration))
+}
+
+func (c *opCounters) load() OpStats {
+	return OpStats{
+		Calls:    c.calls.Load(),
+		Errors:   c.errors.Load(),
+		Blocks:   c.blocks.Load(),
+		Found:    c.found.Load(),
+		Bytes:    c.bytes.Load(),
+		Duration: time.Duration(c.nanos.Load()),
+	}
+}
+
+// InstrumentedStore is a Store that measures the calls made to another Store;
+// see NewInstrumented.
+type InstrumentedStore struct {
+	Store
+	observe func(Event)
+	now     func() time.Time // for testing
+
+	get, put, has opCounters
+}
+
+// NewInstrumented returns a Store that wraps s, and measures every call to its
+// Get, Put, Has and HasMany methods: how long it took, how many bytes it
+// transferred, whether the blocks were found and whether it failed.
+//
+// The totals are returned by Stats, and each call is also passed to observe
+// (if non-nil) as it returns, which allows recording latency distributions.
+// This package doesn't depend on any metrics library, so an adapter is needed
+// to export them; for Prometheus, a Collector can report Stats as counters,
+// and observe can feed a histogram for each Op. observe is called from the
+// goroutine that made the call, so it must be safe for concurrent use, and
+// should be quick.
+func NewInstrumented(s Store, observe func(Event)) *InstrumentedStore {
+	return &InstrumentedStore{Store: s, observe: observe, now: time.Now}
+}
+
+// Stats returns counts of the calls made so far.
+func (s *InstrumentedStore) Stats() InstrumentedStats {
+	return InstrumentedStats{
+		Get: s.get.load(),
+		Put: s.put.load(),
+		Has: s.has.load(),
+	}
+}
+
+// record adds ev to the counters for its operation, and passes it to the
+// observer.
+func (s *InstrumentedStore) record(c *opCounters, ev Event, start time.Time) {
+	ev.Duration = s.now().Sub(start)
+	c.add(ev)
+	if s.observe != nil {
+		s.observe(ev)
+	}
+}
+
+// Get implements the Store interface.
+func (s *InstrumentedStore) Get(ctx context.Context, ref eris.Reference, buf []byte) ([]byte, error) {
+	start := s.now()
+	block, err := s.Store.Get(ctx, ref, buf)
+	ev := Event{Op: OpGet, Blocks: 1}
+	switch {
+	case err == nil:
+		ev.Found = 1
+		ev.Bytes = len(block)
+	case !errors.Is(err, ErrNotFound):
+		ev.Err = err
+	}
+	s.record(&s.get, ev, start)
+	return block, err
+}
+
+// Put implements the Store interface.
+func (s *InstrumentedStore) Put(ctx context.Context, ref eris.Reference, block []byte) error {
+	start := s.now()
+	err := s.Store.Put(ctx, ref, block)
+	s.record(&s.put, Event{Op: OpPut, Blocks: 1, Bytes: len(block), Err: err}, start)
+	return err
+}
+
+// Has implements the Store interface.
+func (s *InstrumentedStore) Has(ctx context.Context, ref eris.Reference) (bool, error) {
+	start := s.now()
+	has, err := s.Store.Has(ctx, ref)
+	ev := Event{Op: OpHas, Blocks: 1, Err: err}
+	if has && err == nil {
+		ev.Found = 1
+	}
+	s.record(&s.has, ev, start)
+	return has, err
+}
+
+// HasMany implements the BatchHaser interface, using the wrapped store's
+// HasMany if it has one. Each call is a single Event.
+func (s *InstrumentedStore) HasMany(ctx context.Context, refs []eris.Reference) ([]bool, error) {
+	start := s.now()
+	has, err := HasMany(ctx, s.Store, refs)
+	ev := Event{Op: OpHas, Blocks: len(refs), Err: err}
+	if err == nil {
+		for _, h := range has {
+			if h {
+				ev.Found++
+			}
+		}
+	}
+	s.record(&s.has, ev, start)
+	return has, err
+}
+
+// Ping implements the Pinger interface.
+func (s *InstrumentedStore) Ping(ctx context.Context) error {
+	return Ping(ctx, s.Store)
+}
+
+// List implements the Lister interface. It returns an error if the
+// underlying store does not implement Lister.
+func (s *InstrumentedStore) List(ctx context.Context, fn func(eris.Reference) error) error {
+	l, ok := s.Store.(Lister)
+	if !ok {
+		return errListUnsupported
+	}
+	return l.List(ctx, fn)
+}
@@ -0,0 +1,71 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/andrew-d/eris-go"
+)
+
+func TestInstrumented(t *testing.T) {
+	ctx := context.Background()
+	var (
+		mu     sync.Mutex
+		events []Event
+	)
+	s := NewInstrumented(NewMemory(), func(ev Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, ev)
+	})
+
+	// The clock advances a millisecond every time it's read, so every
+	// call takes a millisecond.
+	var clock time.Time
+	s.now = func() time.Time {
+		clock = clock.Add(time.Millisecond)
+		return clock
+	}
+
+	ref, block := makeBlock(1, 1024)
+	missing, _ := makeBlock(2, 1024)
+	if err := s.Put(ctx, ref, block); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get(ctx, ref, make([]byte, 1024)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get(ctx, missing, make([]byte, 1024)); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get of missing block: got %v, want ErrNotFound", err)
+	}
+	s.Has(ctx, ref)
+	if _, err := s.HasMany(ctx, []eris.Reference{ref, missing, missing}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := InstrumentedStats{
+		Get: OpStats{Calls: 2, Blocks: 2, Found: 1, Bytes: 1024, Duration: 2 * time.Millisecond},
+		Put: OpStats{Calls: 1, Blocks: 1, Bytes: 1024, Duration: time.Millisecond},
+		Has: OpStats{Calls: 2, Blocks: 4, Found: 2, Duration: 2 * time.Millisecond},
+	}
+	if got := s.Stats(); got != want {
+		t.Errorf("Stats = %+v, want %+v", got, want)
+	}
+	if len(events) != 5 || events[2] != (Event{Op: OpGet, Duration: time.Millisecond, Blocks: 1}) {
+		t.Errorf("events = %+v", events)
+	}
+
+	// Failures are counted as errors, rather than as missing blocks.
+	errBroken := errors.New("broken")
+	s = NewInstrumented(failingStore{errBroken}, nil)
+	s.Get(ctx, ref, nil)
+	s.Put(ctx, ref, block)
+	s.Has(ctx, ref)
+	stats := s.Stats()
+	if stats.Get.Errors != 1 || stats.Put.Errors != 1 || stats.Has.Errors != 1 || stats.Put.Bytes != 1024 {
+		t.Errorf("Stats of failing store = %+v", stats)
+	}
+}
@@ -0,0 +1,133 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/andrew-d/eris-go"
+)
+
+// ErrReadOnly is returned when attempting to write to a read-only store.
+var ErrReadOnly = errors.New("store is read-only")
+
+// readOnlyStore is the Store returned by ReadOnly.
+type readOnlyStore struct {
+	s Store
+}
+
+// ReadOnly returns a Store that reads blocks from s, but rejects all writes
+// with ErrReadOnly.
+func ReadOnly(s Store) Store {
+	return readOnlyStore{s}
+}
+
+// Get implements the Store interface.
+func (r readOnlyStore) Get(ctx context.Context, ref eris.Reference, buf []byte) ([]byte, error) {
+	return r.s.Get(ctx, ref, buf)
+}
+
+// Put implements the Store interface; it always returns ErrReadOnly.
+func (r readOnlyStore) Put(context.Context, eris.Reference, []byte) error {
+	return ErrReadOnly
+}
+
+// Has implements the Store interface.
+func (r readOnlyStore) Has(ctx context.Context, ref eris.Reference) (bool, error) {
+	return r.s.Has(ctx, ref)
+}
+
+// Ping implements the Pinger interface.
+func (r readOnlyStore) Ping(ctx context.Context) error {
+	return Ping(ctx, r.s)
+}
+
+// List implements the Lister interface. It returns an error if the
+// underlying store does not implement Lister.
+func (r readOnlyStore) List(ctx context.Context, fn func(eris.Reference) error) error {
+	l, ok := r.s.(Lister)
+	if !ok {
+		return errListUnsupported
+	}
+	return l.List(ctx, fn)
+}
+
+// UnionStore is a Store that layers a writable store over one or more base
+// stores; see Union.
+type UnionStore struct {
+	upper Store
+	bases []Store
+}
+
+// Union returns a Store that layers the writable store upper over the given
+// base stores, which are typically read-only.
+//
+// Get and Has consult upper first and then each base in order, returning the
+// first block found. Put writes only to upper, and skips blocks that are
+// already present in any layer.
+//
+// This is useful for serving content from an immutable archive while
+// accepting new content into a separate staging store.
+func Union(upper Store, bases ...Store) *UnionStore {
+	return &UnionStore{upper: upper, bases: bases}
+}
+
+// layers returns all the stores in the union, from top to bottom.
+func (u *UnionStore) layers() []Store {
+	return append([]Store{u.upper}, u.bases...)
+}
+
+// Get implements the Store interface.
+func (u *UnionStore) Get(ctx context.Context, ref eris.Reference, buf []byte) ([]byte, error) {
+	var errs []error
+	for _, s := range u.layers() {
+		block, err := s.Get(ctx, ref, buf)
+		if err == nil {
+			return block, nil
+		}
+		errs = append(errs, err)
+	}
+	return nil, joinGetErrors(errs)
+}
+
+// Put implements the Store interface.
+func (u *UnionStore) Put(ctx context.Context, ref eris.Reference, block []byte) error {
+	has, err := u.Has(ctx, ref)
+	if err != nil {
+		return err
+	}
+	if has {
+		return nil
+	}
+	return u.upper.Put(ctx, ref, block)
+}
+
+// Has implements the Store interface.
+func (u *UnionStore) Has(ctx context.Context, ref eris.Reference) (bool, error) {
+	for _, s := range u.layers() {
+		has, err := s.Has(ctx, ref)
+		if err != nil {
+			return false, err
+		}
+		if has {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Ping implements the Pinger interface, checking every layer.
+func (u *UnionStore) Ping(ctx context.Context) error {
+	for i, s := range u.layers() {
+		if err := Ping(ctx, s); err != nil {
+			return fmt.Errorf("union: layer %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// List implements the Lister interface, listing the blocks in every layer. It
+// returns an error if any layer does not implement Lister.
+func (u *UnionStore) List(ctx context.Context, fn func(eris.Reference) error) error {
+	return listMerged(ctx, u.layers(), fn)
+}
@@ -0,0 +1,107 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/andrew-d/eris-go"
+)
+
+func TestReadOnly(t *testing.T) {
+	ctx := context.Background()
+	base := NewMemory()
+	ref, block := makeBlock(1, 1024)
+	base.Put(ctx, ref, block)
+
+	ro := ReadOnly(base)
+	if err := ro.Put(ctx, ref, block); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Put: got %v, want ErrReadOnly", err)
+	}
+	if _, err := ro.Get(ctx, ref, make([]byte, 1024)); err != nil {
+		t.Errorf("Get: %v", err)
+	}
+	if has, _ := ro.Has(ctx, ref); !has {
+		t.Errorf("Has = false, want true")
+	}
+}
+
+func TestUnion(t *testing.T) {
+	ctx := context.Background()
+	upper, base := NewMemory(), NewMemory()
+	u := Union(upper, ReadOnly(base))
+
+	baseRef, baseBlock := makeBlock(1, 1024)
+	base.Put(ctx, baseRef, baseBlock)
+
+	// Blocks in the base are visible through the union, and writing them
+	// again doesn't copy them into the upper layer.
+	if _, err := u.Get(ctx, baseRef, make([]byte, 1024)); err != nil {
+		t.Errorf("Get of base block: %v", err)
+	}
+	if err := u.Put(ctx, baseRef, baseBlock); err != nil {
+		t.Errorf("Put of base block: %v", err)
+	}
+	if upper.Len() != 0 {
+		t.Errorf("base block was copied into upper layer")
+	}
+
+	// New blocks are written to the upper layer.
+	ref, block := makeBlock(2, 1024)
+	if err := u.Put(ctx, ref, block); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if upper.Len() != 1 || base.Len() != 1 {
+		t.Errorf("upper has %d blocks, base has %d; want 1 and 1", upper.Len(), base.Len())
+	}
+
+	missing, _ := makeBlock(3, 1024)
+	if _, err := u.Get(ctx, missing, make([]byte, 1024)); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get of missing block: got %v, want ErrNotFound", err)
+	}
+
+	// A block that is missing from one layer but can't be read from
+	// another isn't reported as missing.
+	errBroken := errors.New("broken")
+	u = Union(upper, failingStore{errBroken})
+	if _, err := u.Get(ctx, missing, make([]byte, 1024)); errors.Is(err, ErrNotFound) || !errors.Is(err, errBroken) {
+		t.Errorf("Get with failing layer: got %v, want only %v", err, errBroken)
+	}
+}
+
+func TestUnion_List(t *testing.T) {
+	ctx := context.Background()
+	upper, base := NewMemory(), NewMemory()
+	for i := 0; i < 5; i++ {
+		ref, block := makeBlock(i, 1024)
+		upper.Put(ctx, ref, block)
+	}
+	for i := 3; i < 8; i++ {
+		ref, block := makeBlock(i, 1024)
+		base.Put(ctx, ref, block)
+	}
+
+	var refs []eris.Reference
+	err := Union(upper, ReadOnly(base)).List(ctx, func(ref eris.Reference) error {
+		refs = append(refs, ref)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(refs) != 8 {
+		t.Errorf("listed %d blocks, want 8", len(refs))
+	}
+	for i := 1; i < len(refs); i++ {
+		if bytes.Compare(refs[i-1][:], refs[i][:]) >= 0 {
+			t.Errorf("references not in ascending order at index %d", i)
+		}
+	}
+
+	// If any layer can't be listed, neither can the union.
+	u := Union(upper, failingStore{errors.New("boom")})
+	if err := u.List(ctx, func(eris.Reference) error { return nil }); err == nil {
+		t.Errorf("List with unlistable layer: expected error")
+	}
+}
@@ -0,0 +1,197 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/andrew-d/eris-go"
+)
+
+// Ledger is a persistent record of the blocks that are known to be present in
+// a store, used to resume an interrupted upload without checking or sending
+// the blocks that were already confirmed; see WithLedger.
+//
+// A ledger is stored as a file containing the reference of each confirmed
+// block, which is only ever appended to. A ledger describes a store at the
+// time that it was written, so it should only be kept for the duration of an
+// upload (including any retries), and then deleted: if blocks are later
+// removed from the store, the ledger will still claim that they are present.
+type Ledger struct {
+	mu   sync.Mutex
+	f    *os.File
+	refs map[eris.Reference]struct{}
+}
+
+// OpenLedger opens the ledger in the file at path, creating it if it doesn't
+// exist. The ledger must be closed with Close when it is no longer needed.
+//
+// If the file ends with a partial record (for example, because the process
+// was killed while writing it), the partial record is discarded.
+func OpenLedger(path string) (*Ledger, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("reading ledger: %w", err)
+	}
+
+	l := &Ledger{f: f, refs: make(map[eris.Reference]struct{}, len(data)/eris.ReferenceSize)}
+	n := len(data) - len(data)%eris.ReferenceSize
+	for i := 0; i < n; i += eris.ReferenceSize {
+		l.refs[eris.Reference(data[i:i+eris.ReferenceSize])] = struct{}{}
+	}
+	if n != len(data) {
+		if err := f.Truncate(int64(n)); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("truncating ledger: %w", err)
+		}
+		if _, err := f.Seek(int64(n), io.SeekStart); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+// Contains reports whether the block with the given reference has been
+// recorded as present.
+func (l *Ledger) Contains(ref eris.Reference) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, ok := l.refs[ref]
+	return ok
+}
+
+// Record records that the blocks with the given references are present.
+// References that are already recorded are ignored.
+func (l *Ledger) Record(refs ...eris.Reference) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var (
+		added []eris.Reference
+		data  []byte
+	)
+	for _, ref := range refs {
+		if _, ok := l.refs[ref]; ok {
+			continue
+		}
+		l.refs[ref] = struct{}{}
+		added = append(added, ref)
+		data = append(data, ref[:]...)
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	if _, err := l.f.Write(data); err != nil {
+		for _, ref := range added {
+			delete(l.refs, ref)
+		}
+		return fmt.Errorf("writing ledger: %w", err)
+	}
+	return nil
+}
+
+// Len returns the number of blocks recorded in the ledger.
+func (l *Ledger) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.refs)
+}
+
+// Close closes the ledger's file.
+func (l *Ledger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.f.Close()
+}
+
+// ledgerStore is the Store returned by WithLedger.
+type ledgerStore struct {
+	Store
+	ledger *Ledger
+}
+
+// WithLedger returns a Store that wraps s and records every block that is
+// confirmed to be present in s (because it was written with Put, or Has
+// reported that it exists) in l. Blocks that are already recorded in l are
+// reported as present by Has and HasMany, and skipped by Put, without
+// accessing s.
+//
+// Wrapping the destination store of EncodeToStore or Replicate with a ledger
+// means that if the upload is interrupted and restarted, the blocks that were
+// confirmed by the first attempt are skipped without a round trip to s.
+func WithLedger(s Store, l *Ledger) Store {
+	return &ledgerStore{Store: s, ledger: l}
+}
+
+// Put implements the Store interface.
+func (s *ledgerStore) Put(ctx context.Context, ref eris.Reference, block []byte) error {
+	if s.ledger.Contains(ref) {
+		return nil
+	}
+	if err := s.Store.Put(ctx, ref, block); err != nil {
+		return err
+	}
+	return s.ledger.Record(ref)
+}
+
+// Has implements the Store interface.
+func (s *ledgerStore) Has(ctx context.Context, ref eris.Reference) (bool, error) {
+	if s.ledger.Contains(ref) {
+		return true, nil
+	}
+	has, err := s.Store.Has(ctx, ref)
+	if err != nil || !has {
+		return has, err
+	}
+	return true, s.ledger.Record(ref)
+}
+
+// Ping implements the Pinger interface.
+func (s *ledgerStore) Ping(ctx context.Context) error {
+	return Ping(ctx, s.Store)
+}
+
+// HasMany implements the BatchHaser interface. Only the blocks that aren't
+// recorded in the ledger are checked in the underlying store.
+func (s *ledgerStore) HasMany(ctx context.Context, refs []eris.Reference) ([]bool, error) {
+	has := make([]bool, len(refs))
+	var (
+		unknown    []eris.Reference
+		unknownIdx []int
+	)
+	for i, ref := range refs {
+		if s.ledger.Contains(ref) {
+			has[i] = true
+		} else {
+			unknown = append(unknown, ref)
+			unknownIdx = append(unknownIdx, i)
+		}
+	}
+	if len(unknown) == 0 {
+		return has, nil
+	}
+
+	found, err := HasMany(ctx, s.Store, unknown)
+	if err != nil {
+		return nil, err
+	}
+	var confirmed []eris.Reference
+	for i, ok := range found {
+		if ok {
+			has[unknownIdx[i]] = true
+			confirmed = append(confirmed, unknown[i])
+		}
+	}
+	if err := s.ledger.Record(confirmed...); err != nil {
+		return nil, err
+	}
+	return has, nil
+}
@@ -0,0 +1,136 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/andrew-d/eris-go"
+)
+
+func TestLedger(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ledger")
+	l, err := OpenLedger(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	refs, _ := makeBlocks(3, eris.BlockSizeSmall)
+	if err := l.Record(refs[0], refs[1], refs[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Record(refs[1]); err != nil {
+		t.Fatal(err)
+	}
+	if !l.Contains(refs[0]) || !l.Contains(refs[1]) || l.Contains(refs[2]) {
+		t.Error("ledger contains the wrong blocks")
+	}
+	l.Close()
+
+	// Each reference is only written once.
+	if fi, err := os.Stat(path); err != nil || fi.Size() != 2*eris.ReferenceSize {
+		t.Errorf("ledger file is %d bytes, want %d", fi.Size(), 2*eris.ReferenceSize)
+	}
+
+	// Simulate a crash while writing a record; the partial record is
+	// discarded when the ledger is reopened.
+	f, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	f.Write(refs[2][:10])
+	f.Close()
+
+	l, err = OpenLedger(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if l.Len() != 2 || !l.Contains(refs[0]) || !l.Contains(refs[1]) {
+		t.Errorf("reopened ledger has %d blocks", l.Len())
+	}
+	if err := l.Record(refs[2]); err != nil {
+		t.Fatal(err)
+	}
+	if fi, _ := os.Stat(path); fi.Size() != 3*eris.ReferenceSize {
+		t.Errorf("ledger file is %d bytes after appending, want %d", fi.Size(), 3*eris.ReferenceSize)
+	}
+}
+
+// hasCountingStore is a Store that counts the number of blocks whose
+// existence is checked, and the number of blocks written.
+type hasCountingStore struct {
+	Store
+	checked, puts int
+}
+
+func (c *hasCountingStore) Has(ctx context.Context, ref eris.Reference) (bool, error) {
+	c.checked++
+	return c.Store.Has(ctx, ref)
+}
+
+func (c *hasCountingStore) Put(ctx context.Context, ref eris.Reference, block []byte) error {
+	c.puts++
+	return c.Store.Put(ctx, ref, block)
+}
+
+func TestWithLedger(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "ledger")
+	content := make([]byte, 100*1024)
+	rand.New(rand.NewSource(1)).Read(content)
+	var secret [eris.ConvergenceSecretSize]byte
+	encode := func(st Store) (eris.ReadCapability, error) {
+		enc := eris.NewEncoder(bytes.NewReader(content), secret, eris.BlockSizeSmall)
+		rc, _, err := EncodeToStore(ctx, st, enc, EncodeOptions{BatchSize: 8})
+		return rc, err
+	}
+
+	// Interrupt the first upload after 20 blocks.
+	dst := NewMemory()
+	limited := &putLimitStore{Memory: dst}
+	limited.n.Store(20)
+	l, err := OpenLedger(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := encode(WithLedger(limited, l)); !errors.Is(err, errPutLimit) {
+		t.Fatalf("expected put limit error, got %v", err)
+	}
+	if l.Len() != 20 {
+		t.Errorf("ledger has %d blocks after 20 writes", l.Len())
+	}
+	l.Close()
+
+	// Resuming only checks and writes the blocks that weren't confirmed.
+	l, err = OpenLedger(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	counting := &hasCountingStore{Store: dst}
+	rc, err := encode(WithLedger(counting, l))
+	if err != nil {
+		t.Fatal(err)
+	}
+	total := dst.Len()
+	if counting.checked != total-20 || counting.puts != total-20 {
+		t.Errorf("resumed upload checked %d and wrote %d blocks; want %d of each", counting.checked, counting.puts, total-20)
+	}
+	if report, err := eris.Verify(ctx, dst.Get, rc); err != nil || !report.OK() {
+		t.Errorf("uploaded content is incomplete: %+v, %v", report, err)
+	}
+
+	// Blocks found by Has are recorded too.
+	l2, err := OpenLedger(filepath.Join(t.TempDir(), "ledger2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l2.Close()
+	if _, err := encode(WithLedger(dst, l2)); err != nil {
+		t.Fatal(err)
+	}
+	if l2.Len() != total {
+		t.Errorf("ledger has %d blocks, want %d", l2.Len(), total)
+	}
+}
@@ -0,0 +1,59 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"slices"
+
+	"github.com/andrew-d/eris-go"
+)
+
+// errListUnsupported is returned when listing a store that does not
+// implement the Lister interface.
+var errListUnsupported = errors.New("store does not support listing blocks")
+
+// listMerged calls fn with the reference of every block in any of the given
+// stores, in ascending order and without duplicates. Every store must
+// implement the Lister interface.
+//
+// The references from every store are collected before fn is called, so this
+// uses memory proportional to the number of blocks.
+func listMerged(ctx context.Context, stores []Store, fn func(eris.Reference) error) error {
+	listers := make([]Lister, len(stores))
+	for i, s := range stores {
+		l, ok := s.(Lister)
+		if !ok {
+			return errListUnsupported
+		}
+		listers[i] = l
+	}
+
+	seen := make(map[eris.Reference]bool)
+	var refs []eris.Reference
+	for _, l := range listers {
+		err := l.List(ctx, func(ref eris.Reference) error {
+			if !seen[ref] {
+				seen[ref] = true
+				refs = append(refs, ref)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	slices.SortFunc(refs, func(a, b eris.Reference) int {
+		return bytes.Compare(a[:], b[:])
+	})
+	for _, ref := range refs {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(ref); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/andrew-d/eris-go"
+)
+
+// Memory is a Store that keeps all blocks in memory. The zero value is not
+// valid; use NewMemory to create one.
+type Memory struct {
+	mu     sync.RWMutex
+	blocks map[eris.Reference][]byte
+}
+
+// NewMemory creates a new, empty, in-memory Store.
+func NewMemory() *Memory {
+	return &Memory{
+		blocks: make(map[eris.Reference][]byte),
+	}
+}
+
+// Get implements the Store interface.
+func (m *Memory) Get(_ context.Context, ref eris.Reference, buf []byte) ([]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	block, ok := m.blocks[ref]
+	if !ok {
+		return nil, fmt.Errorf("%w: %v", ErrNotFound, ref)
+	}
+
+	// Copy the block so that the caller can't modify our copy.
+	return append(buf[:0], block...), nil
+}
+
+// Put implements the Store interface.
+func (m *Memory) Put(_ context.Context, ref eris.Reference, block []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.blocks[ref]; ok {
+		return nil
+	}
+	m.blocks[ref] = append([]byte(nil), block...)
+	return nil
+}
+
+// Has implements the Store interface.
+func (m *Memory) Has(_ context.Context, ref eris.Reference) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	_, ok := m.blocks[ref]
+	return ok, nil
+}
+
+// Len returns the number of blocks in the store.
+func (m *Memory) Len() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.blocks)
+}
+
+// List implements the Lister interface.
+func (m *Memory) List(ctx context.Context, fn func(eris.Reference) error) error {
+	// Take a snapshot of the references, so that we don't hold the lock
+	// while calling fn.
+	m.mu.RLock()
+	refs := make([]eris.Reference, 0, len(m.blocks))
+	for ref := range m.blocks {
+		refs = append(refs, ref)
+	}
+	m.mu.RUnlock()
+
+	slices.SortFunc(refs, func(a, b eris.Reference) int {
+		return bytes.Compare(a[:], b[:])
+	})
+	for _, ref := range refs {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(ref); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete implements the Deleter interface.
+func (m *Memory) Delete(_ context.Context, ref eris.Reference) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.blocks, ref)
+	return nil
+}
@@ -0,0 +1,147 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/andrew-d/eris-go"
+)
+
+// MigrateOptions contains options for Migrate.
+type MigrateOptions struct {
+	// Progress, if non-nil, is called after each block in the source
+	// store has been processed, with the results so far.
+	Progress func(MigrateResult)
+
+	// OnCorrupt, if non-nil, is called for every corrupt block that is
+	// found in the source store, along with an error describing the
+	// corruption. Corrupt blocks are never copied.
+	OnCorrupt func(ref eris.Reference, err error)
+
+	// StartAfter resumes a previous migration: only blocks with a
+	// reference greater than StartAfter are copied. The zero value
+	// copies every block.
+	StartAfter eris.Reference
+
+	// Workers is the number of blocks that are copied concurrently, each
+	// of which is hashed by the worker that copies it. Each worker holds
+	// at most one block in memory at a time. Progress and OnCorrupt are
+	// still called from one goroutine at a time, in order of reference.
+	// If Workers is less than 1, blocks are copied one at a time.
+	Workers int
+}
+
+// MigrateResult contains the results of a Migrate.
+type MigrateResult struct {
+	// Copied is the number of blocks that were written to the
+	// destination store.
+	Copied int64
+	// CopiedBytes is the total size of the blocks that were written to
+	// the destination store.
+	CopiedBytes int64
+	// Skipped is the number of blocks that were already present in the
+	// destination store.
+	Skipped int64
+	// Corrupt contains the references of all corrupt blocks that were
+	// found in the source store.
+	Corrupt []eris.Reference
+	// Last is the reference of the last block that was processed. If a
+	// migration is interrupted, passing this as MigrateOptions.StartAfter
+	// will resume it where it left off.
+	Last eris.Reference
+}
+
+// Migrate copies every block from src, which must implement the Lister
+// interface, to dst. Each block's hash and size are verified before it is
+// written; corrupt blocks are reported and skipped. Blocks that already exist
+// in dst are not written again.
+//
+// Since blocks are listed in order of their reference, a migration can be
+// resumed from where it stopped. If the migration is interrupted by an error
+// (including cancellation of ctx), Migrate returns the results so far along
+// with the error; the migration can be resumed by setting
+// MigrateOptions.StartAfter to MigrateResult.Last.
+func Migrate(ctx context.Context, src, dst Store, opts MigrateOptions) (MigrateResult, error) {
+	var res MigrateResult
+
+	lister, ok := src.(Lister)
+	if !ok {
+		return res, errors.New("source store does not support listing blocks")
+	}
+
+	list := func(fn func(eris.Reference) error) error {
+		return lister.List(ctx, func(ref eris.Reference) error {
+			if bytes.Compare(ref[:], opts.StartAfter[:]) <= 0 {
+				return nil
+			}
+			return fn(ref)
+		})
+	}
+	copyBlock := func(ctx context.Context, ref eris.Reference, buf []byte) (migrated, error) {
+		return migrateBlock(ctx, src, dst, ref, buf)
+	}
+	record := func(ref eris.Reference, m migrated) error {
+		switch {
+		case m.skipped:
+			res.Skipped++
+		case m.corrupt != nil:
+			res.Corrupt = append(res.Corrupt, ref)
+			if opts.OnCorrupt != nil {
+				opts.OnCorrupt(ref, m.corrupt)
+			}
+		case m.copiedBytes > 0:
+			res.Copied++
+			res.CopiedBytes += m.copiedBytes
+		}
+		res.Last = ref
+		if opts.Progress != nil {
+			opts.Progress(res)
+		}
+		return nil
+	}
+
+	err := forEachOrdered(ctx, opts.Workers, list, copyBlock, record)
+	return res, err
+}
+
+// migrated is the result of copying a single block in Migrate.
+type migrated struct {
+	// skipped is set if the block was already in the destination store,
+	// corrupt is the error describing how the source block is corrupt,
+	// and copiedBytes is the size of the block if it was copied. All
+	// are zero if the block was deleted from the source after it was
+	// listed.
+	skipped     bool
+	corrupt     error
+	copiedBytes int64
+}
+
+// migrateBlock copies a single block from src to dst.
+func migrateBlock(ctx context.Context, src, dst Store, ref eris.Reference, buf []byte) (migrated, error) {
+	has, err := dst.Has(ctx, ref)
+	if err != nil {
+		return migrated{}, err
+	}
+	if has {
+		return migrated{skipped: true}, nil
+	}
+
+	block, err := src.Get(ctx, ref, buf)
+	if errors.Is(err, ErrNotFound) {
+		// The block was deleted after it was listed.
+		return migrated{}, nil
+	} else if err != nil {
+		return migrated{}, err
+	}
+
+	if err := checkBlock(ref, block); err != nil {
+		return migrated{corrupt: err}, nil
+	}
+
+	if err := dst.Put(ctx, ref, block); err != nil {
+		return migrated{}, fmt.Errorf("writing block %v: %w", ref, err)
+	}
+	return migrated{copiedBytes: int64(len(block))}, nil
+}
@@ -0,0 +1,108 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/andrew-d/eris-go"
+)
+
+func TestMigrate(t *testing.T) {
+	ctx := context.Background()
+	src := NewMemory()
+	for i := 0; i < 10; i++ {
+		ref, block := makeBlock(i, eris.BlockSizeSmall)
+		src.Put(ctx, ref, block)
+	}
+	badRef, _ := makeBlock(100, eris.BlockSizeSmall)
+	_, other := makeBlock(101, eris.BlockSizeSmall)
+	src.Put(ctx, badRef, other)
+
+	dst, err := NewDir(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// One block is already in the destination.
+	ref, block := makeBlock(0, eris.BlockSizeSmall)
+	dst.Put(ctx, ref, block)
+
+	var progress int
+	res, err := Migrate(ctx, src, dst, MigrateOptions{
+		Progress: func(MigrateResult) { progress++ },
+	})
+	if err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	if res.Copied != 9 || res.Skipped != 1 || len(res.Corrupt) != 1 {
+		t.Errorf("result = %+v; want 9 copied, 1 skipped, 1 corrupt", res)
+	}
+	if res.CopiedBytes != 9*eris.BlockSizeSmall {
+		t.Errorf("CopiedBytes = %d, want %d", res.CopiedBytes, 9*eris.BlockSizeSmall)
+	}
+	if progress != 11 {
+		t.Errorf("Progress called %d times, want 11", progress)
+	}
+	if has, _ := dst.Has(ctx, badRef); has {
+		t.Errorf("corrupt block was copied")
+	}
+}
+
+func TestMigrate_Resume(t *testing.T) {
+	ctx := context.Background()
+	src := NewMemory()
+	for i := 0; i < 10; i++ {
+		ref, block := makeBlock(i, eris.BlockSizeSmall)
+		src.Put(ctx, ref, block)
+	}
+	dst := NewMemory()
+
+	// Interrupt the migration after a few blocks by canceling the context.
+	ctx2, cancel := context.WithCancel(ctx)
+	var calls int
+	cs := &cancelAfterStore{Memory: src, n: 4, cancel: cancel, calls: &calls}
+	res, err := Migrate(ctx2, cs, dst, MigrateOptions{})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Migrate: got error %v, want context.Canceled", err)
+	}
+
+	res2, err := Migrate(ctx, src, dst, MigrateOptions{StartAfter: res.Last})
+	if err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	if res.Copied+res2.Copied != 10 || res2.Skipped != 0 {
+		t.Errorf("copied %d + %d blocks (skipped %d), want 10 total", res.Copied, res2.Copied, res2.Skipped)
+	}
+	if dst.Len() != 10 {
+		t.Errorf("destination has %d blocks, want 10", dst.Len())
+	}
+}
+
+func TestMigrate_Workers(t *testing.T) {
+	ctx := context.Background()
+	src := NewMemory()
+	for i := 0; i < 200; i++ {
+		ref, block := makeBlock(i, eris.BlockSizeSmall)
+		src.Put(ctx, ref, block)
+	}
+	dst := NewMemory()
+
+	var last eris.Reference
+	res, err := Migrate(ctx, src, dst, MigrateOptions{
+		Workers: 8,
+		Progress: func(res MigrateResult) {
+			if bytes.Compare(res.Last[:], last[:]) <= 0 {
+				t.Errorf("Progress called out of order")
+			}
+			last = res.Last
+		},
+	})
+	if err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	if res.Copied != 200 || dst.Len() != 200 {
+		t.Errorf("copied %d blocks, destination has %d; want 200", res.Copied, dst.Len())
+	}
+}
@@ -0,0 +1,143 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/andrew-d/eris-go"
+)
+
+// MirrorError is returned by MirrorStore.Put when writing a block to one or
+// more members of the mirror fails.
+type MirrorError struct {
+	// Errs contains the error from each member of the mirror, in the
+	// order that they were passed to Mirror; the entry is nil for members
+	// that succeeded.
+	Errs []error
+}
+
+// Error implements the error interface.
+func (e *MirrorError) Error() string {
+	var parts []string
+	for i, err := range e.Errs {
+		if err != nil {
+			parts = append(parts, fmt.Sprintf("store %d: %v", i, err))
+		}
+	}
+	return fmt.Sprintf("mirror: %d of %d stores failed: %s",
+		len(parts), len(e.Errs), strings.Join(parts, "; "))
+}
+
+// Unwrap returns the non-nil member errors, for use with errors.Is and
+// errors.As.
+func (e *MirrorError) Unwrap() []error {
+	var errs []error
+	for _, err := range e.Errs {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// Failed returns the number of members that failed.
+func (e *MirrorError) Failed() int {
+	return len(e.Unwrap())
+}
+
+// MirrorStore is a Store that replicates blocks across multiple member
+// stores; see Mirror.
+type MirrorStore struct {
+	stores []Store
+	get    eris.FetchFunc
+}
+
+// Mirror returns a Store that writes every block to all of the given stores,
+// and reads blocks from whichever store returns a valid copy first.
+//
+// Put writes to all members concurrently. If any member fails, Put returns a
+// *MirrorError describing which ones; the block may still have been written to
+// the others.
+//
+// Get queries all members concurrently and returns the first block whose hash
+// matches the requested reference, so a corrupt copy in one member is
+// tolerated as long as another has a valid copy.
+//
+// Has reports whether any member has the block.
+func Mirror(stores ...Store) *MirrorStore {
+	fetches := make([]eris.FetchFunc, len(stores))
+	for i, s := range stores {
+		fetches[i] = s.Get
+	}
+	return &MirrorStore{
+		stores: stores,
+		get:    Race(0, fetches...),
+	}
+}
+
+// Get implements the Store interface.
+func (m *MirrorStore) Get(ctx context.Context, ref eris.Reference, buf []byte) ([]byte, error) {
+	return m.get(ctx, ref, buf)
+}
+
+// Put implements the Store interface.
+func (m *MirrorStore) Put(ctx context.Context, ref eris.Reference, block []byte) error {
+	errs := make([]error, len(m.stores))
+	var wg sync.WaitGroup
+	for i, s := range m.stores {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = s.Put(ctx, ref, block)
+		}()
+	}
+	wg.Wait()
+
+	if errors.Join(errs...) != nil {
+		return &MirrorError{Errs: errs}
+	}
+	return nil
+}
+
+// Has implements the Store interface.
+func (m *MirrorStore) Has(ctx context.Context, ref eris.Reference) (bool, error) {
+	var errs []error
+	for _, s := range m.stores {
+		has, err := s.Has(ctx, ref)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if has {
+			return true, nil
+		}
+	}
+
+	// Only report an error if we couldn't get an answer from any member.
+	if len(errs) == len(m.stores) && len(errs) > 0 {
+		return false, errors.Join(errs...)
+	}
+	return false, nil
+}
+
+// Ping implements the Pinger interface. Since every block is written to every
+// member, it fails with a *MirrorError if any member fails to respond.
+func (m *MirrorStore) Ping(ctx context.Context) error {
+	errs := make([]error, len(m.stores))
+	for i, s := range m.stores {
+		errs[i] = Ping(ctx, s)
+	}
+	if errors.Join(errs...) != nil {
+		return &MirrorError{Errs: errs}
+	}
+	return nil
+}
+
+// List implements the Lister interface, listing the blocks in every member of
+// the mirror. It returns an error if any member does not implement Lister.
+func (m *MirrorStore) List(ctx context.Context, fn func(eris.Reference) error) error {
+	return listMerged(ctx, m.stores, fn)
+}
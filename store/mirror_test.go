@@ -0,0 +1,83 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/andrew-d/eris-go"
+)
+
+// failingStore is a Store whose methods always fail.
+type failingStore struct {
+	err error
+}
+
+func (f failingStore) Get(context.Context, eris.Reference, []byte) ([]byte, error) {
+	return nil, f.err
+}
+
+func (f failingStore) Put(context.Context, eris.Reference, []byte) error {
+	return f.err
+}
+
+func (f failingStore) Has(context.Context, eris.Reference) (bool, error) {
+	return false, f.err
+}
+
+func TestMirror(t *testing.T) {
+	ctx := context.Background()
+	a, b := NewMemory(), NewMemory()
+	m := Mirror(a, b)
+
+	ref, block := makeBlock(1, 1024)
+	if err := m.Put(ctx, ref, block); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if a.Len() != 1 || b.Len() != 1 {
+		t.Errorf("block not written to all members")
+	}
+
+	// Corrupt the copy in one member; Get should still succeed.
+	a.blocks[ref] = make([]byte, 1024)
+	got, err := m.Get(ctx, ref, make([]byte, 1024))
+	if err != nil || string(got) != string(block) {
+		t.Errorf("Get = %v; want valid block", err)
+	}
+
+	// A block in only one member is still found.
+	ref2, block2 := makeBlock(2, 1024)
+	b.Put(ctx, ref2, block2)
+	if has, err := m.Has(ctx, ref2); err != nil || !has {
+		t.Errorf("Has = %v, %v; want true, nil", has, err)
+	}
+}
+
+func TestMirror_PartialFailure(t *testing.T) {
+	ctx := context.Background()
+	errBroken := errors.New("broken")
+	good := NewMemory()
+	m := Mirror(good, failingStore{errBroken})
+
+	ref, block := makeBlock(1, 1024)
+	err := m.Put(ctx, ref, block)
+
+	var merr *MirrorError
+	if !errors.As(err, &merr) {
+		t.Fatalf("Put: got error %v, want *MirrorError", err)
+	}
+	if merr.Failed() != 1 || merr.Errs[0] != nil || !errors.Is(err, errBroken) {
+		t.Errorf("unexpected MirrorError: %v", merr)
+	}
+	if good.Len() != 1 {
+		t.Errorf("block not written to healthy member")
+	}
+
+	// Reads are served by the healthy member.
+	if _, err := m.Get(ctx, ref, make([]byte, 1024)); err != nil {
+		t.Errorf("Get: %v", err)
+	}
+	if has, err := m.Has(ctx, ref); err != nil || !has {
+		t.Errorf("Has = %v, %v; want true, nil", has, err)
+	}
+}
@@ -0,0 +1,192 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/andrew-d/eris-go"
+)
+
+const (
+	// defaultPackThreshold is the default value of
+	// PackOptions.Threshold.
+	defaultPackThreshold = eris.BlockSizeSmall
+
+	// defaultMaxPackSize is the default value of PackOptions.MaxPackSize.
+	defaultMaxPackSize = 1 << 20
+)
+
+// PackOptions contains options for Pack.
+type PackOptions struct {
+	// Threshold is the size below which values are packed together;
+	// values of at least this size are encoded on their own. If zero, a
+	// default of 1KiB (the small block size) is used.
+	Threshold int
+
+	// MaxPackSize is the maximum size of the content of a single pack.
+	// Smaller packs mean that reading a value needs fewer internal nodes,
+	// but share fewer blocks. If zero, a default of 1MiB is used.
+	MaxPackSize int
+}
+
+// PackedValue identifies a value stored by Pack: the value is the Length bytes
+// at Offset in the content identified by Capability.
+type PackedValue struct {
+	Capability eris.ReadCapability
+	Offset     int64
+	Length     int64
+}
+
+// AppendBinary appends the binary representation of the PackedValue to the
+// given byte slice and returns it, or any error that occurs. The
+// representation is the binary form of the read capability followed by the
+// offset and length as uvarints.
+func (v PackedValue) AppendBinary(data []byte) ([]byte, error) {
+	data, err := v.Capability.AppendBinary(data)
+	if err != nil {
+		return nil, err
+	}
+	data = binary.AppendUvarint(data, uint64(v.Offset))
+	data = binary.AppendUvarint(data, uint64(v.Length))
+	return data, nil
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface.
+func (v PackedValue) MarshalBinary() ([]byte, error) {
+	return v.AppendBinary(nil)
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
+func (v *PackedValue) UnmarshalBinary(data []byte) error {
+	// The binary form of a read capability is always 66 bytes.
+	const rcLen = 2 + eris.ReferenceSize + eris.KeySize
+	if len(data) < rcLen {
+		return fmt.Errorf("packed value too short: %d bytes", len(data))
+	}
+	if err := v.Capability.UnmarshalBinary(data[:rcLen]); err != nil {
+		return err
+	}
+	data = data[rcLen:]
+
+	offset, n := binary.Uvarint(data)
+	if n <= 0 || offset > 1<<62 {
+		return errors.New("invalid packed value offset")
+	}
+	data = data[n:]
+	length, n := binary.Uvarint(data)
+	if n <= 0 || length > 1<<62 {
+		return errors.New("invalid packed value length")
+	}
+	if n != len(data) {
+		return fmt.Errorf("%d bytes of trailing data after packed value", len(data)-n)
+	}
+	v.Offset = int64(offset)
+	v.Length = int64(length)
+	return nil
+}
+
+// Pack stores many values in s, packing small values together into shared
+// content so that each doesn't need a block of its own; for example, a 50-byte
+// value stored alone would take a whole 1KiB block. It returns a PackedValue
+// for each value, in the same order, which can be passed to Unpack to read the
+// value back.
+//
+// Values smaller than PackOptions.Threshold are concatenated, in order, into
+// packs of up to PackOptions.MaxPackSize bytes, and each pack is encoded as a
+// single piece of content; larger values are encoded on their own. Every pack
+// and value is encoded with the given convergence secret, and with the block
+// size recommended for its size.
+//
+// The capability in a PackedValue can read the whole pack that the value is
+// in, not just the value itself, so values should only be packed together if
+// anyone who can read one of them may read all of them.
+func Pack(ctx context.Context, s Store, secret [eris.ConvergenceSecretSize]byte, values [][]byte, opts PackOptions) ([]PackedValue, error) {
+	threshold := opts.Threshold
+	if threshold <= 0 {
+		threshold = defaultPackThreshold
+	}
+	maxPackSize := opts.MaxPackSize
+	if maxPackSize <= 0 {
+		maxPackSize = defaultMaxPackSize
+	}
+
+	encode := func(content []byte) (eris.ReadCapability, error) {
+		bs := eris.RecommendedBlockSize(int64(len(content)))
+		enc := eris.NewEncoder(bytes.NewReader(content), secret, bs)
+		rc, _, err := EncodeToStore(ctx, s, enc, EncodeOptions{})
+		return rc, err
+	}
+
+	res := make([]PackedValue, len(values))
+	var (
+		pack    []byte
+		members []int // indexes of the values in pack
+	)
+	flush := func() error {
+		if len(members) == 0 {
+			return nil
+		}
+		rc, err := encode(pack)
+		if err != nil {
+			return fmt.Errorf("encoding pack: %w", err)
+		}
+		for _, i := range members {
+			res[i].Capability = rc
+		}
+		pack = pack[:0]
+		members = members[:0]
+		return nil
+	}
+
+	for i, value := range values {
+		if len(value) >= threshold {
+			rc, err := encode(value)
+			if err != nil {
+				return nil, fmt.Errorf("encoding value %d: %w", i, err)
+			}
+			res[i] = PackedValue{Capability: rc, Length: int64(len(value))}
+			continue
+		}
+
+		if len(pack)+len(value) > maxPackSize {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		}
+		res[i] = PackedValue{Offset: int64(len(pack)), Length: int64(len(value))}
+		pack = append(pack, value...)
+		members = append(members, i)
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Unpack reads a value stored by Pack. Only the blocks of the pack that
+// contain the value (and the internal nodes above them) are fetched.
+func Unpack(ctx context.Context, fetch eris.FetchFunc, v PackedValue) ([]byte, error) {
+	dec := eris.NewDecoder(fetch, v.Capability)
+	if err := dec.SkipTo(ctx, v.Offset); err != nil {
+		return nil, err
+	}
+
+	// The length comes from outside, so don't trust it for more than a
+	// block until the content shows up.
+	value := make([]byte, 0, min(v.Length, int64(v.Capability.BlockSize)))
+	for int64(len(value)) < v.Length && dec.Next(ctx) {
+		block := dec.Block()
+		value = append(value, block[:min(int64(len(block)), v.Length-int64(len(value)))]...)
+	}
+	if err := dec.Err(); err != nil {
+		return nil, err
+	}
+	if int64(len(value)) < v.Length {
+		return nil, fmt.Errorf("reading packed value: %w", io.ErrUnexpectedEOF)
+	}
+	return value, nil
+}
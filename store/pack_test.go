@@ -0,0 +1,141 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"testing"
+
+	"github.com/andrew-d/eris-go"
+)
+
+func TestPack(t *testing.T) {
+	ctx := context.Background()
+	var secret [eris.ConvergenceSecretSize]byte
+	rng := rand.New(rand.NewSource(1))
+
+	// Many small values, with a few large ones mixed in.
+	var values [][]byte
+	for i := range 1000 {
+		size := 20 + rng.Intn(60)
+		if i%250 == 0 {
+			size = 5000
+		}
+		value := make([]byte, size)
+		rng.Read(value)
+		values = append(values, value)
+	}
+
+	st := &countingStore{Store: NewMemory()}
+	packed, err := Pack(ctx, st, secret, values, PackOptions{MaxPackSize: 16 * 1024})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(packed) != len(values) {
+		t.Fatalf("got %d packed values, want %d", len(packed), len(values))
+	}
+
+	// Each value needs at least one block of its own when stored alone;
+	// packed, the small values share a few dozen blocks.
+	if st.puts > 100 {
+		t.Errorf("packing %d values wrote %d blocks", len(values), st.puts)
+	}
+
+	for i, v := range packed {
+		if i%250 == 0 && v.Offset != 0 {
+			t.Errorf("large value %d was packed at offset %d", i, v.Offset)
+		}
+		st.gets = 0
+		got, err := Unpack(ctx, st.Get, v)
+		if err != nil {
+			t.Fatalf("Unpack(%d): %v", i, err)
+		}
+		if !bytes.Equal(got, values[i]) {
+			t.Fatalf("Unpack(%d) returned the wrong value", i)
+		}
+
+		// Reading a small value only needs the root and the leaves
+		// that contain it.
+		if i%250 != 0 && st.gets > 3 {
+			t.Errorf("Unpack(%d) fetched %d blocks", i, st.gets)
+		}
+
+		data, err := v.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var v2 PackedValue
+		if err := v2.UnmarshalBinary(data); err != nil {
+			t.Fatal(err)
+		}
+		if v2.Offset != v.Offset || v2.Length != v.Length || !v2.Capability.Equal(v.Capability) {
+			t.Errorf("round trip of %+v gave %+v", v, v2)
+		}
+	}
+
+	// Packing is deterministic, so packing the same values again stores
+	// nothing new.
+	before := st.puts
+	if _, err := Pack(ctx, st, secret, values, PackOptions{MaxPackSize: 16 * 1024}); err != nil {
+		t.Fatal(err)
+	}
+	if st.puts != before {
+		t.Errorf("packing the same values again wrote %d blocks", st.puts-before)
+	}
+}
+
+func TestUnpack_Truncated(t *testing.T) {
+	ctx := context.Background()
+	var secret [eris.ConvergenceSecretSize]byte
+	st := NewMemory()
+	packed, err := Pack(ctx, st, secret, [][]byte{[]byte("hello"), []byte("world")}, PackOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A value that claims to extend past the end of its pack.
+	v := packed[1]
+	v.Length = 100
+	if _, err := Unpack(ctx, st.Get, v); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("got %v, want io.ErrUnexpectedEOF", err)
+	}
+
+	// The largest length that unmarshals isn't allocated up front.
+	v.Length = 1 << 62
+	data, err := v.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var huge PackedValue
+	if err := huge.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary: %v", err)
+	}
+	if _, err := Unpack(ctx, st.Get, huge); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("oversized length: got %v, want io.ErrUnexpectedEOF", err)
+	}
+}
+
+func TestPackedValue_UnmarshalInvalid(t *testing.T) {
+	valid, _ := PackedValue{
+		Capability: eris.ReadCapability{BlockSize: eris.BlockSizeSmall},
+		Offset:     100,
+		Length:     5,
+	}.MarshalBinary()
+
+	for i, data := range [][]byte{
+		nil,
+		valid[:66],
+		valid[:len(valid)-1],
+		append(bytes.Clone(valid), 0),
+	} {
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
+			var v PackedValue
+			if err := v.UnmarshalBinary(data); err == nil {
+				t.Errorf("expected error unmarshaling %x", data)
+			}
+		})
+	}
+}
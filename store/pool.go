@@ -0,0 +1,100 @@
+package store
+
+import (
+	"context"
+
+	"github.com/andrew-d/eris-go"
+	"github.com/andrew-d/eris-go/internal/result"
+)
+
+// forEachOrdered calls work for every reference that list passes to its
+// callback, on up to workers goroutines at once. Each worker has its own
+// buffer of the largest block size, so that at most workers blocks are held in
+// memory however far ahead of the slowest block the others get. If workers is
+// less than 2, everything runs in the calling goroutine.
+//
+// The results are passed to done in the order in which list produced the
+// references, and never concurrently, so done can update results without
+// locking and keep track of how far a resumable operation has got.
+//
+// If work or done returns an error, no more work is started and done isn't
+// called again; the first error is returned once the work in progress has
+// finished.
+func forEachOrdered[T any](
+	ctx context.Context,
+	workers int,
+	list func(fn func(eris.Reference) error) error,
+	work func(ctx context.Context, ref eris.Reference, buf []byte) (T, error),
+	done func(ref eris.Reference, v T) error,
+) error {
+	if workers < 2 {
+		buf := make([]byte, eris.BlockSizeLarge)
+		return list(func(ref eris.Reference) error {
+			v, err := work(ctx, ref, buf)
+			if err != nil {
+				return err
+			}
+			return done(ref, v)
+		})
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	bufs := make(chan []byte, workers)
+	for i := 0; i < workers; i++ {
+		bufs <- make([]byte, eris.BlockSizeLarge)
+	}
+
+	// Every reference is queued in order along with a channel for its
+	// result, which the collector waits for in turn.
+	type pending struct {
+		ref eris.Reference
+		res chan result.Result[T]
+	}
+	queue := make(chan pending, workers)
+	collected := make(chan error, 1)
+	go func() {
+		var err error
+		for p := range queue {
+			r := <-p.res
+			if err != nil {
+				continue
+			}
+			v, werr := r.Value()
+			if err = werr; err == nil {
+				err = done(p.ref, v)
+			}
+			if err != nil {
+				cancel(err)
+			}
+		}
+		collected <- err
+	}()
+
+	listErr := list(func(ref eris.Reference) error {
+		var buf []byte
+		select {
+		case buf = <-bufs:
+		case <-ctx.Done():
+			return context.Cause(ctx)
+		}
+		p := pending{ref: ref, res: make(chan result.Result[T], 1)}
+		queue <- p
+		go func() {
+			v, err := work(ctx, ref, buf)
+			bufs <- buf
+			if err != nil {
+				p.res <- result.Error[T](err)
+			} else {
+				p.res <- result.Of(v)
+			}
+		}()
+		return nil
+	})
+	close(queue)
+	if err := <-collected; err != nil {
+		return err
+	}
+	return listErr
+}
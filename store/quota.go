@@ -0,0 +1,119 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"sync"
+
+	"github.com/andrew-d/eris-go"
+)
+
+// ErrQuotaExceeded is returned by QuotaStore.Put when writing a block would
+// take a principal over its quota.
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx that carries the given principal: the
+// user or account that blocks written with the context are charged to. It is
+// used by QuotaStore.
+func WithPrincipal(ctx context.Context, principal string) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFromContext returns the principal carried by ctx, or false if it
+// doesn't carry one.
+func PrincipalFromContext(ctx context.Context) (string, bool) {
+	principal, ok := ctx.Value(principalKey{}).(string)
+	return principal, ok
+}
+
+// QuotaStore is a Store that records the number of bytes written by each
+// principal and enforces a per-principal quota; see NewQuota.
+type QuotaStore struct {
+	Store
+	limit func(principal string) int64
+
+	mu    sync.Mutex
+	usage map[string]int64
+}
+
+// NewQuota returns a Store that wraps s, charging every block written with
+// Put to the principal carried by the context (see WithPrincipal). Writes
+// made with a context that carries no principal are charged to the empty
+// principal.
+//
+// The limit function returns the quota of a principal in bytes; a negative
+// quota is unlimited. If a write would take a principal's usage over its
+// quota, Put returns an error wrapping ErrQuotaExceeded without writing the
+// block. The limit function may be called concurrently.
+//
+// Every successful Put is charged, even if the block was already present in
+// the store, so that the usage of a principal doesn't depend on what others
+// have written. Usage is only kept in memory; SetUsage can be used to restore
+// usage that was saved elsewhere.
+func NewQuota(s Store, limit func(principal string) int64) *QuotaStore {
+	return &QuotaStore{
+		Store: s,
+		limit: limit,
+		usage: make(map[string]int64),
+	}
+}
+
+// Ping implements the Pinger interface.
+func (q *QuotaStore) Ping(ctx context.Context) error {
+	return Ping(ctx, q.Store)
+}
+
+// Put implements the Store interface.
+func (q *QuotaStore) Put(ctx context.Context, ref eris.Reference, block []byte) error {
+	principal, _ := PrincipalFromContext(ctx)
+	size := int64(len(block))
+	limit := q.limit(principal)
+
+	// Reserve the space before writing, so that concurrent writes can't
+	// exceed the quota; the reservation is released if the write fails.
+	q.mu.Lock()
+	used := q.usage[principal]
+	if limit >= 0 && used+size > limit {
+		q.mu.Unlock()
+		return fmt.Errorf("%w: %q has used %d of %d bytes", ErrQuotaExceeded, principal, used, limit)
+	}
+	q.usage[principal] = used + size
+	q.mu.Unlock()
+
+	if err := q.Store.Put(ctx, ref, block); err != nil {
+		q.mu.Lock()
+		q.usage[principal] -= size
+		q.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// Usage returns the number of bytes that have been written by the given
+// principal.
+func (q *QuotaStore) Usage(principal string) int64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.usage[principal]
+}
+
+// AllUsage returns the number of bytes that have been written by every
+// principal that has written to the store.
+func (q *QuotaStore) AllUsage() map[string]int64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return maps.Clone(q.usage)
+}
+
+// SetUsage sets the number of bytes that the given principal has written; for
+// example, to restore usage that was saved before a restart, or to reset it
+// at the start of a billing period.
+func (q *QuotaStore) SetUsage(principal string, bytes int64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.usage[principal] = bytes
+}
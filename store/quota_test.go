@@ -0,0 +1,114 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/andrew-d/eris-go"
+)
+
+func TestQuota(t *testing.T) {
+	mem := NewMemory()
+	q := NewQuota(mem, func(principal string) int64 {
+		switch principal {
+		case "alice":
+			return 2 * eris.BlockSizeSmall
+		case "admin":
+			return -1
+		}
+		return 0
+	})
+	alice := WithPrincipal(context.Background(), "alice")
+	refs, blocks := makeBlocks(4, eris.BlockSizeSmall)
+
+	for i := range 2 {
+		if err := q.Put(alice, refs[i], blocks[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := q.Put(alice, refs[2], blocks[2])
+	if !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("Put over quota: got %v, want ErrQuotaExceeded", err)
+	}
+	if has, _ := mem.Has(alice, refs[2]); has {
+		t.Error("block over quota was written")
+	}
+
+	// Writes without a principal are charged to the empty principal.
+	if err := q.Put(context.Background(), refs[2], blocks[2]); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("Put without principal: got %v, want ErrQuotaExceeded", err)
+	}
+
+	admin := WithPrincipal(context.Background(), "admin")
+	for i := range blocks {
+		if err := q.Put(admin, refs[i], blocks[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := map[string]int64{
+		"alice": 2 * eris.BlockSizeSmall,
+		"admin": 4 * eris.BlockSizeSmall,
+		"":      0,
+	}
+	for principal, n := range want {
+		if got := q.Usage(principal); got != n {
+			t.Errorf("Usage(%q) = %d, want %d", principal, got, n)
+		}
+	}
+	if got := q.AllUsage(); got["alice"] != want["alice"] || got["admin"] != want["admin"] {
+		t.Errorf("AllUsage() = %v", got)
+	}
+
+	// Resetting usage allows more writes.
+	q.SetUsage("alice", 0)
+	if err := q.Put(alice, refs[2], blocks[2]); err != nil {
+		t.Errorf("Put after SetUsage: %v", err)
+	}
+}
+
+func TestQuota_FailedPut(t *testing.T) {
+	q := NewQuota(failingStore{errors.New("broken")}, func(string) int64 { return -1 })
+	ctx := WithPrincipal(context.Background(), "alice")
+	ref, block := makeBlock(1, eris.BlockSizeSmall)
+	if err := q.Put(ctx, ref, block); err == nil {
+		t.Fatal("expected error")
+	}
+	if got := q.Usage("alice"); got != 0 {
+		t.Errorf("failed Put was charged %d bytes", got)
+	}
+}
+
+func TestQuota_Concurrent(t *testing.T) {
+	const limit = 10
+	q := NewQuota(NewMemory(), func(string) int64 { return limit * eris.BlockSizeSmall })
+	ctx := WithPrincipal(context.Background(), "alice")
+
+	var (
+		wg  sync.WaitGroup
+		mu  sync.Mutex
+		ok  int
+		bad int
+	)
+	refs, blocks := makeBlocks(50, eris.BlockSizeSmall)
+	for i := range blocks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := q.Put(ctx, refs[i], blocks[i])
+			mu.Lock()
+			defer mu.Unlock()
+			if err == nil {
+				ok++
+			} else if errors.Is(err, ErrQuotaExceeded) {
+				bad++
+			}
+		}()
+	}
+	wg.Wait()
+	if ok != limit || bad != 50-limit {
+		t.Errorf("%d writes succeeded and %d exceeded the quota; want %d and %d", ok, bad, limit, 50-limit)
+	}
+}
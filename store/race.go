@@ -0,0 +1,108 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/crypto/blake2b"
+
+	"github.com/andrew-d/eris-go"
+)
+
+// Race returns an eris.FetchFunc that fetches blocks from multiple sources,
+// returning the first block whose hash matches the requested reference.
+//
+// The sources are tried in order. The first is queried immediately; if it
+// hasn't returned a valid block within hedgeDelay, the next source is queried
+// concurrently, and so on (a "hedged" request). A source that fails or returns
+// a block with the wrong hash causes the next source to be queried
+// immediately. If hedgeDelay is zero or negative, all sources are queried at
+// once.
+//
+// Once a valid block has been returned, the context passed to any outstanding
+// fetches is canceled. If every source fails, the returned error joins the
+// errors from all sources; in particular, it wraps ErrNotFound if any source
+// reported the block as missing.
+//
+// Since sources may be queried concurrently, each is passed its own buffer.
+func Race(hedgeDelay time.Duration, fetches ...eris.FetchFunc) eris.FetchFunc {
+	return func(ctx context.Context, ref eris.Reference, buf []byte) ([]byte, error) {
+		if len(fetches) == 0 {
+			return nil, fmt.Errorf("%w: %v (no sources)", ErrNotFound, ref)
+		}
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		type result struct {
+			block []byte
+			err   error
+		}
+
+		// The channel is buffered so that fetches that complete after
+		// we've returned don't block forever.
+		results := make(chan result, len(fetches))
+		next := 0
+		launch := func() {
+			i := next
+			next++
+			go func() {
+				block, err := fetches[i](ctx, ref, make([]byte, len(buf)))
+				if err == nil && blake2b.Sum256(block) != ref {
+					err = eris.ErrInvalidBlock
+				}
+				if err != nil {
+					err = fmt.Errorf("source %d: %w", i, err)
+				}
+				results <- result{block, err}
+			}()
+		}
+
+		pending := 0
+		launchAll := hedgeDelay <= 0
+		for next < len(fetches) && (launchAll || pending == 0) {
+			launch()
+			pending++
+		}
+
+		var timerC <-chan time.Time
+		if !launchAll {
+			timer := time.NewTimer(hedgeDelay)
+			defer timer.Stop()
+			timerC = timer.C
+		}
+
+		var errs []error
+		for {
+			select {
+			case r := <-results:
+				pending--
+				if r.err == nil {
+					return append(buf[:0], r.block...), nil
+				}
+				errs = append(errs, r.err)
+
+				// Start the next source right away, rather
+				// than waiting for the hedge delay.
+				if next < len(fetches) {
+					launch()
+					pending++
+				} else if pending == 0 {
+					return nil, errors.Join(errs...)
+				}
+
+			case <-timerC:
+				if next < len(fetches) {
+					launch()
+					pending++
+					timerC = time.After(hedgeDelay)
+				}
+
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+	}
+}
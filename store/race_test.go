@@ -0,0 +1,92 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/blake2b"
+
+	"github.com/andrew-d/eris-go"
+	"github.com/andrew-d/eris-go/internal/result"
+)
+
+// constFetch returns a FetchFunc that returns the given block and error after
+// the given delay, and counts the number of times it was called.
+func constFetch(delay time.Duration, res result.Result[[]byte], calls *atomic.Int32) eris.FetchFunc {
+	return func(ctx context.Context, _ eris.Reference, _ []byte) ([]byte, error) {
+		if calls != nil {
+			calls.Add(1)
+		}
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		return res.Value()
+	}
+}
+
+func TestRace(t *testing.T) {
+	ctx := context.Background()
+	block := []byte("some block contents")
+	ref := eris.Reference(blake2b.Sum256(block))
+	good := result.Of(block)
+	corrupt := result.Of([]byte("corrupted contents!"))
+	notFound := result.Error[[]byte](ErrNotFound)
+
+	t.Run("FirstWins", func(t *testing.T) {
+		var second atomic.Int32
+		fetch := Race(time.Hour,
+			constFetch(0, good, nil),
+			constFetch(0, good, &second),
+		)
+		got, err := fetch(ctx, ref, make([]byte, 32))
+		if err != nil || string(got) != string(block) {
+			t.Fatalf("got %q, %v", got, err)
+		}
+		if second.Load() != 0 {
+			t.Errorf("second source was queried")
+		}
+	})
+
+	t.Run("Hedged", func(t *testing.T) {
+		fetch := Race(10*time.Millisecond,
+			constFetch(time.Hour, good, nil),
+			constFetch(0, good, nil),
+		)
+		start := time.Now()
+		got, err := fetch(ctx, ref, make([]byte, 32))
+		if err != nil || string(got) != string(block) {
+			t.Fatalf("got %q, %v", got, err)
+		}
+		if elapsed := time.Since(start); elapsed > time.Minute {
+			t.Errorf("hedged request took %v", elapsed)
+		}
+	})
+
+	t.Run("SkipsCorrupt", func(t *testing.T) {
+		fetch := Race(time.Hour,
+			constFetch(0, corrupt, nil),
+			constFetch(0, notFound, nil),
+			constFetch(0, good, nil),
+		)
+		got, err := fetch(ctx, ref, make([]byte, 32))
+		if err != nil || string(got) != string(block) {
+			t.Fatalf("got %q, %v", got, err)
+		}
+	})
+
+	t.Run("AllFail", func(t *testing.T) {
+		fetch := Race(0,
+			constFetch(0, corrupt, nil),
+			constFetch(0, notFound, nil),
+		)
+		_, err := fetch(ctx, ref, make([]byte, 32))
+		if !errors.Is(err, ErrNotFound) || !errors.Is(err, eris.ErrInvalidBlock) {
+			t.Errorf("got error %v, want ErrNotFound and ErrInvalidBlock", err)
+		}
+	})
+}
@@ -0,0 +1,134 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+
+	"github.com/andrew-d/eris-go"
+)
+
+// ReadRepairStats contains statistics about the reads served by a
+// ReadRepairStore.
+type ReadRepairStats struct {
+	// Fallbacks is the number of blocks that were missing from the
+	// primary store and were read from a secondary instead.
+	Fallbacks int64
+	// Repaired is the number of blocks that were copied back to the
+	// primary store.
+	Repaired int64
+	// Failed is the number of blocks that could not be copied back to
+	// the primary store.
+	Failed int64
+}
+
+// ReadRepairStore is a Store that mirrors blocks across a primary store and
+// one or more secondaries, and repairs the primary as blocks are read from it;
+// see NewReadRepair.
+type ReadRepairStore struct {
+	*MirrorStore
+	primary     Store
+	secondaries []Store
+
+	fallbacks, repaired, failed atomic.Int64
+
+	wg       sync.WaitGroup
+	mu       sync.Mutex
+	inflight map[eris.Reference]bool
+}
+
+// NewReadRepair returns a Store that writes every block to primary and all of
+// the secondaries, like Mirror, but reads blocks from primary alone unless it
+// doesn't have them.
+//
+// If primary doesn't have a block, or fails to return it, Get reads it from
+// each secondary in turn, and returns the first copy whose hash matches its
+// reference. If primary reported the block as missing, that copy is also
+// written back to primary in the background, so that primary gradually
+// recovers the blocks that it lost; for example, after a disk was replaced.
+// Stats reports how often this happens, and Wait waits for repairs that are
+// in progress.
+//
+// Repairs outlive the context passed to Get, so that they aren't abandoned
+// when a client disconnects. A block is only repaired once at a time, however
+// many concurrent reads miss it.
+func NewReadRepair(primary Store, secondaries ...Store) *ReadRepairStore {
+	return &ReadRepairStore{
+		MirrorStore: Mirror(append([]Store{primary}, secondaries...)...),
+		primary:     primary,
+		secondaries: secondaries,
+		inflight:    make(map[eris.Reference]bool),
+	}
+}
+
+// Get implements the Store interface.
+func (r *ReadRepairStore) Get(ctx context.Context, ref eris.Reference, buf []byte) ([]byte, error) {
+	block, err := r.primary.Get(ctx, ref, buf)
+	if err == nil {
+		return block, nil
+	}
+	errs := []error{err}
+	missing := errors.Is(err, ErrNotFound)
+
+	for _, s := range r.secondaries {
+		block, err := s.Get(ctx, ref, buf)
+		if err == nil {
+			err = checkBlock(ref, block)
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		r.fallbacks.Add(1)
+		if missing {
+			r.repair(ctx, ref, block)
+		}
+		return block, nil
+	}
+	return nil, joinGetErrors(errs)
+}
+
+// repair writes a copy of block to the primary store in the background,
+// unless it's already being repaired.
+func (r *ReadRepairStore) repair(ctx context.Context, ref eris.Reference, block []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.inflight[ref] {
+		return
+	}
+	r.inflight[ref] = true
+
+	// The block may be in the caller's buffer, so copy it.
+	block = bytes.Clone(block)
+	ctx = context.WithoutCancel(ctx)
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		if err := r.primary.Put(ctx, ref, block); err != nil {
+			r.failed.Add(1)
+		} else {
+			r.repaired.Add(1)
+		}
+
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		delete(r.inflight, ref)
+	}()
+}
+
+// Wait waits for all repairs that are in progress to finish.
+func (r *ReadRepairStore) Wait() {
+	r.wg.Wait()
+}
+
+// Stats returns statistics about the reads served so far.
+func (r *ReadRepairStore) Stats() ReadRepairStats {
+	return ReadRepairStats{
+		Fallbacks: r.fallbacks.Load(),
+		Repaired:  r.repaired.Load(),
+		Failed:    r.failed.Load(),
+	}
+}
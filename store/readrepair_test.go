@@ -0,0 +1,100 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestReadRepair(t *testing.T) {
+	ctx := context.Background()
+	primary, secondary := NewMemory(), NewMemory()
+	s := NewReadRepair(primary, secondary)
+
+	// Put writes to both stores.
+	ref1, block1 := makeBlock(1, 1024)
+	if err := s.Put(ctx, ref1, block1); err != nil {
+		t.Fatal(err)
+	}
+	for i, m := range []*Memory{primary, secondary} {
+		if ok, _ := m.Has(ctx, ref1); !ok {
+			t.Errorf("store %d is missing the block", i)
+		}
+	}
+
+	// A block only in the secondary is served from there, and copied to
+	// the primary; concurrent reads only repair it once.
+	ref2, block2 := makeBlock(2, 1024)
+	secondary.Put(ctx, ref2, block2)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got, err := s.Get(ctx, ref2, make([]byte, 1024))
+			if err != nil || !bytes.Equal(got, block2) {
+				t.Errorf("Get: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	s.Wait()
+	if got, err := primary.Get(ctx, ref2, nil); err != nil || !bytes.Equal(got, block2) {
+		t.Errorf("primary wasn't repaired: %v", err)
+	}
+	stats := s.Stats()
+	if stats.Fallbacks == 0 || stats.Fallbacks > 10 || stats.Repaired == 0 || stats.Failed != 0 {
+		t.Errorf("stats = %+v", stats)
+	}
+
+	// Once repaired, the block is read from the primary.
+	before := s.Stats().Fallbacks
+	if _, err := s.Get(ctx, ref2, nil); err != nil {
+		t.Fatal(err)
+	}
+	if after := s.Stats().Fallbacks; after != before {
+		t.Errorf("Fallbacks went from %d to %d after repair", before, after)
+	}
+
+	// A corrupt copy in the secondary is never served.
+	ref3, block3 := makeBlock(3, 1024)
+	bad := bytes.Clone(block3)
+	bad[0] ^= 1
+	secondary.Put(ctx, ref3, bad)
+	if _, err := s.Get(ctx, ref3, nil); err == nil {
+		t.Error("Get returned a corrupt block")
+	}
+	ref4, _ := makeBlock(4, 1024)
+	if _, err := s.Get(ctx, ref4, nil); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get of missing block: got %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestReadRepair_PrimaryError(t *testing.T) {
+	ctx := context.Background()
+	errBroken := errors.New("broken")
+	secondary := NewMemory()
+	s := NewReadRepair(failingStore{errBroken}, secondary)
+
+	ref, block := makeBlock(1, 1024)
+	secondary.Put(ctx, ref, block)
+	if got, err := s.Get(ctx, ref, nil); err != nil || !bytes.Equal(got, block) {
+		t.Fatalf("Get: %v", err)
+	}
+	s.Wait()
+
+	// The primary didn't say that the block was missing, so it isn't
+	// repaired.
+	if stats := s.Stats(); stats.Fallbacks != 1 || stats.Repaired != 0 || stats.Failed != 0 {
+		t.Errorf("stats = %+v", stats)
+	}
+
+	// A block that is missing from the primary but can't be read from
+	// any secondary isn't reported as missing.
+	s = NewReadRepair(NewMemory(), failingStore{errBroken})
+	if _, err := s.Get(ctx, ref, nil); errors.Is(err, ErrNotFound) || !errors.Is(err, errBroken) {
+		t.Errorf("Get with failing secondary: got %v, want only %v", err, errBroken)
+	}
+}
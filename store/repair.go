@@ -0,0 +1,99 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/andrew-d/eris-go"
+)
+
+// RepairResult contains the results of a Repair.
+type RepairResult struct {
+	// Report is the result of verifying the content in the store before
+	// repairing it.
+	Report eris.VerifyReport
+	// Uploaded is the number of blocks that were written to the store.
+	Uploaded int64
+}
+
+// Repair restores the content identified by rc in dst from a copy of the
+// original content. It first verifies the content in dst with eris.Verify,
+// and then re-encodes src with the given secret and block size, writing only
+// the blocks that were reported missing or corrupt.
+//
+// If some internal nodes were missing, the blocks underneath them could not
+// be verified; in that case, every block produced by the encoder is checked
+// with Has and written if it's not present.
+//
+// Corrupt blocks must be removed before they can be replaced, so if any are
+// found, dst must implement the Deleter interface.
+//
+// After encoding, Repair checks that src produced the expected capability,
+// and returns an error if it did not.
+func Repair(
+	ctx context.Context,
+	src io.Reader,
+	secret [eris.ConvergenceSecretSize]byte,
+	blockSize int,
+	dst Store,
+	rc eris.ReadCapability,
+) (RepairResult, error) {
+	var res RepairResult
+
+	report, err := eris.Verify(ctx, dst.Get, rc)
+	if err != nil {
+		return res, fmt.Errorf("verifying content: %w", err)
+	}
+	res.Report = report
+	if report.OK() {
+		return res, nil
+	}
+
+	bad := make(map[eris.Reference]bool, len(report.Missing)+len(report.Corrupt))
+	for _, ref := range report.Missing {
+		bad[ref] = true
+	}
+	if len(report.Corrupt) > 0 {
+		deleter, ok := dst.(Deleter)
+		if !ok {
+			return res, errors.New("store does not support deleting corrupt blocks")
+		}
+		for _, ref := range report.Corrupt {
+			if err := deleter.Delete(ctx, ref); err != nil {
+				return res, fmt.Errorf("deleting corrupt block %v: %w", ref, err)
+			}
+			bad[ref] = true
+		}
+	}
+
+	enc := eris.NewEncoder(src, secret, blockSize)
+	for enc.Next() {
+		ref := enc.Reference()
+		if !bad[ref] {
+			if !report.Incomplete {
+				continue
+			}
+			has, err := dst.Has(ctx, ref)
+			if err != nil {
+				return res, err
+			}
+			if has {
+				continue
+			}
+		}
+
+		if err := dst.Put(ctx, ref, enc.Block()); err != nil {
+			return res, err
+		}
+		res.Uploaded++
+	}
+	if err := enc.Err(); err != nil {
+		return res, err
+	}
+	if !enc.Capability().Equal(rc) {
+		return res, errors.New("source content does not match the read capability")
+	}
+	return res, nil
+}
@@ -0,0 +1,53 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/andrew-d/eris-go"
+)
+
+func TestRepair(t *testing.T) {
+	ctx := context.Background()
+	var secret [eris.ConvergenceSecretSize]byte
+	_, content := makeBlock(1, 100*1024)
+
+	s := NewMemory()
+	rc, stats, err := EncodeToStore(ctx, s, eris.NewEncoder(bytes.NewReader(content), secret, 1024), EncodeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Delete some blocks, including internal nodes, and corrupt one.
+	var refs []eris.Reference
+	s.List(ctx, func(ref eris.Reference) error {
+		refs = append(refs, ref)
+		return nil
+	})
+	for _, ref := range refs[:20] {
+		s.Delete(ctx, ref)
+	}
+	s.blocks[refs[30]] = make([]byte, 1024)
+
+	res, err := Repair(ctx, bytes.NewReader(content), secret, 1024, s, rc)
+	if err != nil {
+		t.Fatalf("Repair: %v", err)
+	}
+	if res.Report.OK() {
+		t.Errorf("expected verification to fail before repair")
+	}
+	if res.Uploaded < 21 || res.Uploaded >= stats.Uploaded {
+		t.Errorf("Uploaded = %d, want between 21 and %d", res.Uploaded, stats.Uploaded)
+	}
+
+	report, err := eris.Verify(ctx, s.Get, rc)
+	if err != nil || !report.OK() {
+		t.Errorf("after repair: report = %+v, err = %v", report, err)
+	}
+
+	// Repairing with the wrong content should fail.
+	if _, err := Repair(ctx, bytes.NewReader(content[1:]), secret, 1024, NewMemory(), rc); err == nil {
+		t.Errorf("expected error repairing with wrong content")
+	}
+}
@@ -0,0 +1,223 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/andrew-d/eris-go"
+)
+
+// ReplicateOptions contains options for Replicate.
+type ReplicateOptions struct {
+	// Parallel is the number of leaf blocks to copy concurrently. If it
+	// is less than 1, blocks are copied one at a time.
+	Parallel int
+	// DryRun, if set, only determines which blocks are missing from the
+	// destination store, without copying them.
+	DryRun bool
+	// Progress, if non-nil, is called after each block is copied (or, in
+	// a dry run, found to be missing) with the results so far. It is
+	// never called concurrently.
+	Progress func(ReplicateResult)
+}
+
+// ReplicateResult contains the results of a Replicate.
+type ReplicateResult struct {
+	// Copied is the number of blocks that were written to the
+	// destination store; in a dry run, it is the number of blocks that
+	// would have been written.
+	Copied int64
+	// CopiedBytes is the total size of the blocks counted in Copied.
+	CopiedBytes int64
+	// Present is the number of blocks that were already present in the
+	// destination store. When an internal node is present, the blocks
+	// underneath it are not checked or counted.
+	Present int64
+}
+
+// Replicate copies every block of the content identified by each of the given
+// read capabilities from src to dst. Each block's hash and size are verified
+// before it is written.
+//
+// Blocks are written bottom-up: an internal node is only written once every
+// block underneath it has been written. This means that if an internal node
+// is present in dst, its whole subtree must be too, so Replicate skips it
+// without checking the blocks underneath. This makes replication resumable:
+// if it is interrupted, running it again only copies the blocks that are
+// still missing. The same holds for content written with EncodeToStore, since
+// the encoder produces internal nodes after their children. Stores that were
+// written to in any other order should be checked with eris.Verify instead.
+//
+// Internal nodes are read from src to discover their children; leaves are
+// copied with up to ReplicateOptions.Parallel concurrent calls to src.Get and
+// dst.Put, so both stores must be safe for concurrent use if Parallel is
+// greater than 1.
+//
+// If an error occurs, Replicate returns the results so far along with the
+// error.
+func Replicate(ctx context.Context, src, dst Store, rcs []eris.ReadCapability, opts ReplicateOptions) (ReplicateResult, error) {
+	r := &replicator{
+		src:     src,
+		dst:     dst,
+		opts:    opts,
+		copying: make(map[eris.Reference]bool),
+		sem:     make(chan struct{}, max(opts.Parallel, 1)),
+	}
+	var err error
+	r.ctx, r.cancel = context.WithCancel(ctx)
+	defer r.cancel()
+
+	for _, rc := range rcs {
+		if err = r.replicate(rc); err != nil {
+			break
+		}
+	}
+
+	// Wait for any outstanding copies; if one of them failed, prefer its
+	// error since the cancellation that it caused is less useful.
+	r.wg.Wait()
+	if r.err != nil {
+		err = r.err
+	}
+	return r.res, err
+}
+
+// replicator holds the state of a single call to Replicate.
+type replicator struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	src    Store
+	dst    Store
+	opts   ReplicateOptions
+
+	// copying contains every block that has been (or is being) copied,
+	// so that blocks that appear multiple times are only copied once.
+	copying map[eris.Reference]bool
+
+	// sem limits the number of concurrent copies, and wg tracks them.
+	sem chan struct{}
+	wg  sync.WaitGroup
+
+	// mu protects res and err, which are updated by concurrent copies.
+	mu  sync.Mutex
+	res ReplicateResult
+	err error
+}
+
+// pendingNode is an internal node that is waiting for its subtree to be
+// copied before it can be copied itself.
+type pendingNode struct {
+	ref   eris.Reference
+	level int
+}
+
+func (r *replicator) replicate(rc eris.ReadCapability) error {
+	// The walk visits nodes in pre-order, so when it reaches a node at a
+	// given level, every pending internal node at the same or a lower
+	// level has had its whole subtree visited; once the copies of that
+	// subtree complete, the internal node can be copied.
+	var pending []pendingNode
+	flush := func(level int) error {
+		if len(pending) == 0 || pending[len(pending)-1].level > level {
+			return nil
+		}
+		r.wg.Wait()
+		for len(pending) > 0 && pending[len(pending)-1].level <= level {
+			node := pending[len(pending)-1]
+			pending = pending[:len(pending)-1]
+			if err := r.copyBlock(node.ref, rc.BlockSize); err != nil {
+				return err
+			}
+		}
+		return r.firstErr()
+	}
+
+	err := eris.Walk(r.ctx, r.src.Get, rc, func(ref eris.ReferenceKeyPair, level int) error {
+		if err := flush(level); err != nil {
+			return err
+		}
+		if r.copying[ref.Reference] {
+			return eris.SkipSubtree
+		}
+
+		has, err := r.dst.Has(r.ctx, ref.Reference)
+		if err != nil {
+			return err
+		}
+		if has {
+			r.mu.Lock()
+			r.res.Present++
+			r.mu.Unlock()
+			return eris.SkipSubtree
+		}
+		r.copying[ref.Reference] = true
+
+		if level > 0 {
+			pending = append(pending, pendingNode{ref.Reference, level})
+			return nil
+		}
+		return r.startCopy(ref.Reference, rc.BlockSize)
+	})
+	if err != nil {
+		return err
+	}
+	return flush(rc.Level)
+}
+
+// startCopy copies a leaf on a new goroutine, waiting until fewer than
+// ReplicateOptions.Parallel copies are running.
+func (r *replicator) startCopy(ref eris.Reference, blockSize int) error {
+	select {
+	case r.sem <- struct{}{}:
+	case <-r.ctx.Done():
+		return r.ctx.Err()
+	}
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		defer func() { <-r.sem }()
+		if err := r.copyBlock(ref, blockSize); err != nil {
+			r.mu.Lock()
+			if r.err == nil {
+				r.err = err
+				r.cancel()
+			}
+			r.mu.Unlock()
+		}
+	}()
+	return nil
+}
+
+// copyBlock copies a single block of the given size from src to dst after
+// verifying it. In a dry run, it only records the block in the results.
+func (r *replicator) copyBlock(ref eris.Reference, blockSize int) error {
+	if !r.opts.DryRun {
+		block, err := r.src.Get(r.ctx, ref, make([]byte, blockSize))
+		if err != nil {
+			return fmt.Errorf("reading block %v: %w", ref, err)
+		}
+		if err := checkBlock(ref, block); err != nil {
+			return fmt.Errorf("block %v in source store: %w", ref, err)
+		}
+		if err := r.dst.Put(r.ctx, ref, block); err != nil {
+			return fmt.Errorf("writing block %v: %w", ref, err)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.res.Copied++
+	r.res.CopiedBytes += int64(blockSize)
+	if r.opts.Progress != nil {
+		r.opts.Progress(r.res)
+	}
+	return nil
+}
+
+func (r *replicator) firstErr() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
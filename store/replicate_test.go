@@ -0,0 +1,184 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/rand"
+	"sync/atomic"
+	"testing"
+
+	"github.com/andrew-d/eris-go"
+)
+
+// encodeToMemory encodes content into a new Memory store.
+func encodeToMemory(t *testing.T, content []byte) (*Memory, eris.ReadCapability) {
+	t.Helper()
+	st := NewMemory()
+	return st, encodeToStore(t, st, content)
+}
+
+// encodeToStore encodes content into st with the small block size.
+func encodeToStore(t *testing.T, st Store, content []byte) eris.ReadCapability {
+	t.Helper()
+	var secret [eris.ConvergenceSecretSize]byte
+	enc := eris.NewEncoder(bytes.NewReader(content), secret, eris.BlockSizeSmall)
+	rc, _, err := EncodeToStore(context.Background(), st, enc, EncodeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rc
+}
+
+// putLimitStore is a Store that fails every Put after the first n.
+type putLimitStore struct {
+	*Memory
+	n atomic.Int64
+}
+
+var errPutLimit = errors.New("put limit reached")
+
+func (p *putLimitStore) Put(ctx context.Context, ref eris.Reference, block []byte) error {
+	if p.n.Add(-1) < 0 {
+		return errPutLimit
+	}
+	return p.Memory.Put(ctx, ref, block)
+}
+
+func TestReplicate(t *testing.T) {
+	ctx := context.Background()
+	content := make([]byte, 300*1024)
+	rand.New(rand.NewSource(1)).Read(content)
+	src, rc := encodeToMemory(t, content)
+
+	for _, parallel := range []int{1, 4} {
+		dst := NewMemory()
+		var progress int64
+		res, err := Replicate(ctx, src, dst, []eris.ReadCapability{rc}, ReplicateOptions{
+			Parallel: parallel,
+			Progress: func(ReplicateResult) { progress++ },
+		})
+		if err != nil {
+			t.Fatalf("Replicate: %v", err)
+		}
+		if res.Copied != int64(src.Len()) || res.Present != 0 || progress != res.Copied {
+			t.Errorf("parallel %d: result = %+v with %d progress calls; want %d copied", parallel, res, progress, src.Len())
+		}
+		if res.CopiedBytes != res.Copied*eris.BlockSizeSmall {
+			t.Errorf("parallel %d: CopiedBytes = %d", parallel, res.CopiedBytes)
+		}
+		if report, err := eris.Verify(ctx, dst.Get, rc); err != nil || !report.OK() {
+			t.Errorf("parallel %d: destination is incomplete: %+v, %v", parallel, report, err)
+		}
+
+		// Replicating again only needs to check the root.
+		res, err = Replicate(ctx, src, dst, []eris.ReadCapability{rc}, ReplicateOptions{Parallel: parallel})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Copied != 0 || res.Present != 1 {
+			t.Errorf("parallel %d: second result = %+v; want only the root present", parallel, res)
+		}
+	}
+}
+
+func TestReplicate_Resume(t *testing.T) {
+	ctx := context.Background()
+	content := make([]byte, 300*1024)
+	rand.New(rand.NewSource(2)).Read(content)
+	src, rc := encodeToMemory(t, content)
+
+	// Interrupt replication part of the way through, several times.
+	dst := NewMemory()
+	var total int64
+	for _, n := range []int64{50, 100, 37} {
+		limited := &putLimitStore{Memory: dst}
+		limited.n.Store(n)
+		res, err := Replicate(ctx, src, limited, []eris.ReadCapability{rc}, ReplicateOptions{Parallel: 3})
+		if !errors.Is(err, errPutLimit) {
+			t.Fatalf("expected put limit error, got %v", err)
+		}
+		total += res.Copied
+
+		// Every internal node that was written has its whole subtree
+		// present.
+		for ref := range dst.blocks {
+			sub, err := findSubtree(ctx, src, rc, ref)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sub.Level == 0 {
+				continue
+			}
+			if report, err := eris.Verify(ctx, dst.Get, sub); err != nil || !report.OK() {
+				t.Fatalf("internal node %v was written before its subtree: %+v, %v", ref, report, err)
+			}
+		}
+	}
+
+	// Resuming copies the rest, without copying anything twice.
+	res, err := Replicate(ctx, src, dst, []eris.ReadCapability{rc}, ReplicateOptions{Parallel: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total+res.Copied != int64(src.Len()) {
+		t.Errorf("copied %d blocks in total, want %d", total+res.Copied, src.Len())
+	}
+	if report, err := eris.Verify(ctx, dst.Get, rc); err != nil || !report.OK() {
+		t.Errorf("destination is incomplete: %+v, %v", report, err)
+	}
+}
+
+// findSubtree returns a read capability for the subtree of the tree rooted at
+// rc whose root is ref.
+func findSubtree(ctx context.Context, st Store, rc eris.ReadCapability, ref eris.Reference) (eris.ReadCapability, error) {
+	var sub eris.ReadCapability
+	err := eris.Walk(ctx, st.Get, rc, func(rk eris.ReferenceKeyPair, level int) error {
+		if rk.Reference == ref {
+			sub = eris.ReadCapability{BlockSize: rc.BlockSize, Level: level, Root: rk}
+		}
+		return nil
+	})
+	return sub, err
+}
+
+func TestReplicate_DryRun(t *testing.T) {
+	ctx := context.Background()
+
+	// Repetitive content, so that many blocks are duplicates and must
+	// only be counted once.
+	content := bytes.Repeat([]byte("0123456789abcdef"), 64*100)
+	src, rc := encodeToMemory(t, content)
+
+	dst := NewMemory()
+	res, err := Replicate(ctx, src, dst, []eris.ReadCapability{rc}, ReplicateOptions{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Copied != int64(src.Len()) || res.CopiedBytes != int64(src.Len())*eris.BlockSizeSmall {
+		t.Errorf("result = %+v; want %d blocks", res, src.Len())
+	}
+	if dst.Len() != 0 {
+		t.Errorf("dry run wrote %d blocks", dst.Len())
+	}
+}
+
+func TestReplicate_CorruptSource(t *testing.T) {
+	ctx := context.Background()
+	content := make([]byte, 10*1024)
+	rand.New(rand.NewSource(3)).Read(content)
+	src, rc := encodeToMemory(t, content)
+
+	// Corrupt a leaf in the source store.
+	for ref, block := range src.blocks {
+		if ref != rc.Root.Reference {
+			block[0] ^= 1
+			break
+		}
+	}
+
+	_, err := Replicate(ctx, src, NewMemory(), []eris.ReadCapability{rc}, ReplicateOptions{})
+	if !errors.Is(err, eris.ErrInvalidBlock) {
+		t.Errorf("expected ErrInvalidBlock, got %v", err)
+	}
+}
@@ -0,0 +1,164 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/crypto/blake2b"
+
+	"github.com/andrew-d/eris-go"
+)
+
+// ScrubOptions contains options for Scrub.
+type ScrubOptions struct {
+	// Delete causes corrupt blocks to be removed from the store. The store
+	// must implement the Deleter interface.
+	Delete bool
+
+	// OnCorrupt, if non-nil, is called for every corrupt block that is
+	// found, along with an error describing the corruption.
+	OnCorrupt func(ref eris.Reference, err error)
+
+	// BlocksPerSecond limits the rate at which blocks are checked, to
+	// reduce the impact of scrubbing on other users of the store. If zero,
+	// the rate is unlimited.
+	BlocksPerSecond float64
+
+	// StartAfter resumes a previous scrub: only blocks with a reference
+	// greater than StartAfter are checked. The zero value checks every
+	// block.
+	StartAfter eris.Reference
+
+	// Workers is the number of blocks that are fetched and hashed
+	// concurrently, which speeds up scrubbing of large stores on
+	// machines with several cores. Each worker holds at most one block
+	// in memory at a time. OnCorrupt is still called from one goroutine
+	// at a time, in order of reference. If Workers is less than 1,
+	// blocks are checked one at a time.
+	Workers int
+}
+
+// ScrubResult contains the results of a Scrub.
+type ScrubResult struct {
+	// Checked is the number of blocks that were checked.
+	Checked int64
+	// Corrupt contains the references of all corrupt blocks that were
+	// found.
+	Corrupt []eris.Reference
+	// Last is the reference of the last block that was checked. If a
+	// scrub is interrupted, passing this as ScrubOptions.StartAfter will
+	// resume it where it left off.
+	Last eris.Reference
+}
+
+// Scrub checks the integrity of every block in s, which must implement the
+// Lister interface, by fetching it and verifying that its hash matches its
+// reference and that it has a valid size. Corrupt blocks are reported and,
+// optionally, deleted.
+//
+// If the scrub is interrupted by an error (including cancellation of ctx),
+// Scrub returns the results so far along with the error; the scrub can be
+// resumed by setting ScrubOptions.StartAfter to ScrubResult.Last.
+func Scrub(ctx context.Context, s Store, opts ScrubOptions) (ScrubResult, error) {
+	var res ScrubResult
+
+	lister, ok := s.(Lister)
+	if !ok {
+		return res, errListUnsupported
+	}
+	var deleter Deleter
+	if opts.Delete {
+		deleter, ok = s.(Deleter)
+		if !ok {
+			return res, errors.New("store does not support deleting blocks")
+		}
+	}
+
+	var interval time.Duration
+	if opts.BlocksPerSecond > 0 {
+		interval = time.Duration(float64(time.Second) / opts.BlocksPerSecond)
+	}
+	var lastCheck time.Time
+
+	list := func(fn func(eris.Reference) error) error {
+		return lister.List(ctx, func(ref eris.Reference) error {
+			if bytes.Compare(ref[:], opts.StartAfter[:]) <= 0 {
+				return nil
+			}
+
+			// Wait until we're allowed to check another block.
+			if interval > 0 {
+				if wait := interval - time.Since(lastCheck); wait > 0 {
+					t := time.NewTimer(wait)
+					select {
+					case <-t.C:
+					case <-ctx.Done():
+						t.Stop()
+						return ctx.Err()
+					}
+				}
+				lastCheck = time.Now()
+			}
+			return fn(ref)
+		})
+	}
+
+	// Blocks are fetched and hashed by the workers; the results are
+	// recorded in order, so that res.Last is always a safe place to
+	// resume from.
+	check := func(ctx context.Context, ref eris.Reference, buf []byte) (scrubbed, error) {
+		block, err := s.Get(ctx, ref, buf)
+		if errors.Is(err, ErrNotFound) {
+			// The block was deleted after it was listed.
+			return scrubbed{}, nil
+		} else if err != nil {
+			return scrubbed{}, err
+		}
+		return scrubbed{found: true, err: checkBlock(ref, block)}, nil
+	}
+	record := func(ref eris.Reference, sc scrubbed) error {
+		res.Last = ref
+		if !sc.found {
+			return nil
+		}
+		res.Checked++
+		if sc.err != nil {
+			res.Corrupt = append(res.Corrupt, ref)
+			if opts.OnCorrupt != nil {
+				opts.OnCorrupt(ref, sc.err)
+			}
+			if deleter != nil {
+				if err := deleter.Delete(ctx, ref); err != nil {
+					return fmt.Errorf("deleting corrupt block %v: %w", ref, err)
+				}
+			}
+		}
+		return nil
+	}
+
+	err := forEachOrdered(ctx, opts.Workers, list, check, record)
+	return res, err
+}
+
+// scrubbed is the result of checking a single block in Scrub.
+type scrubbed struct {
+	// found is set if the block still exists, and err describes how it
+	// is corrupt, if it is.
+	found bool
+	err   error
+}
+
+// checkBlock verifies that block is a valid ERIS block with the given
+// reference.
+func checkBlock(ref eris.Reference, block []byte) error {
+	if len(block) != eris.BlockSizeSmall && len(block) != eris.BlockSizeLarge {
+		return fmt.Errorf("%w: unexpected size %d", eris.ErrInvalidBlockSize, len(block))
+	}
+	if blake2b.Sum256(block) != ref {
+		return eris.ErrInvalidBlock
+	}
+	return nil
+}
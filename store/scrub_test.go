@@ -0,0 +1,160 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/andrew-d/eris-go"
+)
+
+func TestScrub(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemory()
+
+	// Add some good blocks, and some corrupt ones.
+	for i := 0; i < 10; i++ {
+		ref, block := makeBlock(i, eris.BlockSizeSmall)
+		s.Put(ctx, ref, block)
+	}
+	badRef, _ := makeBlock(100, eris.BlockSizeSmall)
+	_, other := makeBlock(101, eris.BlockSizeSmall)
+	s.Put(ctx, badRef, other)
+	truncRef, block := makeBlock(102, eris.BlockSizeSmall)
+	s.Put(ctx, truncRef, block[:100])
+
+	var reported []eris.Reference
+	res, err := Scrub(ctx, s, ScrubOptions{
+		Delete: true,
+		OnCorrupt: func(ref eris.Reference, err error) {
+			reported = append(reported, ref)
+		},
+	})
+	if err != nil {
+		t.Fatalf("Scrub: %v", err)
+	}
+	if res.Checked != 12 {
+		t.Errorf("Checked = %d, want 12", res.Checked)
+	}
+	if len(res.Corrupt) != 2 || len(reported) != 2 {
+		t.Errorf("Corrupt = %v, reported = %v; want 2 of each", res.Corrupt, reported)
+	}
+	if s.Len() != 10 {
+		t.Errorf("store has %d blocks after scrub, want 10", s.Len())
+	}
+}
+
+func TestScrub_Resume(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemory()
+	for i := 0; i < 10; i++ {
+		ref, block := makeBlock(i, eris.BlockSizeSmall)
+		s.Put(ctx, ref, block)
+	}
+
+	// Interrupt the scrub after a few blocks by canceling the context.
+	ctx2, cancel := context.WithCancel(ctx)
+	var checked int
+	cs := &cancelAfterStore{Memory: s, n: 4, cancel: cancel, calls: &checked}
+	res, err := Scrub(ctx2, cs, ScrubOptions{})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Scrub: got error %v, want context.Canceled", err)
+	}
+
+	// Resuming should check exactly the remaining blocks.
+	res2, err := Scrub(ctx, s, ScrubOptions{StartAfter: res.Last})
+	if err != nil {
+		t.Fatalf("Scrub: %v", err)
+	}
+	if res.Checked+res2.Checked != 10 {
+		t.Errorf("checked %d + %d blocks, want 10", res.Checked, res2.Checked)
+	}
+}
+
+// cancelAfterStore wraps a Memory store and cancels a context after n calls
+// to Get.
+type cancelAfterStore struct {
+	*Memory
+	n      int
+	cancel func()
+	calls  *int
+}
+
+func (c *cancelAfterStore) Get(ctx context.Context, ref eris.Reference, buf []byte) ([]byte, error) {
+	*c.calls++
+	if *c.calls == c.n {
+		c.cancel()
+	}
+	return c.Memory.Get(ctx, ref, buf)
+}
+
+func TestScrub_Workers(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemory()
+	for i := 0; i < 200; i++ {
+		ref, block := makeBlock(i, eris.BlockSizeSmall)
+		if i%50 == 0 {
+			_, block = makeBlock(1000+i, eris.BlockSizeSmall)
+		}
+		s.Put(ctx, ref, block)
+	}
+
+	var reported []eris.Reference
+	res, err := Scrub(ctx, s, ScrubOptions{
+		Workers: 8,
+		OnCorrupt: func(ref eris.Reference, err error) {
+			reported = append(reported, ref)
+		},
+	})
+	if err != nil {
+		t.Fatalf("Scrub: %v", err)
+	}
+	if res.Checked != 200 || len(res.Corrupt) != 4 {
+		t.Errorf("result = %d checked, %d corrupt; want 200, 4", res.Checked, len(res.Corrupt))
+	}
+	if !slices.IsSortedFunc(reported, func(a, b eris.Reference) int { return bytes.Compare(a[:], b[:]) }) {
+		t.Errorf("corrupt blocks reported out of order: %v", reported)
+	}
+}
+
+func TestScrub_WorkersError(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemory()
+	for i := 0; i < 100; i++ {
+		ref, block := makeBlock(i, eris.BlockSizeSmall)
+		s.Put(ctx, ref, block)
+	}
+
+	// Fail on the 50th block in order; every block before it must be
+	// recorded, and none after it, so that the scrub can be resumed.
+	var refs []eris.Reference
+	s.List(ctx, func(ref eris.Reference) error {
+		refs = append(refs, ref)
+		return nil
+	})
+	errBroken := errors.New("broken")
+	fs := &failRefStore{Memory: s, ref: refs[50], err: errBroken}
+	res, err := Scrub(ctx, fs, ScrubOptions{Workers: 8})
+	if !errors.Is(err, errBroken) {
+		t.Fatalf("Scrub: got error %v, want %v", err, errBroken)
+	}
+	if res.Checked != 50 || res.Last != refs[49] {
+		t.Errorf("Checked = %d, Last = %v; want 50, %v", res.Checked, res.Last, refs[49])
+	}
+}
+
+// failRefStore wraps a Memory store and fails to get a single block.
+type failRefStore struct {
+	*Memory
+	ref eris.Reference
+	err error
+}
+
+func (f *failRefStore) Get(ctx context.Context, ref eris.Reference, buf []byte) ([]byte, error) {
+	if ref == f.ref {
+		return nil, f.err
+	}
+	return f.Memory.Get(ctx, ref, buf)
+}
@@ -0,0 +1,106 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/andrew-d/eris-go"
+)
+
+// SpoolOptions contains options for EncodeSpooled.
+type SpoolOptions struct {
+	// Store, if non-nil, is a local store that the content is encoded
+	// into first; the blocks are then copied to the destination with
+	// Replicate. Otherwise, the content is copied to a temporary file,
+	// and encoded from there to the destination.
+	Store Store
+
+	// Dir is the directory in which the temporary file is created. If
+	// empty, the default directory for temporary files is used. It is
+	// unused if Store is set.
+	Dir string
+
+	// Attempts is the number of times that writing the blocks to the
+	// destination is attempted before giving up. If it is less than 1,
+	// a single attempt is made.
+	Attempts int
+
+	// Encode contains the options used when encoding from the temporary
+	// file to the destination.
+	Encode EncodeOptions
+}
+
+// EncodeSpooled encodes content, which may be a pipe or another reader that
+// can only be read once, to dst. The content is first spooled to a temporary
+// file or to a local store, as chosen by opts, and is then written to dst from
+// the spool; if writing to dst fails, it is retried from the spool, up to
+// opts.Attempts times. Each retry skips the blocks that were already written.
+//
+// If blockSize is 0, the block size is chosen with eris.RecommendedBlockSize.
+//
+// The temporary file is removed before EncodeSpooled returns. Blocks spooled
+// to opts.Store are left there, so that the caller can resume a failed upload
+// with Replicate.
+func EncodeSpooled(ctx context.Context, dst Store, content io.Reader, secret [eris.ConvergenceSecretSize]byte, blockSize int, opts SpoolOptions, encOpts ...eris.EncoderOption) (eris.ReadCapability, error) {
+	attempts := max(opts.Attempts, 1)
+	if opts.Store != nil {
+		return spoolToStore(ctx, dst, content, secret, blockSize, opts.Store, attempts, encOpts)
+	}
+
+	f, err := os.CreateTemp(opts.Dir, "eris-spool-*")
+	if err != nil {
+		return eris.ReadCapability{}, err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	size, err := io.Copy(f, content)
+	if err != nil {
+		return eris.ReadCapability{}, fmt.Errorf("spooling content: %w", err)
+	}
+	if blockSize == 0 {
+		blockSize = eris.RecommendedBlockSize(size)
+	}
+
+	for i := 1; ; i++ {
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return eris.ReadCapability{}, err
+		}
+		enc := eris.NewEncoder(f, secret, blockSize, encOpts...)
+		rc, _, err := EncodeToStore(ctx, dst, enc, opts.Encode)
+		if err == nil || i == attempts || ctx.Err() != nil {
+			return rc, err
+		}
+	}
+}
+
+// spoolToStore implements EncodeSpooled when the content is spooled to a
+// local store.
+func spoolToStore(ctx context.Context, dst Store, content io.Reader, secret [eris.ConvergenceSecretSize]byte, blockSize int, spool Store, attempts int, encOpts []eris.EncoderOption) (eris.ReadCapability, error) {
+	var enc *eris.Encoder
+	if blockSize == 0 {
+		var err error
+		enc, err = eris.EncodeAuto(content, secret, encOpts...)
+		if err != nil {
+			return eris.ReadCapability{}, err
+		}
+	} else {
+		enc = eris.NewEncoder(content, secret, blockSize, encOpts...)
+	}
+	rc, _, err := EncodeToStore(ctx, spool, enc, EncodeOptions{})
+	if err != nil {
+		return eris.ReadCapability{}, fmt.Errorf("spooling content: %w", err)
+	}
+
+	for i := 1; ; i++ {
+		_, err := Replicate(ctx, spool, dst, []eris.ReadCapability{rc}, ReplicateOptions{})
+		if err == nil {
+			return rc, nil
+		}
+		if i == attempts || ctx.Err() != nil {
+			return eris.ReadCapability{}, err
+		}
+	}
+}
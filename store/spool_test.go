@@ -0,0 +1,74 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/rand"
+	"sync/atomic"
+	"testing"
+	"testing/iotest"
+
+	"github.com/andrew-d/eris-go"
+)
+
+// flakyPutStore is a Store whose Put fails the first n times it is called.
+type flakyPutStore struct {
+	*Memory
+	n atomic.Int64
+}
+
+var errFlakyPut = errors.New("flaky put")
+
+func (f *flakyPutStore) Put(ctx context.Context, ref eris.Reference, block []byte) error {
+	if f.n.Add(-1) >= 0 {
+		return errFlakyPut
+	}
+	return f.Memory.Put(ctx, ref, block)
+}
+
+func TestEncodeSpooled(t *testing.T) {
+	ctx := context.Background()
+	var secret [eris.ConvergenceSecretSize]byte
+	content := make([]byte, 100*1024)
+	rand.New(rand.NewSource(1)).Read(content)
+	want, err := eris.ComputeCapability(bytes.NewReader(content), secret, eris.BlockSizeLarge)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name string
+		opts SpoolOptions
+	}{
+		{"File", SpoolOptions{Dir: t.TempDir(), Attempts: 3}},
+		{"Store", SpoolOptions{Store: NewMemory(), Attempts: 3}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			// The reader can only be read once, and the first
+			// two attempts to write to the destination fail.
+			dst := &flakyPutStore{Memory: NewMemory()}
+			dst.n.Store(2)
+			r := iotest.HalfReader(bytes.NewReader(content))
+			rc, err := EncodeSpooled(ctx, dst, r, secret, 0, tc.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !rc.Equal(want) {
+				t.Errorf("capability mismatch")
+			}
+			got, err := eris.DecodeRecursive(ctx, Fetch(dst), rc)
+			if err != nil || !bytes.Equal(got, content) {
+				t.Errorf("decoding from destination: %v", err)
+			}
+
+			// With too few attempts, the error is returned.
+			dst = &flakyPutStore{Memory: NewMemory()}
+			dst.n.Store(2)
+			tc.opts.Attempts = 2
+			if _, err := EncodeSpooled(ctx, dst, bytes.NewReader(content), secret, 0, tc.opts); !errors.Is(err, errFlakyPut) {
+				t.Errorf("expected flaky put error, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+// Package store defines an interface for storing ERIS blocks, along with some
+// simple implementations of it and helpers that operate on any Store.
+//
+// The eris package itself is agnostic to how blocks are stored; this package
+// provides a common abstraction so that helpers such as EncodeToStore can be
+// written once and used with any backend.
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/andrew-d/eris-go"
+)
+
+// ErrNotFound is returned (possibly wrapped) by Store.Get when the requested
+// block does not exist in the store.
+var ErrNotFound = errors.New("block not found")
+
+// joinGetErrors combines the errors from trying to get a block from several
+// stores. The result wraps ErrNotFound only if every store reported the block
+// as missing; otherwise, it contains only the other errors, so that a failing
+// store isn't mistaken for a missing block.
+func joinGetErrors(errs []error) error {
+	var failures []error
+	for _, err := range errs {
+		if !errors.Is(err, ErrNotFound) {
+			failures = append(failures, err)
+		}
+	}
+	if len(failures) > 0 {
+		return errors.Join(failures...)
+	}
+	return errors.Join(errs...)
+}
+
+// Store is the interface implemented by ERIS block stores.
+//
+// Implementations must be safe for concurrent use by multiple goroutines.
+type Store interface {
+	// Get fetches the block with the given reference. It has the same
+	// semantics as eris.FetchFunc: the buf parameter is at least the size
+	// of a block, and the implementation can use it as storage for the
+	// returned block or allocate and return a new slice.
+	//
+	// If the block does not exist, Get returns an error that wraps
+	// ErrNotFound.
+	Get(ctx context.Context, ref eris.Reference, buf []byte) ([]byte, error)
+
+	// Put stores a block with the given reference. The reference must be
+	// the hash of the block; implementations are not required to verify
+	// this. If the block already exists, Put does nothing.
+	//
+	// The block is not retained after Put returns.
+	Put(ctx context.Context, ref eris.Reference, block []byte) error
+
+	// Has reports whether a block with the given reference exists.
+	Has(ctx context.Context, ref eris.Reference) (bool, error)
+}
+
+// BatchHaser is an optional interface that can be implemented by a Store to
+// check for the existence of many blocks at once; for example, a remote store
+// might implement this with a single round-trip.
+type BatchHaser interface {
+	// HasMany reports whether each of the given blocks exists. The
+	// returned slice has the same length as refs.
+	HasMany(ctx context.Context, refs []eris.Reference) ([]bool, error)
+}
+
+// Lister is an optional interface that can be implemented by a Store that
+// is able to enumerate the blocks it contains. Helpers such as Scrub and
+// Migrate use it to operate on every block in a store.
+//
+// All of the stores in this package implement Lister; the wrapper stores
+// (ReadOnly, Union and Mirror) return an error from List if a store they
+// wrap does not.
+type Lister interface {
+	// List calls fn with the reference of every block in the store, in
+	// ascending order of the reference's bytes. If fn returns an error,
+	// List stops and returns that error.
+	//
+	// Blocks that are added or removed while List is running may or may
+	// not be included.
+	List(ctx context.Context, fn func(eris.Reference) error) error
+}
+
+// Deleter is an optional interface that can be implemented by a Store that
+// supports removing blocks.
+type Deleter interface {
+	// Delete removes the block with the given reference. Deleting a
+	// block that does not exist is not an error.
+	Delete(ctx context.Context, ref eris.Reference) error
+}
+
+// BlockInfo describes a block in a store; see Stater.
+type BlockInfo struct {
+	// Size is the size of the block in bytes.
+	Size int64
+	// ModTime is the time at which the block was last written.
+	ModTime time.Time
+}
+
+// Stater is an optional interface that can be implemented by a Store that
+// records when each block was written. GC uses it to avoid deleting blocks
+// that were written recently.
+type Stater interface {
+	// Stat returns information about the block with the given
+	// reference. If the block does not exist, Stat returns an error that
+	// wraps ErrNotFound.
+	Stat(ctx context.Context, ref eris.Reference) (BlockInfo, error)
+}
+
+// Pinger is an optional interface that can be implemented by a Store to check
+// that its backend is reachable and working, without reading or writing any
+// blocks; for example, that a directory still exists or that a server still
+// responds. Servers use it to answer health checks.
+type Pinger interface {
+	// Ping returns an error if the store is unable to serve requests.
+	Ping(ctx context.Context) error
+}
+
+// Ping checks that s is able to serve requests, using the Pinger interface if
+// s implements it and falling back to checking whether it has a block
+// otherwise, which exercises the backend of most stores without any side
+// effects.
+func Ping(ctx context.Context, s Store) error {
+	if p, ok := s.(Pinger); ok {
+		return p.Ping(ctx)
+	}
+	_, err := s.Has(ctx, eris.Reference{})
+	return err
+}
+
+// HasMany reports whether each of the given blocks exists in s, using the
+// BatchHaser interface if s implements it and falling back to calling Has for
+// each reference otherwise.
+func HasMany(ctx context.Context, s Store, refs []eris.Reference) ([]bool, error) {
+	if bh, ok := s.(BatchHaser); ok {
+		return bh.HasMany(ctx, refs)
+	}
+
+	has := make([]bool, len(refs))
+	for i, ref := range refs {
+		var err error
+		has[i], err = s.Has(ctx, ref)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return has, nil
+}
+
+// Fetch returns an eris.FetchFunc that fetches blocks from s.
+func Fetch(s Store) eris.FetchFunc {
+	return s.Get
+}
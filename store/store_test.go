@@ -0,0 +1,206 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"os"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/blake2b"
+
+	"github.com/andrew-d/eris-go"
+)
+
+// countingStore wraps a Store and counts calls to its methods.
+type countingStore struct {
+	Store
+	gets, puts, hasMany int
+}
+
+func (c *countingStore) Get(ctx context.Context, ref eris.Reference, buf []byte) ([]byte, error) {
+	c.gets++
+	return c.Store.Get(ctx, ref, buf)
+}
+
+func (c *countingStore) Put(ctx context.Context, ref eris.Reference, block []byte) error {
+	c.puts++
+	return c.Store.Put(ctx, ref, block)
+}
+
+func (c *countingStore) HasMany(ctx context.Context, refs []eris.Reference) ([]bool, error) {
+	c.hasMany++
+	has := make([]bool, len(refs))
+	for i, ref := range refs {
+		has[i], _ = c.Store.Has(ctx, ref)
+	}
+	return has, nil
+}
+
+func TestPing(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	d, err := NewDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := NewMemory()
+
+	// Wrappers check the stores they wrap.
+	stores := map[string]Store{
+		"dir":       d,
+		"read-only": ReadOnly(d),
+		"union":     Union(m, d),
+		"mirror":    Mirror(m, d),
+		"quota":     NewQuota(d, func(string) int64 { return -1 }),
+		"writeonce": NewWriteOnce(d),
+	}
+	for name, s := range stores {
+		if err := Ping(ctx, s); err != nil {
+			t.Errorf("%s: Ping: %v", name, err)
+		}
+	}
+	if err := os.Remove(dir); err != nil {
+		t.Fatal(err)
+	}
+	for name, s := range stores {
+		if err := Ping(ctx, s); err == nil {
+			t.Errorf("%s: Ping succeeded after the directory was removed", name)
+		}
+	}
+
+	// Stores that don't implement Pinger are checked with Has.
+	errBroken := errors.New("broken")
+	if err := Ping(ctx, failingStore{errBroken}); !errors.Is(err, errBroken) {
+		t.Errorf("Ping = %v, want %v", err, errBroken)
+	}
+}
+
+func TestEncodeToStore(t *testing.T) {
+	ctx := context.Background()
+	var secret [eris.ConvergenceSecretSize]byte
+	content := func() io.Reader {
+		return io.LimitReader(bytes.NewReader(bytes.Repeat([]byte("hello world "), 10000)), 100000)
+	}
+
+	s := &countingStore{Store: NewMemory()}
+	rc, stats, err := EncodeToStore(ctx, s, eris.NewEncoder(content(), secret, 1024), EncodeOptions{BatchSize: 10})
+	if err != nil {
+		t.Fatalf("EncodeToStore: %v", err)
+	}
+	if stats.Uploaded == 0 || stats.Skipped != 0 {
+		t.Errorf("first encode: stats = %+v", stats)
+	}
+	if stats.Uploaded != int64(s.puts) {
+		t.Errorf("stats.Uploaded = %d, but Put called %d times", stats.Uploaded, s.puts)
+	}
+	if want := (stats.Uploaded + 9) / 10; int64(s.hasMany) != want {
+		t.Errorf("HasMany called %d times, want %d", s.hasMany, want)
+	}
+
+	// Encoding the same content again should skip every block.
+	s.puts = 0
+	rc2, stats2, err := EncodeToStore(ctx, s, eris.NewEncoder(content(), secret, 1024), EncodeOptions{})
+	if err != nil {
+		t.Fatalf("EncodeToStore: %v", err)
+	}
+	if !rc2.Equal(rc) {
+		t.Errorf("capability mismatch on second encode")
+	}
+	if stats2.Uploaded != 0 || stats2.Skipped != stats.Uploaded || s.puts != 0 {
+		t.Errorf("second encode: stats = %+v, puts = %d", stats2, s.puts)
+	}
+
+	// Verify that we can decode the content from the store.
+	got, err := eris.DecodeRecursive(ctx, Fetch(s), rc)
+	if err != nil {
+		t.Fatalf("DecodeRecursive: %v", err)
+	}
+	want, _ := io.ReadAll(content())
+	if !bytes.Equal(got, want) {
+		t.Errorf("decoded content mismatch")
+	}
+}
+
+func TestEncodeToStore_Pipelined(t *testing.T) {
+	ctx := context.Background()
+	var secret [eris.ConvergenceSecretSize]byte
+	content := make([]byte, 200*1024)
+	rand.New(rand.NewSource(1)).Read(content)
+	want, err := eris.ComputeCapability(bytes.NewReader(content), secret, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, inFlight := range []int64{1, 4 * 1024, 1 << 20} {
+		mem := NewMemory()
+		opts := EncodeOptions{BatchSize: 8, MaxInFlightBytes: inFlight}
+		rc, stats, err := EncodeToStore(ctx, mem, eris.NewEncoder(bytes.NewReader(content), secret, 1024), opts)
+		if err != nil {
+			t.Fatalf("MaxInFlightBytes=%d: %v", inFlight, err)
+		}
+		if !rc.Equal(want) {
+			t.Errorf("MaxInFlightBytes=%d: capability mismatch", inFlight)
+		}
+		if stats.Uploaded != int64(mem.Len()) || stats.Skipped != 0 {
+			t.Errorf("MaxInFlightBytes=%d: stats = %+v with %d blocks stored", inFlight, stats, mem.Len())
+		}
+		got, err := eris.DecodeRecursive(ctx, Fetch(mem), rc)
+		if err != nil || !bytes.Equal(got, content) {
+			t.Errorf("MaxInFlightBytes=%d: decoding failed: %v", inFlight, err)
+		}
+	}
+
+	// A failed write stops the encoder.
+	limited := &putLimitStore{Memory: NewMemory()}
+	limited.n.Store(20)
+	_, stats, err := EncodeToStore(ctx, limited, eris.NewEncoder(bytes.NewReader(content), secret, 1024), EncodeOptions{MaxInFlightBytes: 8 * 1024})
+	if !errors.Is(err, errPutLimit) {
+		t.Fatalf("expected put limit error, got %v", err)
+	}
+	if stats.Uploaded != 20 {
+		t.Errorf("stats.Uploaded = %d, want 20", stats.Uploaded)
+	}
+}
+
+// slowReader is an io.Reader that sleeps before each read of at most 1024
+// bytes, to simulate an encoder that is slower than the store.
+type slowReader struct {
+	r     io.Reader
+	delay time.Duration
+}
+
+func (s *slowReader) Read(p []byte) (int, error) {
+	time.Sleep(s.delay)
+	return s.r.Read(p[:min(len(p), 1024)])
+}
+
+func TestEncodeToStore_PipelinedSlowEncoder(t *testing.T) {
+	ctx := context.Background()
+	var secret [eris.ConvergenceSecretSize]byte
+	content := make([]byte, 64*1024)
+	rand.New(rand.NewSource(1)).Read(content)
+
+	// The store is much faster than the encoder, but batches are still
+	// filled rather than being written a block at a time.
+	s := &countingStore{Store: NewMemory()}
+	enc := eris.NewEncoder(&slowReader{bytes.NewReader(content), time.Millisecond}, secret, 1024)
+	_, stats, err := EncodeToStore(ctx, s, enc, EncodeOptions{BatchSize: 8, MaxInFlightBytes: 1 << 20})
+	if err != nil {
+		t.Fatalf("EncodeToStore: %v", err)
+	}
+	if limit := (stats.Uploaded + 7) / 8 * 2; int64(s.hasMany) > limit {
+		t.Errorf("HasMany called %d times for %d blocks, want at most %d", s.hasMany, stats.Uploaded, limit)
+	}
+}
+
+// makeBlock returns a block of the given size with deterministic contents
+// derived from seed, along with its reference.
+func makeBlock(seed, size int) (eris.Reference, []byte) {
+	block := make([]byte, size)
+	rand.New(rand.NewSource(int64(seed))).Read(block)
+	return blake2b.Sum256(block), block
+}
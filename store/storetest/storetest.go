@@ -0,0 +1,276 @@
+// Package storetest implements support for testing implementations and users
+// of the store.Store interface.
+package storetest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+
+	"golang.org/x/crypto/blake2b"
+
+	"github.com/andrew-d/eris-go"
+	"github.com/andrew-d/eris-go/store"
+)
+
+// TestStore runs a conformance test suite against a store.Store
+// implementation. The newStore function is called to create a new, empty,
+// store for each subtest.
+//
+// The suite checks the basic Put/Get/Has semantics, that missing blocks are
+// reported with an error wrapping store.ErrNotFound, that both of the block
+// sizes defined by the specification can be stored, and that the store can be
+// used concurrently from multiple goroutines. If the store implements the
+// optional store.Lister or store.Deleter interfaces, those are tested too.
+func TestStore(t *testing.T, newStore func() store.Store) {
+	t.Run("PutGet", func(t *testing.T) {
+		testPutGet(t, newStore())
+	})
+	t.Run("NotFound", func(t *testing.T) {
+		testNotFound(t, newStore())
+	})
+	t.Run("PutExisting", func(t *testing.T) {
+		testPutExisting(t, newStore())
+	})
+	t.Run("LargeBlock", func(t *testing.T) {
+		testLargeBlock(t, newStore())
+	})
+	t.Run("CallerOwnsBuffers", func(t *testing.T) {
+		testCallerOwnsBuffers(t, newStore())
+	})
+	t.Run("Concurrent", func(t *testing.T) {
+		testConcurrent(t, newStore())
+	})
+	t.Run("List", func(t *testing.T) {
+		testList(t, newStore())
+	})
+	t.Run("Delete", func(t *testing.T) {
+		testDelete(t, newStore())
+	})
+}
+
+// MakeBlock returns a block of the given size with deterministic contents
+// derived from seed, along with its reference.
+func MakeBlock(seed, size int) (eris.Reference, []byte) {
+	block := make([]byte, size)
+	rand.New(rand.NewSource(int64(seed))).Read(block)
+	return blake2b.Sum256(block), block
+}
+
+func mustGet(t *testing.T, s store.Store, ref eris.Reference, size int) []byte {
+	t.Helper()
+	got, err := s.Get(context.Background(), ref, make([]byte, size))
+	if err != nil {
+		t.Fatalf("Get(%v): %v", ref, err)
+	}
+	return got
+}
+
+func mustHave(t *testing.T, s store.Store, ref eris.Reference, want bool) {
+	t.Helper()
+	has, err := s.Has(context.Background(), ref)
+	if err != nil {
+		t.Fatalf("Has(%v): %v", ref, err)
+	}
+	if has != want {
+		t.Fatalf("Has(%v) = %v, want %v", ref, has, want)
+	}
+}
+
+func testPutGet(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	for i := 0; i < 10; i++ {
+		ref, block := MakeBlock(i, eris.BlockSizeSmall)
+		mustHave(t, s, ref, false)
+		if err := s.Put(ctx, ref, block); err != nil {
+			t.Fatalf("Put(%v): %v", ref, err)
+		}
+		mustHave(t, s, ref, true)
+	}
+
+	// Verify that all blocks are still present and correct after
+	// writing other blocks.
+	for i := 0; i < 10; i++ {
+		ref, block := MakeBlock(i, eris.BlockSizeSmall)
+		if got := mustGet(t, s, ref, eris.BlockSizeSmall); !bytes.Equal(got, block) {
+			t.Errorf("Get(%v) returned wrong contents", ref)
+		}
+	}
+}
+
+func testNotFound(t *testing.T, s store.Store) {
+	ref, _ := MakeBlock(1, eris.BlockSizeSmall)
+	mustHave(t, s, ref, false)
+
+	_, err := s.Get(context.Background(), ref, make([]byte, eris.BlockSizeSmall))
+	if !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Get of missing block: got error %v, want one wrapping store.ErrNotFound", err)
+	}
+}
+
+func testPutExisting(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	ref, block := MakeBlock(1, eris.BlockSizeSmall)
+	for i := 0; i < 3; i++ {
+		if err := s.Put(ctx, ref, block); err != nil {
+			t.Fatalf("Put #%d: %v", i+1, err)
+		}
+	}
+	if got := mustGet(t, s, ref, eris.BlockSizeSmall); !bytes.Equal(got, block) {
+		t.Errorf("Get returned wrong contents after repeated Put")
+	}
+}
+
+func testLargeBlock(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	ref, block := MakeBlock(1, eris.BlockSizeLarge)
+	if err := s.Put(ctx, ref, block); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if got := mustGet(t, s, ref, eris.BlockSizeLarge); !bytes.Equal(got, block) {
+		t.Errorf("Get returned wrong contents for large block")
+	}
+}
+
+func testCallerOwnsBuffers(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	ref, block := MakeBlock(1, eris.BlockSizeSmall)
+	orig := bytes.Clone(block)
+
+	// Modifying the block after Put must not affect the stored copy.
+	if err := s.Put(ctx, ref, block); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	for i := range block {
+		block[i] = 0
+	}
+	got := mustGet(t, s, ref, eris.BlockSizeSmall)
+	if !bytes.Equal(got, orig) {
+		t.Fatalf("stored block was modified after Put returned")
+	}
+
+	// Modifying a block returned from Get must not affect the stored
+	// copy either.
+	for i := range got {
+		got[i] = 0
+	}
+	if got := mustGet(t, s, ref, eris.BlockSizeSmall); !bytes.Equal(got, orig) {
+		t.Fatalf("stored block was modified via slice returned from Get")
+	}
+}
+
+func testConcurrent(t *testing.T, s store.Store) {
+	const (
+		workers   = 8
+		perWorker = 20
+	)
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			buf := make([]byte, eris.BlockSizeSmall)
+			for i := 0; i < perWorker; i++ {
+				// Overlap the blocks written by each worker,
+				// so that we also test concurrent writes of
+				// the same block.
+				ref, block := MakeBlock(w*perWorker/2+i, eris.BlockSizeSmall)
+				if err := s.Put(ctx, ref, block); err != nil {
+					errs <- fmt.Errorf("Put: %w", err)
+					return
+				}
+				got, err := s.Get(ctx, ref, buf)
+				if err != nil {
+					errs <- fmt.Errorf("Get: %w", err)
+					return
+				}
+				if !bytes.Equal(got, block) {
+					errs <- fmt.Errorf("Get(%v) returned wrong contents", ref)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func testList(t *testing.T, s store.Store) {
+	lister, ok := s.(store.Lister)
+	if !ok {
+		t.Skip("store does not implement store.Lister")
+	}
+
+	ctx := context.Background()
+	want := make(map[eris.Reference]bool)
+	for i := 0; i < 20; i++ {
+		ref, block := MakeBlock(i, eris.BlockSizeSmall)
+		if err := s.Put(ctx, ref, block); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+		want[ref] = true
+	}
+
+	var got []eris.Reference
+	err := lister.List(ctx, func(ref eris.Reference) error {
+		got = append(got, ref)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(got) != len(want) {
+		t.Errorf("List returned %d references, want %d", len(got), len(want))
+	}
+	for i, ref := range got {
+		if !want[ref] {
+			t.Errorf("List returned unexpected reference %v", ref)
+		}
+		if i > 0 && bytes.Compare(got[i-1][:], ref[:]) >= 0 {
+			t.Errorf("List returned references out of order at index %d", i)
+		}
+	}
+
+	// Returning an error from the callback stops iteration.
+	errStop := errors.New("stop")
+	var calls int
+	err = lister.List(ctx, func(eris.Reference) error {
+		calls++
+		return errStop
+	})
+	if !errors.Is(err, errStop) || calls != 1 {
+		t.Errorf("List with error: got %v after %d calls, want errStop after 1", err, calls)
+	}
+}
+
+func testDelete(t *testing.T, s store.Store) {
+	deleter, ok := s.(store.Deleter)
+	if !ok {
+		t.Skip("store does not implement store.Deleter")
+	}
+
+	ctx := context.Background()
+	ref, block := MakeBlock(1, eris.BlockSizeSmall)
+	if err := s.Put(ctx, ref, block); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if err := deleter.Delete(ctx, ref); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	mustHave(t, s, ref, false)
+
+	// Deleting a missing block is not an error.
+	if err := deleter.Delete(ctx, ref); err != nil {
+		t.Errorf("Delete of missing block: %v", err)
+	}
+}
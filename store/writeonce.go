@@ -0,0 +1,65 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/andrew-d/eris-go"
+)
+
+// ErrCollision is returned by WriteOnceStore.Put when a block is written with
+// a reference that the store already has a different block for.
+var ErrCollision = errors.New("block differs from stored block with the same reference")
+
+// WriteOnceStore is a Store that checks that blocks written to it agree with
+// the blocks already stored; see NewWriteOnce.
+type WriteOnceStore struct {
+	Store
+}
+
+// NewWriteOnce returns a Store that wraps s, enforcing that a stored block is
+// never replaced by a different one. When Put is called with a reference that
+// already exists in s, the stored block is read back and compared with the new
+// one: if they are equal, Put does nothing, and otherwise it returns an error
+// wrapping ErrCollision without writing anything.
+//
+// Since a reference is the hash of its block, a mismatch means that either the
+// stored block or the new one is corrupt, or (far less likely) that the hash
+// function has been broken; the error says which. A plain Store would silently
+// keep or overwrite the stored block instead.
+//
+// Every Put of an existing block costs a Get, so this is best suited to stores
+// where writing existing blocks is rare, or where the extra safety matters
+// more than the cost.
+func NewWriteOnce(s Store) *WriteOnceStore {
+	return &WriteOnceStore{Store: s}
+}
+
+// Ping implements the Pinger interface.
+func (w *WriteOnceStore) Ping(ctx context.Context) error {
+	return Ping(ctx, w.Store)
+}
+
+// Put implements the Store interface.
+func (w *WriteOnceStore) Put(ctx context.Context, ref eris.Reference, block []byte) error {
+	stored, err := w.Store.Get(ctx, ref, make([]byte, eris.BlockSizeLarge))
+	if errors.Is(err, ErrNotFound) {
+		return w.Store.Put(ctx, ref, block)
+	} else if err != nil {
+		return err
+	}
+	if bytes.Equal(stored, block) {
+		return nil
+	}
+
+	switch {
+	case checkBlock(ref, stored) != nil:
+		return fmt.Errorf("%w: %v: stored block is corrupt", ErrCollision, ref)
+	case checkBlock(ref, block) != nil:
+		return fmt.Errorf("%w: %v: new block does not match its reference", ErrCollision, ref)
+	default:
+		return fmt.Errorf("%w: %v: both blocks match the reference (hash collision)", ErrCollision, ref)
+	}
+}
@@ -0,0 +1,58 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestWriteOnce(t *testing.T) {
+	ctx := context.Background()
+	mem := NewMemory()
+	s := NewWriteOnce(mem)
+
+	ref, block := makeBlock(1, 1024)
+	if err := s.Put(ctx, ref, block); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := s.Get(ctx, ref, nil); err != nil || !bytes.Equal(got, block) {
+		t.Fatalf("Get after Put: %v", err)
+	}
+
+	// Writing the same block again is fine.
+	if err := s.Put(ctx, ref, bytes.Clone(block)); err != nil {
+		t.Errorf("Put of identical block: %v", err)
+	}
+
+	// Writing a different block under the same reference isn't.
+	bad := bytes.Clone(block)
+	bad[0] ^= 1
+	err := s.Put(ctx, ref, bad)
+	if !errors.Is(err, ErrCollision) || !strings.Contains(err.Error(), "new block") {
+		t.Errorf("Put of different block: got %v, want new block collision", err)
+	}
+	if got, _ := mem.Get(ctx, ref, nil); !bytes.Equal(got, block) {
+		t.Error("stored block was overwritten")
+	}
+
+	// If the stored block is the corrupt one, the error says so.
+	ref2, block2 := makeBlock(2, 1024)
+	if err := mem.Put(ctx, ref2, bad); err != nil {
+		t.Fatal(err)
+	}
+	err = s.Put(ctx, ref2, block2)
+	if !errors.Is(err, ErrCollision) || !strings.Contains(err.Error(), "stored block is corrupt") {
+		t.Errorf("Put over corrupt block: got %v, want stored block collision", err)
+	}
+}
+
+func TestWriteOnce_GetError(t *testing.T) {
+	errBroken := errors.New("broken")
+	s := NewWriteOnce(failingStore{errBroken})
+	ref, block := makeBlock(1, 1024)
+	if err := s.Put(context.Background(), ref, block); !errors.Is(err, errBroken) {
+		t.Errorf("Put = %v, want %v", err, errBroken)
+	}
+}
@@ -52,7 +52,7 @@ func (trc testReadCapability) ReadCapability(t *testing.T) ReadCapability {
 
 // readTestVectors reads all test vectors from disk and returns them as an
 // iterator over (name, vector) pairs.
-func readTestVectors(t *testing.T) iter.Seq2[string, *testVector] {
+func readTestVectors(t testing.TB) iter.Seq2[string, *testVector] {
 	// Read all test vectors from disk.
 	vectors, err := os.ReadDir("testdata/test-vectors")
 	if err != nil {
@@ -269,6 +269,10 @@ func testDecode(t *testing.T, vector *testVector) {
 				t.Errorf("empty block")
 			}
 			decoded = append(decoded, curr...)
+
+			if off := dec.Offset(); off != int64(len(decoded)) {
+				t.Errorf("Offset() = %d, want %d", off, len(decoded))
+			}
 		}
 
 		err := dec.Err()
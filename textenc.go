@@ -0,0 +1,118 @@
+package eris
+
+import (
+	"encoding/base32"
+	"encoding/hex"
+	"fmt"
+)
+
+// Encoding is a textual encoding of references, keys and read capabilities.
+type Encoding int
+
+const (
+	// EncodingBase32 is the unpadded Base32 encoding of RFC 4648, which
+	// is the encoding used by the ERIS specification.
+	EncodingBase32 Encoding = iota
+	// EncodingZBase32 is the unpadded z-base-32 encoding, which uses only
+	// lowercase letters and digits and so suits case-insensitive
+	// filesystems.
+	EncodingZBase32
+	// EncodingHex is the lowercase hexadecimal encoding.
+	EncodingHex
+)
+
+// From the spec:
+//
+//	A read capability can be encoded as an URN [RFC8141] using the
+//	namespace identifier eris and the unpadded Base32 [RFC4648] encoding of
+//	the read capability as namespace specific string.
+var base32Enc = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+var zbase32Enc = base32.NewEncoding("ybndrfg8ejkmcpqxot1uwisza345h769").WithPadding(base32.NoPadding)
+
+// String implements the fmt.Stringer interface.
+func (e Encoding) String() string {
+	switch e {
+	case EncodingBase32:
+		return "base32"
+	case EncodingZBase32:
+		return "zbase32"
+	case EncodingHex:
+		return "hex"
+	default:
+		return fmt.Sprintf("Encoding(%d)", int(e))
+	}
+}
+
+// ParseEncoding returns the Encoding with the given name, as returned by
+// String.
+func ParseEncoding(name string) (Encoding, error) {
+	for _, e := range []Encoding{EncodingBase32, EncodingZBase32, EncodingHex} {
+		if e.String() == name {
+			return e, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown encoding %q", name)
+}
+
+// encode returns the encoding of b. It panics if e isn't a valid Encoding.
+func (e Encoding) encode(b []byte) string {
+	switch e {
+	case EncodingBase32:
+		return base32Enc.EncodeToString(b)
+	case EncodingZBase32:
+		return zbase32Enc.EncodeToString(b)
+	case EncodingHex:
+		return hex.EncodeToString(b)
+	default:
+		panic("eris: invalid encoding " + e.String())
+	}
+}
+
+// encodedLen returns the length of the encoding of n bytes.
+func (e Encoding) encodedLen(n int) int {
+	if e == EncodingHex {
+		return hex.EncodedLen(n)
+	}
+	return base32Enc.EncodedLen(n)
+}
+
+// decode decodes s into dst, which must be exactly the size of the encoded
+// data. It returns false if s isn't a canonical encoding of len(dst) bytes.
+func (e Encoding) decode(dst []byte, s string) bool {
+	// The decoders write everything they decode into dst, so anything
+	// longer would overrun it.
+	if len(s) != e.encodedLen(len(dst)) {
+		return false
+	}
+	var n int
+	var err error
+	switch e {
+	case EncodingBase32:
+		n, err = base32Enc.Decode(dst, []byte(s))
+	case EncodingZBase32:
+		n, err = zbase32Enc.Decode(dst, []byte(s))
+	case EncodingHex:
+		n, err = hex.Decode(dst, []byte(s))
+	}
+	if err != nil || n != len(dst) {
+		return false
+	}
+	// The last character of a base32 encoding can have unused bits,
+	// which must be zero so that each value has only one encoding. Hex
+	// digits may be either case.
+	return e == EncodingHex || e.encode(dst) == s
+}
+
+// decodeText decodes s, in any of the supported encodings, into dst; s must
+// encode exactly len(dst) bytes. Since the Base32 and z-base-32 encodings have
+// the same length, a string that is valid in both is decoded as Base32, which
+// is the encoding defined by the specification.
+func decodeText(dst []byte, s string) error {
+	for _, e := range []Encoding{EncodingBase32, EncodingZBase32, EncodingHex} {
+		if e.decode(dst, s) {
+			return nil
+		}
+	}
+	return fmt.Errorf("not a valid base32, zbase32 or hex encoding of %d bytes", len(dst))
+}
@@ -0,0 +1,49 @@
+package eris
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEncoding(t *testing.T) {
+	content := randomContent(5000)
+	rc, _ := encodeToMap(t, content, BlockSizeSmall)
+
+	for _, enc := range []Encoding{EncodingBase32, EncodingZBase32, EncodingHex} {
+		t.Run(enc.String(), func(t *testing.T) {
+			if got, err := ParseEncoding(enc.String()); err != nil || got != enc {
+				t.Errorf("ParseEncoding(%q) = %v, %v", enc.String(), got, err)
+			}
+
+			s := rc.Root.Reference.Encode(enc)
+			if enc != EncodingBase32 && strings.ToLower(s) != s {
+				t.Errorf("Encode(%v) = %q, want lowercase", enc, s)
+			}
+			if ref, err := ParseReference(s); err != nil || ref != rc.Root.Reference {
+				t.Errorf("ParseReference(%q) = %v, %v", s, ref, err)
+			}
+			if key, err := ParseKey(rc.Root.Key.Encode(enc)); err != nil || key != rc.Root.Key {
+				t.Errorf("ParseKey: %v, %v", key, err)
+			}
+
+			urn, err := rc.URNWithEncoding(enc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := ParseReadCapabilityURN(urn)
+			if err != nil {
+				t.Fatalf("ParseReadCapabilityURN(%q): %v", urn, err)
+			}
+			if !got.Equal(rc) {
+				t.Errorf("ParseReadCapabilityURN(%q) = %+v, want %+v", urn, got, rc)
+			}
+		})
+	}
+
+	if urn, _ := rc.URNWithEncoding(EncodingBase32); urn != rc.MustURN() {
+		t.Errorf("URNWithEncoding(EncodingBase32) = %q, want %q", urn, rc.MustURN())
+	}
+	if _, err := ParseEncoding("base64"); err == nil {
+		t.Error("ParseEncoding(base64) succeeded")
+	}
+}
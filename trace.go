@@ -0,0 +1,105 @@
+package eris
+
+import "context"
+
+// Names of the spans started by a Tracer.
+const (
+	// SpanEncode covers an Encoder, from the first call to Next until it
+	// returns false.
+	SpanEncode = "eris.encode"
+	// SpanDecode covers a Decoder, from the first call to Next (or
+	// SkipTo) until Next returns false.
+	SpanDecode = "eris.decode"
+	// SpanFetch covers a single call to a Decoder's fetch function, and
+	// is a child of the SpanDecode span.
+	SpanFetch = "eris.fetch"
+)
+
+// Attribute is a key-value pair that describes a span. The value is either an
+// int64 or a string.
+type Attribute struct {
+	Key   string
+	Value any
+}
+
+// Tracer receives spans that show where the time goes when encoding and
+// decoding, for example to export them to a distributed tracing system; see
+// WithEncoderTracer and WithDecoderTracer. This package has no dependency on
+// any tracing library, so an adapter is needed to use one; for OpenTelemetry,
+// StartSpan maps directly onto trace.Tracer.Start, with the parent span's
+// context.
+//
+// The following attributes are set:
+//
+//	eris.block_size        encode and decode: the block size
+//	eris.level             encode (at the end): the level of the root
+//	                       decode: the level of the root
+//	                       fetch: the level of the block
+//	eris.reference         fetch: the reference of the block
+//	eris.leaves            encode (at the end): counts of the blocks in the
+//	eris.internal_nodes      tree, as in EncoderStats
+//	eris.duplicate_leaves
+//	eris.duplicate_internal_nodes
+//	eris.blocks_fetched    decode (at the end): see Decoder.BlocksFetched
+//	eris.bytes             decode (at the end): the bytes of content returned
+//
+// A Tracer used with more than one Encoder or Decoder at once must be safe for
+// concurrent use.
+type Tracer interface {
+	// StartSpan starts a span with the given name and attributes. The
+	// parent is the span that the new span is part of, or nil; ctx is the
+	// context of the operation, which may carry a parent span of its
+	// own.
+	StartSpan(ctx context.Context, name string, parent Span, attrs ...Attribute) Span
+}
+
+// Span is a span started by a Tracer.
+type Span interface {
+	// End ends the span, with the error that the operation failed with
+	// (if any), and any attributes that are only known at the end.
+	End(err error, attrs ...Attribute)
+}
+
+// WithEncoderTracer returns an EncoderOption that reports a SpanEncode span to
+// t. Its context is the one given with WithContext, if any.
+func WithEncoderTracer(t Tracer) EncoderOption {
+	return func(e *Encoder) {
+		e.trace.tracer = t
+	}
+}
+
+// WithDecoderTracer returns a DecoderOption that reports a SpanDecode span to
+// t, with a SpanFetch span for every block that is fetched. The contexts of
+// the spans are those passed to the first call to Next (or SkipTo) and to the
+// call that fetched the block, respectively.
+func WithDecoderTracer(t Tracer) DecoderOption {
+	return func(d *Decoder) {
+		d.trace.tracer = t
+	}
+}
+
+// tracedOp tracks the span of a traced Encoder or Decoder.
+type tracedOp struct {
+	tracer Tracer
+	span   Span
+	ended  bool
+}
+
+// start starts the span, unless there is no tracer or it has already been
+// started.
+func (t *tracedOp) start(ctx context.Context, name string, attrs ...Attribute) {
+	if t.tracer == nil || t.span != nil || t.ended {
+		return
+	}
+	t.span = t.tracer.StartSpan(ctx, name, nil, attrs...)
+}
+
+// end ends the span, if it was started and hasn't already ended.
+func (t *tracedOp) end(err error, attrs ...Attribute) {
+	if t.span == nil {
+		return
+	}
+	t.span.End(err, attrs...)
+	t.span = nil
+	t.ended = true
+}
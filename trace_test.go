@@ -0,0 +1,120 @@
+package eris
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"testing"
+)
+
+// recordedSpan is a span recorded by a recordingTracer.
+type recordedSpan struct {
+	t      *recordingTracer
+	name   string
+	parent *recordedSpan
+	attrs  map[string]any
+	ended  bool
+	err    error
+}
+
+func (s *recordedSpan) End(err error, attrs ...Attribute) {
+	s.t.mu.Lock()
+	defer s.t.mu.Unlock()
+	s.ended = true
+	s.err = err
+	for _, a := range attrs {
+		s.attrs[a.Key] = a.Value
+	}
+}
+
+// recordingTracer is a Tracer that records every span.
+type recordingTracer struct {
+	mu    sync.Mutex
+	spans []*recordedSpan
+}
+
+func (t *recordingTracer) StartSpan(ctx context.Context, name string, parent Span, attrs ...Attribute) Span {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := &recordedSpan{t: t, name: name, attrs: make(map[string]any)}
+	if parent != nil {
+		s.parent = parent.(*recordedSpan)
+	}
+	for _, a := range attrs {
+		s.attrs[a.Key] = a.Value
+	}
+	t.spans = append(t.spans, s)
+	return s
+}
+
+func TestEncoderTracer(t *testing.T) {
+	var secret [ConvergenceSecretSize]byte
+	content := bytes.Repeat(randomContent(1024), 20)
+
+	tracer := &recordingTracer{}
+	enc := NewEncoder(bytes.NewReader(content), secret, 1024, WithEncoderTracer(tracer))
+	for enc.Next() {
+	}
+	if err := enc.Err(); err != nil {
+		t.Fatal(err)
+	}
+	enc.Next()
+
+	if len(tracer.spans) != 1 {
+		t.Fatalf("got %d spans, want 1", len(tracer.spans))
+	}
+	s := tracer.spans[0]
+	stats := enc.Stats()
+	if s.name != SpanEncode || !s.ended || s.err != nil {
+		t.Errorf("span = %+v, want ended %s span", s, SpanEncode)
+	}
+	if s.attrs["eris.block_size"] != int64(1024) || s.attrs["eris.level"] != int64(enc.Capability().Level) ||
+		s.attrs["eris.leaves"] != stats.Leaves || s.attrs["eris.duplicate_leaves"] != stats.DuplicateLeaves {
+		t.Errorf("span attributes = %v, stats = %+v", s.attrs, stats)
+	}
+}
+
+func TestDecoderTracer(t *testing.T) {
+	ctx := context.Background()
+	content := randomContent(100*1024 + 1)
+	rc, blocks := encodeToMap(t, content, 1024)
+
+	tracer := &recordingTracer{}
+	dec := NewDecoder(mapFetch(blocks, nil), rc, WithDecoderTracer(tracer))
+	var got []byte
+	for dec.Next(ctx) {
+		got = append(got, dec.Block()...)
+	}
+	if err := dec.Err(); err != nil || !bytes.Equal(got, content) {
+		t.Fatalf("decoding failed: %v", err)
+	}
+
+	decode := tracer.spans[0]
+	if decode.name != SpanDecode || !decode.ended || decode.err != nil {
+		t.Fatalf("first span = %+v, want ended %s span", decode, SpanDecode)
+	}
+	if decode.attrs["eris.bytes"] != int64(len(content)) || decode.attrs["eris.blocks_fetched"] != dec.BlocksFetched() {
+		t.Errorf("decode span attributes = %v", decode.attrs)
+	}
+	fetches := tracer.spans[1:]
+	if int64(len(fetches)) != dec.BlocksFetched() {
+		t.Errorf("got %d fetch spans, want %d", len(fetches), dec.BlocksFetched())
+	}
+	for _, s := range fetches {
+		if s.name != SpanFetch || s.parent != decode || !s.ended || s.err != nil {
+			t.Fatalf("fetch span = %+v, want ended child of decode span", s)
+		}
+	}
+
+	// A failed fetch ends both spans with the error.
+	for ref := range blocks {
+		delete(blocks, ref)
+	}
+	tracer = &recordingTracer{}
+	dec = NewDecoder(mapFetch(blocks, nil), rc, WithDecoderTracer(tracer))
+	for dec.Next(ctx) {
+	}
+	if len(tracer.spans) != 2 || tracer.spans[0].err == nil || tracer.spans[1].err == nil {
+		t.Errorf("spans = %+v, want decode and fetch spans with errors", tracer.spans)
+	}
+}
@@ -1,9 +1,11 @@
 package eris
 
 import (
+	"bytes"
+	"cmp"
 	"crypto/subtle"
-	"encoding/base32"
 	"fmt"
+	"strings"
 
 	"golang.org/x/crypto/blake2b"
 	"golang.org/x/crypto/chacha20"
@@ -20,6 +22,10 @@ const (
 	ConvergenceSecretSize = 32
 
 	referenceKeyLen = ReferenceSize + KeySize
+
+	// readCapabilityLen is the length of the binary representation of a
+	// ReadCapability: the block size, level and root reference-key pair.
+	readCapabilityLen = 2 + referenceKeyLen
 )
 
 // Reference is a hash of an encrypted block of data. It is defined in the ERIS
@@ -38,22 +44,74 @@ func (r Reference) isZero() bool {
 	return true
 }
 
+// Equal reports whether two References are equal. The comparison takes
+// constant time.
+func (r Reference) Equal(other Reference) bool {
+	return subtle.ConstantTimeCompare(r[:], other[:]) == 1
+}
+
 // String implements the fmt.Stringer interface.
 func (r Reference) String() string {
 	return fmt.Sprintf("%x", r[:])
 }
 
+// Base32 returns the unpadded Base32 encoding of the reference, as used in
+// URNs by the ERIS specification.
+func (r Reference) Base32() string {
+	return EncodingBase32.encode(r[:])
+}
+
+// Encode returns the reference encoded with enc.
+func (r Reference) Encode(enc Encoding) string {
+	return enc.encode(r[:])
+}
+
+// ParseReference parses a reference from any of the encodings returned by
+// Encode; the encoding is detected automatically. The hexadecimal encoding
+// is the one returned by String.
+func ParseReference(s string) (ref Reference, err error) {
+	if err := decodeText(ref[:], s); err != nil {
+		return ref, fmt.Errorf("invalid reference: %w", err)
+	}
+	return ref, nil
+}
+
 // Key is the encryption key required to decrypt the block of data. It is
 // defined in the ERIS specification as:
 //
 //	key is the ChaCha20 key to decrypt the block (32 bytes)
 type Key [KeySize]byte
 
+// Equal reports whether two Keys are equal. The comparison takes constant
+// time.
+func (k Key) Equal(other Key) bool {
+	return subtle.ConstantTimeCompare(k[:], other[:]) == 1
+}
+
 // String implements the fmt.Stringer interface.
 func (k Key) String() string {
 	return fmt.Sprintf("%x", k[:])
 }
 
+// Base32 returns the unpadded Base32 encoding of the key.
+func (k Key) Base32() string {
+	return EncodingBase32.encode(k[:])
+}
+
+// Encode returns the key encoded with enc.
+func (k Key) Encode(enc Encoding) string {
+	return enc.encode(k[:])
+}
+
+// ParseKey parses a key from any of the encodings returned by Encode; the
+// encoding is detected automatically.
+func ParseKey(s string) (key Key, err error) {
+	if err := decodeText(key[:], s); err != nil {
+		return key, fmt.Errorf("invalid key: %w", err)
+	}
+	return key, nil
+}
+
 // ReferenceKeyPair represents a pairing of a block reference and the key
 // required to decrypt the block.
 type ReferenceKeyPair struct {
@@ -61,12 +119,14 @@ type ReferenceKeyPair struct {
 	Key       Key
 }
 
-// Equal returns true if the two ReferenceKeyPairs are equal.
+// Equal returns true if the two ReferenceKeyPairs are equal. The comparison
+// takes constant time.
 func (rk ReferenceKeyPair) Equal(other ReferenceKeyPair) bool {
-	// Use crypto/subtle to do a constant-time comparison of the two
-	// values, just to be safe.
-	return subtle.ConstantTimeCompare(rk.Reference[:], other.Reference[:]) == 1 &&
-		subtle.ConstantTimeCompare(rk.Key[:], other.Key[:]) == 1
+	// Evaluate both comparisons, rather than short-circuiting, so that
+	// the timing doesn't reveal which of the two differed.
+	refEqual := rk.Reference.Equal(other.Reference)
+	keyEqual := rk.Key.Equal(other.Key)
+	return refEqual && keyEqual
 }
 
 // ReadCapability is all the information required to read a piece of content
@@ -88,6 +148,66 @@ func (rc ReadCapability) Equal(other ReadCapability) bool {
 		rc.Root.Equal(other.Root)
 }
 
+// Compare returns -1, 0 or +1 depending on whether rc sorts before, the same
+// as, or after other. Capabilities are ordered by block size, then level, then
+// root reference and then root key, which is the same order as bytes.Compare
+// gives their binary representations; so capabilities can be sorted in memory
+// or used as keys in a sorted index interchangeably. Unlike Equal, Compare
+// doesn't take constant time.
+func (rc ReadCapability) Compare(other ReadCapability) int {
+	if c := cmp.Compare(rc.BlockSize, other.BlockSize); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(rc.Level, other.Level); c != 0 {
+		return c
+	}
+	if c := bytes.Compare(rc.Root.Reference[:], other.Root.Reference[:]); c != 0 {
+		return c
+	}
+	return bytes.Compare(rc.Root.Key[:], other.Root.Key[:])
+}
+
+// Validate checks that rc is well-formed: that its block size is one of those
+// defined by the specification, that its level is no higher than is needed
+// for the largest content that can be decoded, and that its root reference and
+// key are not zero. It returns an error wrapping ErrInvalidCapability if not.
+//
+// A valid capability can still fail to decode, since Validate doesn't fetch
+// any blocks; callers that decode untrusted capabilities should also set
+// limits on the Decoder with WithMaxLevel, WithMaxBytes or WithMaxBlocks.
+func (rc ReadCapability) Validate() error {
+	if rc.BlockSize != BlockSizeSmall && rc.BlockSize != BlockSizeLarge {
+		return fmt.Errorf("%w: unsupported block size %d", ErrInvalidCapability, rc.BlockSize)
+	}
+	if max := maxLevel(rc.BlockSize); rc.Level < 0 || rc.Level > max {
+		return fmt.Errorf("%w: level %d is not between 0 and %d", ErrInvalidCapability, rc.Level, max)
+	}
+	if rc.Root.Reference.isZero() {
+		return fmt.Errorf("%w: root reference is zero", ErrInvalidCapability)
+	}
+	if rc.Root.Key == (Key{}) {
+		return fmt.Errorf("%w: root key is zero", ErrInvalidCapability)
+	}
+	return nil
+}
+
+// maxLevel returns the level of the tree needed to encode the largest content,
+// of 2^63-1 bytes, with the given block size. The encoder never produces a tree
+// higher than it needs to, so no valid capability has a higher level.
+func maxLevel(blockSize int) int {
+	// A tree of level L holds up to blockSize*arity^L bytes of content.
+	const maxContent = 1<<63 - 1
+	n := int64(arity(blockSize))
+	level := 0
+	for capacity := int64(blockSize); capacity < maxContent; capacity *= n {
+		level++
+		if capacity > maxContent/n {
+			break
+		}
+	}
+	return level
+}
+
 // AppendBinary appends the binary representation of the ReadCapability to the
 // given byte slice and returns it, or any error that occurs.
 //
@@ -122,7 +242,9 @@ func (rc ReadCapability) AppendBinary(data []byte) ([]byte, error) {
 // MarshalBinary implements the encoding.BinaryMarshaler interface.
 //
 // The binary representation of a ReadCapability is as per the ERIS
-// specification, section 2.6.
+// specification, section 2.6. It is canonical: two capabilities are Equal if
+// and only if their binary representations are equal, so it is suitable for
+// use as a database key.
 func (rc ReadCapability) MarshalBinary() (data []byte, err error) {
 	return rc.AppendBinary(nil)
 }
@@ -153,21 +275,22 @@ func (rc *ReadCapability) UnmarshalBinary(data []byte) error {
 	return nil
 }
 
-// From the spec:
-//
-//	A read capability can be encoded as an URN [RFC8141] using the
-//	namespace identifier eris and the unpadded Base32 [RFC4648] encoding of
-//	the read capability as namespace specific string.
-var base32Enc = base32.StdEncoding.WithPadding(base32.NoPadding)
-
 // URN returns the URN for the ReadCapability, as defined in the ERIS
 // specification, section 2.7.
 func (rc ReadCapability) URN() (string, error) {
+	return rc.URNWithEncoding(EncodingBase32)
+}
+
+// URNWithEncoding is like URN, but encodes the read capability with enc rather
+// than the Base32 encoding required by the specification. The resulting URN
+// can be parsed by ParseReadCapabilityURN, but might not be understood by
+// other implementations.
+func (rc ReadCapability) URNWithEncoding(enc Encoding) (string, error) {
 	data, err := rc.MarshalBinary()
 	if err != nil {
 		return "", err
 	}
-	return "urn:eris:" + base32Enc.EncodeToString(data), nil
+	return "urn:eris:" + enc.encode(data), nil
 }
 
 // MustURN is like URN, but panics if an error occurs.
@@ -180,14 +303,76 @@ func (rc ReadCapability) MustURN() string {
 }
 
 // ParseReadCapabilityURN parses a URN for a ReadCapability, as defined in the
-// ERIS specification, section 2.7.
+// ERIS specification, section 2.7. It also accepts the URNs returned by
+// URNWithEncoding; the encoding is detected automatically.
 func ParseReadCapabilityURN(urn string) (rc ReadCapability, err error) {
-	if urn[:9] != "urn:eris:" {
-		return rc, fmt.Errorf("invalid URN prefix: %q", urn[:9])
+	encoded, ok := strings.CutPrefix(urn, "urn:eris:")
+	if !ok {
+		return rc, fmt.Errorf("invalid URN prefix: %q", urn[:min(len(urn), 9)])
 	}
-	data, err := base32Enc.DecodeString(urn[9:])
-	if err != nil {
+	var data [readCapabilityLen]byte
+	if err := decodeText(data[:], encoded); err != nil {
 		return rc, err
 	}
-	return rc, rc.UnmarshalBinary(data)
+	return rc, rc.UnmarshalBinary(data[:])
+}
+
+// ParseReadCapabilityURNLenient is like ParseReadCapabilityURN, but accepts
+// URNs that have been mangled by being copied from an email or chat message.
+// It ignores whitespace anywhere in the URN, surrounding angle brackets or
+// quotes, a trailing slash or fragment, and the case of the "urn:eris:"
+// prefix and of the encoded read capability.
+//
+// ParseReadCapabilityURN should be preferred wherever URNs are produced by
+// programs, since it accepts only one spelling of each URN.
+func ParseReadCapabilityURNLenient(urn string) (ReadCapability, error) {
+	s := strings.Join(strings.Fields(urn), "")
+	for _, pair := range []string{"<>", `""`, "''"} {
+		if len(s) >= 2 && s[0] == pair[0] && s[len(s)-1] == pair[1] {
+			s = s[1 : len(s)-1]
+		}
+	}
+	s, _, _ = strings.Cut(s, "#")
+	s = strings.TrimRight(s, "/")
+
+	// RFC 8141 makes the "urn" prefix and namespace identifier
+	// case-insensitive.
+	const prefix = "urn:eris:"
+	if len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix) {
+		s = prefix + s[len(prefix):]
+	}
+
+	rc, err := ParseReadCapabilityURN(s)
+	if err == nil {
+		return rc, nil
+	}
+	if !strings.HasPrefix(s, prefix) {
+		return rc, err
+	}
+
+	// Base32 is uppercase, and the other encodings are lowercase, so try
+	// each case in turn.
+	encoded := s[len(prefix):]
+	for _, alt := range []string{strings.ToUpper(encoded), strings.ToLower(encoded)} {
+		if rc, altErr := ParseReadCapabilityURN(prefix + alt); altErr == nil {
+			return rc, nil
+		}
+	}
+	return rc, err
+}
+
+// NormalizeURN parses a URN as ParseReadCapabilityURNLenient does, checks that
+// the capability is valid with Validate, and returns the canonical URN for it,
+// as returned by ReadCapability.URN. Every spelling of the same capability
+// normalizes to the same URN, so URNs from untrusted sources can be normalized
+// before being stored or compared as strings.
+func NormalizeURN(urn string) (string, error) {
+	rc, err := ParseReadCapabilityURNLenient(urn)
+	if err != nil {
+		return "", err
+	}
+	if err := rc.Validate(); err != nil {
+		return "", err
+	}
+	return rc.URN()
 }
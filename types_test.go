@@ -0,0 +1,246 @@
+package eris
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestEqual(t *testing.T) {
+	a := ReferenceKeyPair{Reference: Reference{1, 2, 3}, Key: Key{4, 5, 6}}
+
+	otherRef, otherKey := a, a
+	otherRef.Reference[31] = 1
+	otherKey.Key[31] = 1
+
+	testCases := []struct {
+		name   string
+		b      ReferenceKeyPair
+		refEq  bool
+		keyEq  bool
+		pairEq bool
+	}{
+		{"same", a, true, true, true},
+		{"different reference", otherRef, false, true, false},
+		{"different key", otherKey, true, false, false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := a.Reference.Equal(tc.b.Reference); got != tc.refEq {
+				t.Errorf("Reference.Equal = %v, want %v", got, tc.refEq)
+			}
+			if got := a.Key.Equal(tc.b.Key); got != tc.keyEq {
+				t.Errorf("Key.Equal = %v, want %v", got, tc.keyEq)
+			}
+			if got := a.Equal(tc.b); got != tc.pairEq {
+				t.Errorf("ReferenceKeyPair.Equal = %v, want %v", got, tc.pairEq)
+			}
+		})
+	}
+}
+
+func TestVerifyNodeKey(t *testing.T) {
+	node := make([]byte, 1024)
+	node[0] = 1
+	_, refKey := encryptInternalNode(node, 1, [ConvergenceSecretSize]byte{})
+
+	if !verifyNodeKey(node, refKey.Key) {
+		t.Errorf("verifyNodeKey rejected the correct key")
+	}
+	refKey.Key[0] ^= 1
+	if verifyNodeKey(node, refKey.Key) {
+		t.Errorf("verifyNodeKey accepted an incorrect key")
+	}
+}
+
+func TestReadCapabilityValidate(t *testing.T) {
+	valid := ReadCapability{
+		BlockSize: BlockSizeSmall,
+		Level:     2,
+		Root:      ReferenceKeyPair{Reference: Reference{1}, Key: Key{2}},
+	}
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("Validate() = %v for valid capability", err)
+	}
+
+	testCases := []struct {
+		name   string
+		modify func(*ReadCapability)
+	}{
+		{"block size", func(rc *ReadCapability) { rc.BlockSize = 4096 }},
+		{"negative level", func(rc *ReadCapability) { rc.Level = -1 }},
+		{"small level", func(rc *ReadCapability) { rc.Level = 15 }},
+		{"large level", func(rc *ReadCapability) { rc.BlockSize, rc.Level = BlockSizeLarge, 7 }},
+		{"zero reference", func(rc *ReadCapability) { rc.Root.Reference = Reference{} }},
+		{"zero key", func(rc *ReadCapability) { rc.Root.Key = Key{} }},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rc := valid
+			tc.modify(&rc)
+			if err := rc.Validate(); !errors.Is(err, ErrInvalidCapability) {
+				t.Errorf("Validate() = %v, want ErrInvalidCapability", err)
+			}
+		})
+	}
+
+	// The highest levels are still valid.
+	for _, rc := range []ReadCapability{
+		{BlockSize: BlockSizeSmall, Level: 14, Root: valid.Root},
+		{BlockSize: BlockSizeLarge, Level: 6, Root: valid.Root},
+	} {
+		if err := rc.Validate(); err != nil {
+			t.Errorf("Validate() = %v for level %d", err, rc.Level)
+		}
+	}
+}
+
+func TestParseReference(t *testing.T) {
+	var ref Reference
+	for i := range ref {
+		ref[i] = byte(i * 7)
+	}
+
+	for _, s := range []string{ref.Base32(), ref.String(), strings.ToUpper(ref.String())} {
+		got, err := ParseReference(s)
+		if err != nil {
+			t.Errorf("ParseReference(%q): %v", s, err)
+		} else if got != ref {
+			t.Errorf("ParseReference(%q) = %v, want %v", s, got, ref)
+		}
+	}
+
+	b32 := ref.Base32()
+	for _, s := range []string{
+		"",
+		b32[:len(b32)-1],
+		b32 + "A",
+		ref.String()[:63],
+		ref.String() + "0",
+		b32[:10] + "!" + b32[11:],
+		b32[:len(b32)-1] + "B", // non-zero trailing bits
+		strings.Repeat("g", 64),
+	} {
+		if _, err := ParseReference(s); err == nil {
+			t.Errorf("ParseReference(%q) succeeded", s)
+		}
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	key := Key{1, 2, 3, 31: 0xff}
+	for _, s := range []string{key.Base32(), key.String()} {
+		got, err := ParseKey(s)
+		if err != nil {
+			t.Errorf("ParseKey(%q): %v", s, err)
+		} else if got != key {
+			t.Errorf("ParseKey(%q) = %v, want %v", s, got, key)
+		}
+	}
+	if _, err := ParseKey(key.Base32()[1:]); err == nil {
+		t.Error("ParseKey succeeded for short input")
+	}
+}
+
+func TestParseReadCapabilityURNLenient(t *testing.T) {
+	rc, _ := encodeToMap(t, randomContent(3000), BlockSizeSmall)
+	urn := rc.MustURN()
+	zurn, _ := rc.URNWithEncoding(EncodingZBase32)
+	encoded := strings.TrimPrefix(urn, "urn:eris:")
+
+	for _, s := range []string{
+		urn,
+		"  " + urn + "\n",
+		"<" + urn + ">",
+		`"` + urn + `"`,
+		urn + "/",
+		urn + "#section",
+		"URN:ERIS:" + encoded,
+		"urn:eris:" + strings.ToLower(encoded),
+		"urn:eris:" + encoded[:40] + "\n  " + encoded[40:],
+		strings.ToUpper(zurn),
+	} {
+		got, err := ParseReadCapabilityURNLenient(s)
+		if err != nil {
+			t.Errorf("ParseReadCapabilityURNLenient(%q): %v", s, err)
+		} else if !got.Equal(rc) {
+			t.Errorf("ParseReadCapabilityURNLenient(%q) = %+v, want %+v", s, got, rc)
+		}
+	}
+
+	for _, s := range []string{"", "urn:eris:", "urn:other:" + encoded, urn[:len(urn)-3]} {
+		if _, err := ParseReadCapabilityURNLenient(s); err == nil {
+			t.Errorf("ParseReadCapabilityURNLenient(%q) succeeded", s)
+		}
+	}
+
+	// The strict parser rejects mangled URNs.
+	if _, err := ParseReadCapabilityURN(urn + "/"); err == nil {
+		t.Error("ParseReadCapabilityURN accepted a trailing slash")
+	}
+}
+
+func TestReadCapabilityCompare(t *testing.T) {
+	// Capabilities that differ in each field, so that every comparison
+	// in Compare is exercised.
+	var rcs []ReadCapability
+	for _, bs := range []int{BlockSizeSmall, BlockSizeLarge} {
+		for _, level := range []int{0, 1, 2} {
+			for _, b := range []byte{0, 1, 0xff} {
+				rc := ReadCapability{BlockSize: bs, Level: level}
+				rc.Root.Reference[0] = b
+				rc.Root.Key[31] = b ^ 0x80
+				rcs = append(rcs, rc)
+				rc.Root.Key[31] = b
+				rcs = append(rcs, rc)
+			}
+		}
+	}
+
+	for _, a := range rcs {
+		aData, _ := a.MarshalBinary()
+		for _, b := range rcs {
+			bData, _ := b.MarshalBinary()
+			got, want := a.Compare(b), bytes.Compare(aData, bData)
+			if got != want {
+				t.Fatalf("Compare(%x, %x) = %d, want %d", aData, bData, got, want)
+			}
+			if (got == 0) != a.Equal(b) {
+				t.Fatalf("Compare and Equal disagree for %x and %x", aData, bData)
+			}
+		}
+	}
+}
+
+func TestNormalizeURN(t *testing.T) {
+	rc, _ := encodeToMap(t, randomContent(5000), BlockSizeSmall)
+	want := rc.MustURN()
+
+	zbase32, _ := rc.URNWithEncoding(EncodingZBase32)
+	hex, _ := rc.URNWithEncoding(EncodingHex)
+	for _, urn := range []string{
+		want,
+		zbase32,
+		hex,
+		"<" + want + ">",
+		strings.ToLower(want),
+		"URN:ERIS:" + want[len("urn:eris:"):] + "\n",
+	} {
+		got, err := NormalizeURN(urn)
+		if err != nil {
+			t.Errorf("NormalizeURN(%q): %v", urn, err)
+		} else if got != want {
+			t.Errorf("NormalizeURN(%q) = %q, want %q", urn, got, want)
+		}
+	}
+
+	// A URN that parses, but for an invalid capability.
+	invalid := ReadCapability{BlockSize: BlockSizeSmall}
+	if _, err := NormalizeURN(invalid.MustURN()); !errors.Is(err, ErrInvalidCapability) {
+		t.Errorf("NormalizeURN of invalid capability: got %v, want %v", err, ErrInvalidCapability)
+	}
+	if _, err := NormalizeURN("urn:eris:nope"); err == nil {
+		t.Error("NormalizeURN of garbage succeeded")
+	}
+}
@@ -0,0 +1,71 @@
+package eris
+
+import (
+	"context"
+	"errors"
+)
+
+// VerifyReport contains the results of verifying an ERIS tree with Verify.
+type VerifyReport struct {
+	// Blocks is the number of blocks that were fetched and found to be
+	// valid.
+	Blocks int64
+
+	// Missing contains the references of blocks that could not be
+	// fetched.
+	Missing []Reference
+
+	// Corrupt contains the references of blocks that were fetched, but
+	// whose contents did not match their reference.
+	Corrupt []Reference
+
+	// Incomplete is set if some internal node could not be fetched or was
+	// corrupt, which means that the blocks underneath it could not be
+	// checked.
+	Incomplete bool
+}
+
+// OK returns true if every block in the tree was present and valid.
+func (r *VerifyReport) OK() bool {
+	return len(r.Missing) == 0 && len(r.Corrupt) == 0 && !r.Incomplete
+}
+
+// Verify fetches every block, both internal and leaf nodes, of the ERIS tree
+// rooted at rc and checks that it is present and valid. Unlike the decoders,
+// it does not stop at the first missing or corrupt block, but instead records
+// it in the returned report and continues with the rest of the tree.
+//
+// A block is considered corrupt if fetch returns a block with the wrong size
+// or a hash that does not match its reference; any other error from fetch is
+// treated as the block being missing, except for errors from ctx, which stop
+// verification. Errors that indicate that the tree itself is malformed, such
+// as an invalid root key, are also returned directly.
+func Verify(ctx context.Context, fetch FetchFunc, rc ReadCapability) (VerifyReport, error) {
+	var report VerifyReport
+	buf := make([]byte, rc.BlockSize)
+	err := walkTree(rc, func(ref ReferenceKeyPair, level int, _ bool) ([]byte, error) {
+		return report.check(ctx, fetch, buf, ref, level, rc.BlockSize)
+	})
+	return report, err
+}
+
+// check fetches and decrypts a single node, recording it in the report; it
+// returns a nil node if the block was missing or corrupt.
+func (r *VerifyReport) check(ctx context.Context, fetch FetchFunc, buf []byte, ref ReferenceKeyPair, level, blockSize int) ([]byte, error) {
+	node, err := dereferenceNode(ctx, fetch, buf, ref, level, blockSize)
+	switch {
+	case err == nil:
+		r.Blocks++
+		return node, nil
+	case ctx.Err() != nil:
+		return nil, ctx.Err()
+	case errors.Is(err, ErrInvalidBlock), errors.Is(err, ErrInvalidBlockSize):
+		r.Corrupt = append(r.Corrupt, ref.Reference)
+	default:
+		r.Missing = append(r.Missing, ref.Reference)
+	}
+	if level > 0 {
+		r.Incomplete = true
+	}
+	return nil, nil
+}
@@ -0,0 +1,103 @@
+package eris
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+)
+
+// SampleReport contains the results of verifying part of an ERIS tree with
+// VerifySample.
+type SampleReport struct {
+	VerifyReport
+
+	// Leaves is the number of leaves found in the tree, and Sampled is the
+	// number of them that were selected for verification.
+	Leaves, Sampled int64
+
+	// Truncated is set if the context was done before every selected
+	// block was checked.
+	Truncated bool
+}
+
+// OK returns true if every selected block was checked, and was present and
+// valid. Unlike VerifyReport.OK, it returns false if the report is truncated.
+func (r *SampleReport) OK() bool {
+	return r.VerifyReport.OK() && !r.Truncated
+}
+
+// VerifySample is like Verify, but only checks a pseudo-random sample of
+// about fraction of the leaves of the tree rooted at rc, which must be greater
+// than 0 and at most 1. It is meant for routine checks of content that is too
+// large to verify in full every time; over repeated runs with different
+// seeds, every leaf is eventually checked.
+//
+// Every internal node is fetched and checked, since they are needed to find
+// the leaves. The leaves to check, and the order to check them in, are chosen
+// by a random number generator seeded with seed, so the same seed always
+// checks the same leaves of the same tree.
+//
+// To limit the time spent, pass a ctx with a deadline. If ctx is done before
+// verification is complete, VerifySample stops and returns the report so far
+// with Truncated set, rather than an error. Since the selected leaves are
+// checked in random order, the leaves checked before the deadline are still a
+// random sample of the tree, as long as the internal nodes could all be
+// fetched in time.
+func VerifySample(ctx context.Context, fetch FetchFunc, rc ReadCapability, fraction float64, seed int64) (SampleReport, error) {
+	if !(fraction > 0 && fraction <= 1) {
+		return SampleReport{}, fmt.Errorf("sample fraction must be in (0, 1]: %v", fraction)
+	}
+
+	var report SampleReport
+	rng := rand.New(rand.NewSource(seed))
+	buf := make([]byte, rc.BlockSize)
+
+	// truncated reports whether err is from ctx being done, and records
+	// it in the report.
+	truncated := func(err error) bool {
+		if err != nil && err == ctx.Err() {
+			report.Truncated = true
+			return true
+		}
+		return false
+	}
+
+	// check checks a node, stopping as soon as ctx is done even if fetch
+	// doesn't look at it.
+	check := func(ref ReferenceKeyPair, level int) ([]byte, error) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return report.check(ctx, fetch, buf, ref, level, rc.BlockSize)
+	}
+
+	var selected []ReferenceKeyPair
+	err := walkTree(rc, func(ref ReferenceKeyPair, level int, _ bool) ([]byte, error) {
+		if level > 0 {
+			return check(ref, level)
+		}
+		report.Leaves++
+		if rng.Float64() < fraction {
+			report.Sampled++
+			selected = append(selected, ref)
+		}
+		return nil, nil
+	})
+	if truncated(err) {
+		return report, nil
+	} else if err != nil {
+		return report, err
+	}
+
+	rng.Shuffle(len(selected), func(i, j int) {
+		selected[i], selected[j] = selected[j], selected[i]
+	})
+	for _, ref := range selected {
+		if _, err := check(ref, 0); truncated(err) {
+			return report, nil
+		} else if err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
@@ -0,0 +1,98 @@
+package eris
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestVerifySample(t *testing.T) {
+	ctx := context.Background()
+	content := randomContent(300*1024 + 1)
+	rc, blocks := encodeToMap(t, content, 1024)
+
+	// With a fraction of 1, every block is checked.
+	report, err := VerifySample(ctx, mapFetch(blocks, nil), rc, 1, 1)
+	if err != nil {
+		t.Fatalf("VerifySample: %v", err)
+	}
+	if !report.OK() || report.Truncated || report.Blocks != int64(len(blocks)) {
+		t.Fatalf("report = %+v, want OK with %d blocks", report, len(blocks))
+	}
+	if report.Leaves != 301 || report.Sampled != 301 {
+		t.Errorf("Leaves, Sampled = %d, %d; want 301, 301", report.Leaves, report.Sampled)
+	}
+
+	// Track which leaves are fetched for a smaller sample.
+	var leaves []Reference
+	walkLeaves(ctx, mapFetch(blocks, nil), rc, func(job leafJob) error {
+		leaves = append(leaves, job.ref.Reference)
+		return nil
+	})
+	isLeaf := make(map[Reference]bool)
+	for _, ref := range leaves {
+		isLeaf[ref] = true
+	}
+	sampled := func(seed int64) ([]Reference, SampleReport) {
+		var fetched []Reference
+		fetch := func(ctx context.Context, ref Reference, buf []byte) ([]byte, error) {
+			if isLeaf[ref] {
+				fetched = append(fetched, ref)
+			}
+			return mapFetch(blocks, nil)(ctx, ref, buf)
+		}
+		report, err := VerifySample(ctx, fetch, rc, 0.1, seed)
+		if err != nil {
+			t.Fatalf("VerifySample: %v", err)
+		}
+		return fetched, report
+	}
+
+	fetched, report := sampled(1)
+	if !report.OK() || report.Sampled != int64(len(fetched)) {
+		t.Errorf("report = %+v, want OK with %d leaves sampled", report, len(fetched))
+	}
+	if n := len(fetched); n < 10 || n > 60 {
+		t.Errorf("sampled %d of %d leaves, want about 30", n, len(leaves))
+	}
+	if again, _ := sampled(1); !reflect.DeepEqual(again, fetched) {
+		t.Errorf("same seed sampled different leaves")
+	}
+	if other, _ := sampled(2); reflect.DeepEqual(other, fetched) {
+		t.Errorf("different seeds sampled the same leaves")
+	}
+
+	// A missing leaf is found if it is sampled.
+	delete(blocks, fetched[0])
+	report, err = VerifySample(ctx, mapFetch(blocks, nil), rc, 0.1, 1)
+	if err != nil {
+		t.Fatalf("VerifySample: %v", err)
+	}
+	if len(report.Missing) != 1 || report.Missing[0] != fetched[0] {
+		t.Errorf("Missing = %v, want [%v]", report.Missing, fetched[0])
+	}
+}
+
+func TestVerifySample_Truncated(t *testing.T) {
+	content := randomContent(300*1024 + 1)
+	rc, blocks := encodeToMap(t, content, 1024)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	report, err := VerifySample(ctx, mapFetch(blocks, nil), rc, 1, 1)
+	if err != nil {
+		t.Fatalf("VerifySample: %v", err)
+	}
+	if !report.Truncated || report.Blocks != 0 || report.OK() {
+		t.Errorf("report = %+v, want truncated and not OK with no blocks", report)
+	}
+}
+
+func TestVerifySample_InvalidFraction(t *testing.T) {
+	rc, blocks := encodeToMap(t, randomContent(100), 1024)
+	for _, fraction := range []float64{0, -1, 1.5} {
+		if _, err := VerifySample(context.Background(), mapFetch(blocks, nil), rc, fraction, 1); err == nil {
+			t.Errorf("VerifySample with fraction %v succeeded, want error", fraction)
+		}
+	}
+}
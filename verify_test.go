@@ -0,0 +1,47 @@
+package eris
+
+import (
+	"context"
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+	ctx := context.Background()
+	content := randomContent(300*1024 + 1)
+	rc, blocks := encodeToMap(t, content, 1024)
+
+	report, err := Verify(ctx, mapFetch(blocks, nil), rc)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if !report.OK() || report.Blocks != int64(len(blocks)) {
+		t.Fatalf("report = %+v, want OK with %d blocks", report, len(blocks))
+	}
+
+	// Remove a leaf and corrupt another leaf, and verify that we find
+	// both problems.
+	var leaves []Reference
+	walkLeaves(ctx, mapFetch(blocks, nil), rc, func(job leafJob) error {
+		leaves = append(leaves, job.ref.Reference)
+		return nil
+	})
+
+	delete(blocks, leaves[10])
+	corrupt := append([]byte(nil), blocks[leaves[20]]...)
+	corrupt[0] ^= 0xff
+	blocks[leaves[20]] = corrupt
+
+	report, err = Verify(ctx, mapFetch(blocks, nil), rc)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if report.OK() || report.Incomplete {
+		t.Errorf("report = %+v, want not OK and complete", report)
+	}
+	if len(report.Missing) != 1 || report.Missing[0] != leaves[10] {
+		t.Errorf("Missing = %v, want [%v]", report.Missing, leaves[10])
+	}
+	if len(report.Corrupt) != 1 || report.Corrupt[0] != leaves[20] {
+		t.Errorf("Corrupt = %v, want [%v]", report.Corrupt, leaves[20])
+	}
+}
@@ -0,0 +1,111 @@
+package eris
+
+import (
+	"context"
+	"errors"
+)
+
+// SkipSubtree is used as a return value from a WalkFunc to indicate that the
+// children of the node passed to the function should not be visited. It is
+// not returned as an error by Walk.
+var SkipSubtree = errors.New("skip this subtree")
+
+// WalkFunc is the type of the function called by Walk for every node in an
+// ERIS tree. The level is 0 for leaf nodes and 1 or more for internal nodes.
+//
+// If the function returns SkipSubtree for an internal node, Walk does not
+// fetch the node or visit any of its children; returning SkipSubtree for a
+// leaf has no effect. Any other error stops the walk and is returned by Walk.
+type WalkFunc func(ref ReferenceKeyPair, level int) error
+
+// Walk traverses the ERIS tree rooted at rc in depth-first, left-to-right
+// order, calling fn with the reference-key pair and level of every node in
+// the tree, starting with the root. Each node is visited before its
+// children. Only internal nodes are fetched; leaves are never fetched.
+//
+// A block that appears multiple times in the tree is visited each time it
+// appears.
+func Walk(ctx context.Context, fetch FetchFunc, rc ReadCapability, fn WalkFunc) error {
+	return walkReferences(ctx, fetch, rc, fn)
+}
+
+// walkReferences traverses the ERIS tree rooted at rc in depth-first,
+// left-to-right order, calling fn with the reference-key pair and level of
+// every node in the tree (including the root). Only internal nodes are
+// fetched; leaves are never fetched.
+//
+// If fn returns SkipSubtree, the children of the node are not visited; any
+// other error stops the walk and is returned.
+func walkReferences(ctx context.Context, fetch FetchFunc, rc ReadCapability, fn func(ref ReferenceKeyPair, level int) error) error {
+	buf := make([]byte, rc.BlockSize)
+	return walkTree(rc, func(ref ReferenceKeyPair, level int, _ bool) ([]byte, error) {
+		if err := fn(ref, level); err == SkipSubtree {
+			return nil, nil
+		} else if err != nil {
+			return nil, err
+		}
+		if level == 0 {
+			return nil, nil
+		}
+		return dereferenceNode(ctx, fetch, buf, ref, level, rc.BlockSize)
+	})
+}
+
+// visitFunc is called by walkTree for every node in an ERIS tree. The final
+// flag is set for the last node at each level, which is on the right-most path
+// of the tree, and covers the end of the content.
+//
+// For an internal node, the function returns the decrypted node, whose
+// children are visited next; if it returns a nil node, they are skipped. The
+// node only needs to remain valid until the function is next called. The node
+// returned for a leaf is ignored. An error stops the walk and is returned by
+// walkTree.
+type visitFunc func(ref ReferenceKeyPair, level int, final bool) ([]byte, error)
+
+// walkTree traverses the ERIS tree rooted at rc in depth-first, left-to-right
+// order, calling visit for every node, starting with the root. It doesn't
+// fetch any nodes itself, so that the caller can decide which nodes to fetch
+// and what to do if one can't be fetched. The root node returned by visit is
+// checked against the key in rc.
+func walkTree(rc ReadCapability, visit visitFunc) error {
+	root, err := visit(rc.Root, rc.Level, true)
+	if err != nil || root == nil || rc.Level == 0 {
+		return err
+	}
+	if !verifyNodeKey(root, rc.Root.Key) {
+		return ErrInvalidKey
+	}
+
+	var stack []decodeNode
+	push := func(node []byte, level int) error {
+		refs, err := decodeInternalNode(node, rc.BlockSize)
+		if err != nil {
+			return err
+		}
+		for i := len(refs) - 1; i >= 0; i-- {
+			stack = append(stack, decodeNode{ref: refs[i], level: level})
+		}
+		return nil
+	}
+	if err := push(root, rc.Level-1); err != nil {
+		return err
+	}
+
+	for len(stack) > 0 {
+		lastIdx := len(stack) - 1
+		curr := stack[lastIdx]
+		stack = stack[:lastIdx]
+
+		node, err := visit(curr.ref, curr.level, len(stack) == 0)
+		if err != nil {
+			return err
+		}
+		if node == nil || curr.level == 0 {
+			continue
+		}
+		if err := push(node, curr.level-1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package eris
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestWalk(t *testing.T) {
+	ctx := context.Background()
+	rc, blocks := encodeToMap(t, randomContent(300*1024), 1024)
+
+	// Count the nodes at each level, and check that only internal nodes
+	// are fetched.
+	var calls int
+	counts := make(map[int]int)
+	err := Walk(ctx, mapFetch(blocks, &calls), rc, func(_ ReferenceKeyPair, level int) error {
+		counts[level]++
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counts[0] != 301 || counts[1] != 19 || counts[2] != 2 || counts[3] != 1 {
+		t.Errorf("unexpected node counts per level: %v", counts)
+	}
+	if calls != 1+2+19 {
+		t.Errorf("fetched %d blocks, want %d", calls, 1+2+19)
+	}
+
+	// Skipping every level 2 node visits nothing underneath them, and
+	// doesn't fetch them.
+	calls = 0
+	clear(counts)
+	err = Walk(ctx, mapFetch(blocks, &calls), rc, func(_ ReferenceKeyPair, level int) error {
+		counts[level]++
+		if level == 2 {
+			return SkipSubtree
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(counts) != 2 || counts[2] != 2 || calls != 1 {
+		t.Errorf("after skipping: counts %v, %d fetches", counts, calls)
+	}
+
+	// Skipping the root visits nothing else.
+	calls = 0
+	err = Walk(ctx, mapFetch(blocks, &calls), rc, func(ReferenceKeyPair, int) error {
+		return SkipSubtree
+	})
+	if err != nil || calls != 0 {
+		t.Errorf("skipping root: err = %v, %d fetches", err, calls)
+	}
+
+	// Other errors are returned.
+	errStop := errors.New("stop")
+	err = Walk(ctx, mapFetch(blocks, nil), rc, func(_ ReferenceKeyPair, level int) error {
+		if level == 0 {
+			return errStop
+		}
+		return nil
+	})
+	if !errors.Is(err, errStop) {
+		t.Errorf("expected errStop, got %v", err)
+	}
+}